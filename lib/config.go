@@ -2,6 +2,7 @@ package lib
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/dbsteward/dbsteward/lib/ir"
 )
@@ -37,6 +38,21 @@ type Config struct {
 	FileOutputPrefix               string
 	IgnoreOldNames                 bool
 	AlwaysRecreateViews            bool
+	RefreshMaterializedViews       bool
+	OutputFormat                   string
+	GenerateDowngrade              bool
+	SafeMode                       bool
+	AllowDrop                      []string
+	Lint                           bool
+	LintFailOn                     LintSeverity
+	OnlineSafe                     bool
+	LockTimeout                    string
+	StatementTimeout               string
+	IdleInTransactionTimeout       string
+	LockRetries                    uint
+	LockRetryDelay                 time.Duration
+	Idempotent                     bool
+	TargetVersion                  string
 	OldDatabase                    *ir.Definition
 	NewDatabase                    *ir.Definition
 }
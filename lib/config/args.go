@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/dbsteward/dbsteward/lib/ir"
 )
 
@@ -28,6 +30,7 @@ type Args struct {
 
 	// Generating SQL DDL/DML/DCL to upgrade old to new
 	OldXmlFiles            []string `arg:"--oldxml"`
+	OldDb                  bool     `arg:"--olddb" help:"use the live database given by --dbhost, --dbname etc as the old definition instead of --oldxml"`
 	NewXmlFiles            []string `arg:"--newxml"`
 	OnlySchemaSql          bool
 	OnlyDataSql            bool
@@ -37,10 +40,28 @@ type Args struct {
 	IgnoreOldNames         bool
 	IgnoreCustomRoles      bool
 	IgnorePrimaryKeyErrors bool
+	RefreshMatViews        bool          `arg:"--refreshmatviews" help:"refresh materialized views left in place by an upgrade at the end of stage 4"`
+	Apply                  bool          `arg:"--apply" help:"execute the upgrade against the database given by --dbhost etc instead of writing stage files"`
+	DryRun                 bool          `arg:"--dry-run" help:"with --apply, run every stage in a single transaction and roll it back"`
+	Safe                   bool          `arg:"--safe" help:"fail instead of generating drops of tables, columns etc or data deletes, unless allowed by --allowdrop or <allowDrop>"`
+	AllowDrop              []string      `arg:"--allowdrop" help:"with --safe, objects which may be dropped, as schema.table[.column]; * matches anything"`
+	Lint                   bool          `arg:"--lint" help:"check the upgrade for statements which rewrite or lock tables with existing data for a long time"`
+	LintFailOn             string        `arg:"--lintfailon" default:"error" help:"with --lint, exit with status 3 on findings of this severity or worse: info, warning, error or none"`
+	Online                 bool          `arg:"--online" help:"add constraints NOT VALID in stage 1 and validate them in stage 3, build indexes CONCURRENTLY, and when targeting postgres 12+ SET NOT NULL from a validated check, on tables with existing data"`
+	Downgrade              bool          `arg:"--downgrade" help:"also write _downgrade_stage files reverting newxml back to oldxml, with irreversible steps marked"`
+	LockTimeout            string        `arg:"--locktimeout" help:"SET LOCAL lock_timeout, e.g. 5s, at the top of the build and of every upgrade stage, overriding <database lockTimeout>"`
+	StatementTimeout       string        `arg:"--statementtimeout" help:"SET LOCAL statement_timeout at the top of the build and of every upgrade stage, overriding <database statementTimeout>"`
+	IdleTimeout            string        `arg:"--idletimeout" help:"SET LOCAL idle_in_transaction_session_timeout at the top of the build and of every upgrade stage, overriding <database idleInTransactionSessionTimeout>"`
+	LockRetries            uint          `arg:"--lockretries" help:"with --apply, retry a stage this many times when it fails to acquire a lock within the lock timeout"`
+	LockRetryDelay         time.Duration `arg:"--lockretrydelay" default:"5s" help:"with --lockretries, how long to wait before retrying"`
+	Idempotent             bool          `arg:"--idempotent" help:"guard every creation in the build with IF NOT EXISTS, OR REPLACE or a catalog check, so a partially applied build can be run again"`
+	TargetVersion          string        `arg:"--target-version" help:"postgres version the generated sql may rely on, e.g. 15 or 9.6, overriding <database targetVersion>; without one, only long-standing syntax is generated"`
 
 	// Database definition extraction utilities
 	DbSchemaDump bool
+	Drift        bool `arg:"--drift" help:"compare the --xml definition with the database given by --dbhost etc, exiting with status 2 on drift"`
 	DbDataDiff   []string
+	Db           string `arg:"--db" help:"connection URI (postgres://user@host/name?sslmode=require) or DSN (host=... dbname=...). PG* environment variables, ~/.pgpass and pg_service.conf are honored"`
 	DbHost       string
 	DbPort       uint
 	DbName       string
@@ -58,9 +79,10 @@ type Args struct {
 	OutputFilePrefix string
 
 	// SQL diffing
-	OldSql     []string
-	NewSql     []string
-	OutputFile string
+	OldSql       []string
+	NewSql       []string
+	OutputFile   string
+	OutputFormat string `arg:"--output-format" default:"text" help:"format of drift reports, and whether build and diff also write a migration plan: text or json"`
 
 	// Slony utils
 	RequireSlonyId    bool
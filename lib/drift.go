@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+type DriftStatus string
+
+const (
+	// DriftMissing objects are in the definition but not in the database
+	DriftMissing DriftStatus = "missing"
+	// DriftExtra objects are in the database but not in the definition
+	DriftExtra DriftStatus = "extra"
+	// DriftChanged objects are in both, but differ
+	DriftChanged DriftStatus = "changed"
+)
+
+// DriftItem is a single object that differs between a definition and a live database
+type DriftItem struct {
+	Kind       string      `json:"kind"`
+	Name       string      `json:"name"`
+	Status     DriftStatus `json:"status"`
+	Statements []string    `json:"statements,omitempty"`
+}
+
+// DriftReport is the result of comparing a definition against a live database
+type DriftReport struct {
+	Items []DriftItem `json:"items"`
+}
+
+func (r *DriftReport) HasDrift() bool {
+	return r != nil && len(r.Items) > 0
+}
+
+func (r *DriftReport) JSON() ([]byte, error) {
+	r.Items = util.NonNil(r.Items)
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *DriftReport) Text() string {
+	if !r.HasDrift() {
+		return "No drift detected\n"
+	}
+	b := strings.Builder{}
+	for _, item := range r.Items {
+		b.WriteString(fmt.Sprintf("%-8s %-10s %s\n", item.Status, item.Kind, item.Name))
+		for _, stmt := range item.Statements {
+			b.WriteString("    " + strings.ReplaceAll(stmt, "\n", "\n    ") + "\n")
+		}
+	}
+	return b.String()
+}
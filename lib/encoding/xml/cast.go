@@ -0,0 +1,60 @@
+package xml
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+type Cast struct {
+	Source   string `xml:"source,attr"`
+	Target   string `xml:"target,attr"`
+	Method   string `xml:"method,attr,omitempty"`
+	Function string `xml:"function,attr,omitempty"`
+	Context  string `xml:"context,attr,omitempty"`
+}
+
+func CastsFromIR(l *slog.Logger, recs []*ir.Cast) ([]*Cast, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*Cast
+	for _, rec := range recs {
+		if rec != nil {
+			cast := &Cast{
+				Source:   rec.Source,
+				Target:   rec.Target,
+				Function: rec.Function,
+			}
+			// function and explicit are the defaults
+			if !rec.Method.Equals(ir.CastMethodFunction) {
+				cast.Method = strings.ToLower(string(rec.Method))
+			}
+			if !rec.Context.Equals(ir.CastContextExplicit) {
+				cast.Context = strings.ToLower(string(rec.Context))
+			}
+			rv = append(rv, cast)
+		}
+	}
+	return rv, nil
+}
+
+func (self *Cast) ToIR() (*ir.Cast, error) {
+	rv := ir.Cast{
+		Source:   self.Source,
+		Target:   self.Target,
+		Function: self.Function,
+	}
+	var err error
+	rv.Method, err = ir.NewCastMethod(self.Method)
+	if err != nil {
+		return nil, fmt.Errorf("cast from '%s' to '%s' invalid: %w", self.Source, self.Target, err)
+	}
+	rv.Context, err = ir.NewCastContext(self.Context)
+	if err != nil {
+		return nil, fmt.Errorf("cast from '%s' to '%s' invalid: %w", self.Source, self.Target, err)
+	}
+	return &rv, nil
+}
@@ -17,6 +17,14 @@ type Column struct {
 	Unique           bool   `xml:"unique,attr,omitempty"`
 	Check            string `xml:"check,attr,omitempty"`
 	SerialStart      *int   `xml:"serialStart,attr,omitempty"`
+	Identity         string `xml:"identity,attr,omitempty"`
+	IdentityStart    *int   `xml:"identityStart,attr,omitempty"`
+	IdentityIncr     *int   `xml:"identityIncrement,attr,omitempty"`
+	IdentityMin      *int   `xml:"identityMinValue,attr,omitempty"`
+	IdentityMax      *int   `xml:"identityMaxValue,attr,omitempty"`
+	IdentityCache    *int   `xml:"identityCache,attr,omitempty"`
+	IdentityCycle    bool   `xml:"identityCycle,attr,omitempty"`
+	GeneratedAs      string `xml:"generatedAs,attr,omitempty"`
 	OldColumnName    string `xml:"oldColumnName,attr,omitempty"`
 	ConvertUsing     string `xml:"convertUsing,attr,omitempty"`
 	ForeignSchema    string `xml:"foreignSchema,attr,omitempty"`
@@ -86,6 +94,7 @@ func ColumnFromIR(l *slog.Logger, col *ir.Column) (*Column, error) {
 		ForeignOnUpdate:  string(col.ForeignOnUpdate),
 		ForeignOnDelete:  string(col.ForeignOnDelete),
 		Statistics:       col.Statistics,
+		GeneratedAs:      col.GeneratedAs,
 		BeforeAddStage1:  col.BeforeAddStage1,
 		AfterAddStage1:   col.AfterAddStage1,
 		BeforeAddStage2:  col.BeforeAddStage2,
@@ -94,6 +103,15 @@ func ColumnFromIR(l *slog.Logger, col *ir.Column) (*Column, error) {
 		AfterAddStage3:   col.AfterAddStage3,
 		// Ignoring depricated fields for now
 	}
+	if col.Identity != nil {
+		rv.Identity = identityAttr(col.Identity.Generation)
+		rv.IdentityStart = col.Identity.Start
+		rv.IdentityIncr = col.Identity.Increment
+		rv.IdentityMin = col.Identity.Min
+		rv.IdentityMax = col.Identity.Max
+		rv.IdentityCache = col.Identity.Cache
+		rv.IdentityCycle = col.Identity.Cycle
+	}
 	return &rv, nil
 }
 
@@ -136,9 +154,27 @@ func (col *Column) ToIR() (*ir.Column, error) {
 		BeforeAddStage3:  col.BeforeAddStage3,
 		AfterAddStage3:   col.AfterAddStage3,
 		SerialStart:      col.SerialStart,
+		GeneratedAs:      col.GeneratedAs,
 		Statistics:       col.Statistics,
 	}
 	var err error
+	if col.Identity != "" {
+		generation, err := ir.NewIdentityGeneration(col.Identity)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' invalid: %w", col.Name, err)
+		}
+		rv.Identity = &ir.ColumnIdentity{
+			Generation: generation,
+			Start:      col.IdentityStart,
+			Increment:  col.IdentityIncr,
+			Min:        col.IdentityMin,
+			Max:        col.IdentityMax,
+			Cache:      col.IdentityCache,
+			Cycle:      col.IdentityCycle,
+		}
+		// postgres makes identity columns NOT NULL whether or not they're declared so
+		rv.Nullable = false
+	}
 	rv.ForeignOnUpdate, err = ir.NewForeignKeyAction(col.ForeignOnUpdate)
 	if err != nil {
 		return nil, fmt.Errorf("column '%s' invalid: %w", col.Name, err)
@@ -149,3 +185,11 @@ func (col *Column) ToIR() (*ir.Column, error) {
 	}
 	return &rv, nil
 }
+
+// identityAttr is the identity attribute value for an identity generation
+func identityAttr(generation ir.IdentityGeneration) string {
+	if generation == ir.IdentityGenerationByDefault {
+		return "byDefault"
+	}
+	return "always"
+}
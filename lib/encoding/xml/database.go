@@ -8,9 +8,13 @@ import (
 )
 
 type Database struct {
-	SqlFormat    string          `xml:"sqlFormat"`
-	Roles        *RoleAssignment `xml:"role"`
-	ConfigParams []*ConfigParam  `xml:"configurationParameter"`
+	SqlFormat                string          `xml:"sqlFormat"`
+	Roles                    *RoleAssignment `xml:"role"`
+	ConfigParams             []*ConfigParam  `xml:"configurationParameter"`
+	LockTimeout              string          `xml:"lockTimeout,attr,omitempty"`
+	StatementTimeout         string          `xml:"statementTimeout,attr,omitempty"`
+	IdleInTransactionTimeout string          `xml:"idleInTransactionSessionTimeout,attr,omitempty"`
+	TargetVersion            string          `xml:"targetVersion,attr,omitempty"`
 
 	// slony
 }
@@ -72,6 +76,10 @@ func (db *Database) ToIR() (*ir.Database, error) {
 			ReadOnly:    db.Roles.ReadOnly,
 			CustomRoles: db.Roles.CustomRoles,
 		},
+		LockTimeout:              db.LockTimeout,
+		StatementTimeout:         db.StatementTimeout,
+		IdleInTransactionTimeout: db.IdleInTransactionTimeout,
+		TargetVersion:            db.TargetVersion,
 	}
 	var err error
 	rv.SqlFormat, err = ir.NewSqlFormat(db.SqlFormat)
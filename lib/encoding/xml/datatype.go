@@ -1,6 +1,7 @@
 package xml
 
 import (
+	"fmt"
 	"log/slog"
 
 	"github.com/dbsteward/dbsteward/lib/ir"
@@ -14,6 +15,8 @@ type DataType struct {
 	CompositeFields   []*DataTypeCompositeField   `xml:"typeCompositeElement"`
 	DomainType        *DataTypeDomainType         `xml:"domainType"`
 	DomainConstraints []*DataTypeDomainConstraint `xml:"domainConstraint"`
+	RangeType         *DataTypeRangeType          `xml:"rangeType"`
+	BaseType          *DataTypeBaseType           `xml:"baseType"`
 }
 
 func TypesFromIR(l *slog.Logger, types []*ir.TypeDef) ([]*DataType, error) {
@@ -43,12 +46,15 @@ func TypeFromIR(l *slog.Logger, t *ir.TypeDef) (*DataType, error) {
 		CompositeFields:   DataTypeCompositFieldsFromIR(l, t.CompositeFields),
 		DomainType:        DataTypeDomainTypeFromIR(l, t.DomainType),
 		DomainConstraints: DataTypeDomainConstraintsFromIR(l, t.DomainConstraints),
+		RangeType:         DataTypeRangeTypeFromIR(l, t.RangeType),
+		BaseType:          DataTypeBaseTypeFromIR(l, t.BaseType),
 	}
 	return &ndt, nil
 }
 
 type DataTypeEnumValue struct {
-	Value string `xml:"name,attr"`
+	Value   string `xml:"name,attr"`
+	OldName string `xml:"oldName,attr,omitempty"`
 }
 
 func DataTypeEnumValuesFromIR(l *slog.Logger, vals []ir.DataTypeEnumValue) []*DataTypeEnumValue {
@@ -59,7 +65,7 @@ func DataTypeEnumValuesFromIR(l *slog.Logger, vals []ir.DataTypeEnumValue) []*Da
 	for _, val := range vals {
 		rv = append(
 			rv,
-			&DataTypeEnumValue{Value: string(val)},
+			&DataTypeEnumValue{Value: val.Name, OldName: val.OldName},
 		)
 	}
 	return rv
@@ -126,6 +132,99 @@ func DataTypeDomainConstraintsFromIR(l *slog.Logger, cons []ir.DataTypeDomainCon
 	return rv
 }
 
+type DataTypeRangeType struct {
+	Subtype        string `xml:"subtype,attr"`
+	SubtypeOpClass string `xml:"subtypeOpClass,attr,omitempty"`
+	Collation      string `xml:"collation,attr,omitempty"`
+	Canonical      string `xml:"canonical,attr,omitempty"`
+	SubtypeDiff    string `xml:"subtypeDiff,attr,omitempty"`
+}
+
+func DataTypeRangeTypeFromIR(l *slog.Logger, r *ir.DataTypeRangeType) *DataTypeRangeType {
+	if r == nil {
+		return nil
+	}
+	return &DataTypeRangeType{
+		Subtype:        r.Subtype,
+		SubtypeOpClass: r.SubtypeOpClass,
+		Collation:      r.Collation,
+		Canonical:      r.Canonical,
+		SubtypeDiff:    r.SubtypeDiff,
+	}
+}
+
+type DataTypeBaseType struct {
+	Input          string `xml:"input,attr"`
+	Output         string `xml:"output,attr"`
+	Receive        string `xml:"receive,attr,omitempty"`
+	Send           string `xml:"send,attr,omitempty"`
+	InternalLength string `xml:"internalLength,attr,omitempty"`
+	PassedByValue  bool   `xml:"passedByValue,attr,omitempty"`
+	Alignment      string `xml:"alignment,attr,omitempty"`
+	Storage        string `xml:"storage,attr,omitempty"`
+}
+
+func DataTypeBaseTypeFromIR(l *slog.Logger, b *ir.DataTypeBaseType) *DataTypeBaseType {
+	if b == nil {
+		return nil
+	}
+	return &DataTypeBaseType{
+		Input:          b.Input,
+		Output:         b.Output,
+		Receive:        b.Receive,
+		Send:           b.Send,
+		InternalLength: b.InternalLength,
+		PassedByValue:  b.PassedByValue,
+		Alignment:      b.Alignment,
+		Storage:        b.Storage,
+	}
+}
+
 func (dt *DataType) ToIR() (*ir.TypeDef, error) {
-	panic("todo")
+	kind, err := ir.NewTypeDefKind(dt.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("type '%s' invalid: %w", dt.Name, err)
+	}
+	rv := &ir.TypeDef{
+		Name: dt.Name,
+		Kind: kind,
+	}
+	for _, val := range dt.EnumValues {
+		rv.EnumValues = append(rv.EnumValues, ir.DataTypeEnumValue{Name: val.Value, OldName: val.OldName})
+	}
+	for _, field := range dt.CompositeFields {
+		rv.CompositeFields = append(rv.CompositeFields, ir.DataTypeCompositeField{Name: field.Name, Type: field.Type})
+	}
+	if dt.DomainType != nil {
+		rv.DomainType = &ir.DataTypeDomainType{
+			BaseType: dt.DomainType.BaseType,
+			Default:  dt.DomainType.Default,
+			Nullable: dt.DomainType.Nullable,
+		}
+	}
+	for _, con := range dt.DomainConstraints {
+		rv.DomainConstraints = append(rv.DomainConstraints, ir.DataTypeDomainConstraint{Name: con.Name, Check: con.Check})
+	}
+	if dt.RangeType != nil {
+		rv.RangeType = &ir.DataTypeRangeType{
+			Subtype:        dt.RangeType.Subtype,
+			SubtypeOpClass: dt.RangeType.SubtypeOpClass,
+			Collation:      dt.RangeType.Collation,
+			Canonical:      dt.RangeType.Canonical,
+			SubtypeDiff:    dt.RangeType.SubtypeDiff,
+		}
+	}
+	if dt.BaseType != nil {
+		rv.BaseType = &ir.DataTypeBaseType{
+			Input:          dt.BaseType.Input,
+			Output:         dt.BaseType.Output,
+			Receive:        dt.BaseType.Receive,
+			Send:           dt.BaseType.Send,
+			InternalLength: dt.BaseType.InternalLength,
+			PassedByValue:  dt.BaseType.PassedByValue,
+			Alignment:      dt.BaseType.Alignment,
+			Storage:        dt.BaseType.Storage,
+		}
+	}
+	return rv, nil
 }
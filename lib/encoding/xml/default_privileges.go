@@ -0,0 +1,60 @@
+package xml
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+type DefaultPrivileges struct {
+	Role       string   `xml:"role,attr"`
+	Schema     string   `xml:"schema,attr,omitempty"`
+	ObjectType string   `xml:"objectType,attr"`
+	Grants     []*Grant `xml:"grant"`
+}
+
+func DefaultPrivilegesFromIR(l *slog.Logger, recs []*ir.DefaultPrivileges) ([]*DefaultPrivileges, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*DefaultPrivileges
+	for _, rec := range recs {
+		if rec != nil {
+			grants, err := GrantsFromIR(l, rec.Grants)
+			if err != nil {
+				return nil, err
+			}
+			rv = append(
+				rv,
+				&DefaultPrivileges{
+					Role:       rec.Role,
+					Schema:     rec.Schema,
+					ObjectType: string(rec.ObjectType),
+					Grants:     grants,
+				},
+			)
+		}
+	}
+	return rv, nil
+}
+
+func (self *DefaultPrivileges) ToIR() (*ir.DefaultPrivileges, error) {
+	rv := ir.DefaultPrivileges{
+		Role:   self.Role,
+		Schema: self.Schema,
+	}
+	var err error
+	rv.ObjectType, err = ir.NewDefaultPrivilegesObjectType(self.ObjectType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default privileges for role '%s': %w", self.Role, err)
+	}
+	for _, g := range self.Grants {
+		ng, err := g.ToIR()
+		if err != nil {
+			return nil, fmt.Errorf("default privileges for role '%s' invalid: %w", self.Role, err)
+		}
+		rv.Grants = append(rv.Grants, ng)
+	}
+	return &rv, nil
+}
@@ -10,13 +10,18 @@ import (
 )
 
 type Document struct {
-	XMLName        xml.Name          `xml:"dbsteward"`
-	IncludeFiles   []*IncludeFile    `xml:"includeFile"`
-	InlineAssembly []*InlineAssembly `xml:"inlineAssembly"`
-	Database       *Database         `xml:"database"`
-	Schemas        []*Schema         `xml:"schema"`
-	Languages      []*Language       `xml:"language"`
-	Sql            []*Sql            `xml:"sql"`
+	XMLName           xml.Name             `xml:"dbsteward"`
+	IncludeFiles      []*IncludeFile       `xml:"includeFile"`
+	InlineAssembly    []*InlineAssembly    `xml:"inlineAssembly"`
+	Database          *Database            `xml:"database"`
+	Schemas           []*Schema            `xml:"schema"`
+	Languages         []*Language          `xml:"language"`
+	Extensions        []*Extension         `xml:"extension"`
+	Roles             []*Role              `xml:"role"`
+	Casts             []*Cast              `xml:"cast"`
+	DefaultPrivileges []*DefaultPrivileges `xml:"defaultPrivileges"`
+	Sql               []*Sql               `xml:"sql"`
+	AllowDrops        []*AllowDrop         `xml:"allowDrop"`
 }
 
 type IncludeFile struct {
@@ -63,6 +68,28 @@ func InlineAssemblyFromIR(l *slog.Logger, recs []*ir.InlineAssembly) ([]*InlineA
 	return rv, nil
 }
 
+type AllowDrop struct {
+	Object string `xml:"object,attr"`
+}
+
+func AllowDropsFromIR(l *slog.Logger, recs []*ir.AllowDrop) ([]*AllowDrop, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*AllowDrop
+	for _, rec := range recs {
+		if rec != nil {
+			rv = append(
+				rv,
+				&AllowDrop{
+					Object: rec.Object,
+				},
+			)
+		}
+	}
+	return rv, nil
+}
+
 type Sql struct {
 	Author     string `xml:"author,attr"`
 	Ticket     string `xml:"ticket,attr"`
@@ -104,18 +131,48 @@ func (doc *Document) ToIR() (*ir.Definition, error) {
 		return nil, errors.Wrap(err, "could not process language tags")
 	}
 
+	extensions, err := util.MapErr(doc.Extensions, (*Extension).ToIR)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not process extension tags")
+	}
+
+	roles, err := util.MapErr(doc.Roles, (*Role).ToIR)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not process role tags")
+	}
+
+	casts, err := util.MapErr(doc.Casts, (*Cast).ToIR)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not process cast tags")
+	}
+
+	defaultPrivileges, err := util.MapErr(doc.DefaultPrivileges, (*DefaultPrivileges).ToIR)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not process defaultPrivileges tags")
+	}
+
 	sql, err := util.MapErr(doc.Sql, (*Sql).ToIR)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not process sql tags")
 	}
 
+	allowDrops, err := util.MapErr(doc.AllowDrops, (*AllowDrop).ToIR)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not process allowDrop tags")
+	}
+
 	return &ir.Definition{
-		IncludeFiles:   includeFiles,
-		InlineAssembly: inlineAssembly,
-		Database:       database,
-		Schemas:        schemas,
-		Languages:      languages,
-		Sql:            sql,
+		IncludeFiles:      includeFiles,
+		InlineAssembly:    inlineAssembly,
+		Database:          database,
+		Schemas:           schemas,
+		Languages:         languages,
+		Extensions:        extensions,
+		Roles:             roles,
+		Casts:             casts,
+		DefaultPrivileges: defaultPrivileges,
+		Sql:               sql,
+		AllowDrops:        allowDrops,
 	}, nil
 }
 
@@ -126,9 +183,13 @@ func FromIR(l *slog.Logger, def *ir.Definition) (*Document, error) {
 	defer l.Debug("complted conversion")
 	doc := Document{
 		Database: &Database{
-			SqlFormat:    string(def.Database.SqlFormat),
-			Roles:        RoleAssignmentFromIR(l, def.Database.Roles),
-			ConfigParams: ConfigParamsFromIR(l, def.Database.ConfigParams),
+			SqlFormat:                string(def.Database.SqlFormat),
+			Roles:                    RoleAssignmentFromIR(l, def.Database.Roles),
+			ConfigParams:             ConfigParamsFromIR(l, def.Database.ConfigParams),
+			LockTimeout:              def.Database.LockTimeout,
+			StatementTimeout:         def.Database.StatementTimeout,
+			IdleInTransactionTimeout: def.Database.IdleInTransactionTimeout,
+			TargetVersion:            def.Database.TargetVersion,
 		},
 	}
 	var err error
@@ -144,6 +205,26 @@ func FromIR(l *slog.Logger, def *ir.Definition) (*Document, error) {
 	if err != nil {
 		return nil, err
 	}
+	doc.AllowDrops, err = AllowDropsFromIR(l, def.AllowDrops)
+	if err != nil {
+		return nil, err
+	}
+	doc.Extensions, err = ExtensionsFromIR(l, def.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	doc.Roles, err = RolesFromIR(l, def.Roles)
+	if err != nil {
+		return nil, err
+	}
+	doc.DefaultPrivileges, err = DefaultPrivilegesFromIR(l, def.DefaultPrivileges)
+	if err != nil {
+		return nil, err
+	}
+	doc.Casts, err = CastsFromIR(l, def.Casts)
+	if err != nil {
+		return nil, err
+	}
 	// Languages
 	// SQL
 	return &doc, nil
@@ -158,6 +239,10 @@ func (doc *InlineAssembly) ToIR() (*ir.InlineAssembly, error) {
 	return &ir.InlineAssembly{Name: doc.Name}, nil
 }
 
+func (doc *AllowDrop) ToIR() (*ir.AllowDrop, error) {
+	return &ir.AllowDrop{Object: doc.Object}, nil
+}
+
 func (sql *Sql) ToIR() (*ir.Sql, error) {
 	panic("todo")
 }
@@ -0,0 +1,44 @@
+package xml
+
+import (
+	"log/slog"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+type Extension struct {
+	Name       string `xml:"name,attr"`
+	Version    string `xml:"version,attr,omitempty"`
+	Cascade    bool   `xml:"cascade,attr,omitempty"`
+	WithSchema string `xml:"withSchema,attr,omitempty"`
+}
+
+func ExtensionsFromIR(l *slog.Logger, recs []*ir.Extension) ([]*Extension, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*Extension
+	for _, rec := range recs {
+		if rec != nil {
+			rv = append(
+				rv,
+				&Extension{
+					Name:       rec.Name,
+					Version:    rec.Version,
+					Cascade:    rec.Cascade,
+					WithSchema: rec.Schema,
+				},
+			)
+		}
+	}
+	return rv, nil
+}
+
+func (self *Extension) ToIR() (*ir.Extension, error) {
+	return &ir.Extension{
+		Name:    self.Name,
+		Schema:  self.WithSchema,
+		Version: self.Version,
+		Cascade: self.Cascade,
+	}, nil
+}
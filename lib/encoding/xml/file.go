@@ -2,6 +2,8 @@ package xml
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
@@ -47,6 +49,16 @@ func FormatXml(l *slog.Logger, def *ir.Definition) (string, error) {
 	return buf.String(), nil
 }
 
+// DefinitionHash is the hex encoded sha256 of the definition as XML, which is the sha256sum of its composite file
+func DefinitionHash(l *slog.Logger, def *ir.Definition) (string, error) {
+	doc, err := FormatXml(l, def)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(doc))
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func XmlComposite(l *slog.Logger, files []string) (*ir.Definition, error) {
 	doc, _, err := XmlCompositeAddendums(l, files, 0)
 	if err != nil {
@@ -1,6 +1,11 @@
 package xml
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -277,3 +282,22 @@ func TestXmlParser_CompositeDoc_DuplicateFunctionValidation_SharedDefs(t *testin
 		assert.NotContains(t, strings.ToLower(err.Error()), "for sql format mssql10")
 	}
 }
+
+func TestDefinitionHash_MatchesCompositeFile(t *testing.T) {
+	def := &ir.Definition{
+		Database: &ir.Database{SqlFormat: ir.SqlFormatPgsql8, Roles: &ir.RoleAssignment{Owner: "app"}},
+		Schemas: []*ir.Schema{{
+			Name:   "public",
+			Tables: []*ir.Table{{Name: "t", PrimaryKey: []string{"id"}, Columns: []*ir.Column{{Name: "id", Type: "int"}}}},
+		}},
+	}
+	file := filepath.Join(t.TempDir(), "composite.xml")
+	assert.NoError(t, SaveDefinition(slog.Default(), file, def))
+	contents, err := os.ReadFile(file)
+	assert.NoError(t, err)
+
+	hash, err := DefinitionHash(slog.Default(), def)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(contents)
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+}
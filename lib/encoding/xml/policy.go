@@ -0,0 +1,56 @@
+package xml
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+type Policy struct {
+	Name        string        `xml:"name,attr"`
+	Command     string        `xml:"command,attr,omitempty"`
+	Roles       DelimitedList `xml:"roles,attr,omitempty"`
+	Using       string        `xml:"using,attr,omitempty"`
+	WithCheck   string        `xml:"withCheck,attr,omitempty"`
+	Restrictive bool          `xml:"restrictive,attr,omitempty"`
+}
+
+func PoliciesFromIR(l *slog.Logger, recs []*ir.Policy) ([]*Policy, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*Policy
+	for _, rec := range recs {
+		if rec != nil {
+			p := &Policy{
+				Name:        rec.Name,
+				Roles:       rec.Roles,
+				Using:       rec.Using,
+				WithCheck:   rec.WithCheck,
+				Restrictive: rec.Restrictive,
+			}
+			if !rec.Command.Equals(ir.PolicyCommandAll) {
+				p.Command = string(rec.Command)
+			}
+			rv = append(rv, p)
+		}
+	}
+	return rv, nil
+}
+
+func (p *Policy) ToIR() (*ir.Policy, error) {
+	rv := ir.Policy{
+		Name:        p.Name,
+		Roles:       p.Roles,
+		Using:       p.Using,
+		WithCheck:   p.WithCheck,
+		Restrictive: p.Restrictive,
+	}
+	var err error
+	rv.Command, err = ir.NewPolicyCommand(p.Command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy '%s': %w", p.Name, err)
+	}
+	return &rv, nil
+}
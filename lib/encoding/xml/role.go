@@ -0,0 +1,71 @@
+package xml
+
+import (
+	"encoding/xml"
+	"log/slog"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+// Role is a role the definition manages. Not to be confused with the RoleAssignment,
+// which shares its element name but lives under <database>
+type Role struct {
+	Name            string        `xml:"name,attr"`
+	Login           bool          `xml:"login,attr,omitempty"`
+	Inherit         bool          `xml:"inherit,attr"`
+	CreateDB        bool          `xml:"createdb,attr,omitempty"`
+	ConnectionLimit *int          `xml:"connectionLimit,attr,omitempty"`
+	MemberOf        DelimitedList `xml:"memberOf,attr,omitempty"`
+	PasswordEnv     string        `xml:"passwordEnv,attr,omitempty"`
+}
+
+func RolesFromIR(l *slog.Logger, recs []*ir.Role) ([]*Role, error) {
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	var rv []*Role
+	for _, rec := range recs {
+		if rec != nil {
+			rv = append(
+				rv,
+				&Role{
+					Name:            rec.Name,
+					Login:           rec.Login,
+					Inherit:         rec.Inherit,
+					CreateDB:        rec.CreateDB,
+					ConnectionLimit: rec.ConnectionLimit,
+					MemberOf:        rec.MemberOf,
+					PasswordEnv:     rec.PasswordEnv,
+				},
+			)
+		}
+	}
+	return rv, nil
+}
+
+func (self *Role) ToIR() (*ir.Role, error) {
+	return &ir.Role{
+		Name:            self.Name,
+		Login:           self.Login,
+		Inherit:         self.Inherit,
+		CreateDB:        self.CreateDB,
+		ConnectionLimit: self.ConnectionLimit,
+		MemberOf:        self.MemberOf,
+		PasswordEnv:     self.PasswordEnv,
+	}, nil
+}
+
+// Implement some custom unmarshalling behavior
+func (self *Role) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	type roleAlias Role // prevents recursion while decoding, as type aliases have no methods
+	// set defaults
+	ra := roleAlias{
+		Inherit: true, // as in CREATE ROLE
+	}
+	err := decoder.DecodeElement(&ra, &start)
+	if err != nil {
+		return err
+	}
+	*self = Role(ra)
+	return nil
+}
@@ -8,26 +8,29 @@ import (
 )
 
 type Table struct {
-	Name           string          `xml:"name,attr"`
-	Description    string          `xml:"description,attr,omitempty"`
-	Owner          string          `xml:"owner,attr,omitempty"`
-	PrimaryKey     DelimitedList   `xml:"primaryKey,attr,omitempty"`
-	PrimaryKeyName string          `xml:"primaryKeyName,attr,omitempty"`
-	ClusterIndex   string          `xml:"clusterIndex,attr,omitempty"`
-	InheritsTable  string          `xml:"inheritsTable,attr,omitempty"`
-	InheritsSchema string          `xml:"inheritsSchema,attr,omitempty"`
-	OldTableName   string          `xml:"oldTableName,attr,omitempty"`
-	OldSchemaName  string          `xml:"oldSchemaName,attr,omitempty"`
-	SlonySetId     *int            `xml:"slonySetId,attr,omitempty"`
-	SlonyId        *int            `xml:"slonyId,attr,omitempty"`
-	TableOptions   []*TableOption  `xml:"tableOption"`
-	Partitioning   *TablePartition `xml:"tablePartition"`
-	Columns        []*Column       `xml:"column"`
-	ForeignKeys    []*ForeignKey   `xml:"foreignKey"`
-	Indexes        []*Index        `xml:"index"`
-	Constraints    []*Constraint   `xml:"constraint"`
-	Grants         []*Grant        `xml:"grant"`
-	Rows           *DataRows       `xml:"rows"`
+	Name                  string          `xml:"name,attr"`
+	Description           string          `xml:"description,attr,omitempty"`
+	Owner                 string          `xml:"owner,attr,omitempty"`
+	PrimaryKey            DelimitedList   `xml:"primaryKey,attr,omitempty"`
+	PrimaryKeyName        string          `xml:"primaryKeyName,attr,omitempty"`
+	ClusterIndex          string          `xml:"clusterIndex,attr,omitempty"`
+	InheritsTable         string          `xml:"inheritsTable,attr,omitempty"`
+	InheritsSchema        string          `xml:"inheritsSchema,attr,omitempty"`
+	OldTableName          string          `xml:"oldTableName,attr,omitempty"`
+	OldSchemaName         string          `xml:"oldSchemaName,attr,omitempty"`
+	SlonySetId            *int            `xml:"slonySetId,attr,omitempty"`
+	SlonyId               *int            `xml:"slonyId,attr,omitempty"`
+	RowLevelSecurity      bool            `xml:"enableRowLevelSecurity,attr,omitempty"`
+	ForceRowLevelSecurity bool            `xml:"forceRowLevelSecurity,attr,omitempty"`
+	TableOptions          []*TableOption  `xml:"tableOption"`
+	Partitioning          *TablePartition `xml:"tablePartition"`
+	Columns               []*Column       `xml:"column"`
+	ForeignKeys           []*ForeignKey   `xml:"foreignKey"`
+	Indexes               []*Index        `xml:"index"`
+	Constraints           []*Constraint   `xml:"constraint"`
+	Grants                []*Grant        `xml:"grant"`
+	Policies              []*Policy       `xml:"policy"`
+	Rows                  *DataRows       `xml:"rows"`
 }
 
 type TableOption struct {
@@ -76,6 +79,9 @@ func TableFromIR(l *slog.Logger, irt *ir.Table) (*Table, error) {
 		// SlonySetId: Does not appear in the IR
 		// SlonyID: Does not appear in the IR
 		TableOptions: TableOptionsFromIR(l, irt.TableOptions),
+
+		RowLevelSecurity:      irt.RowLevelSecurity,
+		ForceRowLevelSecurity: irt.ForceRowLevelSecurity,
 	}
 	var err error
 	t.Partitioning, err = TablePartitionFromIR(l, irt.Partitioning)
@@ -102,6 +108,10 @@ func TableFromIR(l *slog.Logger, irt *ir.Table) (*Table, error) {
 	if err != nil {
 		return nil, err
 	}
+	t.Policies, err = PoliciesFromIR(l, irt.Policies)
+	if err != nil {
+		return nil, err
+	}
 	t.Rows, err = DataRowsFromIR(l, irt.Rows)
 	if err != nil {
 		return nil, err
@@ -153,6 +163,9 @@ func (table *Table) ToIR() (*ir.Table, error) {
 		InheritsSchema: table.InheritsSchema,
 		OldTableName:   table.OldTableName,
 		OldSchemaName:  table.OldSchemaName,
+
+		RowLevelSecurity:      table.RowLevelSecurity,
+		ForceRowLevelSecurity: table.ForceRowLevelSecurity,
 	}
 	for _, to := range table.TableOptions {
 		n, err := to.ToIR()
@@ -201,6 +214,13 @@ func (table *Table) ToIR() (*ir.Table, error) {
 		}
 		m.Grants = append(m.Grants, ng)
 	}
+	for _, p := range table.Policies {
+		np, err := p.ToIR()
+		if err != nil {
+			return nil, fmt.Errorf("table '%s' invalid: %w", table.Name, err)
+		}
+		m.Policies = append(m.Policies, np)
+	}
 	m.Rows, err = table.Rows.ToIR()
 	if err != nil {
 		return nil, fmt.Errorf("table '%s' invalid: %w", table.Name, err)
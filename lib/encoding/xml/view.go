@@ -13,8 +13,10 @@ type View struct {
 	Owner          string        `xml:"owner,attr,omitempty"`
 	DependsOnViews DelimitedList `xml:"dependsOnViews,attr,omitempty"`
 	SlonySetId     *int          `xml:"slonySetId,attr,omitempty"`
+	Materialized   bool          `xml:"materialized,attr,omitempty"`
 	Grants         []*Grant      `xml:"grant"`
 	Queries        []*ViewQuery  `xml:"viewQuery"`
+	Indexes        []*Index      `xml:"index"`
 }
 
 func ViewsFromIR(l *slog.Logger, views []*ir.View) ([]*View, error) {
@@ -30,6 +32,7 @@ func ViewsFromIR(l *slog.Logger, views []*ir.View) ([]*View, error) {
 				Description:    view.Description,
 				Owner:          view.Owner,
 				DependsOnViews: view.DependsOnViews,
+				Materialized:   view.Materialized,
 				Queries:        ViewQueriesFromIR(ll, view.Queries),
 			}
 			var err error
@@ -37,6 +40,10 @@ func ViewsFromIR(l *slog.Logger, views []*ir.View) ([]*View, error) {
 			if err != nil {
 				return nil, err
 			}
+			nv.Indexes, err = IndexesFromIR(ll, view.Indexes)
+			if err != nil {
+				return nil, err
+			}
 			rv = append(rv, &nv)
 		}
 	}
@@ -91,6 +98,7 @@ func (v *View) ToIR() (*ir.View, error) {
 		Description:    v.Description,
 		Owner:          v.Owner,
 		DependsOnViews: v.DependsOnViews,
+		Materialized:   v.Materialized,
 	}
 	for _, g := range v.Grants {
 		ng, err := g.ToIR()
@@ -106,5 +114,12 @@ func (v *View) ToIR() (*ir.View, error) {
 		}
 		rv.Queries = append(rv.Queries, nq)
 	}
+	for _, idx := range v.Indexes {
+		ni, err := idx.ToIR()
+		if err != nil {
+			return nil, fmt.Errorf("invalid view '%s': %w", v.Name, err)
+		}
+		rv.Indexes = append(rv.Indexes, ni)
+	}
 	return &rv, nil
 }
@@ -14,9 +14,11 @@ type Operations interface {
 		oldOutputPrefix, oldCompositeFile string, oldDbDoc *ir.Definition, oldFiles []string,
 		newOutputPrefix, newCompositeFile string, newDbDoc *ir.Definition, newFiles []string,
 	) error
-	ExtractSchema(host string, port uint, name, user, pass string) (*ir.Definition, error)
-	CompareDbData(dbDoc *ir.Definition, host string, port uint, name, user, pass string) (*ir.Definition, error)
-	SqlDiff(old, new []string, outputFile string)
+	ExtractSchema(connString string) (*ir.Definition, error)
+	CompareDbData(dbDoc *ir.Definition, connString string) (*ir.Definition, error)
+	SqlDiff(old, new []string, outputFile string) error
+	Drift(dbDoc *ir.Definition, connString string) (*DriftReport, error)
+	ApplyUpgrade(oldDbDoc, newDbDoc *ir.Definition, connString string, dryRun bool, history *MigrationHistory) error
 
 	GetQuoter() output.Quoter
 }
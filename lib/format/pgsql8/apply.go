@@ -0,0 +1,259 @@
+package pgsql8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// upgradeStage is the body of a single upgrade stage, rendered and ready to execute.
+// BEGIN/COMMIT headers and comments are not included; the applier manages transactions itself.
+type upgradeStage struct {
+	Number      int
+	Description string
+	Statements  []string
+	// Settings run at the top of the stage's transaction, before its statements
+	Settings []string
+	// Concurrent statements run on their own, outside of any transaction, once the stage commits
+	Concurrent []string
+}
+
+// applyExecutor is the minimal surface needed to apply an upgrade, satisfied by *liveConnection
+type applyExecutor interface {
+	exec(ctx context.Context, sql string) error
+}
+
+// ApplyError reports the statement that failed while applying an upgrade, and in which stage
+type ApplyError struct {
+	Stage     int
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("stage %d statement %d failed: %s\n%s", e.Stage, e.Index, e.Err, e.Statement)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyUpgrade calculates the upgrade from oldDoc to newDoc and executes it directly against
+// the given database, stage by stage, stopping at the first error.
+// If dryRun is set, all stages are executed in a single transaction which is then rolled back.
+// Unless history is nil, the outcome of each stage is recorded in dbsteward.migration_history.
+func (ops *Operations) ApplyUpgrade(oldDoc, newDoc *ir.Definition, connString string, dryRun bool, history *lib.MigrationHistory) error {
+	stages, err := ops.upgradeStages(oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
+
+	conn, err := newConnection(ops.logger, connString)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer conn.disconnect()
+
+	ctx := context.TODO()
+	recorder, err := ops.prepareHistory(ctx, conn, history, dryRun)
+	if err != nil {
+		return err
+	}
+	return ops.applyStages(ctx, conn, stages, dryRun, recorder)
+}
+
+// upgradeStages runs the differ into in-memory segmenters and collects the executable statements of each stage
+func (ops *Operations) upgradeStages(oldDoc, newDoc *ir.Definition) ([]upgradeStage, error) {
+	segmenters, recorders, err := ops.diffSegments(oldDoc, newDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts, err := ops.timeoutSettings(newDoc)
+	if err != nil {
+		return nil, err
+	}
+	stages := make([]upgradeStage, len(segmenters))
+	for i, seg := range segmenters {
+		concurrent := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+		err = output.WriteChanges(concurrent, recorders[i].concurrent)
+		if err != nil {
+			return nil, err
+		}
+		stages[i] = upgradeStage{
+			Number:      i + 1,
+			Description: upgradeStageDescriptions[i],
+			Statements:  renderStatements(ops.GetQuoter(), seg.Body),
+			Settings:    renderStatements(ops.GetQuoter(), timeouts),
+			Concurrent:  renderStatements(ops.GetQuoter(), concurrent.Body),
+		}
+	}
+	return stages, nil
+}
+
+// diffSegments runs the differ and renders the changes into four in-memory, annotation-stripped stage segmenters,
+// returning the recorders they were rendered from too
+func (ops *Operations) diffSegments(oldDoc, newDoc *ir.Definition) ([]*output.Segmenter, []*changeRecorder, error) {
+	recorders, err := ops.diffChanges(oldDoc, newDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ops.guardDestructive(recorders, newDoc, "old definition")
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ops.lintUpgrade(recorders, oldDoc, newDoc)
+	if err != nil {
+		return nil, nil, err
+	}
+	segmenters := []*output.Segmenter{
+		output.NewAnnotationStrippingSegmenter(ops.GetQuoter()),
+		output.NewAnnotationStrippingSegmenter(ops.GetQuoter()),
+		output.NewAnnotationStrippingSegmenter(ops.GetQuoter()),
+		output.NewAnnotationStrippingSegmenter(ops.GetQuoter()),
+	}
+	err = renderChanges(recorders, segmenters[0], segmenters[1], segmenters[2], segmenters[3])
+	if err != nil {
+		return nil, nil, err
+	}
+	return segmenters, recorders, nil
+}
+
+func renderStatements(q output.Quoter, stmts []output.ToSql) []string {
+	out := []string{}
+	for _, stmt := range stmts {
+		s := strings.TrimSpace(stmt.ToSql(q))
+		if s == "" {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// applyStages executes each stage in order. Normally each stage gets its own transaction, matching
+// the stage files written by BuildUpgrade. With a single stage upgrade or a dry run, everything
+// runs in one transaction, which is rolled back at the end of a dry run.
+// A transaction which fails to acquire a lock within lock_timeout is retried as configured.
+// The outcome of each transaction, along with the concurrent statements following it, is recorded in history.
+func (ops *Operations) applyStages(ctx context.Context, conn applyExecutor, stages []upgradeStage, dryRun bool, history *stageHistory) error {
+	if dryRun || ops.config.SingleStageUpgrade {
+		started := time.Now()
+		err := ops.retryOnLockTimeout(ctx, "upgrade", func() error {
+			return ops.applyTransaction(ctx, conn, "upgrade", stages, dryRun)
+		})
+		for _, stage := range stages {
+			if err != nil {
+				break
+			}
+			if dryRun && len(stage.Concurrent) > 0 {
+				ops.logger.Warn(fmt.Sprintf("Dry run skipped %d concurrent statements of stage %d, they can't run inside a transaction", len(stage.Concurrent), stage.Number))
+				continue
+			}
+			err = ops.applyConcurrent(ctx, conn, stage)
+		}
+		return errors.Join(err, history.record(ctx, conn, historyAllStages, started, err))
+	}
+
+	for _, stage := range stages {
+		name := fmt.Sprintf("stage %d", stage.Number)
+		started := time.Now()
+		err := ops.retryOnLockTimeout(ctx, name, func() error {
+			return ops.applyTransaction(ctx, conn, name, []upgradeStage{stage}, false)
+		})
+		if err != nil && stage.Number > 1 {
+			ops.logger.Warn(fmt.Sprintf("Stages 1 through %d were committed before the failure", stage.Number-1))
+		}
+		if err == nil {
+			err = ops.applyConcurrent(ctx, conn, stage)
+		}
+		if err := errors.Join(err, history.record(ctx, conn, stage.Number, started, err)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTransaction executes the stages in a single transaction, committing it, or rolling it back for a dry run
+func (ops *Operations) applyTransaction(ctx context.Context, conn applyExecutor, name string, stages []upgradeStage, dryRun bool) error {
+	if err := conn.exec(ctx, "BEGIN"); err != nil {
+		return fmt.Errorf("starting transaction for %s: %w", name, err)
+	}
+	for _, setting := range stages[0].Settings {
+		if err := conn.exec(ctx, setting); err != nil {
+			_ = conn.exec(ctx, "ROLLBACK")
+			return fmt.Errorf("setting up transaction for %s: %w", name, err)
+		}
+	}
+	for _, stage := range stages {
+		ops.logger.Info(fmt.Sprintf("Applying stage %d (%s): %d statements", stage.Number, stage.Description, len(stage.Statements)))
+		for i, stmt := range stage.Statements {
+			err := conn.exec(ctx, stmt)
+			if err != nil {
+				// the transaction is already aborted, so a rollback failure here tells us nothing useful
+				_ = conn.exec(ctx, "ROLLBACK")
+				return &ApplyError{
+					Stage:     stage.Number,
+					Index:     i + 1,
+					Statement: stmt,
+					Err:       err,
+				}
+			}
+		}
+	}
+	if dryRun {
+		ops.logger.Info("Dry run complete, rolling back")
+		if err := conn.exec(ctx, "ROLLBACK"); err != nil {
+			return fmt.Errorf("rolling back dry run: %w", err)
+		}
+		return nil
+	}
+	if err := conn.exec(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("committing %s: %w", name, err)
+	}
+	return nil
+}
+
+// retryOnLockTimeout runs apply again, up to LockRetries more times, for as long as it fails on a lock timeout.
+// apply has to leave nothing behind when it fails, as a rolled back transaction does.
+func (ops *Operations) retryOnLockTimeout(ctx context.Context, name string, apply func() error) error {
+	for attempt := uint(1); ; attempt++ {
+		err := apply()
+		if err == nil || !isLockTimeout(err) || attempt > ops.config.LockRetries {
+			return err
+		}
+		ops.logger.Warn(fmt.Sprintf("Could not acquire a lock for %s in time, retrying in %s (retry %d of %d): %s", name, ops.config.LockRetryDelay, attempt, ops.config.LockRetries, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ops.config.LockRetryDelay):
+		}
+	}
+}
+
+// applyConcurrent runs the concurrent statements of a stage which has already committed, each on its own
+func (ops *Operations) applyConcurrent(ctx context.Context, conn applyExecutor, stage upgradeStage) error {
+	if len(stage.Concurrent) > 0 {
+		ops.logger.Info(fmt.Sprintf("Applying %d concurrent statements of stage %d", len(stage.Concurrent), stage.Number))
+	}
+	for i, stmt := range stage.Concurrent {
+		err := conn.exec(ctx, stmt)
+		if err != nil {
+			ops.logger.Warn(fmt.Sprintf("Stages 1 through %d were committed before the failure", stage.Number))
+			return &ApplyError{
+				Stage:     stage.Number,
+				Index:     len(stage.Statements) + i + 1,
+				Statement: stmt,
+				Err:       err,
+			}
+		}
+	}
+	return nil
+}
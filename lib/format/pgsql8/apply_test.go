@@ -0,0 +1,157 @@
+package pgsql8
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingExecutor struct {
+	executed []string
+	failOn   string
+	// failErr is returned instead of a generic error when set, failTimes limits how often failOn fails when set
+	failErr   error
+	failTimes int
+}
+
+func (r *recordingExecutor) exec(_ context.Context, sql string) error {
+	r.executed = append(r.executed, sql)
+	if sql != r.failOn {
+		return nil
+	}
+	if r.failTimes > 0 {
+		r.failTimes--
+		if r.failTimes == 0 {
+			r.failOn = ""
+		}
+	}
+	if r.failErr != nil {
+		return r.failErr
+	}
+	return errors.New("boom")
+}
+
+func testApplyStages() []upgradeStage {
+	return []upgradeStage{
+		{Number: 1, Statements: []string{"CREATE TABLE a ();"}},
+		{Number: 2, Statements: []string{}},
+		{Number: 3, Statements: []string{"DROP TABLE b;", "DROP TABLE c;"}},
+		{Number: 4, Statements: []string{"INSERT INTO a DEFAULT VALUES;"}},
+	}
+}
+
+func TestApply_TransactionPerStage(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	conn := &recordingExecutor{}
+	err := ops.applyStages(context.Background(), conn, testApplyStages(), false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN", "CREATE TABLE a ();", "COMMIT",
+		"BEGIN", "COMMIT",
+		"BEGIN", "DROP TABLE b;", "DROP TABLE c;", "COMMIT",
+		"BEGIN", "INSERT INTO a DEFAULT VALUES;", "COMMIT",
+	}, conn.executed)
+}
+
+func TestApply_SingleStageUpgrade(t *testing.T) {
+	conf := DefaultConfig
+	conf.SingleStageUpgrade = true
+	ops := NewOperations(conf).(*Operations)
+	conn := &recordingExecutor{}
+	err := ops.applyStages(context.Background(), conn, testApplyStages(), false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"CREATE TABLE a ();",
+		"DROP TABLE b;", "DROP TABLE c;",
+		"INSERT INTO a DEFAULT VALUES;",
+		"COMMIT",
+	}, conn.executed)
+}
+
+func TestApply_DryRunRollsBack(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	conn := &recordingExecutor{}
+	err := ops.applyStages(context.Background(), conn, testApplyStages(), true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"CREATE TABLE a ();",
+		"DROP TABLE b;", "DROP TABLE c;",
+		"INSERT INTO a DEFAULT VALUES;",
+		"ROLLBACK",
+	}, conn.executed)
+}
+
+func TestApply_StopsOnFirstError(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	conn := &recordingExecutor{failOn: "DROP TABLE b;"}
+	err := ops.applyStages(context.Background(), conn, testApplyStages(), false, nil)
+
+	var applyErr *ApplyError
+	if assert.ErrorAs(t, err, &applyErr) {
+		assert.Equal(t, 3, applyErr.Stage)
+		assert.Equal(t, 1, applyErr.Index)
+		assert.Equal(t, "DROP TABLE b;", applyErr.Statement)
+	}
+	assert.Equal(t, []string{
+		"BEGIN", "CREATE TABLE a ();", "COMMIT",
+		"BEGIN", "COMMIT",
+		"BEGIN", "DROP TABLE b;", "ROLLBACK",
+	}, conn.executed)
+}
+
+func TestApply_ConcurrentAfterStageCommit(t *testing.T) {
+	stages := []upgradeStage{
+		{Number: 1, Statements: []string{"CREATE TABLE a ();"}, Concurrent: []string{"CREATE INDEX CONCURRENTLY a_idx ON a (x)"}},
+		{Number: 2, Statements: []string{"INSERT INTO a DEFAULT VALUES;"}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	conn := &recordingExecutor{}
+	assert.NoError(t, ops.applyStages(context.Background(), conn, stages, false, nil))
+	assert.Equal(t, []string{
+		"BEGIN", "CREATE TABLE a ();", "COMMIT",
+		"CREATE INDEX CONCURRENTLY a_idx ON a (x)",
+		"BEGIN", "INSERT INTO a DEFAULT VALUES;", "COMMIT",
+	}, conn.executed)
+
+	conn = &recordingExecutor{}
+	assert.NoError(t, ops.applyStages(context.Background(), conn, stages, true, nil))
+	assert.NotContains(t, conn.executed, "CREATE INDEX CONCURRENTLY a_idx ON a (x)")
+}
+
+func TestApply_RetriesStageOnLockTimeout(t *testing.T) {
+	conf := DefaultConfig
+	conf.LockRetries = 2
+	conf.LockRetryDelay = time.Millisecond
+	ops := NewOperations(conf).(*Operations)
+	lockTimeout := &pgconn.PgError{Code: "55P03", Message: "canceling statement due to lock timeout"}
+	stages := []upgradeStage{
+		{Number: 1, Statements: []string{"CREATE TABLE a ();"}, Settings: []string{"SET LOCAL lock_timeout = '5s';"}},
+		{Number: 2, Statements: []string{"ALTER TABLE b ADD x int;"}, Settings: []string{"SET LOCAL lock_timeout = '5s';"}},
+	}
+
+	conn := &recordingExecutor{failOn: "ALTER TABLE b ADD x int;", failErr: lockTimeout, failTimes: 2}
+	assert.NoError(t, ops.applyStages(context.Background(), conn, stages, false, nil))
+	retry := []string{"BEGIN", "SET LOCAL lock_timeout = '5s';", "ALTER TABLE b ADD x int;"}
+	assert.Equal(t, []string{
+		"BEGIN", "SET LOCAL lock_timeout = '5s';", "CREATE TABLE a ();", "COMMIT",
+		retry[0], retry[1], retry[2], "ROLLBACK",
+		retry[0], retry[1], retry[2], "ROLLBACK",
+		retry[0], retry[1], retry[2], "COMMIT",
+	}, conn.executed, "only the stage which timed out is retried")
+
+	conn = &recordingExecutor{failOn: "ALTER TABLE b ADD x int;", failErr: lockTimeout}
+	err := ops.applyStages(context.Background(), conn, stages, false, nil)
+	assert.ErrorIs(t, err, lockTimeout)
+	assert.Len(t, conn.executed, 4+3*4, "gives up after the configured retries")
+
+	conn = &recordingExecutor{failOn: "ALTER TABLE b ADD x int;"}
+	assert.Error(t, ops.applyStages(context.Background(), conn, stages, false, nil))
+	assert.Len(t, conn.executed, 4+4, "other errors aren't retried")
+}
@@ -0,0 +1,33 @@
+package pgsql8
+
+import (
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+func getCreateCastSql(cast *ir.Cast) []output.ToSql {
+	create := &sql.CastCreate{
+		Source: sql.ParseTypeRef(cast.Source),
+		Target: sql.ParseTypeRef(cast.Target),
+		Inout:  cast.Method.Equals(ir.CastMethodInout),
+	}
+	if cast.Method.Equals(ir.CastMethodFunction) {
+		create.Function = cast.Function
+	}
+	if !cast.Context.Equals(ir.CastContextExplicit) {
+		create.Context = strings.ToUpper(string(cast.Context))
+	}
+	return []output.ToSql{create}
+}
+
+func getDropCastSql(cast *ir.Cast) []output.ToSql {
+	return []output.ToSql{
+		&sql.CastDrop{
+			Source: sql.ParseTypeRef(cast.Source),
+			Target: sql.ParseTypeRef(cast.Target),
+		},
+	}
+}
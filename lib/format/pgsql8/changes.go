@@ -0,0 +1,578 @@
+package pgsql8
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/format/sql99"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// UpgradeChanges calculates the upgrade from oldDoc to newDoc as a list of typed changes,
+// in the order they would be written, stage 1 through 4, each stage followed by its concurrent changes.
+// Rendering each change's Statement in order produces the same upgrade as Upgrade, less the BEGIN/COMMIT of each stage.
+func (ops *Operations) UpgradeChanges(oldDoc, newDoc *ir.Definition) ([]*output.Change, error) {
+	recorders, err := ops.diffChanges(oldDoc, newDoc)
+	if err != nil {
+		return nil, err
+	}
+	return stagedChanges(recorders), nil
+}
+
+// stagedChanges returns the recorded changes in the order they run, stage by stage, each stage followed by its concurrent changes
+func stagedChanges(recorders []*changeRecorder) []*output.Change {
+	changes := []*output.Change{}
+	for _, recorder := range recorders {
+		changes = append(changes, recorder.changes...)
+		changes = append(changes, recorder.concurrent...)
+	}
+	return changes
+}
+
+// diffChanges runs the differ into one change recorder per stage
+func (ops *Operations) diffChanges(oldDoc, newDoc *ir.Definition) ([]*changeRecorder, error) {
+	var err error
+	ops.logger.Info("Calculating old table foreign key dependency order...")
+	ops.differ.OldTableDependency, err = oldDoc.TableDependencyOrder()
+	if err != nil {
+		return nil, fmt.Errorf("old document: %w", err)
+	}
+	ops.logger.Info("Calculating new table foreign key dependency order...")
+	ops.differ.NewTableDependency, err = newDoc.TableDependencyOrder()
+	if err != nil {
+		return nil, fmt.Errorf("new document: %w", err)
+	}
+	ops.config.OldDatabase = oldDoc
+	ops.config.NewDatabase = newDoc
+
+	recorders := newChangeRecorders()
+	err = ops.differ.DiffDocWork(recorders[0], recorders[1], recorders[2], recorders[3])
+	if err != nil {
+		return nil, err
+	}
+	return recorders, nil
+}
+
+// renderChanges writes each stage's recorded changes, along with its header and footer, to the given segmenters.
+// When all stages share one segmenter, as in a single stage upgrade, the changes are written in the order
+// the differ wrote them rather than stage by stage, as that's the order the differ relies on.
+func renderChanges(recorders []*changeRecorder, stages ...output.OutputFileSegmenter) error {
+	shared := true
+	for _, stage := range stages {
+		shared = shared && stage == stages[0]
+	}
+	if !shared {
+		for i, recorder := range recorders {
+			if err := recorder.render(stages[i]); err != nil {
+				return fmt.Errorf("stage %d: %w", recorder.stage, err)
+			}
+		}
+		return nil
+	}
+
+	ofs := stages[0]
+	for _, recorder := range recorders {
+		for _, stmt := range recorder.header {
+			if err := ofs.AppendHeader(stmt); err != nil {
+				return err
+			}
+		}
+	}
+	if err := output.WriteChanges(ofs, writtenChanges(recorders)); err != nil {
+		return err
+	}
+	for _, recorder := range recorders {
+		for _, stmt := range recorder.footer {
+			if err := ofs.AppendFooter(stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writtenChanges returns the changes recorded across all recorders, in the order they were written
+func writtenChanges(recorders []*changeRecorder) []*output.Change {
+	if len(recorders) == 0 {
+		return nil
+	}
+	return *recorders[0].sequence
+}
+
+// changeRecorder is an output.OutputFileSegmenter which, instead of writing sql,
+// records the changes written to it. Statements written as sql rather than as changes,
+// such as comments and literal sql, are recorded as changes to no object in particular.
+type changeRecorder struct {
+	stage   int
+	header  []output.ToSql
+	footer  []output.ToSql
+	changes []*output.Change
+	// concurrent changes can't run inside a transaction, so run on their own after the stage commits
+	concurrent []*output.Change
+	// sequence is shared between the recorders of an upgrade, and records changes across all stages in order
+	sequence *[]*output.Change
+}
+
+// newChangeRecorders returns a recorder for each of the four upgrade stages
+func newChangeRecorders() []*changeRecorder {
+	sequence := []*output.Change{}
+	recorders := make([]*changeRecorder, 4)
+	for i := range recorders {
+		recorders[i] = &changeRecorder{stage: i + 1, sequence: &sequence}
+	}
+	return recorders
+}
+
+// newBuildChangeRecorder returns a recorder for a full build, which is a single stage of creations
+func newBuildChangeRecorder() *changeRecorder {
+	return &changeRecorder{stage: 1, sequence: &[]*output.Change{}}
+}
+
+func (r *changeRecorder) Close() error {
+	return nil
+}
+
+func (r *changeRecorder) SetHeader(stmt output.ToSql) error {
+	r.header = []output.ToSql{stmt}
+	return nil
+}
+
+func (r *changeRecorder) AppendHeader(stmt output.ToSql) error {
+	if stmt == nil {
+		return nil
+	}
+	r.header = append(r.header, stmt)
+	return nil
+}
+
+func (r *changeRecorder) AppendFooter(stmt output.ToSql) error {
+	if stmt == nil {
+		return nil
+	}
+	r.footer = append(r.footer, stmt)
+	return nil
+}
+
+func (r *changeRecorder) WriteSql(stmts ...output.ToSql) error {
+	return r.WriteChanges(untypedChanges(stmts...)...)
+}
+
+func (r *changeRecorder) WriteChanges(changes ...*output.Change) error {
+	for _, change := range changes {
+		r.stamp(change)
+		r.changes = append(r.changes, change)
+		*r.sequence = append(*r.sequence, change)
+	}
+	return nil
+}
+
+// WriteConcurrentChanges records changes which have to run outside of the stage's transaction
+func (r *changeRecorder) WriteConcurrentChanges(changes ...*output.Change) error {
+	for _, change := range changes {
+		r.stamp(change)
+		r.concurrent = append(r.concurrent, change)
+	}
+	return nil
+}
+
+func (r *changeRecorder) stamp(change *output.Change) {
+	change.Stage = r.stage
+	for _, part := range change.Parts {
+		part.Stage = r.stage
+	}
+}
+
+func (r *changeRecorder) MustWriteSql(stmts []output.ToSql, err error) {
+	if err != nil {
+		panic(err)
+	}
+	err = r.WriteSql(stmts...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r *changeRecorder) render(ofs output.OutputFileSegmenter) error {
+	for _, stmt := range r.header {
+		if err := ofs.AppendHeader(stmt); err != nil {
+			return err
+		}
+	}
+	if err := output.WriteChanges(ofs, r.changes); err != nil {
+		return err
+	}
+	for _, stmt := range r.footer {
+		if err := ofs.AppendFooter(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeWriter is implemented by segmenters which keep the changes written to them, rather than just their sql
+type changeWriter interface {
+	WriteChanges(changes ...*output.Change) error
+}
+
+// writeChanges writes the changes to ofs, or just their statements if it doesn't keep changes
+func writeChanges(ofs output.OutputFileSegmenter, changes ...*output.Change) error {
+	if cw, ok := ofs.(changeWriter); ok {
+		return cw.WriteChanges(changes...)
+	}
+	for _, change := range changes {
+		if err := ofs.WriteSql(change.Statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// untypedChanges carries statements which don't change any object in particular, such as comments and literal sql
+func untypedChanges(stmts ...output.ToSql) []*output.Change {
+	changes := make([]*output.Change, 0, len(stmts))
+	for _, stmt := range stmts {
+		if stmt != nil {
+			changes = append(changes, &output.Change{Statement: stmt})
+		}
+	}
+	return changes
+}
+
+// changed describes an object the differ changes, which every statement changing it is recorded against
+type changed struct {
+	kind     string
+	identity output.ChangeIdentity
+	old      interface{}
+	new      interface{}
+}
+
+func (c changed) create(stmts ...output.ToSql) []*output.Change {
+	return c.changes(output.ChangeCreate, "", stmts)
+}
+
+func (c changed) alter(stmts ...output.ToSql) []*output.Change {
+	return c.changes(output.ChangeAlter, "", stmts)
+}
+
+func (c changed) drop(stmts ...output.ToSql) []*output.Change {
+	return c.changes(output.ChangeDrop, "", stmts)
+}
+
+// rename records the statements against the object's old name, which they change to newName
+func (c changed) rename(oldName string, stmts ...output.ToSql) []*output.Change {
+	newName := c.identity.Name
+	c.identity.Name = oldName
+	return c.changes(output.ChangeRename, newName, stmts)
+}
+
+// createOrAlter creates the object if there was no old one, otherwise alters it
+func (c changed) createOrAlter(stmts ...output.ToSql) []*output.Change {
+	if c.old == nil {
+		return c.create(stmts...)
+	}
+	return c.alter(stmts...)
+}
+
+func (c changed) changes(action output.ChangeAction, newName string, stmts []output.ToSql) []*output.Change {
+	changes := make([]*output.Change, 0, len(stmts))
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+		change := &output.Change{
+			Kind:        c.kind,
+			Identity:    c.identity,
+			Action:      action,
+			NewName:     newName,
+			Old:         c.old,
+			New:         c.new,
+			Destructive: action == output.ChangeDrop && destructiveDropKinds[c.kind],
+			Statement:   stmt,
+		}
+		switch action {
+		case output.ChangeCreate:
+			change.Old = nil
+		case output.ChangeDrop:
+			change.New = nil
+		}
+		if from, to := columnTypeChange(change); from != "" && typeNarrows(from, to) {
+			change.Destructive = true
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// tableAlter combines the changes made by single part ALTER TABLEs of one table into a single ALTER TABLE.
+// Several parts make an alteration of the table, made up of those parts, and a single part is just the change it makes.
+func tableAlter(table changed, parts []*output.Change) []*output.Change {
+	if len(parts) <= 1 {
+		return parts
+	}
+	alter := &sql.TableAlterParts{}
+	for _, part := range parts {
+		stmt := part.Statement.(*sql.TableAlterParts)
+		alter.Table = stmt.Table
+		alter.Parts = append(alter.Parts, stmt.Parts...)
+	}
+	change := table.alter(alter)[0]
+	change.Parts = parts
+	for _, part := range parts {
+		change.Destructive = change.Destructive || part.Destructive
+	}
+	return []*output.Change{change}
+}
+
+// destructiveDropKinds are the kinds of object which take data with them when dropped
+var destructiveDropKinds = map[string]bool{
+	"schema":   true,
+	"table":    true,
+	"column":   true,
+	"sequence": true,
+	"type":     true,
+	"row":      true,
+}
+
+// node returns n, or an untyped nil if n is nil, so that a change's Old and New compare equal to nil when missing
+func node[T any](n *T) interface{} {
+	if n == nil {
+		return nil
+	}
+	return n
+}
+
+// whichever returns the new object, or the old one if there is no new object
+func whichever[T any](old, new *T) *T {
+	if new != nil {
+		return new
+	}
+	return old
+}
+
+// either returns the name of whichever object there is, preferring the new one
+func either[T any](old, new *T, name func(*T) string) string {
+	return name(whichever(old, new))
+}
+
+func schemaChanged(old, new *ir.Schema) changed {
+	return changed{
+		kind:     "schema",
+		identity: output.ChangeIdentity{Schema: either(old, new, func(s *ir.Schema) string { return s.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func tableChanged(schema string, old, new *ir.Table) changed {
+	return changed{
+		kind:     "table",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, func(t *ir.Table) string { return t.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+// partitionChanged describes a partition of a natively partitioned table, which is only a segment of its parent's definition
+func partitionChanged(schema, partition string) changed {
+	return changed{
+		kind:     "table",
+		identity: output.ChangeIdentity{Schema: schema, Name: partition},
+	}
+}
+
+// rowsChanged describes the data of a table, as its rows are identified by the table alone
+func rowsChanged(schema string, old, new *ir.Table) changed {
+	c := tableChanged(schema, old, new)
+	c.kind = "row"
+	return c
+}
+
+func columnChanged(schema, table string, old, new *ir.Column) changed {
+	return changed{
+		kind:     "column",
+		identity: output.ChangeIdentity{Schema: schema, Parent: table, Name: either(old, new, func(c *ir.Column) string { return c.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+// constraintChanged describes a table constraint, whose IR node is the constraint or foreign key it was declared by, if any
+func constraintChanged(old, new *sql99.TableConstraint) changed {
+	c := whichever(old, new)
+	return changed{
+		kind:     "constraint",
+		identity: output.ChangeIdentity{Schema: c.Schema.Name, Parent: c.Table.Name, Name: c.Name},
+		old:      constraintNode(old),
+		new:      constraintNode(new),
+	}
+}
+
+func constraintNode(c *sql99.TableConstraint) interface{} {
+	if c == nil {
+		return nil
+	}
+	for _, constraint := range c.Table.Constraints {
+		if strings.EqualFold(constraint.Name, c.Name) {
+			return constraint
+		}
+	}
+	for _, fk := range c.Table.ForeignKeys {
+		if strings.EqualFold(fk.ConstraintName, c.Name) {
+			return fk
+		}
+	}
+	return nil
+}
+
+func indexChanged(schema string, old, new *ir.Index) changed {
+	return changed{
+		kind:     "index",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, func(i *ir.Index) string { return i.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func sequenceChanged(schema string, old, new *ir.Sequence) changed {
+	return changed{
+		kind:     "sequence",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, func(s *ir.Sequence) string { return s.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+// serialSequenceChanged describes the sequence behind a serial column, which has no IR node of its own
+func serialSequenceChanged(schema, table, column string) changed {
+	return changed{
+		kind:     "sequence",
+		identity: output.ChangeIdentity{Schema: schema, Name: buildSequenceName(schema, table, column)},
+	}
+}
+
+// functionChanged identifies functions by their signature, so that overloads are told apart
+func functionChanged(schema string, old, new *ir.Function) changed {
+	return changed{
+		kind:     "function",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, (*ir.Function).ShortSig)},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func triggerChanged(schema string, old, new *ir.Trigger) changed {
+	t := whichever(old, new)
+	return changed{
+		kind:     "trigger",
+		identity: output.ChangeIdentity{Schema: schema, Parent: t.Table, Name: t.Name},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func policyChanged(schema, table string, old, new *ir.Policy) changed {
+	return changed{
+		kind:     "policy",
+		identity: output.ChangeIdentity{Schema: schema, Parent: table, Name: either(old, new, func(p *ir.Policy) string { return p.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func viewChanged(schema string, old, new *ir.View) changed {
+	return changed{
+		kind:     "view",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, func(v *ir.View) string { return v.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func typeChanged(schema string, old, new *ir.TypeDef) changed {
+	return changed{
+		kind:     "type",
+		identity: output.ChangeIdentity{Schema: schema, Name: either(old, new, func(t *ir.TypeDef) string { return t.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func languageChanged(old, new *ir.Language) changed {
+	return changed{
+		kind:     "language",
+		identity: output.ChangeIdentity{Name: either(old, new, func(l *ir.Language) string { return l.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func extensionChanged(old, new *ir.Extension) changed {
+	return changed{
+		kind:     "extension",
+		identity: output.ChangeIdentity{Name: either(old, new, func(e *ir.Extension) string { return e.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+// castChanged identifies casts by the types they cast from and to
+func castChanged(old, new *ir.Cast) changed {
+	c := whichever(old, new)
+	return changed{
+		kind:     "cast",
+		identity: output.ChangeIdentity{Parent: c.Source, Name: c.Target},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+func roleChanged(old, new *ir.Role) changed {
+	return changed{
+		kind:     "role",
+		identity: output.ChangeIdentity{Name: either(old, new, func(r *ir.Role) string { return r.Name })},
+		old:      node(old),
+		new:      node(new),
+	}
+}
+
+// defaultPrivilegesChanged identifies default privileges by schema, the role they apply to, as resolved, and the kind of object
+func defaultPrivilegesChanged(conf lib.Config, old, new *ir.DefaultPrivileges) (changed, error) {
+	dp := whichever(old, new)
+	role, err := roleEnum(conf.Logger, conf.NewDatabase, dp.Role, conf.IgnoreCustomRoles)
+	if err != nil {
+		return changed{}, err
+	}
+	return changed{
+		kind:     "default privileges",
+		identity: output.ChangeIdentity{Schema: dp.Schema, Parent: role, Name: strings.ToLower(string(dp.ObjectType))},
+		old:      node(old),
+		new:      node(new),
+	}, nil
+}
+
+// grantChanged describes a grant on an object. Grants have no name of their own, so they are identified by
+// what they grant on what to whom, as in "SELECT ON TABLE public.accounts TO reader", with roles as resolved.
+// The implicit grants which come with it, such as on the sequences of serial columns, are part of the same grant.
+func grantChanged(conf lib.Config, objectType, object string, old, new *ir.Grant) (changed, error) {
+	grant := whichever(old, new)
+	roles := make([]string, len(grant.Roles))
+	for i, role := range grant.Roles {
+		var err error
+		roles[i], err = roleEnum(conf.Logger, conf.NewDatabase, role, conf.IgnoreCustomRoles)
+		if err != nil {
+			return changed{}, err
+		}
+	}
+	perms := util.Map(grant.Permissions, strings.ToUpper)
+	name := fmt.Sprintf("%s ON %s %s TO %s", strings.Join(perms, ", "), objectType, object, strings.Join(roles, ", "))
+	return changed{
+		kind:     "grant",
+		identity: output.ChangeIdentity{Name: name},
+		old:      node(old),
+		new:      node(new),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperations_UpgradeChanges(t *testing.T) {
+	legacy := &ir.Column{Name: "legacy", Type: "text", Nullable: true}
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "orders",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}, legacy},
+			}},
+		}},
+	}
+	audit := &ir.Table{
+		Name:       "order_audit",
+		PrimaryKey: []string{"id"},
+		Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "orders",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				audit,
+			},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	changes, err := ops.UpgradeChanges(oldDoc, newDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = output.FilterChanges(changes, (*output.Change).IsObject)
+	if assert.Len(t, changes, 4) {
+		// the table, its NOT NULL column and its primary key
+		assert.Equal(t, "table", changes[0].Kind)
+		assert.Equal(t, output.ChangeCreate, changes[0].Action)
+		assert.Equal(t, output.ChangeIdentity{Schema: "public", Name: "order_audit"}, changes[0].Identity)
+		assert.Equal(t, 1, changes[0].Stage)
+		assert.Nil(t, changes[0].Old)
+		assert.Same(t, audit, changes[0].New)
+		assert.Equal(t, "constraint", changes[2].Kind)
+		assert.Equal(t, output.ChangeIdentity{Schema: "public", Parent: "order_audit", Name: "order_audit_pkey"}, changes[2].Identity)
+
+		assert.Equal(t, &output.Change{
+			Kind:        "column",
+			Identity:    output.ChangeIdentity{Schema: "public", Parent: "orders", Name: "legacy"},
+			Action:      output.ChangeDrop,
+			Old:         legacy,
+			Stage:       3,
+			Destructive: true,
+			Statement: &sql.TableAlterParts{
+				Table: sql.TableRef{Schema: "public", Table: "orders"},
+				Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnDrop{Column: "legacy"}},
+			},
+		}, changes[3])
+	}
+}
+
+func TestOperations_UpgradeChanges_RenderMatchesUpgrade(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "orders",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "orders",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "note", Type: "text", Nullable: true},
+				},
+				Grants: []*ir.Grant{{Roles: []string{"reader"}, Permissions: []string{"SELECT"}}},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	changes, err := ops.UpgradeChanges(oldDoc, newDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ofs := output.NewSegmenter(ops.GetQuoter())
+	assert.NoError(t, output.WriteChanges(ofs, changes))
+	rendered := []string{}
+	for _, stmt := range ofs.AllStatements() {
+		rendered = append(rendered, stmt.Statement)
+	}
+
+	ops = NewOperations(DefaultConfig).(*Operations)
+	upgrade, err := ops.Upgrade(ops.logger, oldDoc, newDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{}
+	for _, stmt := range upgrade {
+		if stmt.Statement != "\nBEGIN;\n\n" && stmt.Statement != "\nCOMMIT;\n" {
+			expected = append(expected, stmt.Statement)
+		}
+	}
+	assert.Equal(t, expected, rendered)
+}
+
+func TestRenderChanges_SingleSegmenterKeepsWriteOrder(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	recorders := newChangeRecorders()
+	recorders[0].WriteSql(output.NewRawSQL("one;"))
+	recorders[2].WriteSql(output.NewRawSQL("two;"))
+	recorders[0].WriteSql(output.NewRawSQL("three;"))
+
+	single := output.NewSegmenter(ops.GetQuoter())
+	assert.NoError(t, renderChanges(recorders, single, single, single, single))
+	assert.Equal(t, []output.DDLStatement{
+		{Statement: "one;"}, {Statement: "two;"}, {Statement: "three;"},
+	}, single.AllStatements())
+
+	staged := []*output.Segmenter{
+		output.NewSegmenter(ops.GetQuoter()),
+		output.NewSegmenter(ops.GetQuoter()),
+		output.NewSegmenter(ops.GetQuoter()),
+		output.NewSegmenter(ops.GetQuoter()),
+	}
+	assert.NoError(t, renderChanges(recorders, staged[0], staged[1], staged[2], staged[3]))
+	assert.Equal(t, []output.DDLStatement{{Statement: "one;"}, {Statement: "three;"}}, staged[0].AllStatements())
+	assert.Equal(t, []output.DDLStatement{{Statement: "two;"}}, staged[2].AllStatements())
+}
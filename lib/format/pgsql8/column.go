@@ -19,6 +19,9 @@ func getReducedColumnDefinition(l *slog.Logger, doc *ir.Definition, schema *ir.S
 	return sql.ColumnDefinition{
 		Name: column.Name,
 		Type: sql.ParseTypeRef(t),
+		// identity and generated columns can't be made so after the fact, unlike defaults and NOT NULL
+		Identity:    getColumnIdentity(column),
+		GeneratedAs: column.GeneratedAs,
 	}, nil
 }
 
@@ -32,6 +35,9 @@ func getFullColumnDefinition(l *slog.Logger, doc *ir.Definition, schema *ir.Sche
 		Type:     sql.ParseTypeRef(colType),
 		Default:  nil,
 		Nullable: nil,
+
+		Identity:    getColumnIdentity(column),
+		GeneratedAs: column.GeneratedAs,
 	}
 
 	if column.Default != "" {
@@ -59,6 +65,45 @@ func getFullColumnDefinition(l *slog.Logger, doc *ir.Definition, schema *ir.Sche
 	return out, nil
 }
 
+func getColumnIdentity(column *ir.Column) *sql.ColumnIdentity {
+	if column.Identity == nil {
+		return nil
+	}
+	return &sql.ColumnIdentity{
+		Generation: string(column.Identity.Generation),
+		Start:      column.Identity.Start,
+		Increment:  column.Identity.Increment,
+		Min:        column.Identity.Min,
+		Max:        column.Identity.Max,
+		Cache:      column.Identity.Cache,
+		Cycle:      column.Identity.Cycle,
+	}
+}
+
+// checkGeneratedColumns makes sure identity and generated columns can be created in the target version,
+// and that identities aren't declared on serials, which bring their own sequence
+func checkGeneratedColumns(doc *ir.Definition, target VersionNum) error {
+	if doc == nil {
+		return nil
+	}
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			for _, column := range table.Columns {
+				if column.Identity != nil && isColumnSerialType(column) {
+					return fmt.Errorf("column %s.%s.%s is an identity, so it should be an integer type rather than %s", schema.Name, table.Name, column.Name, column.Type)
+				}
+				if column.Identity != nil && target != 0 && !FEAT_IDENTITY_COLUMNS(target) {
+					return fmt.Errorf("column %s.%s.%s is an identity, which needs a target version of at least 10", schema.Name, table.Name, column.Name)
+				}
+				if column.GeneratedAs != "" && target != 0 && !FEAT_GENERATED_COLUMNS(target) {
+					return fmt.Errorf("column %s.%s.%s is generated, which needs a target version of at least 12", schema.Name, table.Name, column.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func getColumnSetupSql(schema *ir.Schema, table *ir.Table, column *ir.Column) []output.ToSql {
 	ddl := []output.ToSql{}
 	colref := sql.ColumnRef{Schema: schema.Name, Table: table.Name, Column: column.Name}
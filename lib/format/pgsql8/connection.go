@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"strconv"
 
 	"github.com/jackc/pgx/v4"
@@ -11,13 +12,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-func newConnection(host string, port uint, name, user, pass string) (*liveConnection, error) {
-	// TODO(go,3) sslmode?
-	// TODO(go,3) just have the user pass the entire DSN
-	// TODO(feat) support envvar password
-	dsnNoPass := fmt.Sprintf("host=%s port=%d user=%s dbname=%s", host, port, user, name)
-	dsn := dsnNoPass + fmt.Sprintf(" password=%s", pass)
-	conn, err := pgx.Connect(context.Background(), dsn)
+// newConnection connects using a URI or DSN connection string. As with libpq, anything the connection
+// string leaves out is taken from PG* environment variables, pg_service.conf and ~/.pgpass.
+func newConnection(l *slog.Logger, connString string) (*liveConnection, error) {
+	config, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse connection string")
+	}
+	l.Info(fmt.Sprintf("Connecting to pgsql8 host %s:%d database %s as %s", config.Host, config.Port, config.Database, config.User))
+	conn, err := pgx.ConnectConfig(context.Background(), config)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not connect to postgres database")
 	}
@@ -46,6 +49,11 @@ func (lconn *liveConnection) disconnect() {
 	lconn.conn.Close(context.TODO())
 }
 
+func (lconn *liveConnection) exec(ctx context.Context, sql string) error {
+	_, err := lconn.conn.Exec(ctx, sql)
+	return err
+}
+
 func (lconn *liveConnection) query(query string, params ...interface{}) (pgx.Rows, error) {
 	return lconn.conn.Query(context.TODO(), query, params...)
 }
@@ -0,0 +1,114 @@
+package pgsql8
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// checkDefaultPrivileges makes sure the default privileges can be altered in the target version
+func checkDefaultPrivileges(doc *ir.Definition, target VersionNum) error {
+	if doc == nil || target == 0 {
+		return nil
+	}
+	for _, dp := range doc.DefaultPrivileges {
+		if !FEAT_DEFAULT_PRIVILEGES(target) {
+			return fmt.Errorf("%s need a target version of at least 9.0", dp)
+		}
+		if dp.ObjectType.Equals(ir.DefaultPrivilegesSchemas) && !FEAT_DEFAULT_PRIVILEGES_ON_SCHEMAS(target) {
+			return fmt.Errorf("%s need a target version of at least 10", dp)
+		}
+	}
+	return nil
+}
+
+func getDefaultPrivilegesGrantSql(conf lib.Config, dp *ir.DefaultPrivileges, grant *ir.Grant) ([]output.ToSql, error) {
+	owner, roles, err := defaultPrivilegesRoles(conf.Logger, conf.NewDatabase, dp, grant.Roles, conf.IgnoreCustomRoles)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := defaultPrivilegesPerms(dp, grant.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	return []output.ToSql{
+		&sql.DefaultPrivilegesGrant{
+			Role:       owner,
+			Schema:     dp.Schema,
+			ObjectType: strings.ToUpper(string(dp.ObjectType)),
+			Perms:      perms,
+			Roles:      roles,
+			CanGrant:   grant.CanGrant(),
+		},
+	}, nil
+}
+
+// getDefaultPrivilegesRevokeSql takes the role names from the old definition, which they were granted under
+func getDefaultPrivilegesRevokeSql(conf lib.Config, dp *ir.DefaultPrivileges, grantees []string, perms []string) ([]output.ToSql, error) {
+	owner, roles, err := defaultPrivilegesRoles(conf.Logger, conf.OldDatabase, dp, grantees, conf.IgnoreCustomRoles)
+	if err != nil {
+		return nil, err
+	}
+	perms, err = defaultPrivilegesPerms(dp, perms)
+	if err != nil {
+		return nil, err
+	}
+	return []output.ToSql{
+		&sql.DefaultPrivilegesRevoke{
+			Role:       owner,
+			Schema:     dp.Schema,
+			ObjectType: strings.ToUpper(string(dp.ObjectType)),
+			Perms:      perms,
+			Roles:      roles,
+		},
+	}, nil
+}
+
+func defaultPrivilegesRoles(l *slog.Logger, doc *ir.Definition, dp *ir.DefaultPrivileges, grantees []string, ignoreCustomRoles bool) (string, []string, error) {
+	owner, err := roleEnum(l, doc, dp.Role, ignoreCustomRoles)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", dp, err)
+	}
+	roles := make([]string, len(grantees))
+	for i, role := range grantees {
+		roles[i], err = roleEnum(l, doc, role, ignoreCustomRoles)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", dp, err)
+		}
+	}
+	return owner, roles, nil
+}
+
+func defaultPrivilegesPerms(dp *ir.DefaultPrivileges, perms []string) ([]string, error) {
+	valid := util.IIntersectStrs(perms, ir.PermissionListAllPgsql8)
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("no format-compatible permissions on %s grant: %v", dp, perms)
+	}
+	invalid := util.IDifferenceStrs(valid, dp.ObjectType.ValidPermissions())
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid permissions on %s grant: %v", dp, invalid)
+	}
+	return valid, nil
+}
+
+// revokedDefaultPrivileges returns the permissions the role loses going from the old to the new default privileges.
+// ALL can't be taken apart in sql, so it's spelled out when only some of it is revoked
+func revokedDefaultPrivileges(oldDP, newDP *ir.DefaultPrivileges, role string) []string {
+	oldPerms, _ := oldDP.GetPermissionsForRole(role)
+	newPerms, _ := newDP.GetPermissionsForRole(role)
+	if util.IStrsContains(newPerms, ir.PermissionAll) {
+		return nil
+	}
+	revoked := util.IDifferenceStrs(oldPerms, newPerms)
+	if util.IStrsContains(revoked, ir.PermissionAll) {
+		all := util.IIntersectStrs(oldDP.ObjectType.ValidPermissions(), ir.PermissionListAllPgsql8)
+		revoked = util.IDifferenceStrs(util.IDifferenceStrs(all, []string{ir.PermissionAll}), newPerms)
+	}
+	return revoked
+}
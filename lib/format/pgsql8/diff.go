@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
@@ -90,15 +91,77 @@ func (d *diff) DiffDocWork(stage1, stage2, stage3, stage4 output.OutputFileSegme
 		}
 	}
 
+	timeouts, err := d.ops.timeoutSettings(d.ops.config.NewDatabase)
+	if err != nil {
+		return err
+	}
+	target, err := parseTargetVersion(d.ops.config.TargetVersion, d.ops.config.NewDatabase)
+	if err != nil {
+		return err
+	}
+	err = checkGeneratedColumns(d.ops.config.NewDatabase, target)
+	if err != nil {
+		return err
+	}
+	err = checkExtensions(d.ops.config.NewDatabase, target)
+	if err != nil {
+		return err
+	}
+	err = checkPolicies(d.ops.config.NewDatabase, target)
+	if err != nil {
+		return err
+	}
+	err = checkDefaultPrivileges(d.ops.config.NewDatabase, target)
+	if err != nil {
+		return err
+	}
+	err = checkTypes(d.ops.config.NewDatabase, target)
+	if err != nil {
+		return err
+	}
+	transactions := []output.OutputFileSegmenter{stage1}
+	if !d.ops.config.SingleStageUpgrade {
+		transactions = append(transactions, stage2, stage3, stage4)
+	}
+	for _, stage := range transactions {
+		for _, timeout := range timeouts {
+			stage.AppendHeader(timeout)
+			stage.AppendHeader(output.NewRawSQL("\n"))
+		}
+		if len(timeouts) > 0 {
+			stage.AppendHeader(output.NewRawSQL("\n"))
+		}
+	}
+
+	if d.ops.config.OnlineSafe {
+		if d.ops.config.SingleStageUpgrade {
+			return fmt.Errorf("online rewrites need a staged upgrade, a single stage upgrade runs in one transaction")
+		}
+		if targetOlderThan(d.ops.config, FEAT_CREATE_INDEX_IF_NOT_EXISTS) {
+			return fmt.Errorf("online rewrites need a target version of at least 9.5, to retry indexes built concurrently")
+		}
+		online := newOnlineRewriter(d.Quoter(), d.ops.config.OldDatabase, d.ops.config.NewDatabase, targetAtLeast(d.ops.config, FEAT_SET_NOT_NULL_FROM_CHECK))
+		stage1, stage2, stage3, stage4, err = online.wrap(stage1, stage2, stage3, stage4)
+		if err != nil {
+			return err
+		}
+	}
+
 	// start with pre-upgrade sql statements that prepare the database to take on its changes
 	buildStagedSql(d.ops.config.NewDatabase, stage1, "STAGE1BEFORE")
 	buildStagedSql(d.ops.config.NewDatabase, stage2, "STAGE2BEFORE")
 
+	d.ops.config.Logger.Info("Update Roles")
+	err = createRoles(d.ops.config, stage1)
+	if err != nil {
+		return err
+	}
+
 	d.ops.config.Logger.Info("Drop Old Schemas")
 	d.DropOldSchemas(stage3)
 
 	d.ops.config.Logger.Info("Create New Schemas")
-	err := d.CreateNewSchemas(stage1)
+	err = d.CreateNewSchemas(stage1)
 	if err != nil {
 		return err
 	}
@@ -113,6 +176,7 @@ func (d *diff) DiffDocWork(stage1, stage2, stage3, stage4 output.OutputFileSegme
 	if err != nil {
 		return err
 	}
+	dropRoles(d.ops.config, stage3)
 
 	d.UpdateDatabaseConfigParameters(stage1, d.ops.config.NewDatabase, d.ops.config.OldDatabase)
 
@@ -128,6 +192,12 @@ func (d *diff) DiffDocWork(stage1, stage2, stage3, stage4 output.OutputFileSegme
 	if err != nil {
 		return err
 	}
+	if d.ops.config.RefreshMaterializedViews {
+		err = refreshViewsOrdered(d.ops.config, stage4, d.ops.config.OldDatabase, d.ops.config.NewDatabase)
+		if err != nil {
+			return err
+		}
+	}
 
 	// append any literal sql in new not in old at the end of data stage 1
 	// TODO(feat) this relies on exact string match - is there a better way?
@@ -167,14 +237,40 @@ func (d *diff) DiffDocWork(stage1, stage2, stage3, stage4 output.OutputFileSegme
 	return nil
 }
 
-func (d *diff) DiffSql(old, new []string, upgradePrefix string) {
-	// TODO(go,sqldiff)
+func (d *diff) DiffSql(old, new []string, upgradePrefix string) error {
+	logger := d.ops.config.Logger
+	logger.Info("Parsing old sql files...")
+	oldDoc, err := parseSqlFiles(logger, old)
+	if err != nil {
+		return fmt.Errorf("parsing old sql: %w", err)
+	}
+	logger.Info("Parsing new sql files...")
+	newDoc, err := parseSqlFiles(logger, new)
+	if err != nil {
+		return fmt.Errorf("parsing new sql: %w", err)
+	}
+
+	logger.Info("Calculating old table foreign key dependency order...")
+	d.OldTableDependency, err = oldDoc.TableDependencyOrder()
+	if err != nil {
+		return fmt.Errorf("calculating dependency order: %w", err)
+	}
+	logger.Info("Calculating new table foreign key dependency order...")
+	d.NewTableDependency, err = newDoc.TableDependencyOrder()
+	if err != nil {
+		return fmt.Errorf("calculating dependency order: %w", err)
+	}
+
+	return d.DiffDoc(strings.Join(old, " "), strings.Join(new, " "), oldDoc, newDoc, upgradePrefix)
 }
 
 func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.OutputFileSegmenter) error {
 	logger := d.ops.config.Logger
 	logger.Info("Update Structure")
 
+	// extensions may provide languages, types and functions used by everything else
+	createExtensions(d.ops.config, stage1)
+
 	err := diffLanguages(d.ops.config, stage1)
 	if err != nil {
 		return err
@@ -182,7 +278,18 @@ func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.
 
 	// drop all views in all schemas, regardless whether dependency order is known or not
 	// TODO(go,4) would be so cool if we could parse the view def and only recreate what's required
-	dropViewsOrdered(stage1, d.ops.config.OldDatabase, d.ops.config.NewDatabase)
+	err = dropViewsOrdered(d.ops.config, stage1, d.ops.config.OldDatabase, d.ops.config.NewDatabase)
+	if err != nil {
+		return err
+	}
+
+	err = dropPolicies(d.ops.config, stage1)
+	if err != nil {
+		return err
+	}
+
+	// casts go ahead of the types and functions they use, and come back once those are done
+	dropCasts(d.ops.config, stage1)
 
 	// TODO(go,3) should we just always use table deps?
 	if len(d.NewTableDependency) == 0 {
@@ -219,13 +326,13 @@ func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.
 			if err != nil {
 				return fmt.Errorf("while diffing tables: %w", err)
 			}
-			err = diffIndexes(stage1, oldSchema, newSchema)
+			err = diffIndexes(d.ops.config, stage1, oldSchema, newSchema)
 			if err != nil {
 				return err
 			}
 			diffClusters(stage1, oldSchema, newSchema)
 			createConstraints(d.ops.config, stage1, oldSchema, newSchema, sql99.ConstraintTypePrimaryKey)
-			err = diffTriggers(stage1, oldSchema, newSchema)
+			err = diffTriggers(d.ops.config, stage1, oldSchema, newSchema)
 			if err != nil {
 				return err
 			}
@@ -321,7 +428,7 @@ func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.
 			if err != nil {
 				return fmt.Errorf("while diffing table %s.%s: %w", newSchema.Name, newTable.Name, err)
 			}
-			err = diffIndexesTable(stage1, oldSchema, oldTable, newSchema, newTable)
+			err = diffIndexesTable(d.ops.config, stage1, oldSchema, oldTable, newSchema, newTable)
 			if err != nil {
 				return err
 			}
@@ -330,7 +437,7 @@ func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.
 			if err != nil {
 				return err
 			}
-			err = diffTriggersTable(stage1, oldSchema, oldTable, newSchema, newTable)
+			err = diffTriggersTable(d.ops.config, stage1, oldSchema, oldTable, newSchema, newTable)
 			if err != nil {
 				return err
 			}
@@ -354,6 +461,15 @@ func (d *diff) updateStructure(stage1 output.OutputFileSegmenter, stage3 output.
 		}
 	}
 
+	createCasts(d.ops.config, stage1)
+
+	err = createPolicies(d.ops.config, stage1)
+	if err != nil {
+		return err
+	}
+
+	dropExtensions(d.ops.config, stage3)
+
 	return createViewsOrdered(d.ops.config, stage3, d.ops.config.OldDatabase, d.ops.config.NewDatabase)
 }
 
@@ -363,6 +479,10 @@ func (d *diff) updatePermissions(stage1 output.OutputFileSegmenter, stage3 outpu
 
 	newDoc := d.ops.config.NewDatabase
 	oldDoc := d.ops.config.OldDatabase
+	droppedView, err := droppedViews(d.ops.config, oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
 	for _, newSchema := range newDoc.Schemas {
 		oldSchema := oldDoc.TryGetSchemaNamed(newSchema.Name)
 		for _, newGrant := range newSchema.Grants {
@@ -371,7 +491,11 @@ func (d *diff) updatePermissions(stage1 output.OutputFileSegmenter, stage3 outpu
 				if err != nil {
 					return err
 				}
-				stage1.WriteSql(s...)
+				grant, err := grantChanged(d.ops.config, "SCHEMA", newSchema.Name, nil, newGrant)
+				if err != nil {
+					return err
+				}
+				writeChanges(stage1, grant.create(s...)...)
 			}
 		}
 
@@ -392,7 +516,11 @@ func (d *diff) updatePermissions(stage1 output.OutputFileSegmenter, stage3 outpu
 					if err != nil {
 						return err
 					}
-					stage1.WriteSql(s...)
+					grant, err := grantChanged(d.ops.config, "TABLE", newSchema.Name+"."+newTable.Name, nil, newGrant)
+					if err != nil {
+						return err
+					}
+					writeChanges(stage1, grant.create(s...)...)
 				}
 			}
 		}
@@ -405,7 +533,11 @@ func (d *diff) updatePermissions(stage1 output.OutputFileSegmenter, stage3 outpu
 					if err != nil {
 						return err
 					}
-					stage1.WriteSql(s...)
+					grant, err := grantChanged(d.ops.config, "SEQUENCE", newSchema.Name+"."+newSeq.Name, nil, newGrant)
+					if err != nil {
+						return err
+					}
+					writeChanges(stage1, grant.create(s...)...)
 				}
 			}
 		}
@@ -418,25 +550,35 @@ func (d *diff) updatePermissions(stage1 output.OutputFileSegmenter, stage3 outpu
 					if err != nil {
 						return err
 					}
-					stage1.WriteSql(grants...)
+					grant, err := grantChanged(d.ops.config, "FUNCTION", newSchema.Name+"."+newFunc.ShortSig(), nil, newGrant)
+					if err != nil {
+						return err
+					}
+					writeChanges(stage1, grant.create(grants...)...)
 				}
 			}
 		}
 
 		for _, newView := range newSchema.Views {
 			oldView := oldSchema.TryGetViewNamed(newView.Name)
+			recreated := droppedView[viewKey(ir.ViewRef{Schema: newSchema, View: newView})]
 			for _, newGrant := range newView.Grants {
-				if d.ops.config.AlwaysRecreateViews || oldView == nil || !ir.HasPermissionsOf(oldView, newGrant, ir.SqlFormatPgsql8) || !oldView.Equals(newView, ir.SqlFormatPgsql8) {
+				if (d.ops.config.AlwaysRecreateViews && !newView.Materialized) || recreated || oldView == nil || !ir.HasPermissionsOf(oldView, newGrant, ir.SqlFormatPgsql8) || !oldView.Equals(newView, ir.SqlFormatPgsql8) {
 					s, err := getViewGrantSql(d.ops.config, newDoc, newSchema, newView, newGrant)
 					if err != nil {
 						return err
 					}
-					stage3.WriteSql(s...)
+					// views are granted on as tables are
+					grant, err := grantChanged(d.ops.config, "TABLE", newSchema.Name+"."+newView.Name, nil, newGrant)
+					if err != nil {
+						return err
+					}
+					writeChanges(stage3, grant.create(s...)...)
 				}
 			}
 		}
 	}
-	return nil
+	return diffDefaultPrivileges(d.ops.config, stage1)
 }
 
 func (d *diff) updateData(ofs output.OutputFileSegmenter, deleteMode bool) error {
@@ -470,13 +612,13 @@ func (d *diff) updateData(ofs output.OutputFileSegmenter, deleteMode bool) error
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				writeChanges(ofs, rowsChanged(oldSchema.Name, oldTable, newTable).drop(s...)...)
 			} else {
-				s, err := getCreateDataSql(d.ops, oldSchema, oldTable, newSchema, newTable)
+				s, err := getCreateDataChanges(d.ops, oldSchema, oldTable, newSchema, newTable)
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				writeChanges(ofs, s...)
 
 				// HACK: For now, we'll generate foreign key constraints in stage 4 after inserting data
 				// https://github.com/dbsteward/dbsteward/issues/142
@@ -510,8 +652,42 @@ func (d *diff) CreateSchemaSQL(s *ir.Schema) ([]output.ToSql, error) {
 }
 
 func (diff *diff) DiffDoc(oldFile, newFile string, oldDoc, newDoc *ir.Definition, upgradePrefix string) error {
+	return diff.diffDocToFiles(oldFile, newFile, oldDoc, newDoc, upgradePrefix, "upgrade")
+}
+
+// DiffDowngrade writes the stage files reverting newDoc back to oldDoc. Steps which can't restore
+// what the upgrade changed, such as dropping columns, are called out with warnings.
+func (diff *diff) DiffDowngrade(oldFile, newFile string, oldDoc, newDoc *ir.Definition, downgradePrefix string) error {
+	return diff.diffDocToFiles(newFile, oldFile, newDoc, oldDoc, downgradePrefix, "downgrade")
+}
+
+func (diff *diff) diffDocToFiles(oldFile, newFile string, oldDoc, newDoc *ir.Definition, upgradePrefix string, direction string) error {
+	diff.ops.config.OldDatabase = oldDoc
+	diff.ops.config.NewDatabase = newDoc
+
+	recorders := newChangeRecorders()
+	var lintErr error
+	err := diff.DiffDocWork(recorders[0], recorders[1], recorders[2], recorders[3])
+	if err != nil {
+		return err
+	}
+	if direction == "downgrade" {
+		// reverting an upgrade is expected to drop what it added, so the safe mode guard doesn't apply
+		diff.ops.warnIrreversible(recorders)
+	} else {
+		err = diff.ops.guardDestructive(recorders, newDoc, oldFile)
+		if err != nil {
+			return err
+		}
+		// lint failures are returned after the stage files are written, so they can be reviewed
+		lintErr = diff.ops.lintUpgrade(recorders, oldDoc, newDoc)
+	}
 	timestamp := time.Now().Format(time.RFC1123Z)
 	oldSetNewSet := fmt.Sprintf("-- Old definition: %s\n-- New definition %s\n", oldFile, newFile)
+	stageKind := ""
+	if direction != "upgrade" {
+		stageKind = " " + direction
+	}
 
 	var stage1, stage2, stage3, stage4 output.OutputFileSegmenter
 	quoter := diff.Quoter()
@@ -524,30 +700,65 @@ func (diff *diff) DiffDoc(oldFile, newFile string, oldDoc, newDoc *ir.Definition
 		}
 
 		stage1 = output.NewOutputFileSegmenterToFile(logger, quoter, fileName, 1, file, fileName, diff.ops.config.OutputFileStatementLimit)
-		stage1.SetHeader(sql.NewComment("DBsteward single stage upgrade changes - generated %s\n%s", timestamp, oldSetNewSet))
+		stage1.SetHeader(sql.NewComment("DBsteward single stage %s changes - generated %s\n%s", direction, timestamp, oldSetNewSet))
 		defer stage1.Close()
 		stage2 = stage1
 		stage3 = stage1
 		stage4 = stage1
 	} else {
 		stage1 = output.NewOutputFileSegmenter(logger, quoter, upgradePrefix+"_stage1_schema", 1, diff.ops.config.OutputFileStatementLimit)
-		stage1.SetHeader(sql.NewComment("DBSteward stage 1 structure additions and modifications - generated %s\n%s", timestamp, oldSetNewSet))
+		stage1.SetHeader(sql.NewComment("DBSteward%s stage 1 structure additions and modifications - generated %s\n%s", stageKind, timestamp, oldSetNewSet))
 		defer stage1.Close()
 		stage2 = output.NewOutputFileSegmenter(logger, quoter, upgradePrefix+"_stage2_data", 1, diff.ops.config.OutputFileStatementLimit)
-		stage2.SetHeader(sql.NewComment("DBSteward stage 2 data definitions removed - generated %s\n%s", timestamp, oldSetNewSet))
+		stage2.SetHeader(sql.NewComment("DBSteward%s stage 2 data definitions removed - generated %s\n%s", stageKind, timestamp, oldSetNewSet))
 		defer stage2.Close()
 		stage3 = output.NewOutputFileSegmenter(logger, quoter, upgradePrefix+"_stage3_schema", 1, diff.ops.config.OutputFileStatementLimit)
-		stage3.SetHeader(sql.NewComment("DBSteward stage 3 structure changes, constraints, and removals - generated %s\n%s", timestamp, oldSetNewSet))
+		stage3.SetHeader(sql.NewComment("DBSteward%s stage 3 structure changes, constraints, and removals - generated %s\n%s", stageKind, timestamp, oldSetNewSet))
 		defer stage3.Close()
 		stage4 = output.NewOutputFileSegmenter(logger, quoter, upgradePrefix+"_stage4_data", 1, diff.ops.config.OutputFileStatementLimit)
-		stage4.SetHeader(sql.NewComment("DBSteward stage 4 data definition changes and additions - generated %s\n%s", timestamp, oldSetNewSet))
+		stage4.SetHeader(sql.NewComment("DBSteward%s stage 4 data definition changes and additions - generated %s\n%s", stageKind, timestamp, oldSetNewSet))
 		defer stage4.Close()
 	}
 
-	diff.ops.config.OldDatabase = oldDoc
-	diff.ops.config.NewDatabase = newDoc
-
-	return diff.DiffDocWork(stage1, stage2, stage3, stage4)
+	err = renderChanges(recorders, stage1, stage2, stage3, stage4)
+	if err != nil {
+		return err
+	}
+	// statements which can't run inside a transaction go in their own file, to run once the stage has committed
+	writeConcurrent := func(suffix, after string, changes []*output.Change) error {
+		if len(changes) == 0 {
+			return nil
+		}
+		concurrent := output.NewOutputFileSegmenter(logger, quoter, upgradePrefix+suffix, 1, diff.ops.config.OutputFileStatementLimit)
+		concurrent.SetHeader(sql.NewComment("DBSteward%s concurrent changes, run outside of a transaction once %s has committed - generated %s\n%s", stageKind, after, timestamp, oldSetNewSet))
+		concurrent.AppendHeader(output.NewRawSQL("\n"))
+		if err := output.WriteChanges(concurrent, changes); err != nil {
+			return err
+		}
+		return concurrent.Close()
+	}
+	if diff.ops.config.SingleStageUpgrade {
+		changes := []*output.Change{}
+		for _, recorder := range recorders {
+			changes = append(changes, recorder.concurrent...)
+		}
+		err = writeConcurrent("_single_stage_concurrent", "the single stage upgrade", changes)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, recorder := range recorders {
+			err = writeConcurrent(fmt.Sprintf("_stage%d_concurrent", recorder.stage), fmt.Sprintf("stage %d", recorder.stage), recorder.concurrent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = diff.ops.writePlan(upgradePrefix+"_plan.json", recorders, upgradeStageDescriptions)
+	if err != nil {
+		return err
+	}
+	return lintErr
 }
 
 func (diff *diff) DropOldSchemas(ofs output.OutputFileSegmenter) {
@@ -555,7 +766,11 @@ func (diff *diff) DropOldSchemas(ofs output.OutputFileSegmenter) {
 	for _, oldSchema := range diff.ops.config.OldDatabase.Schemas {
 		if diff.ops.config.NewDatabase.TryGetSchemaNamed(oldSchema.Name) == nil {
 			diff.ops.config.Logger.Info(fmt.Sprintf("Drop old schema: %s", oldSchema.Name))
-			ofs.MustWriteSql(diff.DropSchemaSQL(oldSchema))
+			s, err := diff.DropSchemaSQL(oldSchema)
+			if err != nil {
+				panic(err)
+			}
+			writeChanges(ofs, schemaChanged(oldSchema, nil).drop(s...)...)
 		}
 	}
 }
@@ -565,7 +780,11 @@ func (diff *diff) CreateNewSchemas(ofs output.OutputFileSegmenter) error {
 	for _, newSchema := range diff.ops.config.NewDatabase.Schemas {
 		if diff.ops.config.OldDatabase.TryGetSchemaNamed(newSchema.Name) == nil {
 			diff.ops.config.Logger.Info(fmt.Sprintf("Create new schema: %s", newSchema.Name))
-			ofs.MustWriteSql(diff.CreateSchemaSQL(newSchema))
+			s, err := diff.CreateSchemaSQL(newSchema)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, schemaChanged(nil, newSchema).create(s...)...)
 		}
 	}
 	return nil
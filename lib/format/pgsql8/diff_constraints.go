@@ -36,9 +36,12 @@ func createConstraintsTable(conf lib.Config, ofs output.OutputFileSegmenter, old
 			// rewrite the constraint definer to refer to the new table
 			// so the constraint by the old, but part of the new table
 			// will be referenced properly in the drop statement
+			oldNode := constraintNode(constraint)
 			constraint.Schema = newSchema
 			constraint.Table = newTable
-			ofs.WriteSql(getTableConstraintDropSql(constraint)...)
+			dropped := constraintChanged(constraint, nil)
+			dropped.old = oldNode
+			writeChanges(ofs, dropped.drop(getTableConstraintDropSql(constraint)...)...)
 		}
 
 		// add all still-defined constraints back and any new ones to the table
@@ -47,7 +50,7 @@ func createConstraintsTable(conf lib.Config, ofs output.OutputFileSegmenter, old
 			return err
 		}
 		for _, constraint := range constraints {
-			ofs.WriteSql(getTableContraintCreationSql(constraint)...)
+			writeChanges(ofs, constraintChanged(nil, constraint).create(getTableContraintCreationSql(constraint)...)...)
 		}
 
 		return nil
@@ -57,7 +60,7 @@ func createConstraintsTable(conf lib.Config, ofs output.OutputFileSegmenter, old
 		return err
 	}
 	for _, constraint := range constraints {
-		ofs.WriteSql(getTableContraintCreationSql(constraint)...)
+		writeChanges(ofs, constraintChanged(nil, constraint).create(getTableContraintCreationSql(constraint)...)...)
 	}
 	return nil
 }
@@ -83,7 +86,7 @@ func dropConstraintsTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSc
 		return err
 	}
 	for _, constraint := range constraints {
-		ofs.WriteSql(getTableConstraintDropSql(constraint)...)
+		writeChanges(ofs, constraintChanged(constraint, nil).drop(getTableConstraintDropSql(constraint)...)...)
 	}
 	return nil
 }
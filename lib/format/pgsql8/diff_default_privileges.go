@@ -0,0 +1,59 @@
+package pgsql8
+
+import (
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// diffDefaultPrivileges revokes default privileges which are no longer given, then grants the new ones.
+// Unlike grants on objects, default privileges are never left behind, as they can't be seen on any object
+// and would otherwise keep applying to everything created from then on.
+func diffDefaultPrivileges(conf lib.Config, ofs output.OutputFileSegmenter) error {
+	newDoc := conf.NewDatabase
+	oldDoc := conf.OldDatabase
+
+	for _, oldDP := range oldDoc.DefaultPrivileges {
+		newDP := newDoc.TryGetDefaultPrivilegesMatching(oldDP)
+		revoked := map[string]bool{}
+		for _, oldGrant := range oldDP.Grants {
+			for _, role := range oldGrant.Roles {
+				if revoked[role] {
+					continue
+				}
+				revoked[role] = true
+				perms := revokedDefaultPrivileges(oldDP, newDP, role)
+				if len(perms) == 0 {
+					continue
+				}
+				s, err := getDefaultPrivilegesRevokeSql(conf, oldDP, []string{role}, perms)
+				if err != nil {
+					return err
+				}
+				revoke, err := defaultPrivilegesChanged(conf, oldDP, newDP)
+				if err != nil {
+					return err
+				}
+				writeChanges(ofs, revoke.drop(s...)...)
+			}
+		}
+	}
+
+	for _, newDP := range newDoc.DefaultPrivileges {
+		oldDP := oldDoc.TryGetDefaultPrivilegesMatching(newDP)
+		for _, newGrant := range newDP.Grants {
+			if oldDP == nil || !ir.HasPermissionsOf(oldDP, newGrant, ir.SqlFormatPgsql8) {
+				s, err := getDefaultPrivilegesGrantSql(conf, newDP, newGrant)
+				if err != nil {
+					return err
+				}
+				grant, err := defaultPrivilegesChanged(conf, oldDP, newDP)
+				if err != nil {
+					return err
+				}
+				writeChanges(ofs, grant.create(s...)...)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDefaultPrivileges(t *testing.T) {
+	roles := &ir.Database{
+		Roles: &ir.RoleAssignment{Application: "app", Owner: "app", ReadOnly: "reader"},
+	}
+	tests := []struct {
+		name     string
+		old      []*ir.DefaultPrivileges
+		new      []*ir.DefaultPrivileges
+		expected []output.ToSql
+	}{
+		{
+			name: "new grants are given",
+			new: []*ir.DefaultPrivileges{
+				{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionSelect, ir.PermissionInsert}},
+				}},
+				{Role: ir.RoleOwner, ObjectType: ir.DefaultPrivilegesFunctions, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleReadOnly}, Permissions: []string{ir.PermissionExecute}, With: ir.PermOptionGrant},
+				}},
+			},
+			expected: []output.ToSql{
+				&sql.DefaultPrivilegesGrant{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT", "INSERT"}, Roles: []string{"app"}},
+				&sql.DefaultPrivilegesGrant{Role: "app", ObjectType: "FUNCTIONS", Perms: []string{"EXECUTE"}, Roles: []string{"reader"}, CanGrant: true},
+			},
+		},
+		{
+			name: "unchanged grants are left alone, removed ones are revoked before anything is granted",
+			old: []*ir.DefaultPrivileges{
+				{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication, ir.RoleReadOnly}, Permissions: []string{ir.PermissionSelect, ir.PermissionInsert}},
+				}},
+			},
+			new: []*ir.DefaultPrivileges{
+				{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionSelect, ir.PermissionInsert, ir.PermissionUpdate}},
+					{Roles: []string{ir.RoleReadOnly}, Permissions: []string{ir.PermissionSelect}},
+				}},
+			},
+			expected: []output.ToSql{
+				&sql.DefaultPrivilegesRevoke{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"INSERT"}, Roles: []string{"reader"}},
+				&sql.DefaultPrivilegesGrant{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT", "INSERT", "UPDATE"}, Roles: []string{"app"}},
+			},
+		},
+		{
+			name: "dropped default privileges are revoked entirely, and ALL is spelled out when only part of it goes",
+			old: []*ir.DefaultPrivileges{
+				{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionAll}},
+				}},
+				{Role: ir.RoleOwner, ObjectType: ir.DefaultPrivilegesSequences, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionUsage}},
+				}},
+			},
+			new: []*ir.DefaultPrivileges{
+				{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+					{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionSelect}},
+				}},
+			},
+			expected: []output.ToSql{
+				&sql.DefaultPrivilegesRevoke{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}, Roles: []string{"app"}},
+				&sql.DefaultPrivilegesRevoke{Role: "app", ObjectType: "SEQUENCES", Perms: []string{"USAGE"}, Roles: []string{"app"}},
+				// ALL doesn't count as having SELECT, though granting it again is harmless
+				&sql.DefaultPrivilegesGrant{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT"}, Roles: []string{"app"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := DefaultConfig
+			conf.OldDatabase = &ir.Definition{Database: roles, DefaultPrivileges: tt.old}
+			conf.NewDatabase = &ir.Definition{Database: roles, DefaultPrivileges: tt.new}
+			recorder := newBuildChangeRecorder()
+			err := diffDefaultPrivileges(conf, recorder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected, changeStatements(recorder.changes))
+		})
+	}
+}
+
+func TestDiffDefaultPrivileges_Changes(t *testing.T) {
+	roles := &ir.Database{
+		Roles: &ir.RoleAssignment{Application: "app", Owner: "app", ReadOnly: "reader"},
+	}
+	oldDP := &ir.DefaultPrivileges{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+		{Roles: []string{ir.RoleApplication, ir.RoleReadOnly}, Permissions: []string{ir.PermissionSelect}},
+	}}
+	newDP := &ir.DefaultPrivileges{Role: ir.RoleOwner, Schema: "public", ObjectType: ir.DefaultPrivilegesTables, Grants: []*ir.Grant{
+		{Roles: []string{ir.RoleApplication}, Permissions: []string{ir.PermissionSelect, ir.PermissionInsert}},
+	}}
+
+	conf := DefaultConfig
+	conf.OldDatabase = &ir.Definition{Database: roles, DefaultPrivileges: []*ir.DefaultPrivileges{oldDP}}
+	conf.NewDatabase = &ir.Definition{Database: roles, DefaultPrivileges: []*ir.DefaultPrivileges{newDP}}
+	recorder := newBuildChangeRecorder()
+	err := diffDefaultPrivileges(conf, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// default privileges are named after the role granting them and the objects they apply to
+	assert.Equal(t, []*output.Change{
+		{
+			Kind:      "default privileges",
+			Identity:  output.ChangeIdentity{Schema: "public", Parent: "app", Name: "tables"},
+			Action:    output.ChangeDrop,
+			Old:       oldDP,
+			Stage:     1,
+			Statement: &sql.DefaultPrivilegesRevoke{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT"}, Roles: []string{"reader"}},
+		},
+		{
+			Kind:      "default privileges",
+			Identity:  output.ChangeIdentity{Schema: "public", Parent: "app", Name: "tables"},
+			Action:    output.ChangeCreate,
+			New:       newDP,
+			Stage:     1,
+			Statement: &sql.DefaultPrivilegesGrant{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT", "INSERT"}, Roles: []string{"app"}},
+		},
+	}, output.FilterChanges(recorder.changes, (*output.Change).IsObject))
+}
+
+func TestBuild_DefaultPrivileges(t *testing.T) {
+	doc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{Owner: "app", ReadOnly: "reader"},
+		},
+		DefaultPrivileges: []*ir.DefaultPrivileges{{
+			Role:       ir.RoleOwner,
+			Schema:     "public",
+			ObjectType: ir.DefaultPrivilegesTables,
+			Grants:     []*ir.Grant{{Roles: []string{ir.RoleReadOnly}, Permissions: []string{ir.PermissionSelect}}},
+		}},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "reports",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(ofs, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := sql.TableRef{Schema: "public", Table: "reports"}
+	// default privileges are in place before tables are created
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.DefaultPrivilegesGrant{Role: "app", Schema: "public", ObjectType: "TABLES", Perms: []string{"SELECT"}, Roles: []string{"reader"}},
+		&sql.TableCreate{
+			Table:        reports,
+			Columns:      []sql.ColumnDefinition{{Name: "id", Type: sql.TypeRef{Type: "integer"}}},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "reports", Column: "id"}},
+		&sql.ConstraintCreatePrimaryKey{Table: reports, Constraint: "reports_pkey", Columns: []string{"id"}},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+
+	doc.DefaultPrivileges[0].Grants[0].Permissions = []string{ir.PermissionExecute}
+	err = ops.build(output.NewAnnotationStrippingSegmenter(ops.GetQuoter()), doc)
+	assert.ErrorContains(t, err, "invalid permissions on default privileges on tables for role ROLE_OWNER in schema public grant: [EXECUTE]")
+
+	schemas := &ir.Definition{DefaultPrivileges: []*ir.DefaultPrivileges{{Role: "owner", ObjectType: ir.DefaultPrivilegesSchemas}}}
+	assert.NoError(t, checkDefaultPrivileges(schemas, NewVersionNum(10, 0)))
+	assert.ErrorContains(t, checkDefaultPrivileges(schemas, NewVersionNum(9, 6)), "default privileges on schemas for role owner need a target version of at least 10")
+}
@@ -0,0 +1,45 @@
+package pgsql8
+
+import (
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// createExtensions creates new extensions and brings existing ones up to their new version and schema.
+// It runs before any types, functions or tables are changed, since those may depend on what the extensions provide.
+func createExtensions(conf lib.Config, ofs output.OutputFileSegmenter) {
+	newDoc := conf.NewDatabase
+	oldDoc := conf.OldDatabase
+
+	for _, newExt := range newDoc.Extensions {
+		oldExt := oldDoc.TryGetExtensionNamed(newExt.Name)
+		if oldExt == nil {
+			writeChanges(ofs, extensionChanged(nil, newExt).create(getCreateExtensionSql(newExt)...)...)
+			continue
+		}
+		// an empty version means whichever is installed, so it isn't updated
+		if newExt.Version != "" && oldExt.Version != newExt.Version {
+			writeChanges(ofs, extensionChanged(oldExt, newExt).alter(&sql.ExtensionUpdate{Extension: newExt.Name, Version: newExt.Version})...)
+		}
+		// an empty schema means wherever it was created, so it isn't moved
+		if newExt.Schema != "" && oldExt.Schema != newExt.Schema {
+			writeChanges(ofs, extensionChanged(oldExt, newExt).alter(&sql.ExtensionSetSchema{Extension: newExt.Name, Schema: newExt.Schema})...)
+		}
+	}
+}
+
+// dropExtensions drops extensions which are no longer defined, once everything that used them has been dropped
+func dropExtensions(conf lib.Config, ofs output.OutputFileSegmenter) {
+	newDoc := conf.NewDatabase
+	oldDoc := conf.OldDatabase
+
+	if oldDoc == nil {
+		return
+	}
+	for _, oldExt := range oldDoc.Extensions {
+		if newDoc.TryGetExtensionNamed(oldExt.Name) == nil {
+			writeChanges(ofs, extensionChanged(oldExt, nil).drop(getDropExtensionSql(oldExt)...)...)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffExtensions(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Extensions: []*ir.Extension{
+			{Name: "hstore", Schema: "public", Version: "1.7"},
+			{Name: "pg_trgm", Schema: "public"},
+			{Name: "unaccent"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "documents",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Extensions: []*ir.Extension{
+			{Name: "hstore", Schema: "public", Version: "1.8"},
+			{Name: "pg_trgm", Schema: "util"},
+			{Name: "citext", Version: "1.6", Cascade: true},
+		},
+		Schemas: []*ir.Schema{
+			{
+				Name: "public",
+				Tables: []*ir.Table{{
+					Name:       "documents",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "tags", Type: "hstore", Nullable: true},
+						{Name: "author", Type: "citext", Nullable: true},
+					},
+				}},
+			},
+			{Name: "util"},
+		},
+	}
+
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	// extensions are brought up to date after new schemas are created, and before the columns which use them
+	assert.Equal(t, []output.ToSql{
+		&sql.SchemaCreate{Schema: "util"},
+		&sql.ExtensionUpdate{Extension: "hstore", Version: "1.8"},
+		&sql.ExtensionSetSchema{Extension: "pg_trgm", Schema: "util"},
+		&sql.ExtensionCreate{Extension: "citext", Version: "1.6", Cascade: true},
+		&sql.TableAlterParts{
+			Table: sql.TableRef{Schema: "public", Table: "documents"},
+			Parts: []sql.TableAlterPart{
+				&sql.TableAlterPartColumnCreate{ColumnDef: sql.ColumnDefinition{Name: "tags", Type: sql.TypeRef{Type: "hstore"}}},
+				&sql.TableAlterPartColumnCreate{ColumnDef: sql.ColumnDefinition{Name: "author", Type: sql.TypeRef{Type: "citext"}}},
+			},
+		},
+	}, changeStatements(recorders[0].changes))
+	assert.Equal(t, []*output.Change{{
+		Kind:      "extension",
+		Identity:  output.ChangeIdentity{Name: "unaccent"},
+		Action:    output.ChangeDrop,
+		Old:       oldDoc.Extensions[2],
+		Stage:     3,
+		Statement: &sql.ExtensionDrop{Extension: "unaccent"},
+	}}, output.FilterChanges(recorders[2].changes, (*output.Change).IsObject))
+
+	// a version which is no longer given is left at whichever is installed, such as the one extracted from the database
+	newDoc.Extensions[0].Version = ""
+	recorders = diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	assert.NotContains(t, changeStatements(recorders[0].changes), &sql.ExtensionUpdate{Extension: "hstore", Version: "1.8"})
+	assert.NotContains(t, changeStatements(recorders[0].changes), &sql.ExtensionUpdate{Extension: "hstore"})
+}
+
+func TestBuild_Extensions(t *testing.T) {
+	doc := &ir.Definition{
+		Extensions: []*ir.Extension{{Name: "hstore", Schema: "public"}},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "documents",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "tags", Type: "hstore", Nullable: true},
+				},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(ofs, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents := sql.TableRef{Schema: "public", Table: "documents"}
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.ExtensionCreate{Extension: "hstore", Schema: "public"},
+		&sql.TableCreate{
+			Table: documents,
+			Columns: []sql.ColumnDefinition{
+				{Name: "id", Type: sql.TypeRef{Type: "integer"}},
+				{Name: "tags", Type: sql.TypeRef{Type: "hstore"}},
+			},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "documents", Column: "id"}},
+		&sql.ConstraintCreatePrimaryKey{Table: documents, Constraint: "documents_pkey", Columns: []string{"id"}},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+
+	assert.Equal(t, &sql.ExtensionCreate{Extension: "hstore", Schema: "public", IfNotExists: true}, guardCreate(getCreateExtensionSql(doc.Extensions[0])[0], false))
+
+	assert.NoError(t, checkExtensions(doc, NewVersionNum(9, 1)))
+	assert.ErrorContains(t, checkExtensions(doc, NewVersionNum(9, 0)), "extension hstore needs a target version of at least 9.1")
+	doc.Extensions[0].Cascade = true
+	assert.ErrorContains(t, checkExtensions(doc, NewVersionNum(9, 5)), "extension hstore is created with cascade, which needs a target version of at least 9.6")
+}
@@ -1,6 +1,8 @@
 package pgsql8
 
 import (
+	"slices"
+
 	"github.com/dbsteward/dbsteward/lib"
 	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
 	"github.com/dbsteward/dbsteward/lib/ir"
@@ -12,27 +14,32 @@ func diffFunctions(conf lib.Config, stage1 output.OutputFileSegmenter, stage3 ou
 	if oldSchema != nil {
 		for _, oldFunction := range oldSchema.Functions {
 			if newSchema.TryGetFunctionMatching(oldFunction) == nil {
-				stage3.WriteSql(getFunctionDropSql(oldSchema, oldFunction)...)
+				writeChanges(stage3, functionChanged(oldSchema.Name, oldFunction, nil).drop(getFunctionDropSql(oldSchema, oldFunction)...)...)
 			}
 		}
 	}
 
 	// add new functions and replace modified functions
+	createdWithTypes := typeSupportFunctionsCreated(oldSchema, newSchema)
 	for _, newFunction := range newSchema.Functions {
 		oldFunction := oldSchema.TryGetFunctionMatching(newFunction)
+		if oldFunction == nil && slices.Contains(createdWithTypes, newFunction) {
+			continue
+		}
 		if oldFunction == nil || !oldFunction.Equals(newFunction, ir.SqlFormatPgsql8) {
 			create, err := getFunctionCreationSql(conf, newSchema, newFunction)
 			if err != nil {
 				return nil
 			}
-			stage1.WriteSql(create...)
+			// functions are replaced in place, so are only created if they didn't exist
+			writeChanges(stage1, functionChanged(newSchema.Name, oldFunction, newFunction).createOrAlter(create...)...)
 		} else if newFunction.ForceRedefine {
 			stage1.WriteSql(sql.NewComment("Function %s.%s has forceRedefine set to true", newSchema.Name, newFunction.Name))
 			create, err := getFunctionCreationSql(conf, newSchema, newFunction)
 			if err != nil {
 				return nil
 			}
-			stage1.WriteSql(create...)
+			writeChanges(stage1, functionChanged(newSchema.Name, oldFunction, newFunction).alter(create...)...)
 		} else {
 			oldReturnType := oldSchema.TryGetTypeNamed(newFunction.Returns)
 			newReturnType := newSchema.TryGetTypeNamed(newFunction.Returns)
@@ -42,7 +49,7 @@ func diffFunctions(conf lib.Config, stage1 output.OutputFileSegmenter, stage3 ou
 				if err != nil {
 					return nil
 				}
-				stage1.WriteSql(create...)
+				writeChanges(stage1, functionChanged(newSchema.Name, oldFunction, newFunction).alter(create...)...)
 			}
 		}
 	}
@@ -3,18 +3,20 @@ package pgsql8
 import (
 	"fmt"
 
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
 	"github.com/dbsteward/dbsteward/lib/ir"
 	"github.com/dbsteward/dbsteward/lib/output"
 )
 
-func diffIndexes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
+func diffIndexes(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
 	for _, newTable := range newSchema.Tables {
 		var oldTable *ir.Table
 		if oldSchema != nil {
 			// TODO(feat) what about renames?
 			oldTable = oldSchema.TryGetTableNamed(newTable.Name)
 		}
-		err := diffIndexesTable(ofs, oldSchema, oldTable, newSchema, newTable)
+		err := diffIndexesTable(conf, ofs, oldSchema, oldTable, newSchema, newTable)
 		if err != nil {
 			return err
 		}
@@ -22,14 +24,36 @@ func diffIndexes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema
 	return nil
 }
 
-func diffIndexesTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+// diffIndexesTable drops and creates the indexes of the table which changed. Indexes marked concurrently are built
+// and dropped without blocking writes to tables which already exist, after ofs commits, when ofs and the target version support that.
+func diffIndexesTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+	// indexes on new tables are built in the same transaction as the table, while it's still empty
+	cw, canDefer := ofs.(concurrentWriter)
+	canDefer = canDefer && oldTable != nil
+	canDeferDrop := canDefer && !targetOlderThan(conf, FEAT_DROP_INDEX_CONCURRENTLY)
+	// a concurrent build which failed has to be retried with IF NOT EXISTS
+	canDeferCreate := canDefer && !targetOlderThan(conf, FEAT_CREATE_INDEX_IF_NOT_EXISTS)
+
 	oldIndexes, err := getOldIndexes(oldSchema, oldTable, newSchema, newTable)
 	if err != nil {
 		return err
 	}
 	for _, oldIndex := range oldIndexes {
+		newIndex, err := tryGetTableIndexNamed(newSchema, newTable, oldIndex.Name)
+		if err != nil {
+			return err
+		}
+		dropped := indexChanged(oldSchema.Name, oldIndex, nil)
+		// an index of the same name built in the transaction has to be preceded by the drop
+		if canDeferDrop && oldIndex.Concurrently && (newIndex == nil || (canDeferCreate && newIndex.Concurrently)) {
+			err = cw.WriteConcurrentChanges(dropped.drop(getDropIndexConcurrentlySql(oldSchema, oldIndex)...)...)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		// TODO(go,pgsql) old code used new schema/table instead of old, but I believe that is incorrect. need to verify this behavior change
-		ofs.WriteSql(getDropIndexSql(oldSchema, oldIndex)...)
+		writeChanges(ofs, dropped.drop(getDropIndexSql(oldSchema, oldIndex)...)...)
 	}
 
 	// TODO(go,pgsql) old code used a different codepath if oldSchema = nil; need to verify this behavior change
@@ -38,7 +62,16 @@ func diffIndexesTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldT
 		return err
 	}
 	for _, newIndex := range newIndexes {
-		ofs.WriteSql(getCreateIndexSql(newSchema, newTable, newIndex)...)
+		create := getCreateIndexSql(newSchema, newTable, newIndex)
+		created := indexChanged(newSchema.Name, nil, newIndex)
+		if canDeferCreate && newIndex.Concurrently {
+			err = cw.WriteConcurrentChanges(created.create(getCreateIndexConcurrentlySql(create[0].(*sql.IndexCreate))...)...)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		writeChanges(ofs, created.create(create...)...)
 	}
 	return nil
 }
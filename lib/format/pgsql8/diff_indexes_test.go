@@ -0,0 +1,128 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffIndexes_Concurrently(t *testing.T) {
+	oldSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{{
+			Name: "users",
+			Columns: []*ir.Column{
+				{Name: "id", Type: "integer"},
+				{Name: "nick", Type: "text"},
+			},
+			Indexes: []*ir.Index{
+				{Name: "users_gone_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}},
+				{Name: "users_changed_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "id_1", Value: "id"}}},
+				{Name: "users_toggled_idx", Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}},
+			},
+		}},
+	}
+	newSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{
+			{
+				Name: "users",
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+				Indexes: []*ir.Index{
+					{Name: "users_changed_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}},
+					{Name: "users_toggled_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}},
+					{Name: "users_nick_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}},
+				},
+			},
+			{
+				Name:    "logins",
+				Columns: []*ir.Column{{Name: "at", Type: "timestamp"}},
+				Indexes: []*ir.Index{{Name: "logins_at_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "at_1", Value: "at"}}}},
+			},
+		},
+	}
+
+	recorder := newBuildChangeRecorder()
+	err := diffIndexes(DefaultConfig, recorder, oldSchema, newSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := sql.TableRef{Schema: "public", Table: "users"}
+
+	// the new table is empty and created in the same transaction
+	assert.Equal(t, []output.ToSql{
+		&sql.IndexCreate{
+			Table:      sql.TableRef{Schema: "public", Table: "logins"},
+			Index:      "logins_at_idx",
+			Dimensions: []sql.Quotable{&sql.QuoteObject{Ident: "at"}},
+		},
+	}, changeStatements(recorder.changes))
+	// toggling concurrently alone doesn't rebuild the index
+	assert.Equal(t, []output.ToSql{
+		&sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "users_gone_idx"}, Concurrently: true, IfExists: true},
+		&sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "users_changed_idx"}, Concurrently: true, IfExists: true},
+		&sql.IndexDropInvalid{Index: sql.IndexRef{Schema: "public", Index: "users_changed_idx"}},
+		&sql.IndexCreate{
+			Table:        users,
+			Index:        "users_changed_idx",
+			Concurrently: true,
+			IfNotExists:  true,
+			Dimensions:   []sql.Quotable{&sql.QuoteObject{Ident: "nick"}},
+		},
+		&sql.IndexDropInvalid{Index: sql.IndexRef{Schema: "public", Index: "users_nick_idx"}},
+		&sql.IndexCreate{
+			Table:        users,
+			Index:        "users_nick_idx",
+			Concurrently: true,
+			IfNotExists:  true,
+			Dimensions:   []sql.Quotable{&sql.QuoteObject{Ident: "nick"}},
+		},
+	}, changeStatements(recorder.concurrent))
+}
+
+func TestDiffIndexes_ConcurrentlyReplacedInTransaction(t *testing.T) {
+	oldSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{{
+			Name: "users",
+			Columns: []*ir.Column{
+				{Name: "id", Type: "integer"},
+				{Name: "nick", Type: "text"},
+			},
+			Indexes: []*ir.Index{{Name: "users_lookup_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "id_1", Value: "id"}}}},
+		}},
+	}
+	newSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{{
+			Name: "users",
+			Columns: []*ir.Column{
+				{Name: "id", Type: "integer"},
+				{Name: "nick", Type: "text"},
+			},
+			Indexes: []*ir.Index{{Name: "users_lookup_idx", Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}}},
+		}},
+	}
+
+	recorder := newBuildChangeRecorder()
+	err := diffIndexes(DefaultConfig, recorder, oldSchema, newSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the new index is built in the transaction, so the old one has to be dropped before it
+	assert.Empty(t, recorder.concurrent)
+	assert.Equal(t, []output.ToSql{
+		&sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "users_lookup_idx"}},
+		&sql.IndexCreate{
+			Table:      sql.TableRef{Schema: "public", Table: "users"},
+			Index:      "users_lookup_idx",
+			Dimensions: []sql.Quotable{&sql.QuoteObject{Ident: "nick"}},
+		},
+	}, changeStatements(recorder.changes))
+}
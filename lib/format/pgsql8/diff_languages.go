@@ -23,7 +23,7 @@ func dropLanguages(conf lib.Config, ofs output.OutputFileSegmenter) {
 		for _, oldLang := range oldDoc.Languages {
 			newLang := newDoc.TryGetLanguageNamed(oldLang.Name)
 			if newLang == nil || !oldLang.Equals(newLang) {
-				ofs.WriteSql(getDropLanguageSql(oldLang)...)
+				writeChanges(ofs, languageChanged(oldLang, nil).drop(getDropLanguageSql(oldLang)...)...)
 			}
 		}
 	}
@@ -41,7 +41,7 @@ func createLanguages(conf lib.Config, ofs output.OutputFileSegmenter) error {
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, languageChanged(nil, newLang).create(s...)...)
 		}
 	}
 	return nil
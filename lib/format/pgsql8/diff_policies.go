@@ -0,0 +1,77 @@
+package pgsql8
+
+import (
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// dropPolicies drops the policies which were removed, or changed in a way they can't be altered.
+// Policies refer to columns and functions, so this happens before those change underneath them.
+func dropPolicies(conf lib.Config, ofs output.OutputFileSegmenter) error {
+	return eachPolicyTable(conf, func(oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+		dropPoliciesTable(ofs, oldSchema, oldTable, newTable)
+		return nil
+	})
+}
+
+// createPolicies creates and alters policies once the tables, columns and functions they refer to are in place,
+// then switches row level security on or off. Enabling it after the policies exist means no one is locked out in between.
+func createPolicies(conf lib.Config, ofs output.OutputFileSegmenter) error {
+	return eachPolicyTable(conf, func(oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+		return createPoliciesTable(conf, ofs, oldTable, newSchema, newTable)
+	})
+}
+
+// eachPolicyTable calls f with every new table and its old counterpart, following renames.
+// Tables which were dropped take their policies with them, so aren't visited.
+func eachPolicyTable(conf lib.Config, f func(oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error) error {
+	for _, newSchema := range conf.NewDatabase.Schemas {
+		for _, newTable := range newSchema.Tables {
+			oldSchema := conf.OldDatabase.TryGetSchemaNamed(newSchema.Name)
+			oldTable := oldSchema.TryGetTableNamed(newTable.Name)
+			oldSchema, oldTable, err := conf.OldDatabase.NewTableName(oldSchema, oldTable, newSchema, newTable)
+			if err != nil {
+				return err
+			}
+			err = f(oldSchema, oldTable, newSchema, newTable)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dropPoliciesTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newTable *ir.Table) {
+	if oldTable == nil {
+		return
+	}
+	for _, oldPolicy := range oldTable.Policies {
+		newPolicy := newTable.TryGetPolicyNamed(oldPolicy.Name)
+		if newPolicy == nil || policyNeedsRecreate(oldPolicy, newPolicy) {
+			writeChanges(ofs, policyChanged(oldSchema.Name, oldTable.Name, oldPolicy, nil).drop(getDropPolicySql(oldSchema, oldTable, oldPolicy)...)...)
+		}
+	}
+}
+
+func createPoliciesTable(conf lib.Config, ofs output.OutputFileSegmenter, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+	for _, newPolicy := range newTable.Policies {
+		oldPolicy := oldTable.TryGetPolicyNamed(newPolicy.Name)
+		if oldPolicy == nil || policyNeedsRecreate(oldPolicy, newPolicy) {
+			s, err := getCreatePolicySql(conf, newSchema, newTable, newPolicy)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, policyChanged(newSchema.Name, newTable.Name, nil, newPolicy).create(s...)...)
+		} else if !oldPolicy.Equals(newPolicy) {
+			s, err := getAlterPolicySql(conf, newSchema, newTable, oldPolicy, newPolicy)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, policyChanged(newSchema.Name, newTable.Name, oldPolicy, newPolicy).alter(s...)...)
+		}
+	}
+	writeChanges(ofs, tableChanged(newSchema.Name, oldTable, newTable).createOrAlter(getRowLevelSecuritySql(newSchema, oldTable, newTable)...)...)
+	return nil
+}
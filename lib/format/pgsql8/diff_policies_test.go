@@ -0,0 +1,241 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPoliciesTable(t *testing.T) {
+	schema := &ir.Schema{Name: "public"}
+	invoices := sql.TableRef{Schema: "public", Table: "invoices"}
+	tests := []struct {
+		name     string
+		old      *ir.Table
+		new      *ir.Table
+		expected []output.ToSql
+	}{
+		{
+			name: "policies are in place before row level security is switched on",
+			old:  &ir.Table{Name: "invoices"},
+			new: &ir.Table{
+				Name:             "invoices",
+				RowLevelSecurity: true,
+				Policies: []*ir.Policy{{
+					Name:    "tenant_isolation",
+					Command: ir.PolicyCommandAll,
+					Roles:   []string{ir.RoleApplication},
+					Using:   "tenant_id = current_setting('app.tenant')::int",
+				}},
+			},
+			expected: []output.ToSql{
+				&sql.PolicyCreate{
+					Policy: "tenant_isolation",
+					Table:  invoices,
+					Roles:  []string{"app"},
+					Using:  "tenant_id = current_setting('app.tenant')::int",
+				},
+				&sql.TableAlterParts{
+					Table: invoices,
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartRowLevelSecurity{Enabled: true}},
+				},
+			},
+		},
+		{
+			name: "roles and expressions are altered in place",
+			old: &ir.Table{
+				Name:             "invoices",
+				RowLevelSecurity: true,
+				Policies: []*ir.Policy{{
+					Name:    "tenant_isolation",
+					Command: ir.PolicyCommandAll,
+					Roles:   []string{ir.RoleApplication},
+					Using:   "tenant_id = current_setting('app.tenant')::int",
+				}},
+			},
+			new: &ir.Table{
+				Name:                  "invoices",
+				RowLevelSecurity:      true,
+				ForceRowLevelSecurity: true,
+				Policies: []*ir.Policy{{
+					Name:      "tenant_isolation",
+					Command:   ir.PolicyCommandAll,
+					Using:     "tenant_id = current_setting('app.tenant')::int",
+					WithCheck: "tenant_id > 0",
+				}},
+			},
+			expected: []output.ToSql{
+				&sql.PolicyAlter{
+					Policy:    "tenant_isolation",
+					Table:     invoices,
+					Roles:     []string{ir.RolePublic},
+					WithCheck: "tenant_id > 0",
+				},
+				&sql.TableAlterParts{
+					Table: invoices,
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartForceRowLevelSecurity{Forced: true}},
+				},
+			},
+		},
+		{
+			name: "the command can't be altered",
+			old: &ir.Table{
+				Name:             "invoices",
+				RowLevelSecurity: true,
+				Policies:         []*ir.Policy{{Name: "tenant_isolation", Command: ir.PolicyCommandAll, Using: "tenant_id = 1"}},
+			},
+			new: &ir.Table{
+				Name:             "invoices",
+				RowLevelSecurity: true,
+				Policies:         []*ir.Policy{{Name: "tenant_isolation", Command: ir.PolicyCommandSelect, Using: "tenant_id = 1"}},
+			},
+			expected: []output.ToSql{
+				&sql.PolicyDrop{Policy: "tenant_isolation", Table: invoices},
+				&sql.PolicyCreate{Policy: "tenant_isolation", Table: invoices, Command: "SELECT", Roles: []string{}, Using: "tenant_id = 1"},
+			},
+		},
+		{
+			name: "neither can an expression be taken away",
+			old: &ir.Table{
+				Name:     "invoices",
+				Policies: []*ir.Policy{{Name: "tenant_isolation", Command: ir.PolicyCommandAll, Using: "tenant_id = 1", WithCheck: "tenant_id > 0"}},
+			},
+			new: &ir.Table{
+				Name:     "invoices",
+				Policies: []*ir.Policy{{Name: "tenant_isolation", Command: ir.PolicyCommandAll, Using: "tenant_id = 1"}},
+			},
+			expected: []output.ToSql{
+				&sql.PolicyDrop{Policy: "tenant_isolation", Table: invoices},
+				&sql.PolicyCreate{Policy: "tenant_isolation", Table: invoices, Roles: []string{}, Using: "tenant_id = 1"},
+			},
+		},
+		{
+			name: "policies removed along with row level security",
+			old: &ir.Table{
+				Name:                  "invoices",
+				RowLevelSecurity:      true,
+				ForceRowLevelSecurity: true,
+				Policies:              []*ir.Policy{{Name: "tenant_isolation", Command: ir.PolicyCommandAll, Using: "tenant_id = 1"}},
+			},
+			new: &ir.Table{Name: "invoices"},
+			expected: []output.ToSql{
+				&sql.PolicyDrop{Policy: "tenant_isolation", Table: invoices},
+				&sql.TableAlterParts{
+					Table: invoices,
+					Parts: []sql.TableAlterPart{
+						&sql.TableAlterPartRowLevelSecurity{Enabled: false},
+						&sql.TableAlterPartForceRowLevelSecurity{Forced: false},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := DefaultConfig
+			conf.NewDatabase = &ir.Definition{Database: &ir.Database{Roles: &ir.RoleAssignment{Application: "app"}}}
+			recorder := newBuildChangeRecorder()
+			dropPoliciesTable(recorder, schema, tt.old, tt.new)
+			err := createPoliciesTable(conf, recorder, tt.old, schema, tt.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected, changeStatements(recorder.changes))
+			for _, change := range output.FilterChanges(recorder.changes, (*output.Change).IsObject) {
+				if change.Kind == "policy" {
+					assert.Equal(t, output.ChangeIdentity{Schema: "public", Parent: "invoices", Name: "tenant_isolation"}, change.Identity)
+				}
+			}
+		})
+	}
+}
+
+func TestBuild_Policies(t *testing.T) {
+	doc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Functions: []*ir.Function{{
+				Name:        "current_tenant",
+				Returns:     "integer",
+				Definitions: []*ir.FunctionDefinition{{SqlFormat: ir.SqlFormatPgsql8, Language: "sql", Text: "SELECT current_setting('app.tenant')::int"}},
+			}},
+			Tables: []*ir.Table{{
+				Name:       "invoices",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "tenant_id", Type: "integer"},
+				},
+				RowLevelSecurity: true,
+				Policies: []*ir.Policy{
+					{Name: "tenant_isolation", Command: ir.PolicyCommandAll, Using: "tenant_id = public.current_tenant()"},
+					{Name: "no_negatives", Command: ir.PolicyCommandInsert, WithCheck: "id > 0", Restrictive: true},
+				},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(ofs, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoices := sql.TableRef{Schema: "public", Table: "invoices"}
+	// policies come after the functions they use, and row level security after the policies
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.TableCreate{
+			Table: invoices,
+			Columns: []sql.ColumnDefinition{
+				{Name: "id", Type: sql.TypeRef{Type: "integer"}},
+				{Name: "tenant_id", Type: sql.TypeRef{Type: "integer"}},
+			},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+		&sql.FunctionCreate{
+			Function:   sql.FunctionRef{Schema: "public", Function: "current_tenant", Params: []string{}},
+			Returns:    "integer",
+			Definition: "SELECT current_setting('app.tenant')::int",
+			Language:   "sql",
+		},
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "invoices", Column: "id"}},
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "invoices", Column: "tenant_id"}},
+		&sql.ConstraintCreatePrimaryKey{Table: invoices, Constraint: "invoices_pkey", Columns: []string{"id"}},
+		&sql.PolicyCreate{Policy: "tenant_isolation", Table: invoices, Roles: []string{}, Using: "tenant_id = public.current_tenant()"},
+		&sql.PolicyCreate{Policy: "no_negatives", Table: invoices, Restrictive: true, Command: "INSERT", Roles: []string{}, WithCheck: "id > 0"},
+		&sql.TableAlterParts{
+			Table: invoices,
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartRowLevelSecurity{Enabled: true}},
+		},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+
+	table := doc.Schemas[0].Tables[0]
+	create, err := getCreatePolicySql(ops.config, doc.Schemas[0], table, table.Policies[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &sql.PolicyCreate{
+		Policy:      "no_negatives",
+		Table:       invoices,
+		Restrictive: true,
+		Command:     "INSERT",
+		Roles:       []string{},
+		WithCheck:   "id > 0",
+		IfNotExists: true,
+	}, guardCreate(create[0], false))
+
+	assert.NoError(t, checkPolicies(doc, NewVersionNum(10, 0)))
+	assert.ErrorContains(t, checkPolicies(doc, NewVersionNum(9, 6)), "policy no_negatives on table public.invoices is restrictive, which needs a target version of at least 10")
+	assert.ErrorContains(t, checkPolicies(doc, NewVersionNum(9, 4)), "table public.invoices uses row level security, which needs a target version of at least 9.5")
+
+	errs := (&ir.Policy{Name: "p", Command: ir.PolicyCommandInsert, Using: "true"}).Validate(doc, doc.Schemas[0], table)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorContains(t, errs[0], "policy p on table public.invoices is for INSERT, which can only have a WITH CHECK expression")
+	}
+}
@@ -0,0 +1,190 @@
+package pgsql8
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/secure/precis"
+)
+
+// createRoles creates new roles, alters changed ones and brings memberships up to date.
+// It runs before anything else, as schemas, tables and grants may name the roles.
+func createRoles(conf lib.Config, ofs output.OutputFileSegmenter) error {
+	newDoc := conf.NewDatabase
+	oldDoc := conf.OldDatabase
+
+	for _, newRole := range newDoc.Roles {
+		oldRole := oldDoc.TryGetRoleNamed(newRole.Name)
+		if oldRole == nil {
+			s, err := getCreateRoleSql(conf, newRole)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, roleChanged(nil, newRole).create(s...)...)
+		} else if !oldRole.AttributesEqual(newRole) || oldRole.PasswordEnv != newRole.PasswordEnv {
+			s, err := getAlterRoleSql(conf, oldRole, newRole)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, roleChanged(oldRole, newRole).alter(s...)...)
+		}
+	}
+
+	// memberships once every role exists, as they may refer to each other
+	for _, newRole := range newDoc.Roles {
+		oldRole := oldDoc.TryGetRoleNamed(newRole.Name)
+		// membership belongs to the member, which is what changes
+		member := roleChanged(oldRole, newRole)
+		for _, parent := range newRole.MemberOf {
+			if oldRole == nil || !util.IStrsContains(oldRole.MemberOf, parent) {
+				writeChanges(ofs, member.alter(&sql.RoleGrantMembership{Role: parent, Member: newRole.Name})...)
+			}
+		}
+		if oldRole != nil {
+			for _, parent := range oldRole.MemberOf {
+				if !util.IStrsContains(newRole.MemberOf, parent) {
+					writeChanges(ofs, member.alter(&sql.RoleRevokeMembership{Role: parent, Member: newRole.Name})...)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dropRoles drops roles which are no longer defined. It runs last, once nothing refers to them any more.
+// Roles still owning objects or holding privileges, in this or any other database, can't be dropped,
+// and postgres will refuse to.
+func dropRoles(conf lib.Config, ofs output.OutputFileSegmenter) {
+	newDoc := conf.NewDatabase
+	oldDoc := conf.OldDatabase
+
+	if oldDoc == nil {
+		return
+	}
+	for i := len(oldDoc.Roles) - 1; i >= 0; i-- {
+		oldRole := oldDoc.Roles[i]
+		if newDoc.TryGetRoleNamed(oldRole.Name) == nil {
+			writeChanges(ofs, roleChanged(oldRole, nil).drop(&sql.RoleDrop{Role: oldRole.Name})...)
+		}
+	}
+}
+
+// getCreateRoleSql creates the role unless it exists, then sets its attributes and password. Roles are shared by
+// the whole cluster, so the role may well exist already, and would otherwise be left as it was
+func getCreateRoleSql(conf lib.Config, role *ir.Role) ([]output.ToSql, error) {
+	password, err := rolePassword(conf, role)
+	if err != nil {
+		return nil, err
+	}
+	alter := roleAlter(role)
+	alter.Password = password
+	return []output.ToSql{
+		&sql.RoleCreate{Role: role.Name, IfNotExists: true},
+		alter,
+	}, nil
+}
+
+// getAlterRoleSql sets the role's attributes, and its password only if it comes from a different variable now,
+// as there's no telling whether the password itself changed
+func getAlterRoleSql(conf lib.Config, oldRole, newRole *ir.Role) ([]output.ToSql, error) {
+	alter := roleAlter(newRole)
+	if oldRole.PasswordEnv != newRole.PasswordEnv {
+		var err error
+		alter.Password, err = rolePassword(conf, newRole)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []output.ToSql{alter}, nil
+}
+
+func roleAlter(role *ir.Role) *sql.RoleAlter {
+	return &sql.RoleAlter{
+		Role:            role.Name,
+		Login:           role.Login,
+		Inherit:         role.Inherit,
+		CreateDB:        role.CreateDB,
+		ConnectionLimit: role.ConnectionLimit,
+	}
+}
+
+// rolePassword hashes the password from the role's environment variable, so that it isn't written out
+// in the clear to upgrade files. Postgres takes passwords which are already hashed as they are.
+// A SCRAM verifier is salted afresh each time, so the upgrade files differ from run to run, and like any
+// hash it can be brute forced offline by whoever reads them. Giving a verifier hashed ahead of time keeps the
+// output stable; either way upgrade files setting passwords need to be kept as safe as the passwords.
+func rolePassword(conf lib.Config, role *ir.Role) (*string, error) {
+	if role.PasswordEnv == "" {
+		return nil, nil
+	}
+	password, ok := os.LookupEnv(role.PasswordEnv)
+	if !ok {
+		return nil, fmt.Errorf("role %s takes its password from $%s, which is not set", role.Name, role.PasswordEnv)
+	}
+	if isHashedPassword(password) {
+		return &password, nil
+	}
+	if targetOlderThan(conf, FEAT_SCRAM_PASSWORDS) {
+		hashed := md5Password(role.Name, password)
+		return &hashed, nil
+	}
+	hashed, err := scramPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("hashing password of role %s: %w", role.Name, err)
+	}
+	return &hashed, nil
+}
+
+const scramIterations = 4096
+
+var md5PasswordPattern = regexp.MustCompile(`^md5[0-9a-f]{32}$`)
+
+func isHashedPassword(password string) bool {
+	return strings.HasPrefix(password, "SCRAM-SHA-256$") || md5PasswordPattern.MatchString(password)
+}
+
+// scramPassword returns the SCRAM-SHA-256 verifier of the password, in the form postgres stores it
+func scramPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	return scramVerifier(password, salt, scramIterations), nil
+}
+
+func scramVerifier(password string, salt []byte, iterations int) string {
+	// postgres normalizes passwords with SASLprep, but takes those which can't be as they are
+	prepared, err := precis.OpaqueString.Bytes([]byte(password))
+	if err != nil {
+		prepared = []byte(password)
+	}
+	salted := pbkdf2.Key(prepared, salt, iterations, sha256.Size, sha256.New)
+	hmacOf := func(key []byte, msg string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(msg))
+		return mac.Sum(nil)
+	}
+	storedKey := sha256.Sum256(hmacOf(salted, "Client Key"))
+	serverKey := hmacOf(salted, "Server Key")
+	b64 := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations, b64(salt), b64(storedKey[:]), b64(serverKey))
+}
+
+// md5Password returns the md5 hash of the password, salted with the role name as postgres does
+func md5Password(role, password string) string {
+	return fmt.Sprintf("md5%x", md5.Sum([]byte(password+role)))
+}
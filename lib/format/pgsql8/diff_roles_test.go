@@ -0,0 +1,255 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRoles(t *testing.T) {
+	analyst := &ir.Role{Name: "analyst", Login: true, Inherit: true, ConnectionLimit: util.Ptr(5), MemberOf: []string{"reporting"}}
+	tests := []struct {
+		name    string
+		old     []*ir.Role
+		new     []*ir.Role
+		created []output.ToSql
+		dropped []output.ToSql
+	}{
+		{
+			name: "new roles are created unless they already exist in the cluster, then given their attributes and memberships",
+			new:  []*ir.Role{analyst, {Name: "reporting", Inherit: true}},
+			created: []output.ToSql{
+				&sql.RoleCreate{Role: "analyst", IfNotExists: true},
+				&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true, ConnectionLimit: util.Ptr(5)},
+				&sql.RoleCreate{Role: "reporting", IfNotExists: true},
+				&sql.RoleAlter{Role: "reporting", Inherit: true},
+				&sql.RoleGrantMembership{Role: "reporting", Member: "analyst"},
+			},
+		},
+		{
+			name: "changed attributes are all set again, memberships are granted and revoked one by one",
+			old:  []*ir.Role{analyst, {Name: "reporting", Inherit: true}, {Name: "auditor", Inherit: true}},
+			new: []*ir.Role{
+				{Name: "analyst", Inherit: true, CreateDB: true, MemberOf: []string{"auditor"}},
+				{Name: "reporting", Inherit: true},
+				{Name: "auditor", Inherit: true},
+			},
+			created: []output.ToSql{
+				&sql.RoleAlter{Role: "analyst", Inherit: true, CreateDB: true},
+				&sql.RoleGrantMembership{Role: "auditor", Member: "analyst"},
+				&sql.RoleRevokeMembership{Role: "reporting", Member: "analyst"},
+			},
+		},
+		{
+			name:    "roles are dropped last, once nothing owned by or granted to them is left",
+			old:     []*ir.Role{analyst, {Name: "reporting", Inherit: true}},
+			new:     []*ir.Role{{Name: "reporting", Inherit: true}},
+			dropped: []output.ToSql{&sql.RoleDrop{Role: "analyst"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := DefaultConfig
+			conf.OldDatabase = &ir.Definition{Roles: tt.old}
+			conf.NewDatabase = &ir.Definition{Roles: tt.new}
+			created := newBuildChangeRecorder()
+			err := createRoles(conf, created)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dropped := newBuildChangeRecorder()
+			dropRoles(conf, dropped)
+			assert.Equal(t, tt.created, changeStatements(created.changes))
+			assert.Equal(t, tt.dropped, changeStatements(dropped.changes))
+		})
+	}
+}
+
+func TestDiffRoles_Changes(t *testing.T) {
+	analyst := &ir.Role{Name: "analyst", Login: true, Inherit: true, MemberOf: []string{"reporting"}}
+	reporting := &ir.Role{Name: "reporting", Inherit: true}
+
+	conf := DefaultConfig
+	conf.OldDatabase = &ir.Definition{}
+	conf.NewDatabase = &ir.Definition{Roles: []*ir.Role{analyst, reporting}}
+	recorder := newBuildChangeRecorder()
+	err := createRoles(conf, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// memberships belong to the member
+	assert.Equal(t, []*output.Change{
+		{
+			Kind:      "role",
+			Identity:  output.ChangeIdentity{Name: "analyst"},
+			Action:    output.ChangeCreate,
+			New:       analyst,
+			Stage:     1,
+			Statement: &sql.RoleCreate{Role: "analyst", IfNotExists: true},
+		},
+		{
+			Kind:      "role",
+			Identity:  output.ChangeIdentity{Name: "analyst"},
+			Action:    output.ChangeCreate,
+			New:       analyst,
+			Stage:     1,
+			Statement: &sql.RoleAlter{Role: "analyst", Login: true, Inherit: true},
+		},
+		{
+			Kind:      "role",
+			Identity:  output.ChangeIdentity{Name: "reporting"},
+			Action:    output.ChangeCreate,
+			New:       reporting,
+			Stage:     1,
+			Statement: &sql.RoleCreate{Role: "reporting", IfNotExists: true},
+		},
+		{
+			Kind:      "role",
+			Identity:  output.ChangeIdentity{Name: "reporting"},
+			Action:    output.ChangeCreate,
+			New:       reporting,
+			Stage:     1,
+			Statement: &sql.RoleAlter{Role: "reporting", Inherit: true},
+		},
+		{
+			Kind:      "role",
+			Identity:  output.ChangeIdentity{Name: "analyst"},
+			Action:    output.ChangeAlter,
+			New:       analyst,
+			Stage:     1,
+			Statement: &sql.RoleGrantMembership{Role: "reporting", Member: "analyst"},
+		},
+	}, output.FilterChanges(recorder.changes, (*output.Change).IsObject))
+
+	conf.OldDatabase, conf.NewDatabase = conf.NewDatabase, &ir.Definition{Roles: []*ir.Role{reporting}}
+	recorder = newBuildChangeRecorder()
+	dropRoles(conf, recorder)
+	if assert.Len(t, recorder.changes, 1) {
+		assert.Equal(t, output.ChangeDrop, recorder.changes[0].Action)
+		assert.Same(t, analyst, recorder.changes[0].Old)
+	}
+}
+
+func TestDiffRoles_Password(t *testing.T) {
+	withEnv := &ir.Role{Name: "analyst", Login: true, Inherit: true, PasswordEnv: "ANALYST_PASSWORD"}
+
+	_, err := getCreateRoleSql(DefaultConfig, withEnv)
+	assert.ErrorContains(t, err, "role analyst takes its password from $ANALYST_PASSWORD, which is not set")
+
+	// the password is hashed, so it's never written out in the clear
+	t.Setenv("ANALYST_PASSWORD", "it's secret")
+	stmts, err := getCreateRoleSql(DefaultConfig, withEnv)
+	assert.NoError(t, err)
+	if assert.Len(t, stmts, 2) && assert.IsType(t, &sql.RoleAlter{}, stmts[1]) {
+		alter := stmts[1].(*sql.RoleAlter)
+		if assert.NotNil(t, alter.Password) {
+			assert.Regexp(t, `^SCRAM-SHA-256\$4096:[^:]+:[^']+$`, *alter.Password)
+		}
+	}
+	assert.Equal(t,
+		"SCRAM-SHA-256$4096:MDEyMzQ1Njc4OWFiY2RlZg==$xeSb2JvVpz9HvliWWPX7V87jRbTtSAzJU6//xWnWR0g=:/5aaGV3GLJefztlzCcok4zJ32IsyWjNiuTpJJ3BIuTU=",
+		scramVerifier("it's secret", []byte("0123456789abcdef"), 4096),
+	)
+
+	// before 10 only md5 hashes are understood, and passwords which are already hashed are left as they are
+	md5 := "md5efcef89246faee30b243f38260c7601f"
+	conf := DefaultConfig
+	conf.TargetVersion = "9.6"
+	stmts, err = getCreateRoleSql(conf, withEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, []output.ToSql{
+		&sql.RoleCreate{Role: "analyst", IfNotExists: true},
+		&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true, Password: &md5},
+	}, stmts)
+	t.Setenv("ANALYST_PASSWORD", md5)
+	stmts, err = getCreateRoleSql(DefaultConfig, withEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, []output.ToSql{
+		&sql.RoleCreate{Role: "analyst", IfNotExists: true},
+		&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true, Password: &md5},
+	}, stmts)
+
+	// the password itself can't be compared, so it's only set again when it comes from elsewhere
+	withoutEnv := &ir.Role{Name: "analyst", Login: true, Inherit: true}
+	stmts, err = getAlterRoleSql(DefaultConfig, withEnv, &ir.Role{Name: "analyst", Login: true, Inherit: true, PasswordEnv: "ANALYST_PASSWORD", CreateDB: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []output.ToSql{&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true, CreateDB: true}}, stmts)
+	stmts, err = getAlterRoleSql(DefaultConfig, withoutEnv, withEnv)
+	assert.NoError(t, err)
+	assert.Equal(t, []output.ToSql{&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true, Password: &md5}}, stmts)
+}
+
+func TestDiffRoles_ExtractedPassword(t *testing.T) {
+	// the variable isn't set, so any attempt at the password would fail
+	declared := &ir.Definition{Roles: []*ir.Role{{Name: "analyst", Login: true, Inherit: true, PasswordEnv: "ANALYST_PASSWORD"}}}
+	extracted := &ir.Definition{Roles: []*ir.Role{{Name: "analyst", Login: true, Inherit: true}, {Name: "postgres", Login: true}}}
+	extracted.KeepDeclaredRoles(declared)
+
+	conf := DefaultConfig
+	conf.OldDatabase = extracted
+	conf.NewDatabase = declared
+	recorder := newBuildChangeRecorder()
+	err := createRoles(conf, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropRoles(conf, recorder)
+	assert.Empty(t, recorder.changes)
+
+	// a changed attribute is set without the password
+	extracted = &ir.Definition{Roles: []*ir.Role{{Name: "analyst", Login: true, Inherit: true, CreateDB: true}}}
+	extracted.KeepDeclaredRoles(declared)
+	conf.OldDatabase = extracted
+	recorder = newBuildChangeRecorder()
+	err = createRoles(conf, recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []output.ToSql{
+		&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true},
+	}, changeStatements(recorder.changes))
+}
+
+func TestBuild_Roles(t *testing.T) {
+	doc := &ir.Definition{
+		Roles: []*ir.Role{{Name: "analyst", Login: true, Inherit: true, MemberOf: []string{"app"}}},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "reports",
+				Owner:      "analyst",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(ofs, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := sql.TableRef{Schema: "public", Table: "reports"}
+	// roles are in place before anything is owned by them
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.RoleCreate{Role: "analyst", IfNotExists: true},
+		&sql.RoleAlter{Role: "analyst", Login: true, Inherit: true},
+		&sql.RoleGrantMembership{Role: "app", Member: "analyst"},
+		&sql.TableCreate{
+			Table:        reports,
+			Columns:      []sql.ColumnDefinition{{Name: "id", Type: sql.TypeRef{Type: "integer"}}},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+		&sql.TableAlterOwner{Table: reports, Role: "analyst"},
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "reports", Column: "id"}},
+		&sql.ConstraintCreatePrimaryKey{Table: reports, Constraint: "reports_pkey", Columns: []string{"id"}},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+}
@@ -12,7 +12,7 @@ func diffSequences(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *i
 	if oldSchema != nil {
 		for _, oldSeq := range oldSchema.Sequences {
 			if newSchema.TryGetSequenceNamed(oldSeq.Name) == nil {
-				ofs.WriteSql(getDropSequenceSql(oldSchema, oldSeq)...)
+				writeChanges(ofs, sequenceChanged(oldSchema.Name, oldSeq, nil).drop(getDropSequenceSql(oldSchema, oldSeq)...)...)
 			}
 		}
 	}
@@ -24,9 +24,9 @@ func diffSequences(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *i
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(sql...)
+			writeChanges(ofs, sequenceChanged(newSchema.Name, nil, newSeq).create(sql...)...)
 		} else {
-			ofs.WriteSql(getAlterSequenceSql(newSchema.Name, oldSeq, newSeq))
+			writeChanges(ofs, sequenceChanged(newSchema.Name, oldSeq, newSeq).alter(getAlterSequenceSql(newSchema.Name, oldSeq, newSeq))...)
 		}
 	}
 	return nil
@@ -56,6 +56,10 @@ func diffTable(conf lib.Config, stage1, stage3 output.OutputFileSegmenter, oldSc
 	if err != nil {
 		return errors.Wrap(err, "while diffing table partitions")
 	}
+	err = diffPartitionSegments(conf, stage1, stage3, oldSchema, oldTable, newSchema, newTable)
+	if err != nil {
+		return errors.Wrap(err, "while diffing table partitions")
+	}
 	err = checkInherits(oldTable, newSchema, newTable)
 	if err != nil {
 		return errors.Wrap(err, "while diffing table inheritance")
@@ -86,12 +90,13 @@ func updateTableOptions(l *slog.Logger, stage1 output.OutputFileSegmenter, oldTa
 		return strings.EqualFold(newKey, oldKey) && !strings.EqualFold(newOpts.Get(newKey), oldOpts.Get(newKey))
 	})
 
-	return applyTableOptionsDiff(l, stage1, newSchema, newTable, updateOpts, createOpts, deleteOpts)
+	return applyTableOptionsDiff(l, stage1, oldTable, newSchema, newTable, updateOpts, createOpts, deleteOpts)
 }
 
-func applyTableOptionsDiff(l *slog.Logger, stage1 output.OutputFileSegmenter, schema *ir.Schema, table *ir.Table, updateOpts, createOpts, deleteOpts *util.OrderedMap[string, string]) error {
+func applyTableOptionsDiff(l *slog.Logger, stage1 output.OutputFileSegmenter, oldTable *ir.Table, schema *ir.Schema, table *ir.Table, updateOpts, createOpts, deleteOpts *util.OrderedMap[string, string]) error {
 	alters := []sql.TableAlterPart{}
 	ref := sql.TableRef{Schema: schema.Name, Table: table.Name}
+	altered := tableChanged(schema.Name, oldTable, table)
 
 	// in pgsql create and alter have the same syntax
 	for _, entry := range createOpts.UnionFunc(updateOpts, strings.EqualFold).Entries() {
@@ -116,10 +121,10 @@ func applyTableOptionsDiff(l *slog.Logger, stage1 output.OutputFileSegmenter, sc
 		} else if strings.EqualFold(entry.Key, "tablespace") {
 			alters = append(alters, &sql.TableAlterPartSetTablespace{TablespaceName: entry.Value})
 			// TODO(go,3) MoveTablespaceIndexes generates a whole function that just walks indexes and issues ALTER INDEXes. can we move that to this side?
-			stage1.WriteSql(&sql.TableMoveTablespaceIndexes{
+			writeChanges(stage1, altered.alter(&sql.TableMoveTablespaceIndexes{
 				Table:      ref,
 				Tablespace: entry.Value,
-			})
+			})...)
 		} else {
 			l.Warn(fmt.Sprintf("Ignoring create/update of unknown table option %s on table %s.%s", entry.Key, schema.Name, table.Name))
 		}
@@ -136,31 +141,31 @@ func applyTableOptionsDiff(l *slog.Logger, stage1 output.OutputFileSegmenter, sc
 			// handle rest normally
 			alters = append(alters, &sql.TableAlterPartResetStorageParams{Params: util.MapKeys(params)})
 		} else if strings.EqualFold(entry.Key, "tablespace") {
-			stage1.WriteSql(&sql.TableResetTablespace{
+			writeChanges(stage1, altered.alter(&sql.TableResetTablespace{
 				Table: ref,
-			})
+			})...)
 		} else {
 			l.Warn(fmt.Sprintf("Ignoring removal of unknown table option %s on table %s.%s", entry.Key, schema.Name, table.Name))
 		}
 	}
 
-	if len(alters) > 0 {
-		stage1.WriteSql(&sql.TableAlterParts{
-			Table: ref,
-			Parts: alters,
-		})
+	parts := []*output.Change{}
+	for _, alter := range alters {
+		parts = append(parts, altered.alter(sql.NewTableAlter(ref, alter))...)
 	}
+	writeChanges(stage1, tableAlter(altered, parts)...)
 
 	return nil
 }
 
 type updateTableColumnsAgg struct {
-	before1 []output.ToSql
-	before3 []output.ToSql
-	stage1  []sql.TableAlterPart
-	stage3  []sql.TableAlterPart
-	after1  []output.ToSql
-	after3  []output.ToSql
+	before1 []*output.Change
+	before3 []*output.Change
+	// single part ALTER TABLEs, combined into one ALTER TABLE per stage
+	stage1 []*output.Change
+	stage3 []*output.Change
+	after1 []*output.Change
+	after3 []*output.Change
 }
 
 func updateTableColumns(conf lib.Config, stage1, stage3 output.OutputFileSegmenter, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
@@ -170,7 +175,7 @@ func updateTableColumns(conf lib.Config, stage1, stage3 output.OutputFileSegment
 	// meaning that a BEFORE3 could be output before a BEFORE1 in a single-stage upgrade. in this implementation,
 	// _all_ BEFORE1s are printed before BEFORE3s. Double check that this doesn't break anything.
 
-	err := addDropTableColumns(conf, agg, oldTable, newTable)
+	err := addDropTableColumns(conf, agg, oldTable, newSchema, newTable)
 	if err != nil {
 		return err
 	}
@@ -184,35 +189,26 @@ func updateTableColumns(conf lib.Config, stage1, stage3 output.OutputFileSegment
 	}
 
 	// Note: in the case of single stage upgrades, stage1==stage3, so do all the Before's before all of the stages, and do them in stage order
-	stage1.WriteSql(agg.before1...)
-	stage3.WriteSql(agg.before3...)
+	writeChanges(stage1, agg.before1...)
+	writeChanges(stage3, agg.before3...)
 
-	ref := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
 	// TODO: This code didn't do anything. Is there a bug hiding here?
 	//ownRole := newTable.Owner
 	//if ownRole == "" {
 	//	ownRole = lib.GlobalXmlParser.RoleEnum(lib.GlobalDBSteward.NewDatabase, ir.RoleOwner)
 	//}
-	if len(agg.stage1) > 0 {
-		stage1.WriteSql(&sql.TableAlterParts{
-			Table: ref,
-			Parts: agg.stage1,
-		})
-	}
-	if len(agg.stage3) > 0 {
-		stage3.WriteSql(&sql.TableAlterParts{
-			Table: ref,
-			Parts: agg.stage3,
-		})
-	}
+	table := tableChanged(newSchema.Name, oldTable, newTable)
+	writeChanges(stage1, tableAlter(table, agg.stage1)...)
+	writeChanges(stage3, tableAlter(table, agg.stage3)...)
 
-	stage1.WriteSql(agg.after1...)
-	stage3.WriteSql(agg.after3...)
+	writeChanges(stage1, agg.after1...)
+	writeChanges(stage3, agg.after3...)
 
 	return nil
 }
 
-func addDropTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable, newTable *ir.Table) error {
+func addDropTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+	ref := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
 	for _, oldColumn := range oldTable.Columns {
 		if newTable.TryGetColumnNamed(oldColumn.Name) != nil {
 			// new column exists, not dropping it
@@ -221,12 +217,13 @@ func addDropTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable,
 
 		renamedColumn := newTable.TryGetColumnOldNamed(oldColumn.Name)
 		if !conf.IgnoreOldNames && renamedColumn != nil {
-			agg.after3 = append(agg.after3, sql.NewComment(
+			agg.after3 = append(agg.after3, untypedChanges(sql.NewComment(
 				"%s DROP COLUMN %s omitted: new column %s indicates it is the replacement for %s",
 				oldTable.Name, oldColumn.Name, renamedColumn.Name, oldColumn.Name,
-			))
+			))...)
 		} else {
-			agg.stage3 = append(agg.stage3, &sql.TableAlterPartColumnDrop{Column: oldColumn.Name})
+			dropped := columnChanged(newSchema.Name, newTable.Name, oldColumn, nil)
+			agg.stage3 = append(agg.stage3, dropped.drop(sql.NewTableAlter(ref, &sql.TableAlterPartColumnDrop{Column: oldColumn.Name}))...)
 		}
 	}
 	return nil
@@ -236,6 +233,8 @@ func addCreateTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 	// note that postgres treats identifiers as case-sensitive when quoted
 	// TODO(go,3) find a way to generalize/streamline this
 	caseSensitive := conf.QuoteAllNames || conf.QuoteColumnNames
+	ref := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
+	rows := rowsChanged(newSchema.Name, oldTable, newTable)
 
 	for _, newColumn := range newTable.Columns {
 		if oldTable.TryGetColumnNamedCase(newColumn.Name, caseSensitive) != nil {
@@ -248,15 +247,17 @@ func addCreateTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 			return errors.Wrapf(err, "while adding new table columns")
 		}
 		if isRenamed {
-			agg.after1 = append(agg.after1, &sql.Annotated{
+			renamed := columnChanged(newSchema.Name, newTable.Name, oldTable.TryGetColumnNamedCase(newColumn.OldColumnName, caseSensitive), newColumn)
+			agg.after1 = append(agg.after1, renamed.rename(newColumn.OldColumnName, &sql.Annotated{
 				Annotation: "column rename from oldColumnName specification",
 				Wrapped: &sql.ColumnRename{
 					Column:  sql.ColumnRef{Schema: newSchema.Name, Table: newTable.Name, Column: newColumn.OldColumnName},
 					NewName: newColumn.Name,
 				},
-			})
+			})...)
 			continue
 		}
+		created := columnChanged(newSchema.Name, newTable.Name, nil, newColumn)
 
 		// notice $include_null_definition is false
 		// this is because ADD COLUMNs with NOT NULL will fail when there are existing rows
@@ -264,26 +265,37 @@ func addCreateTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 		if err != nil {
 			return err
 		}
-		agg.stage1 = append(agg.stage1, &sql.TableAlterPartColumnCreate{
+		deferDefault, err := defaultUsesAddedEnumValue(conf, newSchema, newTable, newColumn)
+		if err != nil {
+			return err
+		}
+		if deferDefault {
+			// the column is added without its default, which is set and filled in once the value exists
+			colDef.Default = nil
+			agg.before3 = append(agg.before3, getDeferredColumnDefaultChanges(oldTable, newSchema, newTable, nil, newColumn, true)...)
+		}
+		agg.stage1 = append(agg.stage1, created.create(sql.NewTableAlter(ref, &sql.TableAlterPartColumnCreate{
 			// TODO(go,nth) clean up this call, get rid of booleans and global flag
-			ColumnDef: colDef,
-		})
+			ColumnDef:   colDef,
+			IfNotExists: targetAtLeast(conf, FEAT_ADD_COLUMN_IF_NOT_EXISTS),
+		}))...)
 
 		// instead we put the NOT NULL defintion in stage3 schema changes once data has been updated in stage2 data
-		if !newColumn.Nullable {
-			agg.stage3 = append(agg.stage3, &sql.TableAlterPartColumnSetNull{
+		// identity columns are NOT NULL as they're added, and filled in by their sequence
+		if !newColumn.Nullable && newColumn.Identity == nil {
+			agg.stage3 = append(agg.stage3, created.create(sql.NewTableAlter(ref, &sql.TableAlterPartColumnSetNull{
 				Column:   newColumn.Name,
 				Nullable: false,
-			})
+			}))...)
 			// also, if it's defined, default the column in stage 1 so the SET NULL will actually pass in stage 3
-			if newColumn.Default != "" {
-				agg.after1 = append(agg.after1, &sql.DataUpdate{
-					Table:          sql.TableRef{Schema: newSchema.Name, Table: newTable.Name},
+			if newColumn.Default != "" && !deferDefault {
+				agg.after1 = append(agg.after1, rows.alter(&sql.DataUpdate{
+					Table:          ref,
 					UpdatedColumns: []string{newColumn.Name},
 					UpdatedValues:  []sql.ToSqlValue{sql.ValueDefault},
 					KeyColumns:     []string{newColumn.Name},
 					KeyValues:      []sql.ToSqlValue{sql.ValueNull},
-				})
+				})...)
 			}
 		}
 
@@ -292,42 +304,42 @@ func addCreateTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 		// because the data in those columns is being placed in as a default by the local db server
 		// to compensate, add UPDATE statements to make the these column's values NOW() from the master
 		if hasDefaultNow(newColumn) {
-			agg.after1 = append(agg.after1, &sql.Annotated{
+			agg.after1 = append(agg.after1, rows.alter(&sql.Annotated{
 				Annotation: "has_default_now: this statement is to make sure new columns are in sync on replicas",
 				Wrapped: &sql.DataUpdate{
-					Table:          sql.TableRef{Schema: newSchema.Name, Table: newTable.Name},
+					Table:          ref,
 					UpdatedColumns: []string{newColumn.Name},
 					UpdatedValues:  []sql.ToSqlValue{sql.RawSql(newColumn.Default)},
 				},
-			})
+			})...)
 		}
 
 		// some columns need to be filled with values before any new constraints can be applied
 		// this is accomplished by defining arbitrary SQL in the column element afterAddPre/PostStageX attribute
 		// TODO(go,nth) original code re-traverses doc->schema->table->column, and I'm not sure why; need to make sure this is well tested and reviewed
 		if newColumn.BeforeAddStage1 != "" {
-			agg.before1 = append(agg.before1, &sql.Annotated{
+			agg.before1 = append(agg.before1, untypedChanges(&sql.Annotated{
 				Annotation: fmt.Sprintf("from %s.%s.%s beforeAddStage1 definition", newSchema.Name, newTable.Name, newColumn.Name),
 				Wrapped:    sql.RawSql(newColumn.BeforeAddStage1),
-			})
+			})...)
 		}
 		if newColumn.AfterAddStage1 != "" {
-			agg.after1 = append(agg.after1, &sql.Annotated{
+			agg.after1 = append(agg.after1, untypedChanges(&sql.Annotated{
 				Annotation: fmt.Sprintf("from %s.%s.%s afterAddStage1 definition", newSchema.Name, newTable.Name, newColumn.Name),
 				Wrapped:    sql.RawSql(newColumn.AfterAddStage1),
-			})
+			})...)
 		}
 		if newColumn.BeforeAddStage3 != "" {
-			agg.before1 = append(agg.before1, &sql.Annotated{
+			agg.before1 = append(agg.before1, untypedChanges(&sql.Annotated{
 				Annotation: fmt.Sprintf("from %s.%s.%s beforeAddStage3 definition", newSchema.Name, newTable.Name, newColumn.Name),
 				Wrapped:    sql.RawSql(newColumn.BeforeAddStage3),
-			})
+			})...)
 		}
 		if newColumn.AfterAddStage3 != "" {
-			agg.after1 = append(agg.after1, &sql.Annotated{
+			agg.after1 = append(agg.after1, untypedChanges(&sql.Annotated{
 				Annotation: fmt.Sprintf("from %s.%s.%s afterAddStage3 definition", newSchema.Name, newTable.Name, newColumn.Name),
 				Wrapped:    sql.RawSql(newColumn.AfterAddStage3),
-			})
+			})...)
 		}
 	}
 
@@ -338,6 +350,8 @@ func addModifyTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 	// note that postgres treats identifiers as case-sensitive when quoted
 	// TODO(go,3) find a way to generalize/streamline this
 	caseSensitive := conf.QuoteAllNames || conf.QuoteColumnNames
+	ref := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
+	rows := rowsChanged(newSchema.Name, oldTable, newTable)
 
 	for _, newColumn := range newTable.Columns {
 		oldColumn := oldTable.TryGetColumnNamedCase(newColumn.Name, caseSensitive)
@@ -354,6 +368,7 @@ func addModifyTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 			// TODO(feat) doens't this mean the ONLY change to a renamed column is the RENAME? That doesn't seem right, could lead to bad data
 			continue
 		}
+		altered := columnChanged(newSchema.Name, newTable.Name, oldColumn, newColumn)
 
 		// TODO(go,pgsql) orig code calls (oldDB, *newSchema*, oldTable, oldColumn) but that seems wrong, need to validate this
 		oldType, err := getColumnType(conf.Logger, conf.OldDatabase, newSchema, oldTable, oldColumn)
@@ -384,56 +399,209 @@ func addModifyTableColumns(conf lib.Config, agg *updateTableColumnsAgg, oldTable
 				expr := sql.ExpressionValue(newColumn.ConvertUsing)
 				alterType.Using = &expr
 			}
-			agg.stage1 = append(agg.stage1, &sql.TableAlterPartAnnotation{
+			agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartAnnotation{
 				Annotation: "changing from type " + oldType,
 				Wrapped:    alterType,
-			})
+			}))...)
 		}
 
-		if oldColumn.Default != newColumn.Default {
+		deferDefault, err := defaultUsesAddedEnumValue(conf, newSchema, newTable, newColumn)
+		if err != nil {
+			return err
+		}
+		if deferDefault {
+			fill := oldColumn.Nullable && !newColumn.Nullable
+			agg.before3 = append(agg.before3, getDeferredColumnDefaultChanges(oldTable, newSchema, newTable, oldColumn, newColumn, fill)...)
+		}
+		if oldColumn.Default != newColumn.Default && !deferDefault {
 			if newColumn.Default == "" {
-				agg.stage1 = append(agg.stage1, &sql.TableAlterPartColumnDropDefault{Column: newColumn.Name})
+				agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartColumnDropDefault{Column: newColumn.Name}))...)
 			} else {
-				agg.stage1 = append(agg.stage1, &sql.TableAlterPartColumnSetDefault{Column: newColumn.Name, Default: sql.RawSql(newColumn.Default)})
+				agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartColumnSetDefault{Column: newColumn.Name, Default: sql.RawSql(newColumn.Default)}))...)
 			}
 		}
 
 		if oldColumn.Nullable != newColumn.Nullable {
 			if newColumn.Nullable {
-				agg.stage1 = append(agg.stage1, &sql.TableAlterPartColumnSetNull{Column: newColumn.Name, Nullable: true})
+				agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartColumnSetNull{Column: newColumn.Name, Nullable: true}))...)
 			} else {
 				// if the default value is defined in the dbsteward XML
 				// set the value of the column to the default in end of stage 1 so that NOT NULL can be applied in stage 3
 				// this way custom <sql> tags can be avoided for upgrade generation if defaults are specified
-				if newColumn.Default != "" {
-					agg.after1 = append(agg.after1, &sql.Annotated{
+				if newColumn.Default != "" && !deferDefault {
+					agg.after1 = append(agg.after1, rows.alter(&sql.Annotated{
 						Annotation: "make modified column that is null the default value before NOT NULL hits",
 						Wrapped: &sql.DataUpdate{
-							Table:          sql.TableRef{Schema: newSchema.Name, Table: newTable.Name},
+							Table:          ref,
 							UpdatedColumns: []string{newColumn.Name},
 							UpdatedValues:  []sql.ToSqlValue{sql.RawSql(newColumn.Default)},
 							KeyColumns:     []string{newColumn.Name},
 							KeyValues:      []sql.ToSqlValue{sql.ValueNull},
 						},
-					})
+					})...)
 				}
 
-				agg.stage3 = append(agg.stage3, &sql.TableAlterPartColumnSetNull{Column: newColumn.Name, Nullable: false})
+				agg.stage3 = append(agg.stage3, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartColumnSetNull{Column: newColumn.Name, Nullable: false}))...)
 			}
 		}
 
 		// drop sequence and default if converting from serial to int
 		if isSerialType(oldColumn.Type) && isIntType(newColumn.Type) {
-			agg.before3 = append(agg.before3, &sql.SequenceDrop{
+			drop := serialSequenceChanged(newSchema.Name, newTable.Name, newColumn.Name).drop(&sql.SequenceDrop{
 				Sequence: sql.SequenceRef{
 					Schema:   newSchema.Name,
 					Sequence: buildSequenceName(newSchema.Name, newTable.Name, newColumn.Name),
 				},
 			})
-			agg.stage1 = append(agg.stage1, &sql.TableAlterPartColumnDropDefault{Column: newColumn.Name})
+			if newColumn.Identity != nil && oldColumn.Identity == nil {
+				// the identity carries on from where the serial's sequence is, so that has to be kept until then
+				agg.after3 = append(agg.after3, drop...)
+			} else {
+				agg.before3 = append(agg.before3, drop...)
+			}
+			agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(ref, &sql.TableAlterPartColumnDropDefault{Column: newColumn.Name}))...)
+		}
+
+		err = addModifyColumnGenerated(conf, agg, oldColumn, newSchema, newTable, newColumn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defaultUsesAddedEnumValue reports whether the column defaults to a value being added to an existing enum.
+// Postgres won't let the value be used until the transaction adding it has committed, so the default has to
+// wait for stage 3.
+func defaultUsesAddedEnumValue(conf lib.Config, schema *ir.Schema, table *ir.Table, column *ir.Column) (bool, error) {
+	if column.Default == "" {
+		return false, nil
+	}
+	colType, err := getColumnType(conf.Logger, conf.NewDatabase, schema, table, column)
+	if err != nil {
+		return false, err
+	}
+	ref := sql.ParseTypeRef(colType)
+	typeSchema := schema
+	if ref.Schema != "" {
+		typeSchema = conf.NewDatabase.TryGetSchemaNamed(ref.Schema)
+	}
+	if typeSchema == nil {
+		return false, nil
+	}
+	datatype := typeSchema.TryGetTypeNamed(ref.Type)
+	if datatype == nil {
+		return false, nil
+	}
+	for _, value := range enumValuesAddedInPlace(conf, typeSchema, datatype) {
+		if !strings.Contains(column.Default, "'"+strings.ReplaceAll(value, "'", "''")+"'") {
+			continue
+		}
+		if conf.SingleStageUpgrade {
+			return false, fmt.Errorf(
+				"column %s.%s.%s defaults to value '%s' added to type %s.%s, which can't be used in the transaction adding it; generate a staged upgrade instead",
+				schema.Name, table.Name, column.Name, value, typeSchema.Name, datatype.Name,
+			)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// getDeferredColumnDefaultChanges sets a column default held back by defaultUsesAddedEnumValue, and fills in
+// the rows left without it if the column is new or becomes NOT NULL
+func getDeferredColumnDefaultChanges(oldTable *ir.Table, schema *ir.Schema, table *ir.Table, oldColumn, column *ir.Column, fill bool) []*output.Change {
+	ref := sql.TableRef{Schema: schema.Name, Table: table.Name}
+	changes := columnChanged(schema.Name, table.Name, oldColumn, column).createOrAlter(
+		sql.NewTableAlter(ref, &sql.TableAlterPartColumnSetDefault{Column: column.Name, Default: sql.RawSql(column.Default)}),
+	)
+	if fill {
+		changes = append(changes, rowsChanged(schema.Name, oldTable, table).alter(&sql.Annotated{
+			Annotation: "default the column once the enum value it defaults to has been added",
+			Wrapped: &sql.DataUpdate{
+				Table:          ref,
+				UpdatedColumns: []string{column.Name},
+				UpdatedValues:  []sql.ToSqlValue{sql.ValueDefault},
+				KeyColumns:     []string{column.Name},
+				KeyValues:      []sql.ToSqlValue{sql.ValueNull},
+			},
+		})...)
+	}
+	return changes
+}
+
+// addModifyColumnGenerated changes whether and how the column is an identity or generated
+func addModifyColumnGenerated(conf lib.Config, agg *updateTableColumnsAgg, oldColumn *ir.Column, newSchema *ir.Schema, newTable *ir.Table, newColumn *ir.Column) error {
+	ref := sql.ColumnRef{Schema: newSchema.Name, Table: newTable.Name, Column: newColumn.Name}
+	tableRef := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
+	altered := columnChanged(newSchema.Name, newTable.Name, oldColumn, newColumn)
+	switch {
+	case oldColumn.Identity == nil && newColumn.Identity != nil:
+		add := altered.alter(sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnAddIdentity{Column: newColumn.Name, Identity: *getColumnIdentity(newColumn)}))
+		if isSerialType(oldColumn.Type) {
+			// the serial's sequence is detached so the identity's is the only one of the column, then the
+			// identity picks up where it left off, and it's dropped at the end of stage 3
+			oldSequence := sql.SequenceRef{
+				Schema:   newSchema.Name,
+				Sequence: buildSequenceName(newSchema.Name, newTable.Name, newColumn.Name),
+			}
+			agg.before1 = append(agg.before1, serialSequenceChanged(newSchema.Name, newTable.Name, newColumn.Name).alter(&sql.SequenceOwnedByNone{Sequence: oldSequence})...)
+			agg.stage1 = append(agg.stage1, add...)
+			agg.after1 = append(agg.after1, altered.alter(&sql.Annotated{
+				Annotation: fmt.Sprintf("%s.%s.%s was serial, its identity continues from %s", newSchema.Name, newTable.Name, newColumn.Name, oldSequence.Sequence),
+				Wrapped:    &sql.SequenceSerialSetValFrom{Column: ref, From: oldSequence},
+			})...)
+			break
+		}
+		// an identity can only be added to a NOT NULL column, so a column made NOT NULL in stage 3 gets it there too
+		var setMax []*output.Change
+		if newColumn.Identity.Start == nil {
+			setMax = altered.alter(&sql.Annotated{
+				Annotation: fmt.Sprintf("%s.%s.%s identity continues from the values the column already has", newSchema.Name, newTable.Name, newColumn.Name),
+				Wrapped:    &sql.SequenceSerialSetValMax{Column: ref},
+			})
+		}
+		if oldColumn.Nullable {
+			agg.stage3 = append(agg.stage3, add...)
+			agg.after3 = append(agg.after3, setMax...)
+		} else {
+			agg.stage1 = append(agg.stage1, add...)
+			agg.after1 = append(agg.after1, setMax...)
 		}
+	case oldColumn.Identity != nil && newColumn.Identity == nil:
+		agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnDropIdentity{Column: newColumn.Name}))...)
+	case !oldColumn.Identity.Equals(newColumn.Identity):
+		agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnSetIdentity{Column: newColumn.Name, Identity: *getColumnIdentity(newColumn)}))...)
 	}
 
+	if oldColumn.GeneratedAs == newColumn.GeneratedAs {
+		return nil
+	}
+	if newColumn.GeneratedAs == "" {
+		if targetOlderThan(conf, FEAT_DROP_EXPRESSION) {
+			return fmt.Errorf("column %s.%s.%s is no longer generated, which needs a target version of at least 13", newSchema.Name, newTable.Name, newColumn.Name)
+		}
+		agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnDropExpression{Column: newColumn.Name}))...)
+		return nil
+	}
+	if oldColumn.GeneratedAs != "" && targetAtLeast(conf, FEAT_SET_EXPRESSION) {
+		agg.stage1 = append(agg.stage1, altered.alter(sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnSetExpression{Column: newColumn.Name, Expression: newColumn.GeneratedAs}))...)
+		return nil
+	}
+	// otherwise the column has to be added again, which loses nothing as its values are computed anyway
+	colDef, err := getFullColumnDefinition(conf.Logger, conf.NewDatabase, newSchema, newTable, newColumn, false, true)
+	if err != nil {
+		return err
+	}
+	// which also makes the drop an alteration of the column rather than a loss of its data
+	agg.stage1 = append(agg.stage1, altered.alter(
+		sql.NewTableAlter(tableRef, &sql.TableAlterPartAnnotation{
+			Annotation: "generated column expression changed, recreating it",
+			Wrapped:    &sql.TableAlterPartColumnDrop{Column: newColumn.Name},
+		}),
+		sql.NewTableAlter(tableRef, &sql.TableAlterPartColumnCreate{ColumnDef: colDef}),
+	)...)
 	return nil
 }
 
@@ -454,6 +622,74 @@ func checkPartition(oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Sche
 	return xmlParser.CheckPartitionChange(oldSchema, oldTable, newSchema, newTable)
 }
 
+// diffPartitionSegments creates, attaches, detaches and drops the partitions of a natively partitioned table.
+// A segment which is a standalone table in the other definition is attached or detached instead of created or dropped.
+func diffPartitionSegments(conf lib.Config, stage1, stage3 output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+	if newTable.Partitioning == nil || !newTable.Partitioning.Type.IsNative() {
+		return nil
+	}
+	parent := sql.TableRef{Schema: newSchema.Name, Table: newTable.Name}
+	// attaching and detaching partitions alters the parent, creating and dropping them changes the partitions themselves
+	parentChanged := tableChanged(newSchema.Name, oldTable, newTable)
+	for _, newSegment := range newTable.Partitioning.Segments {
+		partition := sql.TableRef{Schema: newSchema.Name, Table: newSegment.Name}
+		oldSegment := oldTable.Partitioning.TryGetSegmentNamed(newSegment.Name)
+		if oldSegment == nil {
+			if oldSchema.TryGetTableNamed(newSegment.Name) != nil {
+				err := writeChanges(stage1, parentChanged.alter(&sql.TableAttachPartition{Table: parent, Partition: partition, Bound: newSegment.Value})...)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			created := partitionChanged(newSchema.Name, newSegment.Name)
+			err := writeChanges(stage1, created.create(getCreatePartitionSql(newSchema, newTable, newSegment))...)
+			if err != nil {
+				return err
+			}
+			if newTable.Owner != "" {
+				role, err := roleEnum(conf.Logger, conf.NewDatabase, newTable.Owner, conf.IgnoreCustomRoles)
+				if err != nil {
+					return err
+				}
+				err = writeChanges(stage1, created.create(&sql.TableAlterOwner{Table: partition, Role: role})...)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !strings.EqualFold(strings.Join(strings.Fields(oldSegment.Value), " "), strings.Join(strings.Fields(newSegment.Value), " ")) {
+			// bounds can't be altered in place, but the data can stay put while we re-attach
+			err := writeChanges(stage1, parentChanged.alter(
+				&sql.TableDetachPartition{Table: parent, Partition: partition},
+				&sql.TableAttachPartition{Table: parent, Partition: partition, Bound: newSegment.Value},
+			)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, oldSegment := range oldTable.Partitioning.Segments {
+		if newTable.Partitioning.TryGetSegmentNamed(oldSegment.Name) != nil {
+			continue
+		}
+		partition := sql.TableRef{Schema: oldSchema.Name, Table: oldSegment.Name}
+		if newSchema.TryGetTableNamed(oldSegment.Name) != nil {
+			err := writeChanges(stage1, parentChanged.alter(&sql.TableDetachPartition{Table: parent, Partition: partition})...)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := writeChanges(stage3, partitionChanged(oldSchema.Name, oldSegment.Name).drop(&sql.TableDrop{Table: partition})...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func checkInherits(oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
 	if oldTable.InheritsSchema == "" && oldTable.InheritsTable == "" && newTable.InheritsSchema == "" && newTable.InheritsTable == "" {
 		return nil
@@ -473,16 +709,17 @@ func addAlterStatistics(stage1 output.OutputFileSegmenter, oldTable *ir.Table, n
 			continue
 		}
 
+		altered := columnChanged(newSchema.Name, newTable.Name, oldColumn, newColumn)
 		if newColumn.Statistics != nil && (oldColumn.Statistics == nil || *oldColumn.Statistics != *newColumn.Statistics) {
-			stage1.WriteSql(&sql.ColumnAlterStatistics{
+			writeChanges(stage1, altered.alter(&sql.ColumnAlterStatistics{
 				Column:     sql.ColumnRef{Schema: newSchema.Name, Table: newTable.Name, Column: newColumn.Name},
 				Statistics: *newColumn.Statistics,
-			})
+			})...)
 		} else if oldColumn.Statistics != nil && newColumn.Statistics == nil {
-			stage1.WriteSql(&sql.ColumnAlterStatistics{
+			writeChanges(stage1, altered.alter(&sql.ColumnAlterStatistics{
 				Column:     sql.ColumnRef{Schema: newSchema.Name, Table: newTable.Name, Column: newColumn.Name},
 				Statistics: -1,
-			})
+			})...)
 		}
 	}
 	return nil
@@ -560,6 +797,11 @@ func createTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema, new
 		l.Debug("old table exists")
 		return nil
 	}
+	if oldParent := oldSchema.TryGetNativePartitionParent(newTable.Name); oldParent != nil && newSchema.TryGetTableNamed(oldParent.Name) != nil {
+		// old partition will be detached by diffPartitionSegments
+		l.Debug("old partition exists")
+		return nil
+	}
 
 	isRenamed, err := conf.OldDatabase.IsRenamedTable(slog.Default(), newSchema, newTable)
 	if err != nil {
@@ -573,22 +815,23 @@ func createTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema, new
 
 		// ALTER TABLE ... RENAME TO does not accept schema qualifiers ...
 		oldRef := sql.TableRef{Schema: oldTableSchema.Name, Table: oldTable.Name}
-		ofs.WriteSql(&sql.Annotated{
+		renamed := tableChanged(oldTableSchema.Name, oldTable, newTable)
+		writeChanges(ofs, renamed.rename(oldTable.Name, &sql.Annotated{
 			Annotation: "table rename from oldTableName specification",
 			Wrapped: &sql.TableAlterRename{
 				Table:   oldRef,
 				NewName: newTable.Name,
 			},
-		})
+		})...)
 		// ... so if the schema changes issue a SET SCHEMA
 		if !strings.EqualFold(oldTableSchema.Name, newSchema.Name) {
-			ofs.WriteSql(&sql.Annotated{
+			writeChanges(ofs, renamed.alter(&sql.Annotated{
 				Annotation: "table reschema from oldSchemaName specification",
 				Wrapped: &sql.TableAlterSetSchema{
 					Table:     oldRef,
 					NewSchema: newSchema.Name,
 				},
-			})
+			})...)
 		}
 	} else {
 		l.Debug("table not renamed")
@@ -596,11 +839,12 @@ func createTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema, new
 		if err != nil {
 			return err
 		}
-		err = ofs.WriteSql(createTableSQL...)
+		created := tableChanged(newSchema.Name, nil, newTable)
+		err = writeChanges(ofs, created.create(createTableSQL...)...)
 		if err != nil {
 			return err
 		}
-		err = ofs.WriteSql(defineTableColumnDefaults(l, newSchema, newTable)...)
+		err = writeChanges(ofs, created.create(defineTableColumnDefaults(l, newSchema, newTable)...)...)
 		if err != nil {
 			return err
 		}
@@ -623,6 +867,10 @@ func dropTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Sc
 		// table exists, nothing to do
 		return
 	}
+	if newParent := newSchema.TryGetNativePartitionParent(oldTable.Name); newParent != nil && oldSchema.TryGetTableNamed(newParent.Name) != nil {
+		// table will be attached as a partition by diffPartitionSegments
+		return
+	}
 	if !conf.IgnoreOldNames {
 		renamedRef := conf.NewDatabase.TryGetTableFormerlyKnownAs(oldSchema, oldTable)
 		if renamedRef != nil {
@@ -631,7 +879,7 @@ func dropTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Sc
 		}
 	}
 
-	ofs.WriteSql(getDropTableSql(oldSchema, oldTable)...)
+	writeChanges(ofs, tableChanged(oldSchema.Name, oldTable, nil).drop(getDropTableSql(oldSchema, oldTable)...)...)
 }
 
 func diffClusters(ofs output.OutputFileSegmenter, oldSchema, newSchema *ir.Schema) {
@@ -643,10 +891,10 @@ func diffClusters(ofs output.OutputFileSegmenter, oldSchema, newSchema *ir.Schem
 
 func diffClustersTable(ofs output.OutputFileSegmenter, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) {
 	if (oldTable == nil && newTable.ClusterIndex != "") || (oldTable != nil && oldTable.ClusterIndex != newTable.ClusterIndex) {
-		ofs.WriteSql(&sql.TableAlterClusterOn{
+		writeChanges(ofs, tableChanged(newSchema.Name, oldTable, newTable).createOrAlter(&sql.TableAlterClusterOn{
 			Table: sql.TableRef{Schema: newSchema.Name, Table: newTable.Name},
 			Index: newTable.ClusterIndex,
-		})
+		})...)
 	}
 }
 
@@ -660,34 +908,43 @@ func diffData(ops *Operations, ofs output.OutputFileSegmenter, oldSchema, newSch
 			// if the table was renamed, get old definition pointers, diff that
 			oldSchema := ops.config.OldDatabase.GetOldTableSchema(newSchema, newTable)
 			oldTable := ops.config.OldDatabase.GetOldTable(newSchema, newTable)
-			s, err := getCreateDataSql(ops, oldSchema, oldTable, newSchema, newTable)
+			s, err := getCreateDataChanges(ops, oldSchema, oldTable, newSchema, newTable)
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, s...)
 		} else {
 			oldTable := oldSchema.TryGetTableNamed(newTable.Name)
-			s, err := getCreateDataSql(ops, oldSchema, oldTable, newSchema, newTable)
+			s, err := getCreateDataChanges(ops, oldSchema, oldTable, newSchema, newTable)
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, s...)
 		}
 	}
 	return nil
 }
 
 func getCreateDataSql(ops *Operations, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) ([]output.ToSql, error) {
+	changes, err := getCreateDataChanges(ops, oldSchema, oldTable, newSchema, newTable)
+	if err != nil {
+		return nil, err
+	}
+	return util.Map(changes, func(c *output.Change) output.ToSql { return c.Statement }), nil
+}
+
+func getCreateDataChanges(ops *Operations, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) ([]*output.Change, error) {
 	newRows, updatedRows := getNewAndChangedRows(oldTable, newTable)
+	rows := rowsChanged(newSchema.Name, oldTable, newTable)
 	// cut back on allocations - we know that there's going to be _at least_ one statement for every new and updated row, and likely 1 for the serial start
-	out := make([]output.ToSql, 0, len(newRows)+len(updatedRows)+1)
+	out := make([]*output.Change, 0, len(newRows)+len(updatedRows)+1)
 
 	for _, updatedRow := range updatedRows {
 		update, err := buildDataUpdate(ops, newSchema, newTable, updatedRow)
 		if err != nil {
 			return nil, err
 		}
-		out = append(out, update)
+		out = append(out, rows.alter(update)...)
 	}
 	for _, newRow := range newRows {
 		// TODO(go,3) batch inserts
@@ -695,16 +952,18 @@ func getCreateDataSql(ops *Operations, oldSchema *ir.Schema, oldTable *ir.Table,
 		if err != nil {
 			return nil, err
 		}
-		out = append(out, insert)
+		out = append(out, rows.create(insert)...)
 	}
 
 	if oldTable == nil {
 		// if this is a fresh build, make sure serial starts are issued _after_ the hardcoded data inserts
-		dml, err := getSerialStartDml(newSchema, newTable, nil)
-		if err != nil {
-			return nil, err
+		for _, column := range newTable.Columns {
+			dml, err := getSerialStartDml(newSchema, newTable, column)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, serialSequenceChanged(newSchema.Name, newTable.Name, column.Name).alter(dml...)...)
 		}
-		out = append(out, dml...)
 		return out, nil
 	}
 
@@ -807,17 +1066,27 @@ func buildDataInsert(ops *Operations, schema *ir.Schema, table *ir.Table, row *i
 	util.Assert(table.Rows != nil, "table.Rows should not be nil when calling buildDataInsert")
 	util.Assert(!row.Delete, "do not call buildDataInsert for a row marked for deletion")
 	values := make([]sql.ToSqlValue, len(row.Columns))
+	overriding := false
 	var err error
 	for i, col := range table.Rows.Columns {
+		if column := table.TryGetColumnNamed(col); column != nil {
+			if column.GeneratedAs != "" {
+				return nil, fmt.Errorf("column %s.%s.%s is generated, rows can't give it a value", schema.Name, table.Name, col)
+			}
+			if column.Identity != nil && column.Identity.Generation == ir.IdentityGenerationAlways {
+				overriding = true
+			}
+		}
 		values[i], err = ops.columnValueDefault(ops.logger, schema, table, col, row.Columns[i])
 		if err != nil {
 			return nil, err
 		}
 	}
 	return &sql.DataInsert{
-		Table:   sql.TableRef{Schema: schema.Name, Table: table.Name},
-		Columns: table.Rows.Columns,
-		Values:  values,
+		Table:                 sql.TableRef{Schema: schema.Name, Table: table.Name},
+		Columns:               table.Rows.Columns,
+		Values:                values,
+		OverridingSystemValue: overriding,
 	}, nil
 }
 
@@ -0,0 +1,247 @@
+package pgsql8
+
+import (
+	dbsql "database/sql"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffTables_IdentityAndGeneratedColumns(t *testing.T) {
+	byDefault := &ir.ColumnIdentity{Generation: ir.IdentityGenerationByDefault}
+	accounts := sql.TableRef{Schema: "public", Table: "accounts"}
+	sequence := sql.SequenceRef{Schema: "public", Sequence: "accounts_id_seq"}
+	id := sql.ColumnRef{Schema: "public", Table: "accounts", Column: "id"}
+	cents := &ir.Column{Name: "cents", Type: "bigint"}
+
+	tests := []struct {
+		name   string
+		target string
+		old    []*ir.Column
+		new    []*ir.Column
+		ddl1   []output.ToSql
+		ddl3   []output.ToSql
+	}{
+		{
+			name: "a serial column carries on from its sequence, which is only dropped once the identity has taken over",
+			old:  []*ir.Column{{Name: "id", Type: "serial"}},
+			new:  []*ir.Column{{Name: "id", Type: "integer", Identity: byDefault}},
+			ddl1: []output.ToSql{
+				&sql.SequenceOwnedByNone{Sequence: sequence},
+				sql.NewTableAlter(accounts,
+					&sql.TableAlterPartAnnotation{
+						Annotation: "changing from type serial",
+						Wrapped:    &sql.TableAlterPartColumnChangeType{Column: "id", Type: sql.TypeRef{Type: "integer"}},
+					},
+					&sql.TableAlterPartColumnDropDefault{Column: "id"},
+					&sql.TableAlterPartColumnAddIdentity{Column: "id", Identity: sql.ColumnIdentity{Generation: "BY DEFAULT"}},
+				),
+				&sql.SequenceSerialSetValFrom{Column: id, From: sequence},
+			},
+			ddl3: []output.ToSql{&sql.SequenceDrop{Sequence: sequence}},
+		},
+		{
+			name: "a nullable column gets its identity after it's made NOT NULL, then continues from its values",
+			old:  []*ir.Column{{Name: "id", Type: "integer", Nullable: true}},
+			new:  []*ir.Column{{Name: "id", Type: "integer", Identity: byDefault}},
+			ddl3: []output.ToSql{
+				sql.NewTableAlter(accounts,
+					&sql.TableAlterPartColumnSetNull{Column: "id", Nullable: false},
+					&sql.TableAlterPartColumnAddIdentity{Column: "id", Identity: sql.ColumnIdentity{Generation: "BY DEFAULT"}},
+				),
+				&sql.SequenceSerialSetValMax{Column: id},
+			},
+		},
+		{
+			name: "changed options reset the whole identity",
+			old:  []*ir.Column{{Name: "id", Type: "integer", Identity: byDefault}},
+			new: []*ir.Column{{Name: "id", Type: "integer", Identity: &ir.ColumnIdentity{
+				Generation: ir.IdentityGenerationAlways, Increment: util.Ptr(10), Cycle: true,
+			}}},
+			ddl1: []output.ToSql{
+				sql.NewTableAlter(accounts, &sql.TableAlterPartColumnSetIdentity{
+					Column:   "id",
+					Identity: sql.ColumnIdentity{Generation: "ALWAYS", Increment: util.Ptr(10), Cycle: true},
+				}),
+			},
+		},
+		{
+			name: "an identity is dropped",
+			old:  []*ir.Column{{Name: "id", Type: "integer", Identity: byDefault}},
+			new:  []*ir.Column{{Name: "id", Type: "integer"}},
+			ddl1: []output.ToSql{sql.NewTableAlter(accounts, &sql.TableAlterPartColumnDropIdentity{Column: "id"})},
+		},
+		{
+			name: "new identity columns are filled in and NOT NULL as they're added",
+			old:  []*ir.Column{{Name: "name", Type: "text"}},
+			new: []*ir.Column{
+				{Name: "name", Type: "text"},
+				{Name: "id", Type: "bigint", Identity: &ir.ColumnIdentity{Generation: ir.IdentityGenerationAlways, Start: util.Ptr(1000)}},
+			},
+			ddl1: []output.ToSql{
+				sql.NewTableAlter(accounts, &sql.TableAlterPartColumnCreate{
+					ColumnDef: sql.ColumnDefinition{
+						Name:     "id",
+						Type:     sql.TypeRef{Type: "bigint"},
+						Identity: &sql.ColumnIdentity{Generation: "ALWAYS", Start: util.Ptr(1000)},
+					},
+				}),
+			},
+		},
+		{
+			name: "before 17 a changed expression means adding the column again",
+			old:  []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100"}},
+			new:  []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100.0"}},
+			ddl1: []output.ToSql{
+				sql.NewTableAlter(accounts,
+					&sql.TableAlterPartAnnotation{
+						Annotation: "generated column expression changed, recreating it",
+						Wrapped:    &sql.TableAlterPartColumnDrop{Column: "dollars"},
+					},
+					&sql.TableAlterPartColumnCreate{ColumnDef: sql.ColumnDefinition{
+						Name:        "dollars",
+						Type:        sql.TypeRef{Type: "numeric"},
+						GeneratedAs: "cents / 100.0",
+					}},
+				),
+			},
+		},
+		{
+			name:   "from 17 the expression is changed in place",
+			target: "17",
+			old:    []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100"}},
+			new:    []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100.0"}},
+			ddl1: []output.ToSql{
+				sql.NewTableAlter(accounts, &sql.TableAlterPartColumnSetExpression{Column: "dollars", Expression: "cents / 100.0"}),
+			},
+		},
+		{
+			name: "a column which is no longer generated keeps its values",
+			old:  []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100"}},
+			new:  []*ir.Column{cents, {Name: "dollars", Type: "numeric"}},
+			ddl1: []output.ToSql{sql.NewTableAlter(accounts, &sql.TableAlterPartColumnDropExpression{Column: "dollars"})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := DefaultConfig
+			conf.TargetVersion = tt.target
+			ddl1, ddl3 := diffTablesCommon(t, NewOperations(conf).(*Operations),
+				&ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: tt.old}}},
+				&ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: tt.new}}},
+			)
+			assert.Equal(t, tt.ddl1, ddl1)
+			assert.Equal(t, tt.ddl3, ddl3)
+		})
+	}
+
+	// the parts of an identity change are each column alterations
+	oldSchema := &ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: []*ir.Column{{Name: "id", Type: "serial"}}}}}
+	newSchema := &ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: []*ir.Column{{Name: "id", Type: "integer", Identity: byDefault}}}}}
+	recorders := newChangeRecorders()
+	assert.NoError(t, diffTables(DefaultConfig, recorders[0], recorders[2], oldSchema, newSchema))
+	if assert.Len(t, recorders[0].changes, 3) && assert.Len(t, recorders[0].changes[1].Parts, 3) {
+		for _, part := range recorders[0].changes[1].Parts {
+			assert.Equal(t, "column", part.Kind)
+			assert.Equal(t, output.ChangeAlter, part.Action)
+		}
+	}
+
+	// but dropping an expression can't be done before 13
+	conf := DefaultConfig
+	conf.TargetVersion = "12"
+	_, _, err := diffTablesCommonErr(NewOperations(conf).(*Operations),
+		&ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: []*ir.Column{cents, {Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100"}}}}},
+		&ir.Schema{Name: "public", Tables: []*ir.Table{{Name: "accounts", PrimaryKey: []string{"id"}, Columns: []*ir.Column{cents, {Name: "dollars", Type: "numeric"}}}}},
+	)
+	assert.ErrorContains(t, err, "column public.accounts.dollars is no longer generated, which needs a target version of at least 13")
+}
+
+func TestBuild_IdentityAndGeneratedColumns(t *testing.T) {
+	schema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{{
+			Name:       "accounts",
+			PrimaryKey: []string{"id"},
+			Columns: []*ir.Column{
+				{Name: "id", Type: "integer", Identity: &ir.ColumnIdentity{Generation: ir.IdentityGenerationAlways, Cache: util.Ptr(20)}},
+				{Name: "cents", Type: "bigint"},
+				{Name: "dollars", Type: "numeric", GeneratedAs: "cents / 100.0"},
+			},
+			Rows: &ir.DataRows{
+				Columns: []string{"id", "cents"},
+				Rows:    []*ir.DataRow{{Columns: []*ir.DataCol{{Text: "1"}, {Text: "250"}}}},
+			},
+		}},
+	}
+	conf := DefaultConfig
+	conf.NewDatabase = &ir.Definition{Schemas: []*ir.Schema{schema}}
+	ops := NewOperations(conf).(*Operations)
+	accounts := sql.TableRef{Schema: "public", Table: "accounts"}
+
+	ddl, err := getCreateTableSql(conf, schema, schema.Tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []output.ToSql{
+		&sql.TableCreate{
+			Table: accounts,
+			Columns: []sql.ColumnDefinition{
+				{Name: "id", Type: sql.TypeRef{Type: "integer"}, Identity: &sql.ColumnIdentity{Generation: "ALWAYS", Cache: util.Ptr(20)}},
+				{Name: "cents", Type: sql.TypeRef{Type: "bigint"}},
+				{Name: "dollars", Type: sql.TypeRef{Type: "numeric"}, GeneratedAs: "cents / 100.0"},
+			},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+	}, ddl)
+
+	// rows give their own ids, which an identity generated always has to be told to accept
+	insert, err := buildDataInsert(ops, schema, schema.Tables[0], schema.Tables[0].Rows.Rows[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &sql.DataInsert{
+		Table:   accounts,
+		Columns: []string{"id", "cents"},
+		Values: []sql.ToSqlValue{
+			&sql.TypedValue{Type: "integer", Value: "1"},
+			&sql.TypedValue{Type: "bigint", Value: "250"},
+		},
+		OverridingSystemValue: true,
+	}, insert)
+
+	schema.Tables[0].Rows.Columns = []string{"id", "dollars"}
+	_, err = buildDataInsert(ops, schema, schema.Tables[0], schema.Tables[0].Rows.Rows[0])
+	assert.ErrorContains(t, err, "column public.accounts.dollars is generated, rows can't give it a value")
+
+	doc := &ir.Definition{Schemas: []*ir.Schema{schema}}
+	assert.NoError(t, checkGeneratedColumns(doc, NewVersionNum(12, 0)))
+	assert.ErrorContains(t, checkGeneratedColumns(doc, NewVersionNum(11, 0)), "column public.accounts.dollars is generated, which needs a target version of at least 12")
+	assert.ErrorContains(t, checkGeneratedColumns(doc, NewVersionNum(9, 6)), "column public.accounts.id is an identity, which needs a target version of at least 10")
+	schema.Tables[0].Columns[0].Type = "serial"
+	assert.ErrorContains(t, checkGeneratedColumns(doc, 0), "should be an integer type rather than serial")
+}
+
+func TestColumnEntry_IdentityToIR(t *testing.T) {
+	n := func(v int64) dbsql.NullInt64 { return dbsql.NullInt64{Int64: v, Valid: true} }
+	assert.Nil(t, columnEntry{AttrType: "integer"}.IdentityToIR())
+
+	// options which are the defaults for the type are left out
+	entry := columnEntry{
+		AttrType: "integer", Identity: "a",
+		IdentityStart: n(1), IdentityIncrement: n(1), IdentityMin: n(1), IdentityMax: n(2147483647), IdentityCache: n(1),
+	}
+	assert.Equal(t, &ir.ColumnIdentity{Generation: ir.IdentityGenerationAlways}, entry.IdentityToIR())
+
+	entry = columnEntry{
+		AttrType: "bigint", Identity: "d",
+		IdentityStart: n(-1), IdentityIncrement: n(-1), IdentityMin: n(-9223372036854775808), IdentityMax: n(-1), IdentityCache: n(50), IdentityCycle: true,
+	}
+	assert.Equal(t, &ir.ColumnIdentity{
+		Generation: ir.IdentityGenerationByDefault, Increment: util.Ptr(-1), Cache: util.Ptr(50), Cycle: true,
+	}, entry.IdentityToIR())
+}
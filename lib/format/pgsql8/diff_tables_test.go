@@ -396,3 +396,79 @@ func diffTablesCommonErr(ops *Operations, oldSchema, newSchema *ir.Schema) ([]ou
 	}
 	return ofs1.Body, ofs3.Body, nil
 }
+
+func TestDiffTables_DiffTables_NativePartitionSegments(t *testing.T) {
+	partitioned := func(segments ...*ir.TablePartitionSegment) *ir.Table {
+		return &ir.Table{
+			Name:       "events",
+			PrimaryKey: []string{"id"},
+			Columns: []*ir.Column{
+				{Name: "id", Type: "int"},
+			},
+			Partitioning: &ir.TablePartition{
+				Type:     ir.TablePartitionTypeRange,
+				Options:  []*ir.TablePartitionOption{{Name: "column", Value: "id"}},
+				Segments: segments,
+			},
+		}
+	}
+	standalone := func(name string) *ir.Table {
+		return &ir.Table{
+			Name:       name,
+			PrimaryKey: []string{"id"},
+			Columns: []*ir.Column{
+				{Name: "id", Type: "int"},
+			},
+		}
+	}
+	oldSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{
+			partitioned(
+				&ir.TablePartitionSegment{Name: "events_keep", Value: "FROM (0) TO (10)"},
+				&ir.TablePartitionSegment{Name: "events_rebound", Value: "FROM (10) TO (20)"},
+				&ir.TablePartitionSegment{Name: "events_drop", Value: "FROM (20) TO (30)"},
+				&ir.TablePartitionSegment{Name: "events_detach", Value: "FROM (30) TO (40)"},
+			),
+			standalone("events_attach"),
+		},
+	}
+	newSchema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{
+			partitioned(
+				&ir.TablePartitionSegment{Name: "events_keep", Value: "FROM (0)  TO (10)"},
+				&ir.TablePartitionSegment{Name: "events_rebound", Value: "FROM (10) TO (25)"},
+				&ir.TablePartitionSegment{Name: "events_attach", Value: "FROM (40) TO (50)"},
+				&ir.TablePartitionSegment{Name: "events_new", Value: "DEFAULT"},
+			),
+			standalone("events_detach"),
+		},
+	}
+	parent := sql.TableRef{Schema: "public", Table: "events"}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ddl1, ddl3 := diffTablesCommon(t, ops, oldSchema, newSchema)
+	assert.Equal(t, []output.ToSql{
+		&sql.TableDetachPartition{Table: parent, Partition: sql.TableRef{Schema: "public", Table: "events_rebound"}},
+		&sql.TableAttachPartition{Table: parent, Partition: sql.TableRef{Schema: "public", Table: "events_rebound"}, Bound: "FROM (10) TO (25)"},
+		&sql.TableAttachPartition{Table: parent, Partition: sql.TableRef{Schema: "public", Table: "events_attach"}, Bound: "FROM (40) TO (50)"},
+		&sql.TableCreatePartitionOf{Table: sql.TableRef{Schema: "public", Table: "events_new"}, Parent: parent, Bound: "DEFAULT"},
+		&sql.TableDetachPartition{Table: parent, Partition: sql.TableRef{Schema: "public", Table: "events_detach"}},
+	}, ddl1)
+	assert.Equal(t, []output.ToSql{
+		&sql.TableDrop{Table: sql.TableRef{Schema: "public", Table: "events_drop"}},
+	}, ddl3)
+
+	// the table being attached must not be dropped
+	ofs := output.NewAnnotationStrippingSegmenter(defaultQuoter(ops.config))
+	dropTables(ops.config, ofs, oldSchema, newSchema)
+	assert.Empty(t, ofs.Body)
+
+	// changing the partition key is not supported
+	newSchema.Tables[0].Partitioning.Options[0].Value = "id, other"
+	_, _, err := diffTablesCommonErr(ops, oldSchema, newSchema)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "partition key")
+	}
+}
@@ -1,14 +1,16 @@
 package pgsql8
 
 import (
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
 	"github.com/dbsteward/dbsteward/lib/ir"
 	"github.com/dbsteward/dbsteward/lib/output"
 )
 
-func diffTriggers(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
+func diffTriggers(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
 	for _, newTable := range newSchema.Tables {
 		oldTable := oldSchema.TryGetTableNamed(newTable.Name)
-		err := diffTriggersTable(ofs, oldSchema, oldTable, newSchema, newTable)
+		err := diffTriggersTable(conf, ofs, oldSchema, oldTable, newSchema, newTable)
 		if err != nil {
 			return err
 		}
@@ -16,12 +18,14 @@ func diffTriggers(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchem
 	return nil
 }
 
-func diffTriggersTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+func diffTriggersTable(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
 	if newTable == nil {
 		// if newTable does not exist, existing triggers will have been implicitly dropped
 		// and there cannot (should not?) be triggers for it
 		return nil
 	}
+	// changed triggers are replaced in place rather than dropped and created again, where the target version allows
+	replace := oldTable != nil && targetAtLeast(conf, FEAT_CREATE_OR_REPLACE_TRIGGER)
 
 	if oldTable != nil {
 		// drop old or changed triggers
@@ -30,8 +34,8 @@ func diffTriggersTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, old
 				continue
 			}
 			newTrigger := newSchema.TryGetTriggerMatching(oldTrigger)
-			if newTrigger == nil || !oldTrigger.Equals(newTrigger) {
-				ofs.WriteSql(getDropTriggerSql(oldSchema, oldTrigger)...)
+			if newTrigger == nil || (!replace && !oldTrigger.Equals(newTrigger)) {
+				writeChanges(ofs, triggerChanged(oldSchema.Name, oldTrigger, nil).drop(getDropTriggerSql(oldSchema, oldTrigger)...)...)
 			}
 		}
 	}
@@ -48,7 +52,17 @@ func diffTriggersTable(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, old
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			trigger := triggerChanged(newSchema.Name, oldTrigger, newTrigger)
+			if replace && oldTrigger != nil {
+				for _, stmt := range s {
+					if create, ok := stmt.(*sql.TriggerCreate); ok {
+						create.OrReplace = true
+					}
+				}
+				writeChanges(ofs, trigger.alter(s...)...)
+				continue
+			}
+			writeChanges(ofs, trigger.create(s...)...)
 		}
 	}
 	return nil
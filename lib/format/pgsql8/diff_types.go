@@ -2,6 +2,7 @@ package pgsql8
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/dbsteward/dbsteward/lib"
@@ -12,7 +13,7 @@ import (
 
 func diffTypes(conf lib.Config, differ *diff, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
 	dropTypes(ofs, oldSchema, newSchema)
-	err := createTypes(ofs, oldSchema, newSchema)
+	err := createTypes(conf, ofs, oldSchema, newSchema)
 	if err != nil {
 		return err
 	}
@@ -32,20 +33,51 @@ func diffTypes(conf lib.Config, differ *diff, ofs output.OutputFileSegmenter, ol
 			continue
 		}
 
+		if isTypeShell(oldType) && newType.Kind == ir.DataTypeKindBase {
+			// the shell the functions of the type were declared with is filled in
+			support, err := getTypeSupportChanges(conf, oldSchema, newSchema, newType)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, support...)
+			create, err := getCreateTypeSql(newSchema, newType)
+			if err != nil {
+				return fmt.Errorf("could not get data type creation sql for type alter: %w", err)
+			}
+			writeChanges(ofs, typeChanged(newSchema.Name, oldType, newType).alter(create...)...)
+			continue
+		}
+		if typeDependsOnOwnFunctions(oldType) {
+			return fmt.Errorf(
+				"%s type %s.%s can't be recreated to change it, as the functions it's defined with depend on it; remove it in one upgrade and add it back in the next",
+				oldType.Kind, newSchema.Name, newType.Name,
+			)
+		}
+
+		if oldType.Kind == ir.DataTypeKindEnum && newType.Kind == ir.DataTypeKindEnum {
+			altered, err := alterEnum(conf, ofs, newSchema, oldType, newType)
+			if err != nil {
+				return err
+			}
+			if altered {
+				continue
+			}
+		}
+
 		// TODO(feat) what about functions in other schemas?
 		for _, oldFunc := range commonSchema.GetFunctionsDependingOnType(oldSchema, oldType) {
 			ofs.WriteSql(sql.NewComment(
 				"Type migration of %s.%s requires recreating dependent function %s.%s",
 				newSchema.Name, newType.Name, oldSchema.Name, oldFunc.Name,
 			))
-			ofs.WriteSql(getFunctionDropSql(oldSchema, oldFunc)...)
+			writeChanges(ofs, functionChanged(oldSchema.Name, oldFunc, nil).drop(getFunctionDropSql(oldSchema, oldFunc)...)...)
 		}
 
-		columns, sql, err := alterColumnTypePlaceholder(conf, differ, oldType)
+		columns, placeholders, err := alterColumnTypePlaceholder(conf, differ, oldType)
 		if err != nil {
 			return err
 		}
-		ofs.WriteSql(sql...)
+		writeChanges(ofs, placeholders...)
 
 		if newType.Kind == ir.DataTypeKindDomain {
 			err = diffDomain(ofs, oldSchema, oldType, newSchema, newType)
@@ -53,47 +85,184 @@ func diffTypes(conf lib.Config, differ *diff, ofs output.OutputFileSegmenter, ol
 				return err
 			}
 		} else {
-			ofs.WriteSql(getDropTypeSql(oldSchema, oldType)...)
-			sql, err := getCreateTypeSql(newSchema, newType)
+			writeChanges(ofs, typeChanged(oldSchema.Name, oldType, nil).drop(getDropTypeSql(oldSchema, oldType)...)...)
+			// the type's functions were dropped along with it, so are created again too
+			create, err := getCreateTypeWithSupportChanges(conf, nil, newSchema, newType)
 			if err != nil {
 				return fmt.Errorf("could not get data type creation sql for type alter: %w", err)
 			}
-			ofs.WriteSql(sql...)
+			writeChanges(ofs, create...)
 		}
 
 		// functions are only recreated if they changed elsewise, so need to create them here
+		support := typeSupportFunctions(newSchema, newType)
 		for _, newFunc := range commonSchema.GetFunctionsDependingOnType(newSchema, newType) {
+			if slices.Contains(support, newFunc) {
+				// already created along with the type
+				continue
+			}
 			s, err := getFunctionCreationSql(conf, newSchema, newFunc)
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, functionChanged(newSchema.Name, nil, newFunc).create(s...)...)
 		}
 
-		ofs.WriteSql(alterColumnTypeRestore(columns, newSchema, newType)...)
+		writeChanges(ofs, alterColumnTypeRestore(conf, columns, newSchema, newType)...)
 	}
 	return nil
 }
 
+// alterEnum renames and adds enum values in place, which spares the columns using the enum from being rewritten.
+// It returns false when the type still has to be recreated, because values were removed or reordered
+// or the target version can't alter enums; values are renamed beforehand where possible, so rows keep them.
+func alterEnum(conf lib.Config, ofs output.OutputFileSegmenter, newSchema *ir.Schema, oldType *ir.TypeDef, newType *ir.TypeDef) (bool, error) {
+	ref := sql.TypeRef{Schema: newSchema.Name, Type: newType.Name}
+	renames, adds, rebuild := diffEnumValues(ref, oldType, newType)
+	altered := typeChanged(newSchema.Name, oldType, newType)
+
+	if len(renames) > 0 {
+		if targetOlderThan(conf, FEAT_ALTER_TYPE_RENAME_VALUE) {
+			return false, nil
+		}
+		for _, rename := range renames {
+			err := writeChanges(ofs, altered.alter(rename)...)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	if rebuild {
+		return false, nil
+	}
+
+	if len(adds) == 0 {
+		return true, nil
+	}
+	if targetOlderThan(conf, FEAT_ALTER_TYPE_ADD_VALUE) {
+		return false, nil
+	}
+	if targetAtLeast(conf, FEAT_ALTER_TYPE_ADD_VALUE_IN_TRANSACTION) {
+		// postgres won't let the rest of the transaction use the new values until it has committed,
+		// so column defaults using them wait for stage 3, see defaultUsesAddedEnumValue
+		for _, add := range adds {
+			err := writeChanges(ofs, altered.alter(add)...)
+			if err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	// before 12, or when the target isn't known, values can only be added outside of a transaction
+	cw, ok := ofs.(concurrentWriter)
+	if !ok {
+		return false, nil
+	}
+	for _, add := range adds {
+		err := cw.WriteConcurrentChanges(altered.alter(add)...)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// enumValuesAddedInPlace returns the values alterEnum adds to an existing enum, rather than the type being created
+// or recreated. These can't be used until the transaction adding them has committed.
+func enumValuesAddedInPlace(conf lib.Config, schema *ir.Schema, datatype *ir.TypeDef) []string {
+	if datatype.Kind != ir.DataTypeKindEnum {
+		return nil
+	}
+	if conf.OldDatabase == nil {
+		return nil
+	}
+	oldSchema := conf.OldDatabase.TryGetSchemaNamed(schema.Name)
+	if oldSchema == nil {
+		return nil
+	}
+	oldType := oldSchema.TryGetTypeNamed(datatype.Name)
+	if oldType == nil || oldType.Kind != ir.DataTypeKindEnum {
+		return nil
+	}
+	renames, adds, rebuild := diffEnumValues(sql.TypeRef{Schema: schema.Name, Type: datatype.Name}, oldType, datatype)
+	if rebuild || targetOlderThan(conf, FEAT_ALTER_TYPE_ADD_VALUE) ||
+		(len(renames) > 0 && targetOlderThan(conf, FEAT_ALTER_TYPE_RENAME_VALUE)) {
+		return nil
+	}
+	values := make([]string, len(adds))
+	for i, add := range adds {
+		values[i] = add.Value
+	}
+	return values
+}
+
+// diffEnumValues works out the renames and additions taking the old enum values to the new ones.
+// Values can't be removed or reordered in place, in which case rebuild is true.
+func diffEnumValues(ref sql.TypeRef, oldType *ir.TypeDef, newType *ir.TypeDef) (renames []*sql.TypeEnumRenameValue, adds []*sql.TypeEnumAddValue, rebuild bool) {
+	// the index of the old value each new value comes from, or -1 if it's added
+	from := make([]int, len(newType.EnumValues))
+	kept := 0
+	lastFrom := -1
+	firstKept := -1
+	for i, val := range newType.EnumValues {
+		from[i] = oldType.TryGetEnumValueNamed(val.Name)
+		if from[i] < 0 && val.OldName != "" {
+			from[i] = oldType.TryGetEnumValueNamed(val.OldName)
+			if from[i] >= 0 {
+				renames = append(renames, &sql.TypeEnumRenameValue{Type: ref, OldValue: val.OldName, NewValue: val.Name})
+			}
+		}
+		if from[i] < 0 {
+			continue
+		}
+		if from[i] < lastFrom {
+			rebuild = true
+		}
+		lastFrom = from[i]
+		kept += 1
+		if firstKept < 0 {
+			firstKept = i
+		}
+	}
+	if kept < len(oldType.EnumValues) || firstKept < 0 {
+		rebuild = true
+	}
+	if rebuild {
+		return renames, nil, true
+	}
+
+	// values ahead of every existing one go in front of the value after them, last first,
+	// the rest go after the value before them
+	for i := firstKept - 1; i >= 0; i-- {
+		adds = append(adds, &sql.TypeEnumAddValue{Type: ref, Value: newType.EnumValues[i].Name, Before: newType.EnumValues[i+1].Name})
+	}
+	for i := firstKept + 1; i < len(from); i++ {
+		if from[i] < 0 {
+			adds = append(adds, &sql.TypeEnumAddValue{Type: ref, Value: newType.EnumValues[i].Name, After: newType.EnumValues[i-1].Name})
+		}
+	}
+	return renames, adds, false
+}
+
 func dropTypes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) {
 	if oldSchema != nil {
 		for _, oldType := range oldSchema.Types {
 			if newSchema.TryGetTypeNamed(oldType.Name) == nil {
 				// TODO(go,pgsql) old dbsteward does GetDropSql(*newSchema*, oldtype) but that's not consistent with anything else. Need to validate
-				ofs.WriteSql(getDropTypeSql(oldSchema, oldType)...)
+				writeChanges(ofs, typeChanged(oldSchema.Name, oldType, nil).drop(getDropTypeSql(oldSchema, oldType)...)...)
 			}
 		}
 	}
 }
 
-func createTypes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
-	for _, newType := range newSchema.Types {
+func createTypes(conf lib.Config, ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema *ir.Schema) error {
+	for _, newType := range typesInDependencyOrder(newSchema, newSchema.Types) {
 		if oldSchema.TryGetTypeNamed(newType.Name) == nil {
-			sql, err := getCreateTypeSql(newSchema, newType)
+			create, err := getCreateTypeWithSupportChanges(conf, oldSchema, newSchema, newType)
 			if err != nil {
 				return fmt.Errorf("could not get data type creation sql for type diff: %w", err)
 			}
-			ofs.WriteSql(sql...)
+			writeChanges(ofs, create...)
 		}
 	}
 	return nil
@@ -102,29 +271,30 @@ func createTypes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, newSchema
 func diffDomain(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldType *ir.TypeDef, newSchema *ir.Schema, newType *ir.TypeDef) error {
 	oldInfo := oldType.DomainType
 	newInfo := newType.DomainType
+	domain := typeChanged(newSchema.Name, oldType, newType)
 
 	// TODO(feat) what about minor typename changes like "character varying" => "varchar" or "mytype" => "public.mytype"
 	if !strings.EqualFold(oldInfo.BaseType, newInfo.BaseType) {
 		// TODO(feat) don't we need to convert columns as in DiffTypes?
 		ofs.WriteSql(sql.NewComment("domain base type changed from %s to %s; recreating the type", oldInfo.BaseType, newInfo.BaseType))
-		ofs.WriteSql(getDropTypeSql(oldSchema, oldType)...)
+		writeChanges(ofs, domain.drop(getDropTypeSql(oldSchema, oldType)...)...)
 		sql, err := getCreateTypeSql(newSchema, newType)
 		if err != nil {
 			return fmt.Errorf("could not get data type creation sql for domain diff: %w", err)
 		}
-		ofs.WriteSql(sql...)
+		writeChanges(ofs, domain.create(sql...)...)
 	}
 
 	ref := sql.TypeRef{Schema: newSchema.Name, Type: newType.Name}
 
 	if oldInfo.Default != "" && newInfo.Default == "" {
-		ofs.WriteSql(&sql.Annotated{
+		writeChanges(ofs, domain.alter(&sql.Annotated{
 			Annotation: "domain default dropped",
 			Wrapped:    &sql.TypeDomainAlterDropDefault{Type: ref},
-		})
+		})...)
 	} else if oldInfo.Default != newInfo.Default {
 		// TODO(feat) what about recursively resolving this in the case that the base type is another user defined type?
-		ofs.WriteSql(&sql.Annotated{
+		writeChanges(ofs, domain.alter(&sql.Annotated{
 			Annotation: "domain default changed from " + oldInfo.Default,
 			Wrapped: &sql.TypeDomainAlterSetDefault{
 				Type: ref,
@@ -134,14 +304,14 @@ func diffDomain(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldType *i
 					IsNull: false, // TODO(feat) how do we distinguish default="NULL" meaning 'NULL' or NULL, and default="" meaning '' or NULL?
 				},
 			},
-		})
+		})...)
 	}
 
 	if oldInfo.Nullable != newInfo.Nullable {
-		ofs.WriteSql(&sql.Annotated{
+		writeChanges(ofs, domain.alter(&sql.Annotated{
 			Annotation: "domain nullability changed",
 			Wrapped:    &sql.TypeDomainAlterSetNullable{Type: ref, Nullable: newInfo.Nullable},
-		})
+		})...)
 	}
 
 	for _, newConstraint := range newType.DomainConstraints {
@@ -149,29 +319,70 @@ func diffDomain(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldType *i
 		if oldConstraint != nil {
 			if !oldConstraint.Equals(newConstraint) {
 				ofs.WriteSql(sql.NewComment("domain constraint %s changed from %s", oldConstraint.Name, oldConstraint.Check))
-				ofs.WriteSql(&sql.TypeDomainAlterDropConstraint{Type: ref, Constraint: oldConstraint.Name})
-				ofs.WriteSql(&sql.TypeDomainAlterAddConstraint{
-					Type:       ref,
-					Constraint: newConstraint.Name,
-					Check:      sql.RawSql(newConstraint.GetNormalizedCheck())},
-				)
+				writeChanges(ofs, domain.alter(
+					&sql.TypeDomainAlterDropConstraint{Type: ref, Constraint: oldConstraint.Name},
+					&sql.TypeDomainAlterAddConstraint{
+						Type:       ref,
+						Constraint: newConstraint.Name,
+						Check:      sql.RawSql(newConstraint.GetNormalizedCheck()),
+					},
+				)...)
 			}
 		} else {
 			ofs.WriteSql(sql.NewComment("domain constraint %s added", newConstraint.Name))
-			ofs.WriteSql(&sql.TypeDomainAlterAddConstraint{
+			writeChanges(ofs, domain.alter(&sql.TypeDomainAlterAddConstraint{
 				Type:       ref,
 				Constraint: newConstraint.Name,
-				Check:      sql.RawSql(newConstraint.GetNormalizedCheck())},
-			)
+				Check:      sql.RawSql(newConstraint.GetNormalizedCheck()),
+			})...)
 		}
 	}
 	for _, oldConstraint := range oldType.DomainConstraints {
 		if newType.TryGetDomainConstraintNamed(oldConstraint.Name) == nil {
-			ofs.WriteSql(&sql.Annotated{
+			writeChanges(ofs, domain.alter(&sql.Annotated{
 				Annotation: fmt.Sprintf("domain constraint %s removed", oldConstraint.Name),
 				Wrapped:    &sql.TypeDomainAlterDropConstraint{Type: ref, Constraint: oldConstraint.Name},
-			})
+			})...)
 		}
 	}
 	return nil
 }
+
+// dropCasts drops casts which were removed or changed, ahead of the types and functions they use.
+// Casts over types which changed are dropped too, in case the type is recreated, and are created again by createCasts.
+func dropCasts(conf lib.Config, ofs output.OutputFileSegmenter) {
+	for _, oldCast := range conf.OldDatabase.Casts {
+		newCast := conf.NewDatabase.TryGetCastMatching(oldCast)
+		if !oldCast.Equals(newCast) || castTypeChanged(conf, oldCast) {
+			writeChanges(ofs, castChanged(oldCast, newCast).drop(getDropCastSql(oldCast)...)...)
+		}
+	}
+}
+
+// createCasts creates casts which are new or were dropped by dropCasts, once the types and functions they use exist
+func createCasts(conf lib.Config, ofs output.OutputFileSegmenter) {
+	for _, newCast := range conf.NewDatabase.Casts {
+		oldCast := conf.OldDatabase.TryGetCastMatching(newCast)
+		if !newCast.Equals(oldCast) || castTypeChanged(conf, oldCast) {
+			writeChanges(ofs, castChanged(oldCast, newCast).create(getCreateCastSql(newCast)...)...)
+		}
+	}
+}
+
+// castTypeChanged is whether the old cast is from or to a type which changed
+func castTypeChanged(conf lib.Config, oldCast *ir.Cast) bool {
+	if oldCast == nil {
+		return false
+	}
+	for _, oldSchema := range conf.OldDatabase.Schemas {
+		for _, oldType := range oldSchema.Types {
+			if oldCast.DependsOnType(oldSchema, oldType) {
+				newType := conf.NewDatabase.TryGetSchemaNamed(oldSchema.Name).TryGetTypeNamed(oldType.Name)
+				if !oldType.Equals(newType) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild_RangeAndBaseTypes(t *testing.T) {
+	doc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Types: []*ir.TypeDef{
+				// declared ahead of the domain it's over, which has to be created first
+				{Name: "amounts", Kind: ir.DataTypeKindRange, RangeType: &ir.DataTypeRangeType{Subtype: "public.amount"}},
+				{Name: "amount", Kind: ir.DataTypeKindDomain, DomainType: &ir.DataTypeDomainType{BaseType: "integer", Nullable: true}},
+				{
+					Name: "cents",
+					Kind: ir.DataTypeKindBase,
+					BaseType: &ir.DataTypeBaseType{
+						Input:          "cents_in",
+						Output:         "cents_out",
+						InternalLength: "4",
+						PassedByValue:  true,
+						Alignment:      "int4",
+					},
+				},
+			},
+			Functions: []*ir.Function{
+				{
+					Name:        "cents_in",
+					Returns:     "public.cents",
+					CachePolicy: "IMMUTABLE",
+					Parameters:  []*ir.FunctionParameter{{Type: "cstring"}},
+					Definitions: []*ir.FunctionDefinition{{SqlFormat: ir.SqlFormatPgsql8, Language: "internal", Text: "int4in"}},
+				},
+				{
+					Name:        "cents_out",
+					Returns:     "cstring",
+					CachePolicy: "IMMUTABLE",
+					Parameters:  []*ir.FunctionParameter{{Type: "public.cents"}},
+					Definitions: []*ir.FunctionDefinition{{SqlFormat: ir.SqlFormatPgsql8, Language: "internal", Text: "int4out"}},
+				},
+			},
+		}},
+		Casts: []*ir.Cast{{Source: "public.cents", Target: "integer", Method: ir.CastMethodBinary, Context: ir.CastContextImplicit}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(ofs, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cents := sql.TypeRef{Schema: "public", Type: "cents"}
+	// the shell comes before the support functions, which are created with
+	// their type and not again with the others, and the cast comes last
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.TypeDomainCreate{
+			Type:        sql.TypeRef{Schema: "public", Type: "amount"},
+			BaseType:    "integer",
+			Nullable:    true,
+			Constraints: []sql.TypeDomainCreateConstraint{},
+		},
+		&sql.TypeRangeCreate{Type: sql.TypeRef{Schema: "public", Type: "amounts"}, Subtype: "public.amount"},
+		&sql.TypeShellCreate{Type: cents},
+		&sql.FunctionCreate{
+			Function:    sql.FunctionRef{Schema: "public", Function: "cents_in", Params: []string{"cstring"}},
+			Returns:     "public.cents",
+			Definition:  "int4in",
+			Language:    "internal",
+			CachePolicy: "IMMUTABLE",
+		},
+		&sql.FunctionCreate{
+			Function:    sql.FunctionRef{Schema: "public", Function: "cents_out", Params: []string{"public.cents"}},
+			Returns:     "cstring",
+			Definition:  "int4out",
+			Language:    "internal",
+			CachePolicy: "IMMUTABLE",
+		},
+		&sql.TypeBaseCreate{
+			Type:           cents,
+			Input:          "cents_in",
+			Output:         "cents_out",
+			InternalLength: "4",
+			PassedByValue:  true,
+			Alignment:      "int4",
+		},
+		&sql.CastCreate{Source: cents, Target: sql.TypeRef{Type: "integer"}, Context: "IMPLICIT"},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+
+	assert.NoError(t, checkTypes(doc, NewVersionNum(9, 2)))
+	assert.ErrorContains(t, checkTypes(doc, NewVersionNum(9, 1)), "range type public.amounts needs a target version of at least 9.2")
+}
+
+func TestDiffTypes_RangesAndCasts(t *testing.T) {
+	spanDoc := func(subtype string, context ir.CastContext) *ir.Definition {
+		return &ir.Definition{
+			Schemas: []*ir.Schema{{
+				Name:  "public",
+				Types: []*ir.TypeDef{{Name: "span", Kind: ir.DataTypeKindRange, RangeType: &ir.DataTypeRangeType{Subtype: subtype}}},
+				Tables: []*ir.Table{{
+					Name:       "bookings",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "span", Type: "public.span", Nullable: true},
+					},
+				}},
+			}},
+			Casts: []*ir.Cast{{Source: "public.span", Target: "text", Method: ir.CastMethodInout, Context: context}},
+		}
+	}
+	span := sql.TypeRef{Schema: "public", Type: "span"}
+	text := sql.TypeRef{Type: "text"}
+	bookings := sql.TableRef{Schema: "public", Table: "bookings"}
+
+	tests := []struct {
+		name     string
+		old      *ir.Definition
+		new      *ir.Definition
+		expected []output.ToSql
+	}{
+		{
+			name: "new ranges are created ahead of their tables, and casts after",
+			old:  &ir.Definition{Schemas: []*ir.Schema{{Name: "public"}}},
+			new:  spanDoc("integer", ir.CastContextExplicit),
+			expected: []output.ToSql{
+				&sql.TypeRangeCreate{Type: span, Subtype: "integer"},
+				&sql.TableCreate{
+					Table: bookings,
+					Columns: []sql.ColumnDefinition{
+						{Name: "id", Type: sql.TypeRef{Type: "integer"}},
+						{Name: "span", Type: span},
+					},
+					OtherOptions: []sql.TableCreateOption{},
+				},
+				&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "bookings", Column: "id"}},
+				&sql.ConstraintCreatePrimaryKey{Table: bookings, Constraint: "bookings_pkey", Columns: []string{"id"}},
+				&sql.CastCreate{Source: span, Target: text, Inout: true},
+			},
+		},
+		{
+			name: "a changed range is recreated, with the casts over it put back afterwards",
+			old:  spanDoc("integer", ir.CastContextExplicit),
+			new:  spanDoc("bigint", ir.CastContextExplicit),
+			expected: []output.ToSql{
+				&sql.CastDrop{Source: span, Target: text},
+				&sql.TableAlterParts{
+					Table: bookings,
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeType{Column: "span", Type: text}},
+				},
+				&sql.TypeDrop{Type: span},
+				&sql.TypeRangeCreate{Type: span, Subtype: "bigint"},
+				&sql.TableAlterParts{
+					Table: bookings,
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeTypeUsingCast{Column: "span", Type: span}},
+				},
+				&sql.CastCreate{Source: span, Target: text, Inout: true},
+			},
+		},
+		{
+			name: "a changed cast is replaced",
+			old:  spanDoc("integer", ir.CastContextExplicit),
+			new:  spanDoc("integer", ir.CastContextAssignment),
+			expected: []output.ToSql{
+				&sql.CastDrop{Source: span, Target: text},
+				&sql.CastCreate{Source: span, Target: text, Inout: true, Context: "ASSIGNMENT"},
+			},
+		},
+		{
+			name: "an unchanged one is left alone",
+			old:  spanDoc("integer", ir.CastContextExplicit),
+			new:  spanDoc("integer", ir.CastContextExplicit),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorders := diffChangesCommon(t, DefaultConfig, tt.old, tt.new)
+			assert.Equal(t, tt.expected, changeStatements(recorders[0].changes))
+			for _, recorder := range recorders[1:] {
+				assert.Empty(t, changeStatements(recorder.changes), "stage %d", recorder.stage)
+			}
+		})
+	}
+}
+
+func TestDiffTypes_BaseTypes(t *testing.T) {
+	baseDoc := func(alignment string) *ir.Definition {
+		return &ir.Definition{
+			Schemas: []*ir.Schema{{
+				Name: "public",
+				Types: []*ir.TypeDef{{
+					Name: "cents",
+					Kind: ir.DataTypeKindBase,
+					BaseType: &ir.DataTypeBaseType{
+						Input:          "cents_in",
+						Output:         "cents_out",
+						InternalLength: "4",
+						PassedByValue:  true,
+						Alignment:      alignment,
+					},
+				}},
+				Functions: []*ir.Function{
+					{
+						Name:        "cents_in",
+						Returns:     "public.cents",
+						CachePolicy: "IMMUTABLE",
+						Parameters:  []*ir.FunctionParameter{{Type: "cstring"}},
+						Definitions: []*ir.FunctionDefinition{{SqlFormat: ir.SqlFormatPgsql8, Language: "internal", Text: "int4in"}},
+					},
+					{
+						Name:        "cents_out",
+						Returns:     "cstring",
+						CachePolicy: "IMMUTABLE",
+						Parameters:  []*ir.FunctionParameter{{Type: "public.cents"}},
+						Definitions: []*ir.FunctionDefinition{{SqlFormat: ir.SqlFormatPgsql8, Language: "internal", Text: "int4out"}},
+					},
+				},
+			}},
+		}
+	}
+	shellDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name:  "public",
+			Types: []*ir.TypeDef{{Name: "cents", Kind: ir.DataTypeKindBase}},
+		}},
+	}
+
+	// a shell is filled in with its functions in between
+	recorders := diffChangesCommon(t, DefaultConfig, shellDoc, baseDoc("int4"))
+	assert.Equal(t, []output.ToSql{
+		&sql.FunctionCreate{
+			Function:    sql.FunctionRef{Schema: "public", Function: "cents_in", Params: []string{"cstring"}},
+			Returns:     "public.cents",
+			Definition:  "int4in",
+			Language:    "internal",
+			CachePolicy: "IMMUTABLE",
+		},
+		&sql.FunctionCreate{
+			Function:    sql.FunctionRef{Schema: "public", Function: "cents_out", Params: []string{"public.cents"}},
+			Returns:     "cstring",
+			Definition:  "int4out",
+			Language:    "internal",
+			CachePolicy: "IMMUTABLE",
+		},
+		&sql.TypeBaseCreate{
+			Type:           sql.TypeRef{Schema: "public", Type: "cents"},
+			Input:          "cents_in",
+			Output:         "cents_out",
+			InternalLength: "4",
+			PassedByValue:  true,
+			Alignment:      "int4",
+		},
+	}, changeStatements(recorders[0].changes))
+
+	// a defined base type can't be recreated in the same upgrade as its functions
+	ops := NewOperations(DefaultConfig).(*Operations)
+	_, err := ops.diffChanges(baseDoc("int4"), baseDoc("double"))
+	assert.ErrorContains(t, err, "base type public.cents can't be recreated to change it")
+}
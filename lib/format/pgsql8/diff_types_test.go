@@ -147,3 +147,236 @@ func TestDiffTypes_DiffTypes_RecreateDependentFunctions(t *testing.T) {
 		},
 	}, ofs.Body)
 }
+
+func TestDiffTypes_EnumValues(t *testing.T) {
+	status := sql.TypeRef{Schema: "public", Type: "status"}
+	oldSchema := &ir.Schema{
+		Name: "public",
+		Types: []*ir.TypeDef{{
+			Name:       "status",
+			Kind:       ir.DataTypeKindEnum,
+			EnumValues: []ir.DataTypeEnumValue{{Name: "new"}, {Name: "open"}, {Name: "closed"}},
+		}},
+		Tables: []*ir.Table{{
+			Name:       "tickets",
+			PrimaryKey: []string{"id"},
+			Columns: []*ir.Column{
+				{Name: "id", Type: "integer"},
+				{Name: "status", Type: "public.status"},
+			},
+		}},
+	}
+	tests := []struct {
+		name       string
+		target     string
+		values     []ir.DataTypeEnumValue
+		changes    []output.ToSql
+		concurrent []output.ToSql
+	}{
+		{
+			name:   "values are added in place, wherever they go, once the transaction has committed",
+			values: []ir.DataTypeEnumValue{{Name: "draft"}, {Name: "queued"}, {Name: "new"}, {Name: "open"}, {Name: "stalled"}, {Name: "closed"}, {Name: "archived"}},
+			concurrent: []output.ToSql{
+				&sql.TypeEnumAddValue{Type: status, Value: "queued", Before: "new"},
+				&sql.TypeEnumAddValue{Type: status, Value: "draft", Before: "queued"},
+				&sql.TypeEnumAddValue{Type: status, Value: "stalled", After: "open"},
+				&sql.TypeEnumAddValue{Type: status, Value: "archived", After: "closed"},
+			},
+		},
+		{
+			name:    "values are added in the transaction from 12",
+			target:  "12",
+			values:  []ir.DataTypeEnumValue{{Name: "new"}, {Name: "open"}, {Name: "closed"}, {Name: "archived"}},
+			changes: []output.ToSql{&sql.TypeEnumAddValue{Type: status, Value: "archived", After: "closed"}},
+		},
+		{
+			name:       "values are added after the transaction before 12",
+			target:     "11",
+			values:     []ir.DataTypeEnumValue{{Name: "new"}, {Name: "open"}, {Name: "closed"}, {Name: "archived"}},
+			concurrent: []output.ToSql{&sql.TypeEnumAddValue{Type: status, Value: "archived", After: "closed"}},
+		},
+		{
+			name:       "renamed values keep their rows",
+			values:     []ir.DataTypeEnumValue{{Name: "new"}, {Name: "in progress", OldName: "open"}, {Name: "done"}, {Name: "closed"}},
+			changes:    []output.ToSql{&sql.TypeEnumRenameValue{Type: status, OldValue: "open", NewValue: "in progress"}},
+			concurrent: []output.ToSql{&sql.TypeEnumAddValue{Type: status, Value: "done", After: "in progress"}},
+		},
+		{
+			name:   "before 9.1 the type is recreated",
+			target: "9.0",
+			values: []ir.DataTypeEnumValue{{Name: "new"}, {Name: "open"}, {Name: "closed"}, {Name: "archived"}},
+			changes: []output.ToSql{
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeType{Column: "status", Type: sql.TypeRef{Type: "text"}}},
+				},
+				&sql.TypeDrop{Type: status},
+				&sql.TypeEnumCreate{Type: status, Values: []string{"new", "open", "closed", "archived"}},
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeTypeUsingCast{Column: "status", Type: status}},
+				},
+			},
+		},
+		{
+			name:   "removed values need the type recreated, after renaming what can be",
+			values: []ir.DataTypeEnumValue{{Name: "fresh", OldName: "new"}, {Name: "closed"}},
+			changes: []output.ToSql{
+				&sql.TypeEnumRenameValue{Type: status, OldValue: "new", NewValue: "fresh"},
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeType{Column: "status", Type: sql.TypeRef{Type: "text"}}},
+				},
+				&sql.TypeDrop{Type: status},
+				&sql.TypeEnumCreate{Type: status, Values: []string{"fresh", "closed"}},
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeTypeUsingCast{Column: "status", Type: status}},
+				},
+			},
+		},
+		{
+			name:   "reordered values need the type recreated",
+			values: []ir.DataTypeEnumValue{{Name: "open"}, {Name: "new"}, {Name: "closed"}},
+			changes: []output.ToSql{
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeType{Column: "status", Type: sql.TypeRef{Type: "text"}}},
+				},
+				&sql.TypeDrop{Type: status},
+				&sql.TypeEnumCreate{Type: status, Values: []string{"open", "new", "closed"}},
+				&sql.TableAlterParts{
+					Table: sql.TableRef{Schema: "public", Table: "tickets"},
+					Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnChangeTypeUsingCast{Column: "status", Type: status}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSchema := &ir.Schema{
+				Name:   "public",
+				Types:  []*ir.TypeDef{{Name: "status", Kind: ir.DataTypeKindEnum, EnumValues: tt.values}},
+				Tables: oldSchema.Tables,
+			}
+			conf := DefaultConfig
+			conf.TargetVersion = tt.target
+			// the columns using the type are looked up across the whole definition
+			conf.OldDatabase = &ir.Definition{Schemas: []*ir.Schema{oldSchema}}
+			conf.NewDatabase = &ir.Definition{Schemas: []*ir.Schema{newSchema}}
+			differ := newDiff(NewOperations(conf).(*Operations), defaultQuoter(conf))
+			var err error
+			differ.NewTableDependency, err = conf.NewDatabase.TableDependencyOrder()
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := newBuildChangeRecorder()
+			err = diffTypes(conf, differ, recorder, oldSchema, newSchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.changes, changeStatements(recorder.changes))
+			assert.Equal(t, tt.concurrent, changeStatements(recorder.concurrent))
+		})
+	}
+}
+
+func TestDiffTypes_EnumValueDefaults(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Types: []*ir.TypeDef{{
+				Name:       "status",
+				Kind:       ir.DataTypeKindEnum,
+				EnumValues: []ir.DataTypeEnumValue{{Name: "open"}, {Name: "closed"}},
+			}},
+			Tables: []*ir.Table{{
+				Name:       "tickets",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "status", Type: "public.status", Default: "'open'", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Types: []*ir.TypeDef{{
+				Name:       "status",
+				Kind:       ir.DataTypeKindEnum,
+				EnumValues: []ir.DataTypeEnumValue{{Name: "new"}, {Name: "open"}, {Name: "closed"}},
+			}},
+			Tables: []*ir.Table{{
+				Name:       "tickets",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "status", Type: "public.status", Default: "'new'", Nullable: true},
+					{Name: "triage", Type: "public.status", Default: "'new'::public.status"},
+				},
+			}},
+		}},
+	}
+
+	tickets := sql.TableRef{Schema: "public", Table: "tickets"}
+	status := sql.TypeRef{Schema: "public", Type: "status"}
+	addTriage := func(ifNotExists bool) output.ToSql {
+		return &sql.TableAlterParts{
+			Table: tickets,
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnCreate{
+				ColumnDef:   sql.ColumnDefinition{Name: "triage", Type: status},
+				IfNotExists: ifNotExists,
+			}},
+		}
+	}
+	tests := []struct {
+		target     string
+		stage1     []output.ToSql
+		concurrent []output.ToSql
+	}{
+		{
+			stage1:     []output.ToSql{addTriage(false)},
+			concurrent: []output.ToSql{&sql.TypeEnumAddValue{Type: status, Value: "new", Before: "open"}},
+		},
+		{
+			target: "12",
+			stage1: []output.ToSql{&sql.TypeEnumAddValue{Type: status, Value: "new", Before: "open"}, addTriage(true)},
+		},
+	}
+	for _, tt := range tests {
+		conf := DefaultConfig
+		conf.TargetVersion = tt.target
+		recorders := diffChangesCommon(t, conf, oldDoc, newDoc)
+		assert.Equal(t, tt.stage1, changeStatements(recorders[0].changes), tt.target)
+		assert.Equal(t, tt.concurrent, changeStatements(recorders[0].concurrent), tt.target)
+		// the value isn't usable until the transaction adding it has committed, so the defaults wait for stage 3
+		assert.Equal(t, []output.ToSql{
+			&sql.TableAlterParts{
+				Table: tickets,
+				Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnSetDefault{Column: "triage", Default: sql.RawSql("'new'::public.status")}},
+			},
+			&sql.DataUpdate{
+				Table:          tickets,
+				UpdatedColumns: []string{"triage"},
+				UpdatedValues:  []sql.ToSqlValue{sql.RawSql("DEFAULT")},
+				KeyColumns:     []string{"triage"},
+				KeyValues:      []sql.ToSqlValue{sql.RawSql("NULL")},
+			},
+			&sql.TableAlterParts{
+				Table: tickets,
+				Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnSetDefault{Column: "status", Default: sql.RawSql("'new'")}},
+			},
+			&sql.TableAlterParts{
+				Table: tickets,
+				Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnSetNull{Column: "triage"}},
+			},
+		}, changeStatements(recorders[2].changes), tt.target)
+	}
+
+	conf := DefaultConfig
+	conf.SingleStageUpgrade = true
+	_, err := NewOperations(conf).(*Operations).diffChanges(oldDoc, newDoc)
+	assert.ErrorContains(t, err, "column public.tickets.triage defaults to value 'new' added to type public.status")
+}
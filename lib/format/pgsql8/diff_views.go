@@ -1,7 +1,9 @@
 package pgsql8
 
 import (
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/dbsteward/dbsteward/lib"
 	"github.com/dbsteward/dbsteward/lib/ir"
@@ -12,6 +14,14 @@ import (
 
 func createViewsOrdered(conf lib.Config, ofs output.OutputFileSegmenter, oldDoc *ir.Definition, newDoc *ir.Definition) error {
 	l := conf.Logger
+	kept, err := keptMaterializedViews(oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
+	dropped, err := droppedViews(conf, oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
 	return forEachViewInDepOrder(newDoc, func(newRef ir.ViewRef) error {
 		ll := l.With(slog.String("view", newRef.String()))
 		ll.Debug("consider creating")
@@ -24,7 +34,11 @@ func createViewsOrdered(conf lib.Config, ofs output.OutputFileSegmenter, oldDoc
 		if oldView != nil {
 			ll = ll.With(slog.String("old view", oldView.Name))
 		}
-		if shouldCreateView(conf, oldView, newRef.View) {
+		if newRef.View.Materialized && kept[viewKey(newRef)] {
+			ll.Debug("materialized view kept, diffing indexes")
+			return diffViewIndexes(ofs, oldSchema, oldView, newRef.Schema, newRef.View)
+		}
+		if newRef.View.Materialized || dropped[viewKey(newRef)] || shouldCreateView(conf, oldView, newRef.View) {
 			ll.Debug("shouldCreateView returned true")
 			s, err := getCreateViewSql(conf, newRef.Schema, newRef.View)
 			for _, s1 := range s {
@@ -33,7 +47,7 @@ func createViewsOrdered(conf lib.Config, ofs output.OutputFileSegmenter, oldDoc
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, viewChanged(newRef.Schema.Name, nil, newRef.View).create(s...)...)
 		} else {
 			ll.Debug("shouldCreateView returned false")
 		}
@@ -45,21 +59,147 @@ func shouldCreateView(conf lib.Config, oldView, newView *ir.View) bool {
 	return oldView == nil || conf.AlwaysRecreateViews || !oldView.Equals(newView, ir.SqlFormatPgsql8)
 }
 
-func dropViewsOrdered(ofs output.OutputFileSegmenter, oldDoc *ir.Definition, newDoc *ir.Definition) error {
-	return forEachViewInDepOrder(oldDoc, func(oldViewRef ir.ViewRef) error {
-		newSchema := newDoc.TryGetSchemaNamed(oldViewRef.Schema.Name)
-		newView := newSchema.TryGetViewNamed(oldViewRef.View.Name)
-		if shouldDropView(oldViewRef.View, newSchema, newView) {
-			ofs.WriteSql(getDropViewSql(oldViewRef.Schema, oldViewRef.View)...)
+func dropViewsOrdered(conf lib.Config, ofs output.OutputFileSegmenter, oldDoc *ir.Definition, newDoc *ir.Definition) error {
+	dropped, err := droppedViews(conf, oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
+	// views must be dropped before the views they depend on, so collect them and drop in reverse
+	toDrop := []ir.ViewRef{}
+	err = forEachViewInDepOrder(oldDoc, func(oldViewRef ir.ViewRef) error {
+		if dropped[viewKey(oldViewRef)] {
+			toDrop = append(toDrop, oldViewRef)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(toDrop) - 1; i >= 0; i-- {
+		writeChanges(ofs, viewChanged(toDrop[i].Schema.Name, toDrop[i].View, nil).drop(getDropViewSql(toDrop[i].Schema, toDrop[i].View)...)...)
+	}
+	return nil
+}
+
+// droppedViews returns the old views which are dropped ahead of the upgrade: those which changed
+// or no longer exist, and any view depending on one of those, as postgres won't let us drop a view out
+// from under its dependents.
+func droppedViews(conf lib.Config, oldDoc *ir.Definition, newDoc *ir.Definition) (map[string]bool, error) {
+	kept, err := keptMaterializedViews(oldDoc, newDoc)
+	if err != nil {
+		return nil, err
+	}
+	dropped := map[string]bool{}
+	err = forEachViewInDepOrder(oldDoc, func(oldRef ir.ViewRef) error {
+		newSchema := newDoc.TryGetSchemaNamed(oldRef.Schema.Name)
+		if newSchema == nil {
+			// the whole schema is dropped, taking the view with it
+			return nil
+		}
+		newView := newSchema.TryGetViewNamed(oldRef.View.Name)
+		drop := false
+		if oldRef.View.Materialized {
+			drop = !kept[viewKey(oldRef)]
+		} else {
+			drop = shouldDropView(conf, oldRef.View, newSchema, newView)
+		}
+		if !drop {
+			deps, err := getViewDependencies(oldDoc, oldRef.Schema, oldRef.View)
+			if err != nil {
+				return err
+			}
+			for _, dep := range deps {
+				// dependencies have already been visited
+				if dropped[viewKey(dep)] {
+					drop = true
+					break
+				}
+			}
+		}
+		if drop {
+			dropped[viewKey(oldRef)] = true
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+// refreshViewsOrdered refreshes the materialized views that an upgrade leaves in place,
+// as their contents may be stale after the data changes. Recreated views are already fresh.
+func refreshViewsOrdered(conf lib.Config, ofs output.OutputFileSegmenter, oldDoc *ir.Definition, newDoc *ir.Definition) error {
+	kept, err := keptMaterializedViews(oldDoc, newDoc)
+	if err != nil {
+		return err
+	}
+	return forEachViewInDepOrder(newDoc, func(newRef ir.ViewRef) error {
+		if newRef.View.Materialized && kept[viewKey(newRef)] {
+			oldView := oldDoc.TryGetSchemaNamed(newRef.Schema.Name).TryGetViewNamed(newRef.View.Name)
+			writeChanges(ofs, viewChanged(newRef.Schema.Name, oldView, newRef.View).alter(getRefreshViewSql(newRef.Schema, newRef.View)...)...)
+		}
+		return nil
+	})
+}
+
+// keptMaterializedViews returns the materialized views which don't need to be dropped and recreated,
+// because they are unchanged and depend only on other kept materialized views.
+// Rebuilding a materialized view can be expensive, so unlike regular views we avoid it where we can,
+// even when regular views are always recreated.
+func keptMaterializedViews(oldDoc *ir.Definition, newDoc *ir.Definition) (map[string]bool, error) {
+	kept := map[string]bool{}
+	if oldDoc == nil || newDoc == nil {
+		return kept, nil
+	}
+	err := forEachViewInDepOrder(oldDoc, func(oldRef ir.ViewRef) error {
+		newView := newDoc.TryGetSchemaNamed(oldRef.Schema.Name).TryGetViewNamed(oldRef.View.Name)
+		if !oldRef.View.Materialized || !oldRef.View.Equals(newView, ir.SqlFormatPgsql8) {
+			return nil
+		}
+		deps, err := getViewDependencies(oldDoc, oldRef.Schema, oldRef.View)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			// dependencies have already been visited
+			if !kept[viewKey(dep)] {
+				return nil
+			}
 		}
+		kept[viewKey(oldRef)] = true
 		return nil
 	})
+	return kept, err
+}
+
+func viewKey(ref ir.ViewRef) string {
+	return strings.ToLower(fmt.Sprintf("%s.%s", ref.Schema.Name, ref.View.Name))
+}
+
+func diffViewIndexes(ofs output.OutputFileSegmenter, oldSchema *ir.Schema, oldView *ir.View, newSchema *ir.Schema, newView *ir.View) error {
+	for _, oldIndex := range oldView.Indexes {
+		newIndex := newView.TryGetIndexMatching(oldIndex)
+		if newIndex == nil || !oldIndex.Equals(newIndex, ir.SqlFormatPgsql8) {
+			err := writeChanges(ofs, indexChanged(oldSchema.Name, oldIndex, nil).drop(getDropIndexSql(oldSchema, oldIndex)...)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, newIndex := range newView.Indexes {
+		oldIndex := oldView.TryGetIndexMatching(newIndex)
+		if oldIndex == nil || !oldIndex.Equals(newIndex, ir.SqlFormatPgsql8) {
+			err := writeChanges(ofs, indexChanged(newSchema.Name, nil, newIndex).create(getCreateViewIndexSql(newSchema, newView, newIndex)...)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func shouldDropView(oldView *ir.View, newSchema *ir.Schema, newView *ir.View) bool {
+func shouldDropView(conf lib.Config, oldView *ir.View, newSchema *ir.Schema, newView *ir.View) bool {
 	// don't drop the view if new_schema is null - we've already dropped the view by this point
-	// otherwise, drop if it changed or no longer exists
-	return newSchema != nil && !oldView.Equals(newView, ir.SqlFormatPgsql8)
+	// otherwise, drop if it's always recreated, changed or no longer exists
+	return newSchema != nil && (conf.AlwaysRecreateViews || !oldView.Equals(newView, ir.SqlFormatPgsql8))
 }
 
 func forEachViewInDepOrder(doc *ir.Definition, callback func(ir.ViewRef) error) error {
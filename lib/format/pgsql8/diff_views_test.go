@@ -69,3 +69,262 @@ func TestCreateViewsOrdered(t *testing.T) {
 		ofs.Body,
 	)
 }
+
+func TestDropViewsOrdered_DependentsFirst(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{
+				{
+					Name:           "recent_orders",
+					DependsOnViews: []string{"orders_view"},
+					Queries:        []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM orders_view LIMIT 10"}},
+				},
+				{
+					Name:    "orders_view",
+					Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM orders"}},
+				},
+			},
+		}},
+	}
+	newDoc := &ir.Definition{Schemas: []*ir.Schema{{Name: "public"}}}
+	ofs := output.NewAnnotationStrippingSegmenter(defaultQuoter(DefaultConfig))
+	assert.NoError(t, dropViewsOrdered(DefaultConfig, ofs, oldDoc, newDoc))
+	// postgres won't drop a view out from under the views using it
+	assert.Equal(t, []output.ToSql{
+		&sql.ViewDrop{View: sql.ViewRef{Schema: "public", View: "recent_orders"}},
+		&sql.ViewDrop{View: sql.ViewRef{Schema: "public", View: "orders_view"}},
+	}, ofs.Body)
+}
+
+func TestDropViewsOrdered_OnlyChanged(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{
+				{
+					Name:    "orders_view",
+					Owner:   "app",
+					Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM orders"}},
+				},
+				{
+					Name:    "customers_view",
+					Owner:   "app",
+					Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM customers"}},
+				},
+			},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{
+				{
+					Name:    "orders_view",
+					Owner:   "APP",
+					Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM orders"}},
+				},
+				{
+					Name:    "customers_view",
+					Owner:   "reporting",
+					Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM customers"}},
+				},
+			},
+		}},
+	}
+	conf := DefaultConfig
+	conf.AlwaysRecreateViews = false
+	ofs := output.NewAnnotationStrippingSegmenter(defaultQuoter(conf))
+	assert.NoError(t, dropViewsOrdered(conf, ofs, oldDoc, newDoc))
+	// a view is only changed by a different owner, compared without case, or query
+	assert.Equal(t, []output.ToSql{
+		&sql.ViewDrop{View: sql.ViewRef{Schema: "public", View: "customers_view"}},
+	}, ofs.Body)
+}
+
+func TestDiffViews_AlwaysRecreate(t *testing.T) {
+	doc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{{
+				Name:    "orders_view",
+				Queries: []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT * FROM orders"}},
+			}},
+		}},
+	}
+	// an unchanged view is dropped as well as created, so it's built from scratch like the others
+	ref := sql.ViewRef{Schema: "public", View: "orders_view"}
+	q := defaultQuoter(DefaultConfig)
+	ofs1 := output.NewAnnotationStrippingSegmenter(q)
+	ofs3 := output.NewAnnotationStrippingSegmenter(q)
+	assert.NoError(t, dropViewsOrdered(DefaultConfig, ofs1, doc, doc))
+	assert.NoError(t, createViewsOrdered(DefaultConfig, ofs3, doc, doc))
+	assert.Equal(t, []output.ToSql{&sql.ViewDrop{View: ref}}, ofs1.Body)
+	assert.Equal(t, []output.ToSql{&sql.ViewCreate{View: ref, Query: "SELECT * FROM orders"}}, ofs3.Body)
+}
+
+func TestDiffViews_MaterializedViews(t *testing.T) {
+	tv := sql.ViewRef{Schema: "public", View: "tv"}
+	tests := []struct {
+		name   string
+		old    *ir.Definition
+		new    *ir.Definition
+		stage1 []output.ToSql
+		stage3 []output.ToSql
+		stage4 []output.ToSql
+	}{
+		{
+			name: "an identical materialized view is left in place and refreshed",
+			old: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t"}},
+						Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+					}},
+				}},
+			},
+			new: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t"}},
+						Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+					}},
+				}},
+			},
+			stage4: []output.ToSql{&sql.MaterializedViewRefresh{View: tv}},
+		},
+		{
+			name: "an unchanged materialized view is left in place and refreshed, with only its indexes diffed",
+			old: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t"}},
+						Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+					}},
+				}},
+			},
+			new: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t"}},
+						Indexes:      []*ir.Index{{Name: "tv_name", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_name_1", Value: "name"}}}},
+					}},
+				}},
+			},
+			stage3: []output.ToSql{
+				&sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "tv_id"}},
+				&sql.IndexCreate{
+					Table:      sql.TableRef{Schema: "public", Table: "tv"},
+					Index:      "tv_name",
+					Using:      "btree",
+					Dimensions: []sql.Quotable{&sql.QuoteObject{Ident: "name"}},
+				},
+			},
+			stage4: []output.ToSql{&sql.MaterializedViewRefresh{View: tv}},
+		},
+		{
+			name: "a changed materialized view is dropped and built again with its indexes",
+			old: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t"}},
+						Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+					}},
+				}},
+			},
+			new: &ir.Definition{
+				Schemas: []*ir.Schema{{
+					Name: "public",
+					Views: []*ir.View{{
+						Name:         "tv",
+						Materialized: true,
+						Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT id, name FROM t WHERE id > 0"}},
+						Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+					}},
+				}},
+			},
+			stage1: []output.ToSql{&sql.ViewDrop{View: tv, Materialized: true}},
+			stage3: []output.ToSql{
+				&sql.ViewCreate{View: tv, Query: "SELECT id, name FROM t WHERE id > 0", Materialized: true},
+				&sql.IndexCreate{
+					Table:      sql.TableRef{Schema: "public", Table: "tv"},
+					Index:      "tv_id",
+					Using:      "btree",
+					Dimensions: []sql.Quotable{&sql.QuoteObject{Ident: "id"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// materialized views are kept where they can be even though regular views are always recreated
+			q := defaultQuoter(DefaultConfig)
+			ofs1 := output.NewAnnotationStrippingSegmenter(q)
+			ofs3 := output.NewAnnotationStrippingSegmenter(q)
+			ofs4 := output.NewAnnotationStrippingSegmenter(q)
+			assert.NoError(t, dropViewsOrdered(DefaultConfig, ofs1, tt.old, tt.new))
+			assert.NoError(t, createViewsOrdered(DefaultConfig, ofs3, tt.old, tt.new))
+			assert.NoError(t, refreshViewsOrdered(DefaultConfig, ofs4, tt.old, tt.new))
+			assert.Equal(t, tt.stage1, ofs1.Body)
+			assert.Equal(t, tt.stage3, ofs3.Body)
+			assert.Equal(t, tt.stage4, ofs4.Body)
+		})
+	}
+	q := defaultQuoter(DefaultConfig)
+	assert.Equal(t, "DROP MATERIALIZED VIEW IF EXISTS public.tv;", (&sql.ViewDrop{View: tv, Materialized: true}).ToSql(q))
+	assert.Equal(t, "CREATE MATERIALIZED VIEW public.tv AS\nSELECT id FROM t;", (&sql.ViewCreate{View: tv, Query: "SELECT id FROM t", Materialized: true}).ToSql(q))
+}
+
+func TestDiff_KeepsMaterializedViews(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{{
+				Name:         "tv",
+				Materialized: true,
+				Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT 1 AS id"}},
+				Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Views: []*ir.View{{
+				Name:         "tv",
+				Materialized: true,
+				Queries:      []*ir.ViewQuery{{SqlFormat: ir.SqlFormatPgsql8, Text: "SELECT 1 AS id"}},
+				Indexes:      []*ir.Index{{Name: "tv_id", Using: ir.IndexTypeBtree, Dimensions: []*ir.IndexDim{{Name: "tv_id_1", Value: "id"}}}},
+			}},
+		}},
+	}
+
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	for _, recorder := range recorders {
+		assert.Empty(t, changeStatements(recorder.changes), "stage %d", recorder.stage)
+	}
+
+	conf := DefaultConfig
+	conf.RefreshMaterializedViews = true
+	recorders = diffChangesCommon(t, conf, oldDoc, newDoc)
+	assert.Empty(t, changeStatements(recorders[0].changes))
+	assert.Empty(t, changeStatements(recorders[2].changes))
+	assert.Equal(t, []output.ToSql{
+		&sql.MaterializedViewRefresh{View: sql.ViewRef{Schema: "public", View: "tv"}},
+	}, changeStatements(recorders[3].changes))
+}
@@ -0,0 +1,220 @@
+package pgsql8
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// buildDowngrade writes the downgrade stage files reverting newDoc back to oldDoc
+func (ops *Operations) buildDowngrade(oldCompositeFile string, oldDoc *ir.Definition, newCompositeFile string, newDoc *ir.Definition, downgradePrefix string) error {
+	var err error
+	// the downgrade runs from new to old, so the dependency orders swap sides too
+	ops.differ.OldTableDependency, err = newDoc.TableDependencyOrder()
+	if err != nil {
+		return fmt.Errorf("calculating dependency order: %w", err)
+	}
+	ops.differ.NewTableDependency, err = oldDoc.TableDependencyOrder()
+	if err != nil {
+		return fmt.Errorf("calculating dependency order: %w", err)
+	}
+	return ops.differ.DiffDowngrade(oldCompositeFile, newCompositeFile, oldDoc, newDoc, downgradePrefix)
+}
+
+// warnIrreversible puts a warning comment ahead of each recorded change which loses data that
+// the upgrade it reverts can't give back, and notes the number of such changes in each stage's header
+func (ops *Operations) warnIrreversible(recorders []*changeRecorder) {
+	warnings := map[*output.Change]*output.Change{}
+	for _, recorder := range recorders {
+		changes := []*output.Change{}
+		count := 0
+		for _, change := range recorder.changes {
+			if reason := irreversibleReason(change); reason != "" {
+				ops.logger.Warn(fmt.Sprintf("Irreversible downgrade step in stage %d: %s", recorder.stage, reason))
+				warning := &output.Change{Stage: recorder.stage, Statement: sql.NewComment("WARNING: irreversible: %s", reason)}
+				warnings[change] = warning
+				changes = append(changes, warning)
+				count++
+			}
+			changes = append(changes, change)
+		}
+		recorder.changes = changes
+		if count > 0 {
+			warning := sql.NewComment("WARNING: this stage contains %d irreversible steps, marked below. Data they remove cannot be restored by this downgrade", count)
+			recorder.header = append([]output.ToSql{warning}, recorder.header...)
+		}
+	}
+
+	sequence := []*output.Change{}
+	for _, change := range writtenChanges(recorders) {
+		if warning, ok := warnings[change]; ok {
+			sequence = append(sequence, warning)
+		}
+		sequence = append(sequence, change)
+	}
+	*recorders[0].sequence = sequence
+}
+
+// irreversibleReason explains why a change loses data, or returns an empty string if it doesn't
+func irreversibleReason(change *output.Change) string {
+	if len(change.Parts) > 0 {
+		reasons := []string{}
+		for _, part := range change.Parts {
+			if reason := irreversibleReason(part); reason != "" {
+				reasons = append(reasons, reason)
+			}
+		}
+		return strings.Join(reasons, "; ")
+	}
+	switch {
+	case change.Kind == "row" && change.Action == output.ChangeDrop:
+		return fmt.Sprintf("deleting rows from %s, they cannot be restored", change.Identity)
+	case change.Kind == "schema" && change.Action == output.ChangeDrop:
+		return fmt.Sprintf("dropping schema %s discards everything in it", change.Identity)
+	case change.Kind == "table" && change.Action == output.ChangeDrop:
+		return fmt.Sprintf("dropping table %s discards its data", change.Identity)
+	case change.Kind == "column" && change.Action == output.ChangeDrop:
+		return fmt.Sprintf("dropping column %s discards its data", change.Identity)
+	case change.Kind == "sequence" && change.Action == output.ChangeDrop:
+		return fmt.Sprintf("dropping sequence %s discards its current value", change.Identity)
+	case change.Kind == "column" && change.Destructive:
+		from, to := columnTypeChange(change)
+		return fmt.Sprintf("changing column %s from %s to %s may truncate or lose data", change.Identity, from, to)
+	}
+	return ""
+}
+
+// columnTypeChange returns the types a column change converts between, or empty strings if it doesn't change the type
+func columnTypeChange(change *output.Change) (string, string) {
+	oldColumn, ok := change.Old.(*ir.Column)
+	if !ok {
+		return "", ""
+	}
+	stmt := change.Statement
+	if an, ok := stmt.(*sql.Annotated); ok {
+		stmt = an.Wrapped
+	}
+	alter, ok := stmt.(*sql.TableAlterParts)
+	if !ok || len(alter.Parts) != 1 {
+		return "", ""
+	}
+	part := alter.Parts[0]
+	if an, ok := part.(*sql.TableAlterPartAnnotation); ok {
+		part = an.Wrapped
+	}
+	switch p := part.(type) {
+	case *sql.TableAlterPartColumnChangeType:
+		return oldColumn.Type, typeRefName(p.Type)
+	case *sql.TableAlterPartColumnChangeTypeUsingCast:
+		return oldColumn.Type, typeRefName(p.Type)
+	}
+	return "", ""
+}
+
+func typeRefName(ref sql.TypeRef) string {
+	if ref.Schema == "" {
+		return ref.Type
+	}
+	return ref.Schema + "." + ref.Type
+}
+
+var typeSpecRegex = regexp.MustCompile(`^([a-z][a-z0-9_ ]*?)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?(?:\s+with(?:out)? time zone)?$`)
+
+// typeAliases maps the alternative spellings of builtin types to a single name
+var typeAliases = map[string]string{
+	"int2":              "smallint",
+	"int":               "integer",
+	"int4":              "integer",
+	"int8":              "bigint",
+	"serial":            "integer",
+	"serial4":           "integer",
+	"bigserial":         "bigint",
+	"serial8":           "bigint",
+	"float4":            "real",
+	"float8":            "double precision",
+	"decimal":           "numeric",
+	"character varying": "varchar",
+	"character":         "char",
+	"bpchar":            "char",
+	"timestamptz":       "timestamp",
+	"timetz":            "time",
+}
+
+// numericRanks orders number types by the values they can hold without loss
+var numericRanks = map[string]int{
+	"smallint":         1,
+	"integer":          2,
+	"bigint":           3,
+	"real":             4,
+	"double precision": 5,
+	"numeric":          6,
+}
+
+type typeSpec struct {
+	name   string
+	length int
+	scale  int
+}
+
+func parseTypeSpec(spec string) (typeSpec, bool) {
+	match := typeSpecRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(spec)))
+	if match == nil {
+		return typeSpec{}, false
+	}
+	name := strings.TrimSpace(match[1])
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+	t := typeSpec{name: name, length: -1, scale: -1}
+	if match[2] != "" {
+		t.length, _ = strconv.Atoi(match[2])
+	}
+	if match[3] != "" {
+		t.scale, _ = strconv.Atoi(match[3])
+	}
+	if t.name == "char" && t.length == -1 {
+		t.length = 1
+	}
+	return t, true
+}
+
+// typeNarrows reports whether converting a column from one type to another can lose data.
+// Only conversions between builtin types are recognised; anything else is assumed to be safe.
+func typeNarrows(from, to string) bool {
+	f, ok := parseTypeSpec(from)
+	if !ok {
+		return false
+	}
+	t, ok := parseTypeSpec(to)
+	if !ok {
+		return false
+	}
+	isText := func(name string) bool {
+		return name == "text" || name == "varchar" || name == "char"
+	}
+	// unbounded lengths are -1, so treat them as larger than any limit
+	shorter := func(a, b int) bool {
+		return b != -1 && (a == -1 || b < a)
+	}
+
+	switch {
+	case isText(f.name) && isText(t.name):
+		return shorter(f.length, t.length)
+	case isText(f.name):
+		// parsing text into anything else fails on values which don't fit
+		return true
+	case numericRanks[f.name] > 0 && numericRanks[t.name] > 0:
+		if f.name == "numeric" && t.name == "numeric" {
+			return shorter(f.length, t.length) || shorter(f.scale, t.scale)
+		}
+		return numericRanks[t.name] < numericRanks[f.name] || (t.name == "numeric" && t.length != -1)
+	case f.name == "timestamp" && (t.name == "date" || t.name == "time"):
+		return true
+	}
+	return false
+}
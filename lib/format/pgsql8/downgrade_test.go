@@ -0,0 +1,108 @@
+package pgsql8
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeNarrows(t *testing.T) {
+	cases := []struct {
+		from, to string
+		narrows  bool
+	}{
+		{"varchar(100)", "varchar(50)", true},
+		{"varchar(50)", "character varying(100)", false},
+		{"text", "varchar(20)", true},
+		{"varchar(20)", "text", false},
+		{"text", "varchar", false},
+		{"text", "integer", true},
+		{"bigint", "int4", true},
+		{"integer", "bigint", false},
+		{"double precision", "integer", true},
+		{"numeric(10,2)", "numeric(8,2)", true},
+		{"numeric(10,2)", "numeric(10,4)", false},
+		{"numeric", "numeric(10,2)", true},
+		{"integer", "numeric(3)", true},
+		{"timestamp with time zone", "date", true},
+		{"date", "timestamp", false},
+		{"public.my_enum", "text", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.narrows, typeNarrows(c.from, c.to), "%s -> %s", c.from, c.to)
+	}
+}
+
+func TestWarnIrreversible(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "customers",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "varchar(100)", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "customers",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "varchar(200)", Nullable: true},
+					{Name: "email", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+
+	// downgrading runs the differ from new back to old
+	recorders := diffChangesCommon(t, DefaultConfig, newDoc, oldDoc)
+	NewOperations(DefaultConfig).(*Operations).warnIrreversible(recorders)
+
+	// the warnings, and the steps they warn about
+	warned := func(recorder *changeRecorder) []output.ToSql {
+		out := []output.ToSql{}
+		for _, change := range recorder.changes {
+			if comment, ok := change.Statement.(sql.Comment); ok && strings.HasPrefix(string(comment), "WARNING") {
+				out = append(out, comment)
+			} else if change.IsObject() {
+				out = append(out, change.Statement)
+			}
+		}
+		return out
+	}
+	customers := sql.TableRef{Schema: "public", Table: "customers"}
+	assert.Equal(t, []output.ToSql{
+		sql.NewComment("WARNING: irreversible: changing column public.customers.name from varchar(200) to varchar(100) may truncate or lose data"),
+		&sql.TableAlterParts{
+			Table: customers,
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartAnnotation{
+				Annotation: "changing from type varchar(200)",
+				Wrapped:    &sql.TableAlterPartColumnChangeType{Column: "name", Type: sql.TypeRef{Type: "varchar(100)"}},
+			}},
+		},
+	}, warned(recorders[0]))
+	assert.Equal(t, []output.ToSql{
+		sql.NewComment("WARNING: irreversible: dropping column public.customers.email discards its data"),
+		&sql.TableAlterParts{
+			Table: customers,
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnDrop{Column: "email"}},
+		},
+	}, warned(recorders[2]))
+
+	summary := sql.NewComment("WARNING: this stage contains 1 irreversible steps, marked below. Data they remove cannot be restored by this downgrade")
+	assert.Equal(t, summary, recorders[0].header[0])
+	assert.Equal(t, summary, recorders[2].header[0])
+	assert.Empty(t, warned(recorders[1]))
+}
@@ -0,0 +1,170 @@
+package pgsql8
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// Drift extracts the live database and reports how it differs from dbDoc.
+// Rather than comparing the two definitions directly, the upgrade from the database to dbDoc is
+// calculated and the changes it makes are collected, so that drift and upgrades never disagree.
+func (ops *Operations) Drift(dbDoc *ir.Definition, connString string) (*lib.DriftReport, error) {
+	conn, err := newConnection(ops.logger, connString)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	defer conn.disconnect()
+	liveDoc, err := ops.extractSchema(context.TODO(), conn)
+	if err != nil {
+		return nil, fmt.Errorf("extracting schema: %w", err)
+	}
+	return ops.drift(liveDoc, dbDoc)
+}
+
+func (ops *Operations) drift(liveDoc, dbDoc *ir.Definition) (*lib.DriftReport, error) {
+	// passwords can't be extracted, so can't drift either, and the variables holding them needn't be set
+	dbDoc = withoutRolePasswords(dbDoc)
+	// the cluster's other roles belong to other databases or are managed by hand, they aren't drift
+	liveDoc.KeepDeclaredRoles(dbDoc)
+	// views which haven't changed must not show up as drift
+	conf := ops.config
+	conf.AlwaysRecreateViews = false
+	// drift reports destructive changes, it doesn't make them
+	conf.SafeMode = false
+	// nor does it rewrite them to run online
+	conf.OnlineSafe = false
+	driftOps := NewOperations(conf).(*Operations)
+
+	ops.logger.Info("Calculating changes from database to definition...")
+	forward, err := driftOps.diffChanges(liveDoc, dbDoc)
+	if err != nil {
+		return nil, err
+	}
+	drift := newDriftCollector()
+	// structural changes are mostly in stages 1 and 3, but foreign keys are created in stage 4 after
+	// the data they refer to. indexes built concurrently run after the stage, but are just as much a part of it
+	for _, recorder := range forward {
+		for _, change := range slices.Concat(recorder.changes, recorder.concurrent) {
+			drift.record(driftOps.GetQuoter(), change)
+		}
+	}
+
+	// the differ never revokes grants on objects, so those only present in the database are
+	// found by calculating the upgrade in the other direction
+	ops.logger.Info("Calculating changes from definition to database...")
+	driftOps = NewOperations(conf).(*Operations)
+	reverse, err := driftOps.diffChanges(dbDoc, liveDoc)
+	if err != nil {
+		return nil, err
+	}
+	for _, recorder := range reverse {
+		for _, change := range slices.Concat(recorder.changes, recorder.concurrent) {
+			if change.Kind == "grant" {
+				drift.recordExtra(change)
+			}
+		}
+	}
+
+	return drift.report(), nil
+}
+
+// withoutRolePasswords is a shallow copy of doc whose roles have no password
+func withoutRolePasswords(doc *ir.Definition) *ir.Definition {
+	stripped := *doc
+	stripped.Roles = util.Map(doc.Roles, func(role *ir.Role) *ir.Role {
+		copied := *role
+		copied.PasswordEnv = ""
+		return &copied
+	})
+	return &stripped
+}
+
+type driftKey struct {
+	kind string
+	name string
+}
+
+func changeDriftKey(change *output.Change) driftKey {
+	return driftKey{change.Kind, change.Identity.String()}
+}
+
+type driftCollector struct {
+	order      []driftKey
+	actions    map[driftKey]map[output.ChangeAction]bool
+	statements map[driftKey][]string
+	extra      map[driftKey]bool
+}
+
+func newDriftCollector() *driftCollector {
+	return &driftCollector{
+		actions:    map[driftKey]map[output.ChangeAction]bool{},
+		statements: map[driftKey][]string{},
+		extra:      map[driftKey]bool{},
+	}
+}
+
+func (c *driftCollector) record(q output.Quoter, change *output.Change) {
+	// data, comments, raw sql and anything else isn't structural drift
+	if !change.IsObject() || change.Kind == "row" {
+		return
+	}
+	stmt := change.Statement
+	if an, ok := stmt.(output.AnnotatedSQL); ok {
+		stmt = an.StripAnnotation()
+	}
+	rendered := stmt.ToSql(q)
+	parts := change.Parts
+	if len(parts) == 0 {
+		parts = []*output.Change{change}
+	}
+	for _, part := range parts {
+		key := changeDriftKey(part)
+		if _, ok := c.actions[key]; !ok {
+			c.order = append(c.order, key)
+			c.actions[key] = map[output.ChangeAction]bool{}
+		}
+		c.actions[key][part.Action] = true
+		c.statements[key] = append(c.statements[key], rendered)
+	}
+}
+
+func (c *driftCollector) recordExtra(change *output.Change) {
+	key := changeDriftKey(change)
+	if c.extra[key] {
+		return
+	}
+	c.order = append(c.order, key)
+	c.extra[key] = true
+}
+
+func (c *driftCollector) report() *lib.DriftReport {
+	report := &lib.DriftReport{}
+	for _, key := range c.order {
+		if c.extra[key] {
+			report.Items = append(report.Items, lib.DriftItem{Kind: key.kind, Name: key.name, Status: lib.DriftExtra})
+			continue
+		}
+		actions := c.actions[key]
+		// owners, comments etc set right after creation are part of creating the object,
+		// but an object which is both dropped and created has been changed
+		status := lib.DriftChanged
+		if actions[output.ChangeCreate] && !actions[output.ChangeDrop] {
+			status = lib.DriftMissing
+		} else if actions[output.ChangeDrop] && !actions[output.ChangeCreate] {
+			status = lib.DriftExtra
+		}
+		report.Items = append(report.Items, lib.DriftItem{
+			Kind:       key.kind,
+			Name:       key.name,
+			Status:     status,
+			Statements: c.statements[key],
+		})
+	}
+	return report
+}
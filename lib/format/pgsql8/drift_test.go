@@ -0,0 +1,304 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrift_ReportsMissingExtraAndChanged(t *testing.T) {
+	liveDoc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "accounts",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "legacy", Type: "text"},
+					{Name: "email", Type: "text"},
+				},
+				Grants: []*ir.Grant{{Roles: []string{"reader"}, Permissions: []string{"SELECT"}}},
+			}},
+		}},
+	}
+	dbDoc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "accounts",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "email", Type: "varchar(100)"},
+						{Name: "created", Type: "timestamp"},
+					},
+				},
+				{
+					Name:       "audit",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+			},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	report, err := ops.drift(liveDoc, dbDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]lib.DriftStatus{}
+	for _, item := range report.Items {
+		statuses[item.Kind+" "+item.Name] = item.Status
+	}
+	// the grant is only found by diffing the other way, the differ never revokes
+	assert.Equal(t, map[string]lib.DriftStatus{
+		"table public.audit":                              lib.DriftMissing,
+		"constraint public.audit.audit_pkey":              lib.DriftMissing,
+		"column public.accounts.created":                  lib.DriftMissing,
+		"column public.accounts.legacy":                   lib.DriftExtra,
+		"column public.accounts.email":                    lib.DriftChanged,
+		"grant SELECT ON TABLE public.accounts TO reader": lib.DriftExtra,
+	}, statuses)
+}
+
+func TestDrift_NoDrift(t *testing.T) {
+	doc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "accounts",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				Grants:     []*ir.Grant{{Roles: []string{"reader"}, Permissions: []string{"SELECT"}}},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	report, err := ops.drift(doc, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, report.HasDrift())
+	json, err := report.JSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"items": []}`, string(json))
+}
+
+func TestDrift_OnlyDeclaredRoles(t *testing.T) {
+	liveDoc := &ir.Definition{
+		Roles: []*ir.Role{
+			{Name: "app", Login: true, Inherit: true},
+			{Name: "analyst", Inherit: true},
+		},
+	}
+	// passwords are never looked at, so their variables needn't be set
+	dbDoc := &ir.Definition{
+		Roles: []*ir.Role{
+			{Name: "analyst", Login: true, Inherit: true, PasswordEnv: "ANALYST_PASSWORD"},
+			{Name: "auditor", Login: true, Inherit: true, PasswordEnv: "AUDITOR_PASSWORD"},
+		},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	report, err := ops.drift(liveDoc, dbDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the roles extracted along with the database aren't dropped, only those the definition manages are compared
+	if assert.Len(t, report.Items, 2) {
+		assert.Equal(t, lib.DriftItem{
+			Kind:       "role",
+			Name:       "analyst",
+			Status:     lib.DriftChanged,
+			Statements: []string{"ALTER ROLE analyst WITH LOGIN INHERIT NOCREATEDB CONNECTION LIMIT -1;"},
+		}, report.Items[0])
+		assert.Equal(t, "auditor", report.Items[1].Name)
+		assert.Equal(t, lib.DriftMissing, report.Items[1].Status)
+	}
+	assert.Equal(t, "ANALYST_PASSWORD", dbDoc.Roles[0].PasswordEnv)
+}
+
+func TestDrift_ConcurrentIndexes(t *testing.T) {
+	withoutIndex := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "searches",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "term", Type: "text"},
+				},
+			}},
+		}},
+	}
+	withIndex := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "searches",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "term", Type: "text"},
+				},
+				Indexes: []*ir.Index{{
+					Name:         "searches_term_idx",
+					Concurrently: true,
+					Dimensions:   []*ir.IndexDim{{Name: "term_1", Value: "term"}},
+				}},
+			}},
+		}},
+	}
+
+	// online rewrites are for running upgrades, they don't change what has drifted
+	conf := DefaultConfig
+	conf.OnlineSafe = true
+	ops := NewOperations(conf).(*Operations)
+	report, err := ops.drift(withoutIndex, withIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, report.Items, 1) {
+		assert.Equal(t, "index", report.Items[0].Kind)
+		assert.Equal(t, "public.searches_term_idx", report.Items[0].Name)
+		assert.Equal(t, lib.DriftMissing, report.Items[0].Status)
+	}
+
+	report, err = ops.drift(withIndex, withoutIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []lib.DriftItem{{
+		Kind:       "index",
+		Name:       "public.searches_term_idx",
+		Status:     lib.DriftExtra,
+		Statements: []string{"DROP INDEX CONCURRENTLY IF EXISTS public.searches_term_idx;"},
+	}}, report.Items)
+}
+
+func TestDrift_MissingForeignKey(t *testing.T) {
+	liveDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer"},
+					},
+				},
+			},
+		}},
+	}
+	dbDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer", ForeignTable: "owners", ForeignColumn: "id", ForeignKeyName: "pets_owner_fkey"},
+					},
+				},
+			},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	report, err := ops.drift(liveDoc, dbDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// foreign keys are created in stage 4, after the data they refer to
+	assert.Equal(t, []lib.DriftItem{{
+		Kind:       "constraint",
+		Name:       "public.pets.pets_owner_fkey",
+		Status:     lib.DriftMissing,
+		Statements: []string{"ALTER TABLE public.pets\n  ADD CONSTRAINT pets_owner_fkey FOREIGN KEY (owner_id) REFERENCES public.owners (id);"},
+	}}, report.Items)
+}
+
+func TestDrift_ChangedForeignKey(t *testing.T) {
+	liveDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer", ForeignTable: "owners", ForeignColumn: "id", ForeignKeyName: "pets_owner_fkey"},
+					},
+				},
+			},
+		}},
+	}
+	dbDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer", ForeignTable: "owners", ForeignColumn: "id", ForeignKeyName: "pets_owner_fkey", ForeignOnDelete: ir.ForeignKeyActionCascade},
+					},
+				},
+			},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	report, err := ops.drift(liveDoc, dbDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the old constraint is dropped in stage 1, and the new one created in stage 4
+	if assert.Len(t, report.Items, 1) {
+		assert.Equal(t, "public.pets.pets_owner_fkey", report.Items[0].Name)
+		assert.Equal(t, lib.DriftChanged, report.Items[0].Status)
+		assert.Len(t, report.Items[0].Statements, 2)
+	}
+}
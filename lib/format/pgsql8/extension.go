@@ -0,0 +1,42 @@
+package pgsql8
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// checkExtensions makes sure the extensions can be created in the target version
+func checkExtensions(doc *ir.Definition, target VersionNum) error {
+	if doc == nil || target == 0 {
+		return nil
+	}
+	for _, ext := range doc.Extensions {
+		if !FEAT_EXTENSIONS(target) {
+			return fmt.Errorf("extension %s needs a target version of at least 9.1", ext.Name)
+		}
+		if ext.Cascade && !FEAT_CREATE_EXTENSION_CASCADE(target) {
+			return fmt.Errorf("extension %s is created with cascade, which needs a target version of at least 9.6", ext.Name)
+		}
+	}
+	return nil
+}
+
+func getCreateExtensionSql(ext *ir.Extension) []output.ToSql {
+	return []output.ToSql{
+		&sql.ExtensionCreate{
+			Extension: ext.Name,
+			Schema:    ext.Schema,
+			Version:   ext.Version,
+			Cascade:   ext.Cascade,
+		},
+	}
+}
+
+func getDropExtensionSql(ext *ir.Extension) []output.ToSql {
+	return []output.ToSql{
+		&sql.ExtensionDrop{Extension: ext.Name},
+	}
+}
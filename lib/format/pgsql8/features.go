@@ -30,3 +30,85 @@ var FEAT_CONSTRAINT_USE_GETTER = VersAtLeast(12, 0)
 //
 // https://www.postgresql.org/docs/11/catalog-pg-proc.html
 var FEAT_FUNCTION_USE_KIND = VersAtLeast(11, 0)
+
+// The following are syntax generated sql may use, once the target version allows it.
+
+// In 9.2 indexes can be dropped CONCURRENTLY, outside of a transaction
+//
+// https://www.postgresql.org/docs/9.2/sql-dropindex.html
+var FEAT_DROP_INDEX_CONCURRENTLY = VersAtLeast(9, 2)
+
+// In 9.5 CREATE INDEX gained IF NOT EXISTS, which lets a failed CREATE INDEX CONCURRENTLY be retried,
+// and INSERT gained ON CONFLICT DO NOTHING
+var FEAT_CREATE_INDEX_IF_NOT_EXISTS = VersAtLeast(9, 5)
+var FEAT_INSERT_ON_CONFLICT = VersAtLeast(9, 5)
+
+// In 12.0 SET NOT NULL skips scanning the table when a validated CHECK (column IS NOT NULL) proves it
+//
+// https://www.postgresql.org/docs/12/sql-altertable.html
+var FEAT_SET_NOT_NULL_FROM_CHECK = VersAtLeast(12, 0)
+
+// In 9.6 ALTER TABLE ... ADD COLUMN gained IF NOT EXISTS
+//
+// https://www.postgresql.org/docs/9.6/sql-altertable.html
+var FEAT_ADD_COLUMN_IF_NOT_EXISTS = VersAtLeast(9, 6)
+
+// In 9.1 extensions can be installed with CREATE EXTENSION, and in 9.6 it gained CASCADE
+// to install the extensions they require as well
+//
+// https://www.postgresql.org/docs/9.6/sql-createextension.html
+var FEAT_EXTENSIONS = VersAtLeast(9, 1)
+var FEAT_CREATE_EXTENSION_CASCADE = VersAtLeast(9, 6)
+
+// In 9.1 values can be added to an existing enum with ALTER TYPE ... ADD VALUE,
+// though only outside of a transaction block until 12.0
+//
+// https://www.postgresql.org/docs/12/sql-altertype.html
+var FEAT_ALTER_TYPE_ADD_VALUE = VersAtLeast(9, 1)
+var FEAT_ALTER_TYPE_ADD_VALUE_IN_TRANSACTION = VersAtLeast(12, 0)
+
+// In 10.0 enum values can be renamed with ALTER TYPE ... RENAME VALUE
+//
+// https://www.postgresql.org/docs/10/sql-altertype.html
+var FEAT_ALTER_TYPE_RENAME_VALUE = VersAtLeast(10, 0)
+
+// In 10.0 columns can be GENERATED AS IDENTITY, the SQL standard replacement for serial
+//
+// https://www.postgresql.org/docs/10/sql-createtable.html
+var FEAT_IDENTITY_COLUMNS = VersAtLeast(10, 0)
+
+// In 12.0 columns can be GENERATED ALWAYS AS (...) STORED, in 13.0 they can be made regular columns
+// with DROP EXPRESSION, and in 17.0 their expression can be changed with SET EXPRESSION
+//
+// https://www.postgresql.org/docs/17/sql-altertable.html
+var FEAT_GENERATED_COLUMNS = VersAtLeast(12, 0)
+var FEAT_DROP_EXPRESSION = VersAtLeast(13, 0)
+var FEAT_SET_EXPRESSION = VersAtLeast(17, 0)
+
+// In 9.5 tables can have row level security policies, and in 10.0 policies can be AS RESTRICTIVE
+//
+// https://www.postgresql.org/docs/10/sql-createpolicy.html
+var FEAT_ROW_LEVEL_SECURITY = VersAtLeast(9, 5)
+var FEAT_RESTRICTIVE_POLICIES = VersAtLeast(10, 0)
+
+// In 14.0 triggers can be changed in place with CREATE OR REPLACE TRIGGER
+//
+// https://www.postgresql.org/docs/14/sql-createtrigger.html
+var FEAT_CREATE_OR_REPLACE_TRIGGER = VersAtLeast(14, 0)
+
+// In 9.0 the privileges given to objects as they're created can be set with ALTER DEFAULT PRIVILEGES,
+// and in 10.0 they can be set on schemas too
+//
+// https://www.postgresql.org/docs/10/sql-alterdefaultprivileges.html
+var FEAT_DEFAULT_PRIVILEGES = VersAtLeast(9, 0)
+var FEAT_DEFAULT_PRIVILEGES_ON_SCHEMAS = VersAtLeast(10, 0)
+
+// In 9.2 range types can be created with CREATE TYPE ... AS RANGE, and are listed in pg_range
+//
+// https://www.postgresql.org/docs/9.2/sql-createtype.html
+var FEAT_RANGE_TYPES = VersAtLeast(9, 2)
+
+// In 10.0 passwords can be given as SCRAM-SHA-256 verifiers, before which only md5 hashes are taken as already hashed
+//
+// https://www.postgresql.org/docs/10/sql-createrole.html
+var FEAT_SCRAM_PASSWORDS = VersAtLeast(10, 0)
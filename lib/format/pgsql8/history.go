@@ -0,0 +1,178 @@
+package pgsql8
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+const (
+	historyApplied = "applied"
+	historyFailed  = "failed"
+
+	// historyAllStages is recorded as the stage of an upgrade applied in a single transaction
+	historyAllStages = 0
+
+	// historySchema holds historyTable, which is left out of extracted definitions
+	historySchema = "dbsteward"
+	historyTable  = "migration_history"
+)
+
+var historyTableSql = []string{
+	`CREATE SCHEMA IF NOT EXISTS dbsteward`,
+	`CREATE TABLE IF NOT EXISTS dbsteward.migration_history (
+		id bigserial PRIMARY KEY,
+		old_hash text NOT NULL,
+		new_hash text NOT NULL,
+		tool_version text NOT NULL,
+		stage integer NOT NULL,
+		started_at timestamptz NOT NULL,
+		finished_at timestamptz NOT NULL,
+		outcome text NOT NULL,
+		error text
+	)`,
+}
+
+// leaveOutHistory removes the history kept by --apply from an extracted definition, as it isn't part of the
+// database's definition and must survive upgrades. Its schema goes too, unless anything else was put in it
+func leaveOutHistory(doc *ir.Definition) {
+	schema := doc.TryGetSchemaNamed(historySchema)
+	if schema == nil {
+		return
+	}
+	schema.Tables = slices.DeleteFunc(schema.Tables, func(table *ir.Table) bool {
+		return table.Name == historyTable
+	})
+	schema.Sequences = slices.DeleteFunc(schema.Sequences, func(sequence *ir.Sequence) bool {
+		return sequence.OwnedBySchema == historySchema && sequence.OwnedByTable == historyTable
+	})
+	schema.Triggers = slices.DeleteFunc(schema.Triggers, func(trigger *ir.Trigger) bool {
+		return trigger.Table == historyTable
+	})
+	if len(schema.Tables) == 0 && len(schema.Types) == 0 && len(schema.Sequences) == 0 &&
+		len(schema.Functions) == 0 && len(schema.Triggers) == 0 && len(schema.Views) == 0 {
+		doc.Schemas = slices.DeleteFunc(doc.Schemas, func(s *ir.Schema) bool { return s == schema })
+	}
+}
+
+// historyEntry is a row of dbsteward.migration_history, recording the outcome of one stage of an upgrade
+type historyEntry struct {
+	OldHash string
+	NewHash string
+	Stage   int
+	Outcome string
+	Error   string
+}
+
+// complete reports whether the entry is the last stage of an upgrade, and that stage was applied
+func (e *historyEntry) complete() bool {
+	return e.Outcome == historyApplied && (e.Stage == historyAllStages || e.Stage == len(upgradeStageDescriptions))
+}
+
+// stageHistory records the outcome of each stage of an upgrade as it is applied
+type stageHistory struct {
+	quoter  output.Quoter
+	upgrade lib.MigrationHistory
+}
+
+// record inserts the outcome of a stage once its transaction has finished, so a failure is recorded despite the rollback
+func (h *stageHistory) record(ctx context.Context, conn applyExecutor, stage int, started time.Time, stageErr error) error {
+	if h == nil {
+		return nil
+	}
+	outcome, message := historyApplied, "NULL"
+	if stageErr != nil {
+		outcome, message = historyFailed, h.quoter.LiteralString(stageErr.Error())
+	}
+	err := conn.exec(ctx, fmt.Sprintf(
+		"INSERT INTO dbsteward.migration_history (old_hash, new_hash, tool_version, stage, started_at, finished_at, outcome, error) VALUES (%s, %s, %s, %d, %s, now(), %s, %s)",
+		h.quoter.LiteralString(h.upgrade.OldHash),
+		h.quoter.LiteralString(h.upgrade.NewHash),
+		h.quoter.LiteralString(h.upgrade.ToolVersion),
+		stage,
+		h.quoter.LiteralString(started.UTC().Format(time.RFC3339Nano)),
+		h.quoter.LiteralString(outcome),
+		message,
+	))
+	if err != nil {
+		return fmt.Errorf("recording stage %d in dbsteward.migration_history: %w", stage, err)
+	}
+	return nil
+}
+
+// prepareHistory checks the upgrade was generated against the definition the database was last upgraded to,
+// and unless this is a dry run, creates the history table if need be and returns a recorder for the stages
+func (ops *Operations) prepareHistory(ctx context.Context, conn *liveConnection, upgrade *lib.MigrationHistory, dryRun bool) (*stageHistory, error) {
+	if upgrade == nil {
+		return nil, nil
+	}
+	last, err := lastHistoryEntry(conn)
+	if err != nil {
+		return nil, fmt.Errorf("reading dbsteward.migration_history: %w", err)
+	}
+	if upgrade.VerifyBaseline {
+		if err := checkBaseline(last, upgrade.OldHash); err != nil {
+			return nil, err
+		}
+		if last == nil {
+			ops.logger.Info("No upgrades recorded in dbsteward.migration_history yet, so the old definition can't be verified")
+		}
+	}
+	if dryRun {
+		return nil, nil
+	}
+	for _, stmt := range historyTableSql {
+		if err := conn.exec(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("creating dbsteward.migration_history: %w", err)
+		}
+	}
+	return &stageHistory{quoter: ops.GetQuoter(), upgrade: *upgrade}, nil
+}
+
+// lastHistoryEntry returns the most recently recorded stage, or nil if there is none
+func lastHistoryEntry(conn *liveConnection) (*historyEntry, error) {
+	var exists bool
+	err := conn.queryVal(&exists, `SELECT to_regclass('dbsteward.migration_history') IS NOT NULL`)
+	if err != nil || !exists {
+		return nil, err
+	}
+	rows, err := conn.queryMap(`SELECT old_hash, new_hash, stage, outcome, error FROM dbsteward.migration_history ORDER BY id DESC LIMIT 1`)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	stage, err := strconv.Atoi(rows[0]["stage"])
+	if err != nil {
+		return nil, err
+	}
+	return &historyEntry{
+		OldHash: rows[0]["old_hash"],
+		NewHash: rows[0]["new_hash"],
+		Stage:   stage,
+		Outcome: rows[0]["outcome"],
+		Error:   rows[0]["error"],
+	}, nil
+}
+
+// checkBaseline returns an error unless the last recorded upgrade finished, leaving the database at oldHash.
+// A database with no recorded upgrades passes, as there's nothing to check against.
+func checkBaseline(last *historyEntry, oldHash string) error {
+	if last == nil {
+		return nil
+	}
+	if last.Outcome != historyApplied {
+		return fmt.Errorf("the last upgrade recorded in dbsteward.migration_history, to definition %s, failed in stage %d: %s", last.NewHash, last.Stage, last.Error)
+	}
+	if !last.complete() {
+		return fmt.Errorf("the last upgrade recorded in dbsteward.migration_history, to definition %s, stopped after stage %d", last.NewHash, last.Stage)
+	}
+	if last.NewHash != oldHash {
+		return fmt.Errorf("the database was last upgraded to definition %s, but this upgrade was generated from definition %s, refusing to apply it to the wrong baseline", last.NewHash, oldHash)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package pgsql8
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBaseline(t *testing.T) {
+	assert.NoError(t, checkBaseline(nil, "aaa"), "nothing recorded yet")
+	assert.NoError(t, checkBaseline(&historyEntry{OldHash: "000", NewHash: "aaa", Stage: 4, Outcome: historyApplied}, "aaa"))
+	assert.NoError(t, checkBaseline(&historyEntry{OldHash: "000", NewHash: "aaa", Stage: historyAllStages, Outcome: historyApplied}, "aaa"))
+
+	err := checkBaseline(&historyEntry{OldHash: "000", NewHash: "bbb", Stage: 4, Outcome: historyApplied}, "aaa")
+	assert.ErrorContains(t, err, "last upgraded to definition bbb, but this upgrade was generated from definition aaa")
+
+	err = checkBaseline(&historyEntry{OldHash: "000", NewHash: "aaa", Stage: 2, Outcome: historyApplied}, "aaa")
+	assert.ErrorContains(t, err, "stopped after stage 2")
+
+	err = checkBaseline(&historyEntry{OldHash: "000", NewHash: "aaa", Stage: 3, Outcome: historyFailed, Error: "boom"}, "aaa")
+	assert.ErrorContains(t, err, "failed in stage 3: boom")
+}
+
+func TestApply_RecordsHistory(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	history := &stageHistory{
+		quoter:  ops.GetQuoter(),
+		upgrade: lib.MigrationHistory{OldHash: "aaa", NewHash: "bbb", ToolVersion: "2.0.0"},
+	}
+	inserts := func(executed []string) []string {
+		out := []string{}
+		for _, stmt := range executed {
+			if strings.HasPrefix(stmt, "INSERT INTO dbsteward.migration_history") {
+				out = append(out, stmt)
+			}
+		}
+		return out
+	}
+
+	conn := &recordingExecutor{}
+	assert.NoError(t, ops.applyStages(context.Background(), conn, testApplyStages(), false, history))
+	recorded := inserts(conn.executed)
+	if assert.Len(t, recorded, 4) {
+		assert.Contains(t, recorded[0], "VALUES ('aaa', 'bbb', '2.0.0', 1, '")
+		assert.Contains(t, recorded[3], "'2.0.0', 4, '")
+		assert.True(t, strings.HasSuffix(recorded[3], "now(), 'applied', NULL)"), recorded[3])
+	}
+	// each stage is recorded once it has committed
+	assert.Equal(t, "COMMIT", conn.executed[2])
+	assert.Equal(t, recorded[0], conn.executed[3])
+
+	conn = &recordingExecutor{failOn: "DROP TABLE b;"}
+	assert.Error(t, ops.applyStages(context.Background(), conn, testApplyStages(), false, history))
+	recorded = inserts(conn.executed)
+	if assert.Len(t, recorded, 3) {
+		assert.Contains(t, recorded[2], "'2.0.0', 3, '")
+		assert.Contains(t, recorded[2], "'failed', 'stage 3 statement 1 failed: boom\nDROP TABLE b;')")
+	}
+	assert.Equal(t, recorded[2], conn.executed[len(conn.executed)-1], "the failure is recorded after the rollback")
+
+	conf := DefaultConfig
+	conf.SingleStageUpgrade = true
+	conn = &recordingExecutor{}
+	assert.NoError(t, NewOperations(conf).(*Operations).applyStages(context.Background(), conn, testApplyStages(), false, history))
+	recorded = inserts(conn.executed)
+	if assert.Len(t, recorded, 1) {
+		assert.Contains(t, recorded[0], "'2.0.0', 0, '")
+	}
+}
+
+func TestExtract_LeavesOutHistory(t *testing.T) {
+	// a database which has had an upgrade applied to it, as extracted for --olddb
+	pgDoc := structure{
+		Version:  NewVersionNum(15, 0),
+		Database: Database{Name: "shop", Owner: "app"},
+		Schemas:  []schemaEntry{{Name: "public", Owner: "app"}, {Name: "dbsteward", Owner: "app"}},
+		Tables: []tableEntry{
+			{Schema: "public", Table: "products", Owner: "app", Columns: []columnEntry{{Name: "id", AttrType: "integer", Position: 1}}},
+			{Schema: "dbsteward", Table: "migration_history", Owner: "app", Columns: []columnEntry{
+				{Name: "id", AttrType: "bigint", Position: 1},
+				{Name: "outcome", AttrType: "text", Position: 2},
+			}},
+		},
+		Constraints: []constraintEntry{
+			{Schema: "public", Table: "products", Name: "products_pkey", Type: "p", Columns: []string{"id"}},
+			{Schema: "dbsteward", Table: "migration_history", Name: "migration_history_pkey", Type: "p", Columns: []string{"id"}},
+		},
+	}
+	ops := NewOperations(DefaultConfig).(*Operations)
+	oldDoc, err := ops.pgToIR(pgDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, oldDoc.TryGetSchemaNamed("dbsteward"))
+
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name:  "public",
+			Owner: "app",
+			Tables: []*ir.Table{{
+				Name:           "products",
+				Owner:          "app",
+				PrimaryKey:     []string{"id"},
+				PrimaryKeyName: "products_pkey",
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	// the history table isn't dropped just because the definition doesn't mention it
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	assert.Equal(t, []output.ToSql{
+		&sql.TableAlterParts{
+			Table: sql.TableRef{Schema: "public", Table: "products"},
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnCreate{
+				ColumnDef: sql.ColumnDefinition{Name: "name", Type: sql.TypeRef{Type: "text"}},
+			}},
+		},
+	}, changeStatements(recorders[0].changes))
+	for _, recorder := range recorders[1:] {
+		assert.Empty(t, changeStatements(recorder.changes), "stage %d", recorder.stage)
+	}
+}
+
+func TestExtract_KeepsOtherObjectsInHistorySchema(t *testing.T) {
+	// a schema of the same name as the history's, which the definition has its own tables in
+	pgDoc := structure{
+		Version:  NewVersionNum(15, 0),
+		Database: Database{Name: "shop", Owner: "app"},
+		Schemas:  []schemaEntry{{Name: "dbsteward", Owner: "app"}},
+		Tables: []tableEntry{
+			{Schema: "dbsteward", Table: "settings", Owner: "app", Columns: []columnEntry{{Name: "id", AttrType: "integer", Position: 1}}},
+			{Schema: "dbsteward", Table: "migration_history", Owner: "app", Columns: []columnEntry{
+				{Name: "id", AttrType: "bigint", Position: 1},
+				{Name: "outcome", AttrType: "text", Position: 2},
+			}},
+		},
+	}
+	ops := NewOperations(DefaultConfig).(*Operations)
+	doc, err := ops.pgToIR(pgDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := doc.TryGetSchemaNamed("dbsteward")
+	if assert.NotNil(t, schema) {
+		assert.NotNil(t, schema.TryGetTableNamed("settings"))
+		assert.Nil(t, schema.TryGetTableNamed("migration_history"))
+	}
+}
@@ -0,0 +1,140 @@
+package pgsql8
+
+import (
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// idempotentSegmenter guards every creation written to it, so a build which was partially applied
+// can simply be run again. Everything else a build writes, such as grants, comments, owners, defaults
+// and CREATE OR REPLACE of functions and views, can already be repeated as is.
+type idempotentSegmenter struct {
+	output.OutputFileSegmenter
+	// replaceTriggers uses CREATE OR REPLACE TRIGGER rather than a catalog check, so changed triggers are updated too
+	replaceTriggers bool
+}
+
+func (s *idempotentSegmenter) WriteSql(stmts ...output.ToSql) error {
+	guarded := make([]output.ToSql, 0, len(stmts))
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+		guarded = append(guarded, guardCreate(stmt, s.replaceTriggers))
+	}
+	return s.OutputFileSegmenter.WriteSql(guarded...)
+}
+
+// WriteChanges writes copies of the changes made by their guarded statements
+func (s *idempotentSegmenter) WriteChanges(changes ...*output.Change) error {
+	guarded := make([]*output.Change, len(changes))
+	for i, change := range changes {
+		copied := *change
+		copied.Statement = guardCreate(change.Statement, s.replaceTriggers)
+		guarded[i] = &copied
+	}
+	return writeChanges(s.OutputFileSegmenter, guarded...)
+}
+
+func (s *idempotentSegmenter) MustWriteSql(stmts []output.ToSql, err error) {
+	if err != nil {
+		panic(err)
+	}
+	err = s.WriteSql(stmts...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// guardCreate returns a copy of the statement in its guarded form, or the statement itself if it has none
+func guardCreate(stmt output.ToSql, replaceTriggers bool) output.ToSql {
+	inner, rewrap := unwrapAnnotated(stmt)
+	switch s := inner.(type) {
+	case *sql.SchemaCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TableCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TableCreatePartitionOf:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.SequenceCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.IndexCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.ViewCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.ConstraintCreateRaw:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.ConstraintCreatePrimaryKey:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.ConstraintCreateForeignKey:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeEnumCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeCompositeCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeDomainCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeShellCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeRangeCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TypeBaseCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.CastCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.TriggerCreate:
+		guarded := *s
+		guarded.IfNotExists = !replaceTriggers
+		guarded.OrReplace = replaceTriggers
+		return rewrap(&guarded)
+	case *sql.ExtensionCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.PolicyCreate:
+		guarded := *s
+		guarded.IfNotExists = true
+		return rewrap(&guarded)
+	case *sql.LanguageCreate:
+		guarded := *s
+		guarded.OrReplace = true
+		return rewrap(&guarded)
+	case *sql.DataInsert:
+		guarded := *s
+		guarded.OnConflictDoNothing = true
+		return rewrap(&guarded)
+	}
+	return stmt
+}
@@ -0,0 +1,95 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentBuild(t *testing.T) {
+	doc := &ir.Definition{
+		Database: &ir.Database{
+			Roles: &ir.RoleAssignment{ReadOnly: "reader"},
+		},
+		Schemas: []*ir.Schema{{
+			Name:  "public",
+			Types: []*ir.TypeDef{{Name: "status", Kind: ir.DataTypeKindEnum, EnumValues: []ir.DataTypeEnumValue{{Name: "on"}, {Name: "off"}}}},
+			Tables: []*ir.Table{{
+				Name:       "devices",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "status", Type: "public.status", Nullable: true},
+				},
+				Indexes: []*ir.Index{{Name: "devices_status_idx", Dimensions: []*ir.IndexDim{{Name: "status_1", Value: "status"}}}},
+				Rows:    &ir.DataRows{Columns: []string{"id"}, Rows: []*ir.DataRow{{Columns: []*ir.DataCol{{Text: "1"}}}}},
+			}},
+		}},
+	}
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	ofs := output.NewAnnotationStrippingSegmenter(ops.GetQuoter())
+	err := ops.build(&idempotentSegmenter{OutputFileSegmenter: ofs}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := sql.TableRef{Schema: "public", Table: "devices"}
+	assert.Equal(t, []output.ToSql{
+		output.NewRawSQL("BEGIN;\n\n"),
+		&sql.TypeEnumCreate{
+			Type:        sql.TypeRef{Schema: "public", Type: "status"},
+			Values:      []string{"on", "off"},
+			IfNotExists: true,
+		},
+		&sql.TableCreate{
+			Table: devices,
+			Columns: []sql.ColumnDefinition{
+				{Name: "id", Type: sql.TypeRef{Type: "integer"}},
+				{Name: "status", Type: sql.TypeRef{Schema: "public", Type: "status"}},
+			},
+			OtherOptions: []sql.TableCreateOption{},
+			IfNotExists:  true,
+		},
+		&sql.IndexCreate{
+			Table:       devices,
+			Index:       "devices_status_idx",
+			IfNotExists: true,
+			Dimensions:  []sql.Quotable{&sql.QuoteObject{Ident: "status"}},
+		},
+		// setting NOT NULL again is harmless
+		&sql.ColumnSetNull{Column: sql.ColumnRef{Schema: "public", Table: "devices", Column: "id"}},
+		&sql.ConstraintCreatePrimaryKey{
+			Table:       devices,
+			Constraint:  "devices_pkey",
+			Columns:     []string{"id"},
+			IfNotExists: true,
+		},
+		&sql.DataInsert{
+			Table:               devices,
+			Columns:             []string{"id"},
+			Values:              []sql.ToSqlValue{&sql.TypedValue{Type: "integer", Value: "1"}},
+			OnConflictDoNothing: true,
+		},
+		output.NewRawSQL("\n"),
+		output.NewRawSQL("COMMIT;\n\n"),
+	}, ofs.Body)
+
+	// targets with CREATE OR REPLACE TRIGGER update triggers rather than skip them
+	trigger := &sql.TriggerCreate{Trigger: sql.TriggerRef{Schema: "public", Trigger: "t"}, Table: devices, Timing: "AFTER", Events: []string{"INSERT"}, ForEach: "ROW", Function: "f()"}
+	assert.Equal(t, &sql.TriggerCreate{Trigger: trigger.Trigger, Table: devices, Timing: "AFTER", Events: []string{"INSERT"}, ForEach: "ROW", Function: "f()", OrReplace: true}, guardCreate(trigger, true))
+	assert.Equal(t, &sql.TriggerCreate{Trigger: trigger.Trigger, Table: devices, Timing: "AFTER", Events: []string{"INSERT"}, ForEach: "ROW", Function: "f()", IfNotExists: true}, guardCreate(trigger, false))
+
+	// the guarded statements are still recognized as the creations they guard
+	recorder := newBuildChangeRecorder()
+	assert.NoError(t, (&idempotentSegmenter{OutputFileSegmenter: recorder}).WriteChanges(
+		tableChanged("public", nil, &ir.Table{Name: "devices"}).create(&sql.TableCreate{Table: devices})...,
+	))
+	if assert.Len(t, recorder.changes, 1) {
+		assert.Equal(t, "table", recorder.changes[0].Kind)
+		assert.Equal(t, output.ChangeCreate, recorder.changes[0].Action)
+		assert.Equal(t, &sql.TableCreate{Table: devices, IfNotExists: true}, recorder.changes[0].Statement)
+	}
+}
@@ -12,6 +12,11 @@ import (
 )
 
 func getCreateIndexSql(schema *ir.Schema, table *ir.Table, index *ir.Index) []output.ToSql {
+	return getCreateRelationIndexSql(sql.TableRef{Schema: schema.Name, Table: table.Name}, index)
+}
+
+// getCreateRelationIndexSql builds an index on any relation, e.g. a table or a materialized view
+func getCreateRelationIndexSql(relation sql.TableRef, index *ir.Index) []output.ToSql {
 	dims := make([]sql.Quotable, len(index.Dimensions))
 	for i, dim := range index.Dimensions {
 		if dim.Sql {
@@ -26,13 +31,34 @@ func getCreateIndexSql(schema *ir.Schema, table *ir.Table, index *ir.Index) []ou
 	}
 	return []output.ToSql{
 		&sql.IndexCreate{
-			Table:        sql.TableRef{Schema: schema.Name, Table: table.Name},
-			Index:        index.Name,
-			Unique:       index.Unique,
-			Concurrently: index.Concurrently,
-			Using:        string(index.Using),
-			Dimensions:   dims,
-			Where:        condStr,
+			Table:      relation,
+			Index:      index.Name,
+			Unique:     index.Unique,
+			Using:      string(index.Using),
+			Dimensions: dims,
+			Where:      condStr,
+		},
+	}
+}
+
+// getCreateIndexConcurrentlySql builds an index without blocking writes to its table. This can't run inside a transaction,
+// and can be rerun if it fails part way through: an INVALID index left behind by an earlier attempt is dropped first.
+func getCreateIndexConcurrentlySql(create *sql.IndexCreate) []output.ToSql {
+	concurrent := *create
+	concurrent.Concurrently = true
+	concurrent.IfNotExists = true
+	return []output.ToSql{
+		&sql.IndexDropInvalid{Index: sql.IndexRef{Schema: create.Table.Schema, Index: create.Index}},
+		&concurrent,
+	}
+}
+
+func getDropIndexConcurrentlySql(schema *ir.Schema, index *ir.Index) []output.ToSql {
+	return []output.ToSql{
+		&sql.IndexDrop{
+			Index:        sql.IndexRef{Schema: schema.Name, Index: index.Name},
+			Concurrently: true,
+			IfExists:     true,
 		},
 	}
 }
@@ -37,6 +37,14 @@ func (li *introspector) GetFullStructure(ctx context.Context) (structure, error)
 	if err != nil {
 		return rv, err
 	}
+	rv.Extensions, err = li.getExtensions()
+	if err != nil {
+		return rv, err
+	}
+	rv.Roles, err = li.getRoles(rv.Database.Owner)
+	if err != nil {
+		return rv, err
+	}
 	rv.Tables, err = li.getTableList(ctx)
 	if err != nil {
 		return rv, err
@@ -48,6 +56,10 @@ func (li *introspector) GetFullStructure(ctx context.Context) (structure, error)
 				seqName := strings.Split(column.Default, "'")
 				colBoundSequences = append(colBoundSequences, seqName[1])
 			}
+			if column.IdentitySequence != "" {
+				// identity sequences are part of their column
+				colBoundSequences = append(colBoundSequences, column.IdentitySequence)
+			}
 		}
 	}
 	for _, schema := range rv.Schemas {
@@ -61,6 +73,11 @@ func (li *introspector) GetFullStructure(ctx context.Context) (structure, error)
 	if err != nil {
 		return rv, err
 	}
+	matViews, err := li.getMaterializedViews(ctx)
+	if err != nil {
+		return rv, err
+	}
+	rv.Views = append(rv.Views, matViews...)
 	rv.Constraints, err = li.getConstraints()
 	if err != nil {
 		return rv, err
@@ -77,6 +94,10 @@ func (li *introspector) GetFullStructure(ctx context.Context) (structure, error)
 	if err != nil {
 		return rv, err
 	}
+	rv.Policies, err = li.getPolicies()
+	if err != nil {
+		return rv, err
+	}
 	rv.TablePerms, err = li.getTablePerms()
 	if err != nil {
 		return rv, err
@@ -85,6 +106,102 @@ func (li *introspector) GetFullStructure(ctx context.Context) (structure, error)
 	if err != nil {
 		return rv, err
 	}
+	rv.DefaultACLs, err = li.getDefaultPrivileges()
+	if err != nil {
+		return rv, err
+	}
+	rv.RangeTypes, err = li.getRangeTypes()
+	if err != nil {
+		return rv, err
+	}
+	rv.Casts, err = li.getCasts()
+	if err != nil {
+		return rv, err
+	}
+	return foldPartitions(rv)
+}
+
+// foldPartitions removes native partitions from the table list, recording them
+// on their parent table instead. Objects belonging to the partitions themselves
+// are either inherited from the parent or not supported, so they are dropped too.
+func foldPartitions(rv structure) (structure, error) {
+	parents := map[string]*tableEntry{}
+	for idx := range rv.Tables {
+		table := &rv.Tables[idx]
+		if table.PartitionKey != "" {
+			parents[table.Schema+"."+table.Table] = table
+		}
+	}
+	partitions := map[string]bool{}
+	for _, table := range rv.Tables {
+		if table.PartitionBound == "" {
+			continue
+		}
+		if table.PartitionKey != "" {
+			return rv, fmt.Errorf("unsupported: partition %s.%s is itself partitioned", table.Schema, table.Table)
+		}
+		if len(table.ParentTables) != 1 {
+			return rv, fmt.Errorf("unexpected: partition %s.%s has parents %v", table.Schema, table.Table, table.ParentTables)
+		}
+		parent, ok := parents[table.ParentTables[0]]
+		if !ok {
+			return rv, fmt.Errorf("unexpected: partition %s.%s parent %s is not partitioned", table.Schema, table.Table, table.ParentTables[0])
+		}
+		if parent.Schema != table.Schema {
+			return rv, fmt.Errorf("unsupported: partition %s.%s is not in the same schema as its parent %s.%s", table.Schema, table.Table, parent.Schema, parent.Table)
+		}
+		parent.Partitions = append(parent.Partitions, partitionEntry{
+			Name:  table.Table,
+			Bound: strings.TrimSpace(strings.TrimPrefix(table.PartitionBound, "FOR VALUES ")),
+		})
+		partitions[table.Schema+"."+table.Table] = true
+	}
+	if len(partitions) == 0 {
+		return rv, nil
+	}
+
+	tables := []tableEntry{}
+	for _, table := range rv.Tables {
+		if !partitions[table.Schema+"."+table.Table] {
+			tables = append(tables, table)
+		}
+	}
+	rv.Tables = tables
+	constraints := []constraintEntry{}
+	for _, constraint := range rv.Constraints {
+		if !partitions[constraint.Schema+"."+constraint.Table] {
+			constraints = append(constraints, constraint)
+		}
+	}
+	rv.Constraints = constraints
+	foreignKeys := []foreignKeyEntry{}
+	for _, fk := range rv.ForeignKeys {
+		if !partitions[fk.LocalSchema+"."+fk.LocalTable] {
+			foreignKeys = append(foreignKeys, fk)
+		}
+	}
+	rv.ForeignKeys = foreignKeys
+	triggers := []triggerEntry{}
+	for _, trigger := range rv.Triggers {
+		if !partitions[trigger.Schema+"."+trigger.Table] {
+			triggers = append(triggers, trigger)
+		}
+	}
+	rv.Triggers = triggers
+	policies := []policyEntry{}
+	for _, policy := range rv.Policies {
+		if !partitions[policy.Schema+"."+policy.Table] {
+			policies = append(policies, policy)
+		}
+	}
+	rv.Policies = policies
+	perms := []tablePermEntry{}
+	for _, perm := range rv.TablePerms {
+		if !partitions[perm.Schema+"."+perm.Table] {
+			perms = append(perms, perm)
+		}
+	}
+	rv.TablePerms = perms
 	return rv, nil
 }
 
@@ -139,6 +256,67 @@ func (li *introspector) getSchemaList() ([]schemaEntry, error) {
 	return out, nil
 }
 
+// getExtensions lists installed extensions, other than plpgsql which every database has
+func (li *introspector) getExtensions() ([]extensionEntry, error) {
+	if !FEAT_EXTENSIONS(li.vers) {
+		return nil, nil
+	}
+	rows, err := li.conn.query(`
+		SELECT e.extname, n.nspname, e.extversion
+		FROM pg_catalog.pg_extension e
+		JOIN pg_catalog.pg_namespace n ON n.oid = e.extnamespace
+		WHERE e.extname <> 'plpgsql'
+		ORDER BY e.extname
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("running get extensions query: %w", err)
+	}
+	defer rows.Close()
+	out := []extensionEntry{}
+	for rows.Next() {
+		entry := extensionEntry{}
+		err := rows.Scan(&entry.Name, &entry.Schema, &entry.Version)
+		if err != nil {
+			return nil, fmt.Errorf("scanning extension row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// getRoles lists the roles of the cluster along with the roles they're members of. Built in roles,
+// superusers and the database owner are left out, as those are managed outside of any definition.
+func (li *introspector) getRoles(owner string) ([]roleEntry, error) {
+	rows, err := li.conn.query(`
+		SELECT
+			r.rolname, r.rolcanlogin, r.rolinherit, r.rolcreatedb, r.rolconnlimit,
+			ARRAY(
+				SELECT g.rolname::text
+				FROM pg_catalog.pg_auth_members m
+				JOIN pg_catalog.pg_roles g ON g.oid = m.roleid
+				WHERE m.member = r.oid
+				ORDER BY g.rolname
+			) AS member_of
+		FROM pg_catalog.pg_roles r
+		WHERE r.rolname !~ '^pg_' AND NOT r.rolsuper AND r.rolname <> $1
+		ORDER BY r.rolname
+	`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("running get roles query: %w", err)
+	}
+	defer rows.Close()
+	out := []roleEntry{}
+	for rows.Next() {
+		entry := roleEntry{}
+		err := rows.Scan(&entry.Name, &entry.Login, &entry.Inherit, &entry.CreateDB, &entry.ConnectionLimit, &entry.MemberOf)
+		if err != nil {
+			return nil, fmt.Errorf("scanning role row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
 // TODO(go,3) can we elevate this to an engine-agnostic interface?
 // TODO(go,3) can we defer this to model operations entirely?
 
@@ -146,7 +324,18 @@ func (li *introspector) getTableList(ctx context.Context) ([]tableEntry, error)
 	// TODO(go,3) move column description to column query
 	// Note that old versions of postgres don't support array_agg(description ORDER BY objsubid)
 	// so we need to use subquery to do ordering
-	res, err := li.conn.query(`
+	// NOTE: native partitioning was introduced in pg 10.0
+	partitionCols := "NULL::text AS partition_key, NULL::text AS partition_bound"
+	if !li.getServerVersion().IsOlderThan(10, 0) {
+		partitionCols = `CASE WHEN c.relkind = 'p' THEN pg_catalog.pg_get_partkeydef(c.oid) END AS partition_key,
+			CASE WHEN c.relispartition THEN pg_catalog.pg_get_expr(c.relpartbound, c.oid) END AS partition_bound`
+	}
+	// NOTE: row level security was introduced in pg 9.5
+	rlsCols := "false AS row_security, false AS force_row_security"
+	if FEAT_ROW_LEVEL_SECURITY(li.getServerVersion()) {
+		rlsCols = "c.relrowsecurity AS row_security, c.relforcerowsecurity AS force_row_security"
+	}
+	res, err := li.conn.query(fmt.Sprintf(`
 		SELECT
 			t.schemaname, t.tablename, t.tableowner, t.tablespace,
 			sd.description as schema_description, td.description as table_description,
@@ -154,15 +343,22 @@ func (li *introspector) getTableList(ctx context.Context) ([]tableEntry, error)
 				FROM pg_catalog.pg_inherits i
 				LEFT JOIN pg_catalog.pg_class pc ON (i.inhparent = pc.oid)
 				LEFT JOIN pg_catalog.pg_namespace pn ON (pc.relnamespace = pn.oid)
-				WHERE i.inhrelid = c.oid) AS parent_tables
+				WHERE i.inhrelid = c.oid) AS parent_tables,
+			%s,
+			%s
 		FROM pg_catalog.pg_tables t
 		LEFT JOIN pg_catalog.pg_namespace n ON (n.nspname = t.schemaname)
 		LEFT JOIN pg_catalog.pg_class c ON (c.relname = t.tablename AND c.relnamespace = n.oid)
 		LEFT JOIN pg_catalog.pg_description td ON (td.objoid = c.oid AND td.classoid = c.tableoid AND td.objsubid = 0)
 		LEFT JOIN pg_catalog.pg_description sd ON (sd.objoid = n.oid)
 		WHERE schemaname NOT IN ('information_schema', 'pg_catalog')
+		AND NOT EXISTS (
+			-- tables belonging to an extension are created by the extension
+			SELECT 1 FROM pg_catalog.pg_depend dep
+			WHERE dep.classid = 'pg_catalog.pg_class'::regclass AND dep.objid = c.oid AND dep.deptype = 'e'
+		)
 		ORDER BY schemaname, tablename;
-	`)
+	`, partitionCols, rlsCols))
 	if err != nil {
 		return nil, errors.Wrap(err, "while running query")
 	}
@@ -174,6 +370,8 @@ func (li *introspector) getTableList(ctx context.Context) ([]tableEntry, error)
 			&entry.Schema, &entry.Table, &entry.Owner, &entry.Tablespace,
 			&maybeStr{&entry.SchemaDescription}, &maybeStr{&entry.TableDescription},
 			&entry.ParentTables,
+			&maybeStr{&entry.PartitionKey}, &maybeStr{&entry.PartitionBound},
+			&entry.RowSecurity, &entry.ForceRowSecurity,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "while scanning result")
@@ -247,20 +445,38 @@ func (li *introspector) getTableStorageOptions(schema, table string) (map[string
 }
 
 func (li *introspector) getColumns(schema, table string) ([]columnEntry, error) {
-	res, err := li.conn.query(`
+	// identities are only in pg_attribute from 10.0, and generated columns from 12.0
+	identityCols := "'', NULL, NULL, NULL, NULL, NULL, NULL, false"
+	identityJoin := ""
+	if FEAT_IDENTITY_COLUMNS(li.getServerVersion()) {
+		identityCols = "pga.attidentity::text, seqcls.relname, seq.seqstart, seq.seqincrement, seq.seqmin, seq.seqmax, seq.seqcache, COALESCE(seq.seqcycle, false)"
+		identityJoin = `
+			LEFT JOIN pg_depend seqdep ON (seqdep.refobjid = pgc.oid AND seqdep.refobjsubid = pga.attnum AND seqdep.deptype = 'i' AND seqdep.classid = 'pg_class'::regclass)
+			LEFT JOIN pg_class seqcls ON (seqcls.oid = seqdep.objid AND seqcls.relkind = 'S')
+			LEFT JOIN pg_sequence seq ON (seq.seqrelid = seqcls.oid)`
+	}
+	generatedCol := "NULL"
+	generatedJoin := ""
+	if FEAT_GENERATED_COLUMNS(li.getServerVersion()) {
+		generatedCol = "CASE WHEN pga.attgenerated = 's' THEN pg_get_expr(pgad.adbin, pgad.adrelid) END"
+		generatedJoin = `
+			LEFT JOIN pg_attrdef pgad ON (pgad.adrelid = pgc.oid AND pgad.adnum = pga.attnum)`
+	}
+	res, err := li.conn.query(fmt.Sprintf(`
 		SELECT
 			column_name, column_default, is_nullable = 'YES', pgd.description,
-			ordinal_position, format_type(atttypid, atttypmod) as attribute_data_type
+			ordinal_position, format_type(atttypid, atttypmod) as attribute_data_type,
+			%s, %s
 		FROM information_schema.columns
-			JOIN pg_class pgc ON (pgc.relname = table_name AND pgc.relkind='r')
+			JOIN pg_class pgc ON (pgc.relname = table_name AND pgc.relkind IN ('r', 'p'))
 			JOIN pg_namespace nsp ON (nsp.nspname = table_schema AND nsp.oid = pgc.relnamespace)
 			JOIN pg_attribute pga ON (pga.attrelid = pgc.oid AND columns.column_name = pga.attname)
-			LEFT JOIN pg_description pgd ON (pgd.objoid = pgc.oid AND pgd.classoid = pgc.tableoid AND pgd.objsubid = ordinal_position)
+			LEFT JOIN pg_description pgd ON (pgd.objoid = pgc.oid AND pgd.classoid = pgc.tableoid AND pgd.objsubid = ordinal_position)%s%s
 		WHERE table_schema=$1 AND table_name=$2
 			AND attnum > 0
 			AND NOT attisdropped
 		ORDER BY ordinal_position ASC
-	`, schema, table)
+	`, generatedCol, identityCols, generatedJoin, identityJoin), schema, table)
 	if err != nil {
 		return nil, errors.Wrap(err, "while running query")
 	}
@@ -271,6 +487,9 @@ func (li *introspector) getColumns(schema, table string) ([]columnEntry, error)
 		err := res.Scan(
 			&entry.Name, &maybeStr{&entry.Default}, &entry.Nullable,
 			&maybeStr{&entry.Description}, &entry.Position, &entry.AttrType,
+			&maybeStr{&entry.GeneratedAs}, &entry.Identity, &maybeStr{&entry.IdentitySequence},
+			&entry.IdentityStart, &entry.IdentityIncrement, &entry.IdentityMin, &entry.IdentityMax,
+			&entry.IdentityCache, &entry.IdentityCycle,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "while scanning result")
@@ -300,6 +519,8 @@ func (li *introspector) getIndexes(ctx context.Context, schema, table string) ([
 		WHERE tc.relname = $2
 			AND n.nspname = $1
 			AND i.indisprimary != 't'
+			-- an INVALID index was left behind by a CREATE INDEX CONCURRENTLY which failed, queries don't use it
+			AND i.indisvalid
 			AND ic.relname NOT IN (
 				SELECT constraint_name
 				FROM information_schema.table_constraints
@@ -352,7 +573,7 @@ func (li *introspector) getSequenceRelList(ctx context.Context, schema string, s
 	`
 	params := []interface{}{schema}
 	if len(sequenceCols) > 0 {
-		sql += `AND s.relname != ANY($2)`
+		sql += `AND s.relname != ALL($2)`
 		params = append(params, sequenceCols)
 	}
 	sql += `GROUP BY s.relname, r.rolname, d.description`
@@ -516,6 +737,47 @@ func (li *introspector) getViews() ([]viewEntry, error) {
 	return out, nil
 }
 
+func (li *introspector) getMaterializedViews(ctx context.Context) ([]viewEntry, error) {
+	// NOTE: materialized views were introduced in pg 9.3
+	if li.getServerVersion().IsOlderThan(9, 3) {
+		return nil, nil
+	}
+	res, err := li.conn.query(`
+		SELECT m.schemaname, m.matviewname, m.matviewowner, m.definition,
+		coalesce(pg_catalog.obj_description(c.oid, 'pg_class'), '')
+		FROM pg_catalog.pg_matviews m
+		JOIN pg_catalog.pg_namespace n ON n.nspname = m.schemaname
+		JOIN pg_catalog.pg_class c ON (c.relname = m.matviewname AND c.relnamespace = n.oid)
+		WHERE m.schemaname NOT IN ('information_schema', 'pg_catalog');
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "while running query")
+	}
+	defer res.Close()
+
+	out := []viewEntry{}
+	for res.Next() {
+		entry := viewEntry{Materialized: true}
+		err := res.Scan(&entry.Schema, &entry.Name, &entry.Owner, &entry.Definition, &entry.Description)
+		if err != nil {
+			return nil, errors.Wrap(err, "while scanning result")
+		}
+		out = append(out, entry)
+	}
+	if err := res.Err(); err != nil {
+		return nil, errors.Wrap(err, "while iterating results")
+	}
+	for idx := range out {
+		view := out[idx]
+		view.Indexes, err = li.getIndexes(ctx, view.Schema, view.Name)
+		if err != nil {
+			return nil, fmt.Errorf("materialized view '%s.%s': %w", view.Schema, view.Name, err)
+		}
+		out[idx] = view
+	}
+	return out, nil
+}
+
 func (li *introspector) getConstraints() ([]constraintEntry, error) {
 	consrcCol := "consrc AS check_src"
 	if FEAT_CONSTRAINT_USE_GETTER(li.vers) {
@@ -650,7 +912,12 @@ func (li *introspector) getFunctions() ([]functionEntry, error) {
 		FROM pg_catalog.pg_proc p
 			LEFT JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
 			LEFT JOIN pg_catalog.pg_language l ON l.oid = p.prolang
-		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema');
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND NOT EXISTS (
+			-- functions belonging to an extension are created by the extension
+			SELECT 1 FROM pg_catalog.pg_depend dep
+			WHERE dep.classid = 'pg_catalog.pg_proc'::regclass AND dep.objid = p.oid AND dep.deptype = 'e'
+		);
 	`, typeCase))
 	if err != nil {
 		return nil, errors.Wrap(err, "while running query")
@@ -775,6 +1042,57 @@ func (li *introspector) getTriggers() ([]triggerEntry, error) {
 	return out, nil
 }
 
+func (li *introspector) getPolicies() ([]policyEntry, error) {
+	if !FEAT_ROW_LEVEL_SECURITY(li.vers) {
+		return nil, nil
+	}
+	restrictive := "false"
+	if FEAT_RESTRICTIVE_POLICIES(li.vers) {
+		restrictive = "NOT pol.polpermissive"
+	}
+	res, err := li.conn.query(fmt.Sprintf(`
+		SELECT
+			n.nspname, c.relname, pol.polname,
+			CASE pol.polcmd
+				WHEN 'r' THEN 'SELECT'
+				WHEN 'a' THEN 'INSERT'
+				WHEN 'w' THEN 'UPDATE'
+				WHEN 'd' THEN 'DELETE'
+				ELSE 'ALL'
+			END AS command,
+			%s AS restrictive,
+			ARRAY(
+				SELECT r.rolname::text FROM pg_catalog.pg_roles r
+				WHERE r.oid = ANY(pol.polroles)
+				ORDER BY r.rolname
+			) AS roles,
+			pg_catalog.pg_get_expr(pol.polqual, pol.polrelid) AS using_expr,
+			pg_catalog.pg_get_expr(pol.polwithcheck, pol.polrelid) AS with_check
+		FROM pg_catalog.pg_policy pol
+		JOIN pg_catalog.pg_class c ON c.oid = pol.polrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY n.nspname, c.relname, pol.polname
+	`, restrictive))
+	if err != nil {
+		return nil, fmt.Errorf("running get policies query: %w", err)
+	}
+	defer res.Close()
+	out := []policyEntry{}
+	for res.Next() {
+		entry := policyEntry{}
+		err := res.Scan(
+			&entry.Schema, &entry.Table, &entry.Name, &entry.Command, &entry.Restrictive,
+			&entry.Roles, &maybeStr{&entry.Using}, &maybeStr{&entry.WithCheck},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning policy row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, res.Err()
+}
+
 func (li *introspector) getSchemaPerms() ([]schemaPermEntry, error) {
 	rows, err := li.conn.query(`
 		SELECT n.nspname AS "Name",
@@ -837,6 +1155,136 @@ func (li *introspector) getTablePerms() ([]tablePermEntry, error) {
 	return out, nil
 }
 
+// getDefaultPrivileges lists the default privileges granted, one row per grantee and privilege.
+// Privileges every new object gets anyway, and which only show up once the defaults for all schemas
+// have been altered, are left out: those of the role creating the objects, and PUBLIC's on functions and types.
+func (li *introspector) getDefaultPrivileges() ([]defaultPrivilegeEntry, error) {
+	if !FEAT_DEFAULT_PRIVILEGES(li.vers) {
+		return nil, nil
+	}
+	res, err := li.conn.query(`
+		SELECT role, schema, objtype, grantee, privilege_type, is_grantable
+		FROM (
+			SELECT
+				pg_catalog.pg_get_userbyid(d.defaclrole) AS role,
+				COALESCE(n.nspname, '') AS schema,
+				CASE d.defaclobjtype
+					WHEN 'r' THEN 'TABLES'
+					WHEN 'S' THEN 'SEQUENCES'
+					WHEN 'f' THEN 'FUNCTIONS'
+					WHEN 'T' THEN 'TYPES'
+					WHEN 'n' THEN 'SCHEMAS'
+				END AS objtype,
+				CASE WHEN (d.acl).grantee = 0 THEN 'PUBLIC' ELSE pg_catalog.pg_get_userbyid((d.acl).grantee) END AS grantee,
+				(d.acl).privilege_type,
+				(d.acl).is_grantable,
+				d.defaclnamespace = 0 AS all_schemas,
+				(d.acl).grantee = d.defaclrole AS is_owner
+			FROM (
+				SELECT defaclrole, defaclnamespace, defaclobjtype, pg_catalog.aclexplode(defaclacl) AS acl
+				FROM pg_catalog.pg_default_acl
+			) d
+			LEFT JOIN pg_catalog.pg_namespace n ON n.oid = d.defaclnamespace
+		) p
+		WHERE NOT is_owner
+		AND NOT (all_schemas AND grantee = 'PUBLIC' AND objtype IN ('FUNCTIONS', 'TYPES'))
+		ORDER BY role, schema, objtype, grantee, privilege_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("running get default privileges query: %w", err)
+	}
+	defer res.Close()
+	out := []defaultPrivilegeEntry{}
+	for res.Next() {
+		entry := defaultPrivilegeEntry{}
+		err := res.Scan(&entry.Role, &entry.Schema, &entry.ObjectType, &entry.Grantee, &entry.Type, &entry.Grantable)
+		if err != nil {
+			return nil, fmt.Errorf("scanning default privileges row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, res.Err()
+}
+
+// getRangeTypes lists range types outside of the system schemas, other than those belonging to extensions.
+// Options which are the defaults for the subtype are left empty.
+func (li *introspector) getRangeTypes() ([]rangeTypeEntry, error) {
+	if !FEAT_RANGE_TYPES(li.vers) {
+		return nil, nil
+	}
+	rows, err := li.conn.query(`
+		SELECT
+			n.nspname, t.typname,
+			pg_catalog.format_type(r.rngsubtype, NULL),
+			CASE WHEN opc.opcdefault THEN '' ELSE quote_ident(opcn.nspname) || '.' || quote_ident(opc.opcname) END,
+			COALESCE((
+				SELECT quote_ident(c.collname) FROM pg_catalog.pg_collation c
+				WHERE c.oid = r.rngcollation AND r.rngcollation <> st.typcollation
+			), ''),
+			CASE WHEN r.rngcanonical = 0 THEN '' ELSE r.rngcanonical::regproc::text END,
+			CASE WHEN r.rngsubdiff = 0 THEN '' ELSE r.rngsubdiff::regproc::text END
+		FROM pg_catalog.pg_range r
+		JOIN pg_catalog.pg_type t ON t.oid = r.rngtypid
+		JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_catalog.pg_type st ON st.oid = r.rngsubtype
+		JOIN pg_catalog.pg_opclass opc ON opc.oid = r.rngsubopc
+		JOIN pg_catalog.pg_namespace opcn ON opcn.oid = opc.opcnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_catalog.pg_depend d
+			WHERE d.classid = 'pg_catalog.pg_type'::regclass AND d.objid = t.oid AND d.deptype = 'e'
+		)
+		ORDER BY n.nspname, t.typname
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("running get range types query: %w", err)
+	}
+	defer rows.Close()
+	out := []rangeTypeEntry{}
+	for rows.Next() {
+		entry := rangeTypeEntry{}
+		err := rows.Scan(&entry.Schema, &entry.Name, &entry.Subtype, &entry.SubtypeOpClass, &entry.Collation, &entry.Canonical, &entry.SubtypeDiff)
+		if err != nil {
+			return nil, fmt.Errorf("scanning range type row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
+// getCasts lists the casts created in the database, leaving out the built in ones and those belonging to extensions
+func (li *introspector) getCasts() ([]castEntry, error) {
+	rows, err := li.conn.query(`
+		SELECT
+			pg_catalog.format_type(c.castsource, NULL),
+			pg_catalog.format_type(c.casttarget, NULL),
+			c.castmethod,
+			CASE WHEN c.castfunc = 0 THEN '' ELSE c.castfunc::regprocedure::text END,
+			c.castcontext
+		FROM pg_catalog.pg_cast c
+		WHERE c.oid >= 16384 -- objects below FirstNormalObjectId are built in
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_catalog.pg_depend d
+			WHERE d.classid = 'pg_catalog.pg_cast'::regclass AND d.objid = c.oid AND d.deptype = 'e'
+		)
+		ORDER BY 1, 2
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("running get casts query: %w", err)
+	}
+	defer rows.Close()
+	out := []castEntry{}
+	for rows.Next() {
+		entry := castEntry{}
+		err := rows.Scan(&entry.Source, &entry.Target, &entry.Method, &entry.Function, &entry.Context)
+		if err != nil {
+			return nil, fmt.Errorf("scanning cast row: %w", err)
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
 func (li *introspector) getSequencePerms(seq string) ([]string, error) {
 	res, err := li.conn.query(`SELECT relacl FROM pg_class WHERE relname = $1`, seq)
 	if err != nil {
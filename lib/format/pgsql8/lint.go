@@ -0,0 +1,332 @@
+package pgsql8
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// lintUpgrade logs lint findings for the recorded upgrade if linting is on, and returns a
+// *lib.LintFailure if any of them are at least as severe as the configured threshold
+func (ops *Operations) lintUpgrade(recorders []*changeRecorder, oldDoc, newDoc *ir.Definition) error {
+	if !ops.config.Lint {
+		return nil
+	}
+	report := lintChanges(ops.GetQuoter(), recorders, oldDoc, newDoc)
+	for _, finding := range report.Findings {
+		msg := fmt.Sprintf("Lint %s in stage %d on %s: %s [%s]", finding.Severity, finding.Stage, finding.Object, finding.Message, finding.Rule)
+		switch finding.Severity {
+		case lib.LintError:
+			ops.logger.Error(msg)
+		case lib.LintWarning:
+			ops.logger.Warn(msg)
+		default:
+			ops.logger.Info(msg)
+		}
+	}
+	if report.Fails(ops.config.LintFailOn) {
+		return &lib.LintFailure{Report: report, Threshold: ops.config.LintFailOn}
+	}
+	return nil
+}
+
+// lintChanges looks for recorded statements which rewrite or lock tables for a long time
+// when run against a database which already has data in them. Tables created by the same
+// upgrade are empty, so statements which only touch them are never flagged.
+func lintChanges(q output.Quoter, recorders []*changeRecorder, oldDoc, newDoc *ir.Definition) *lib.LintReport {
+	report := &lib.LintReport{}
+	// not null checks which the upgrade itself adds NOT VALID and then validates spare SET NOT NULL its scan too
+	pendingChecks := map[string]string{}
+	checkedColumns := map[string]bool{}
+	for _, change := range stagedChanges(recorders) {
+		changes := change.Parts
+		if len(changes) == 0 {
+			changes = []*output.Change{change}
+		}
+		for _, part := range changes {
+			stmt := part.Statement
+			if an, ok := stmt.(*sql.Annotated); ok {
+				stmt = an.Wrapped
+			}
+			switch s := stmt.(type) {
+			case *sql.ConstraintCreateRaw:
+				if column := notNullCheckColumn(s.Definition); s.ConstraintType.Equals(ir.ConstraintTypeCheck) && column != "" {
+					pendingChecks[part.Identity.String()] = output.ChangeIdentity{Schema: s.Table.Schema, Parent: s.Table.Table, Name: column}.String()
+				}
+			case *sql.ConstraintValidate:
+				if column, ok := pendingChecks[part.Identity.String()]; ok {
+					checkedColumns[strings.ToLower(column)] = true
+				}
+			}
+
+			finding := lintStatement(q, stmt, oldDoc, newDoc)
+			if finding == nil || (finding.Rule == "set-not-null" && checkedColumns[strings.ToLower(part.Identity.String())]) {
+				continue
+			}
+			finding.Stage = change.Stage
+			finding.Object = part.Identity.String()
+			finding.SQL = strings.TrimSpace(stmt.ToSql(q))
+			report.Findings = append(report.Findings, *finding)
+		}
+	}
+	return report
+}
+
+func lintStatement(q output.Quoter, stmt output.ToSql, oldDoc, newDoc *ir.Definition) *lib.LintFinding {
+	switch s := stmt.(type) {
+	case *sql.TableAlterParts:
+		oldTable := existingTable(oldDoc, newDoc, s.Table)
+		if oldTable == nil || len(s.Parts) != 1 {
+			return nil
+		}
+		part := s.Parts[0]
+		if an, ok := part.(*sql.TableAlterPartAnnotation); ok {
+			part = an.Wrapped
+		}
+		return lintTableAlterPart(q, part, oldTable, newDoc)
+
+	case *sql.ColumnSetNull:
+		oldTable := existingTable(oldDoc, newDoc, sql.TableRef{Schema: s.Column.Schema, Table: s.Column.Table})
+		if oldTable == nil || s.Nullable {
+			return nil
+		}
+		return lintSetNotNull(oldTable, s.Column.Column)
+
+	case *sql.IndexCreate:
+		if s.Concurrently || existingTable(oldDoc, newDoc, s.Table) == nil {
+			return nil
+		}
+		return &lib.LintFinding{
+			Severity: lib.LintWarning,
+			Rule:     "index-not-concurrent",
+			Message:  fmt.Sprintf("building index %s blocks writes to %s until it finishes, create it CONCURRENTLY", s.Index, s.Table.Qualified(q)),
+		}
+
+	case *sql.ConstraintCreateForeignKey:
+		if s.NotValid || existingTable(oldDoc, newDoc, s.Table) == nil {
+			return nil
+		}
+		return lintForeignKey(q, s.Constraint, s.Table)
+	case *sql.ConstraintCreateRaw:
+		if !s.ConstraintType.Equals(ir.ConstraintTypeForeign) || s.NotValid || notValidRegex.MatchString(s.Definition) {
+			return nil
+		}
+		if existingTable(oldDoc, newDoc, s.Table) == nil {
+			return nil
+		}
+		return lintForeignKey(q, s.Constraint, s.Table)
+	}
+	return nil
+}
+
+func lintTableAlterPart(q output.Quoter, part sql.TableAlterPart, oldTable *ir.Table, newDoc *ir.Definition) *lib.LintFinding {
+	switch p := part.(type) {
+	case *sql.TableAlterPartColumnChangeType:
+		from := columnTypeOf(oldTable, p.Column)
+		to := typeRefName(p.Type)
+		if p.Using == nil && !typeRewrites(from, to) {
+			return nil
+		}
+		return lintColumnTypeRewrite(p.Column, from, to)
+	case *sql.TableAlterPartColumnChangeTypeUsingCast:
+		return lintColumnTypeRewrite(p.Column, columnTypeOf(oldTable, p.Column), typeRefName(p.Type))
+
+	case *sql.TableAlterPartColumnCreate:
+		def := p.ColumnDef
+		if isSerialType(def.Type.Type) {
+			return &lib.LintFinding{
+				Severity: lib.LintError,
+				Rule:     "volatile-default",
+				Message:  fmt.Sprintf("adding %s column %s fills every existing row from a sequence, rewriting the table under an ACCESS EXCLUSIVE lock", def.Type.Type, def.Name),
+			}
+		}
+		if def.Default == nil {
+			return nil
+		}
+		value := def.Default.GetValueSql(q)
+		if !isVolatileExpression(value, newDoc) {
+			return nil
+		}
+		return &lib.LintFinding{
+			Severity: lib.LintError,
+			Rule:     "volatile-default",
+			Message:  fmt.Sprintf("adding column %s with volatile default %s rewrites the table under an ACCESS EXCLUSIVE lock", def.Name, value),
+		}
+
+	case *sql.TableAlterPartColumnSetNull:
+		if p.Nullable {
+			return nil
+		}
+		return lintSetNotNull(oldTable, p.Column)
+	}
+	return nil
+}
+
+func lintColumnTypeRewrite(column, from, to string) *lib.LintFinding {
+	return &lib.LintFinding{
+		Severity: lib.LintError,
+		Rule:     "column-type-rewrite",
+		Message:  fmt.Sprintf("changing column %s from %s to %s rewrites the table under an ACCESS EXCLUSIVE lock", column, from, to),
+	}
+}
+
+func lintSetNotNull(oldTable *ir.Table, column string) *lib.LintFinding {
+	if hasNotNullCheck(oldTable, column) {
+		return nil
+	}
+	return &lib.LintFinding{
+		Severity: lib.LintWarning,
+		Rule:     "set-not-null",
+		Message: fmt.Sprintf(
+			"SET NOT NULL on %s scans the whole table under an ACCESS EXCLUSIVE lock, add and validate CHECK (%s IS NOT NULL) in an earlier upgrade",
+			column, column,
+		),
+	}
+}
+
+func lintForeignKey(q output.Quoter, constraint string, table sql.TableRef) *lib.LintFinding {
+	return &lib.LintFinding{
+		Severity: lib.LintWarning,
+		Rule:     "foreign-key-not-valid",
+		Message: fmt.Sprintf(
+			"adding foreign key %s checks every row of %s while blocking writes to both tables, add it NOT VALID and VALIDATE CONSTRAINT separately",
+			constraint, table.Qualified(q),
+		),
+	}
+}
+
+// hasNotNullCheck reports whether an existing, so already validated, check constraint proves the column isn't null,
+// which postgres 12+ uses to skip scanning the table for SET NOT NULL
+func hasNotNullCheck(table *ir.Table, column string) bool {
+	for _, constraint := range table.Constraints {
+		if constraint.Type.Equals(ir.ConstraintTypeCheck) && isNotNullCheck(constraint.Definition, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// existingTable returns the table in oldDoc which the upgrade alters as ref, following renames,
+// or nil if ref is created by the upgrade
+func existingTable(oldDoc, newDoc *ir.Definition, ref sql.TableRef) *ir.Table {
+	newSchema := newDoc.TryGetSchemaNamed(ref.Schema)
+	if newTable := newSchema.TryGetTableNamed(ref.Table); newTable != nil && newTable.OldTableName != "" {
+		return oldDoc.GetOldTable(newSchema, newTable)
+	}
+	return oldDoc.TryGetSchemaNamed(ref.Schema).TryGetTableNamed(ref.Table)
+}
+
+func columnTypeOf(table *ir.Table, column string) string {
+	if col := table.TryGetColumnNamed(column); col != nil {
+		return col.Type
+	}
+	return ""
+}
+
+var notValidRegex = regexp.MustCompile(`(?i)\bNOT\s+VALID\b`)
+
+// volatileFunctions are builtin functions which return a different value for each row
+var volatileFunctions = []string{
+	"random", "clock_timestamp", "timeofday", "nextval", "txid_current",
+	"gen_random_uuid", "uuid_generate_v1", "uuid_generate_v1mc", "uuid_generate_v4",
+}
+
+var functionCallRegex = regexp.MustCompile(`(?i)([a-z_][a-z0-9_]*(?:\.[a-z_][a-z0-9_]*)?)\s*\(`)
+
+// isVolatileExpression reports whether expr calls a volatile builtin function, or a function
+// defined in doc which isn't declared IMMUTABLE or STABLE, as postgres functions are volatile by default
+func isVolatileExpression(expr string, doc *ir.Definition) bool {
+	for _, match := range functionCallRegex.FindAllStringSubmatch(expr, -1) {
+		name := strings.ToLower(match[1])
+		schemaName := ""
+		if dot := strings.Index(name, "."); dot >= 0 {
+			schemaName, name = name[:dot], name[dot+1:]
+		}
+		for _, volatile := range volatileFunctions {
+			if name == volatile {
+				return true
+			}
+		}
+		for _, schema := range doc.Schemas {
+			if schemaName != "" && !strings.EqualFold(schema.Name, schemaName) {
+				continue
+			}
+			for _, function := range schema.Functions {
+				if !strings.EqualFold(function.Name, name) {
+					continue
+				}
+				policy := strings.ToUpper(function.CachePolicy)
+				if policy != "IMMUTABLE" && policy != "STABLE" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isNotNullCheck reports whether a check constraint definition is exactly "column IS NOT NULL"
+func isNotNullCheck(definition, column string) bool {
+	checked := notNullCheckColumn(definition)
+	return checked != "" && strings.EqualFold(checked, column)
+}
+
+// notNullCheckColumn returns the column a check constraint definition of the form "column IS NOT NULL"
+// checks, or an empty string for any other definition
+func notNullCheckColumn(definition string) string {
+	def := strings.TrimSpace(definition)
+	for strings.HasPrefix(def, "(") && strings.HasSuffix(def, ")") {
+		def = strings.TrimSpace(def[1 : len(def)-1])
+	}
+	match := notNullCheckRegex.FindStringSubmatch(def)
+	if match == nil {
+		return ""
+	}
+	return strings.Trim(match[1], `"`)
+}
+
+var notNullCheckRegex = regexp.MustCompile(`(?i)^("[^"]+"|[a-z_][a-z0-9_]*)\s+IS\s+NOT\s+NULL$`)
+
+// typeRewrites reports whether postgres has to rewrite a table to change a column from one type
+// to another. Only the binary compatible conversions between builtin types avoid a rewrite.
+func typeRewrites(from, to string) bool {
+	f, ok := parseTypeSpec(from)
+	if !ok {
+		return true
+	}
+	t, ok := parseTypeSpec(to)
+	if !ok {
+		return true
+	}
+	if hasTimeZone(from) != hasTimeZone(to) {
+		return true
+	}
+	// unbounded lengths are -1, so treat them as larger than any limit
+	widens := func(a, b int) bool {
+		return b == -1 || (a != -1 && b >= a)
+	}
+
+	switch {
+	case (f.name == "varchar" || f.name == "text") && t.name == "text":
+		return false
+	case (f.name == "varchar" || f.name == "text") && t.name == "varchar":
+		return !widens(f.length, t.length)
+	case f.name == "numeric" && t.name == "numeric":
+		return !(t.length == -1 || (widens(f.length, t.length) && f.scale == t.scale))
+	case f.name == t.name && (f.name == "timestamp" || f.name == "time"):
+		return !widens(f.length, t.length)
+	case f == t:
+		return false
+	}
+	return true
+}
+
+func hasTimeZone(spec string) bool {
+	spec = strings.ToLower(spec)
+	return strings.Contains(spec, "with time zone") || strings.HasPrefix(strings.TrimSpace(spec), "timestamptz") || strings.HasPrefix(strings.TrimSpace(spec), "timetz")
+}
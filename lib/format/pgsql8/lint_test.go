@@ -0,0 +1,265 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintChanges(t *testing.T) {
+	owners := &ir.Table{
+		Name:       "owners",
+		PrimaryKey: []string{"id"},
+		Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+	}
+	tests := []struct {
+		name      string
+		oldTables []*ir.Table
+		newTables []*ir.Table
+		findings  []lib.LintFinding
+	}{
+		{
+			name: "column-type-rewrite",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "code", Type: "varchar(10)", Nullable: true},
+				},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "code", Type: "integer", Nullable: true},
+				},
+			}},
+			findings: []lib.LintFinding{{
+				Severity: lib.LintError,
+				Rule:     "column-type-rewrite",
+				Stage:    1,
+				Object:   "public.vehicles.code",
+				Message:  "changing column code from varchar(10) to integer rewrites the table under an ACCESS EXCLUSIVE lock",
+				SQL:      "ALTER TABLE public.vehicles\n  /* changing from type varchar(10) */\n  ALTER COLUMN code TYPE integer;",
+			}},
+		},
+		{
+			name: "varchar widened in place",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "varchar(100)", Nullable: true},
+				},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "name", Type: "varchar(200)", Nullable: true},
+				},
+			}},
+		},
+		{
+			name: "volatile-default",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "token", Type: "uuid", Nullable: true, Default: "gen_random_uuid()"},
+				},
+			}},
+			findings: []lib.LintFinding{{
+				Severity: lib.LintError,
+				Rule:     "volatile-default",
+				Stage:    1,
+				Object:   "public.vehicles.token",
+				Message:  "adding column token with volatile default gen_random_uuid() rewrites the table under an ACCESS EXCLUSIVE lock",
+				SQL:      "ALTER TABLE public.vehicles\n  ADD COLUMN token uuid DEFAULT gen_random_uuid();",
+			}},
+		},
+		{
+			name: "stable default",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "created", Type: "timestamp", Nullable: true, Default: "now()"},
+				},
+			}},
+		},
+		{
+			name: "set-not-null",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text", Nullable: true},
+				},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+			}},
+			findings: []lib.LintFinding{{
+				Severity: lib.LintWarning,
+				Rule:     "set-not-null",
+				Stage:    3,
+				Object:   "public.vehicles.nick",
+				Message:  "SET NOT NULL on nick scans the whole table under an ACCESS EXCLUSIVE lock, add and validate CHECK (nick IS NOT NULL) in an earlier upgrade",
+				SQL:      "ALTER TABLE public.vehicles\n  ALTER COLUMN nick SET NOT NULL;",
+			}},
+		},
+		{
+			name: "set-not-null proven by a check",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text", Nullable: true},
+				},
+				Constraints: []*ir.Constraint{{Name: "nick_not_null", Type: ir.ConstraintTypeCheck, Definition: "(nick IS NOT NULL)"}},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+				Constraints: []*ir.Constraint{{Name: "nick_not_null", Type: ir.ConstraintTypeCheck, Definition: "(nick IS NOT NULL)"}},
+			}},
+		},
+		{
+			name: "index-not-concurrent",
+			oldTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+			}},
+			newTables: []*ir.Table{{
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+				Indexes: []*ir.Index{{Name: "vehicles_nick_idx", Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}}},
+			}},
+			findings: []lib.LintFinding{{
+				Severity: lib.LintWarning,
+				Rule:     "index-not-concurrent",
+				Stage:    1,
+				Object:   "public.vehicles_nick_idx",
+				Message:  "building index vehicles_nick_idx blocks writes to public.vehicles until it finishes, create it CONCURRENTLY",
+				SQL:      "CREATE INDEX vehicles_nick_idx ON public.vehicles (nick)",
+			}},
+		},
+		{
+			name: "index on a new table",
+			newTables: []*ir.Table{{
+				Name:       "trips",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "at", Type: "timestamp"},
+				},
+				Indexes: []*ir.Index{{Name: "trips_at_idx", Dimensions: []*ir.IndexDim{{Name: "at_1", Value: "at"}}}},
+			}},
+		},
+		{
+			name: "foreign-key-not-valid",
+			oldTables: []*ir.Table{owners, {
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "owner_id", Type: "integer"},
+				},
+			}},
+			newTables: []*ir.Table{owners, {
+				Name:       "vehicles",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "owner_id", Type: "integer", ForeignTable: "owners", ForeignColumn: "id", ForeignKeyName: "vehicles_owner_fkey"},
+				},
+			}},
+			findings: []lib.LintFinding{{
+				Severity: lib.LintWarning,
+				Rule:     "foreign-key-not-valid",
+				Stage:    4,
+				Object:   "public.vehicles.vehicles_owner_fkey",
+				Message:  "adding foreign key vehicles_owner_fkey checks every row of public.vehicles while blocking writes to both tables, add it NOT VALID and VALIDATE CONSTRAINT separately",
+				SQL:      "ALTER TABLE public.vehicles\n  ADD CONSTRAINT vehicles_owner_fkey FOREIGN KEY (owner_id) REFERENCES public.owners (id);",
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldDoc := &ir.Definition{Schemas: []*ir.Schema{{Name: "public", Tables: test.oldTables}}}
+			newDoc := &ir.Definition{Schemas: []*ir.Schema{{Name: "public", Tables: test.newTables}}}
+			recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+			report := lintChanges(defaultQuoter(DefaultConfig), recorders, oldDoc, newDoc)
+			assert.Equal(t, test.findings, report.Findings)
+		})
+	}
+}
+
+func TestLintReport_Fails(t *testing.T) {
+	report := &lib.LintReport{Findings: []lib.LintFinding{{Severity: lib.LintWarning}}}
+	assert.False(t, report.Fails(lib.LintError))
+	assert.True(t, report.Fails(lib.LintWarning))
+	assert.True(t, report.Fails(lib.LintInfo))
+	assert.False(t, report.Fails("none"))
+}
+
+func TestTypeRewrites(t *testing.T) {
+	cases := []struct {
+		from, to string
+		rewrites bool
+	}{
+		{"varchar(50)", "varchar(100)", false},
+		{"varchar(100)", "varchar(50)", true},
+		{"varchar(100)", "text", false},
+		{"text", "varchar", false},
+		{"text", "varchar(10)", true},
+		{"numeric(10,2)", "numeric(12,2)", false},
+		{"numeric(10,2)", "numeric(12,3)", true},
+		{"numeric(10,2)", "numeric", false},
+		{"integer", "bigint", true},
+		{"timestamp", "timestamp with time zone", true},
+		{"timestamptz", "timestamp with time zone", false},
+		{"public.my_enum", "text", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.rewrites, typeRewrites(c.from, c.to), "%s -> %s", c.from, c.to)
+	}
+}
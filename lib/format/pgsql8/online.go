@@ -0,0 +1,273 @@
+package pgsql8
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// concurrentWriter is implemented by segmenters which can hold changes that have to run
+// outside of their stage's transaction, such as CREATE INDEX CONCURRENTLY
+type concurrentWriter interface {
+	WriteConcurrentChanges(changes ...*output.Change) error
+}
+
+// onlineRewriter rewrites statements which would lock tables with existing data for as long as it
+// takes to scan them into steps which don't:
+//   - foreign keys and checks are added NOT VALID in stage 1, and validated in stage 3
+//   - SET NOT NULL in stage 3 relies on a NOT VALID check added in stage 1 and validated just before it,
+//     which postgres 12+ uses instead of scanning the table itself. Older targets scan regardless,
+//     so SET NOT NULL is left as it is for them
+//   - indexes are built CONCURRENTLY once stage 1 has committed
+//
+// Validation only takes a SHARE UPDATE EXCLUSIVE lock, so reads and writes carry on meanwhile.
+type onlineRewriter struct {
+	quoter         output.Quoter
+	oldDoc         *ir.Definition
+	newDoc         *ir.Definition
+	notNullByCheck bool
+}
+
+func newOnlineRewriter(q output.Quoter, oldDoc, newDoc *ir.Definition, notNullByCheck bool) *onlineRewriter {
+	return &onlineRewriter{
+		quoter:         q,
+		oldDoc:         oldDoc,
+		newDoc:         newDoc,
+		notNullByCheck: notNullByCheck,
+	}
+}
+
+// onlineStage is a stage segmenter which passes every change written to it through a rewrite
+type onlineStage struct {
+	output.OutputFileSegmenter
+	rewrite func(change *output.Change) error
+}
+
+func (s *onlineStage) WriteSql(stmts ...output.ToSql) error {
+	return s.WriteChanges(untypedChanges(stmts...)...)
+}
+
+func (s *onlineStage) WriteChanges(changes ...*output.Change) error {
+	for _, change := range changes {
+		if err := s.rewrite(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteConcurrentChanges passes changes which the differ already runs outside of the transaction straight through
+func (s *onlineStage) WriteConcurrentChanges(changes ...*output.Change) error {
+	return s.OutputFileSegmenter.(concurrentWriter).WriteConcurrentChanges(changes...)
+}
+
+func (s *onlineStage) MustWriteSql(stmts []output.ToSql, err error) {
+	if err != nil {
+		panic(err)
+	}
+	err = s.WriteSql(stmts...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// wrap returns the four stages with the rewrites applied. Every stage has to be a concurrentWriter.
+func (r *onlineRewriter) wrap(stage1, stage2, stage3, stage4 output.OutputFileSegmenter) (output.OutputFileSegmenter, output.OutputFileSegmenter, output.OutputFileSegmenter, output.OutputFileSegmenter, error) {
+	for i, stage := range []output.OutputFileSegmenter{stage1, stage2, stage3, stage4} {
+		if _, ok := stage.(concurrentWriter); !ok {
+			return nil, nil, nil, nil, fmt.Errorf("stage %d can't hold statements to run after it commits", i+1)
+		}
+	}
+	wrapped1 := &onlineStage{stage1, func(change *output.Change) error {
+		if handled, err := r.rewriteIndex(stage1, change); handled {
+			return err
+		}
+		if handled, err := r.rewriteConstraint(stage1, stage3, change); handled {
+			return err
+		}
+		return writeChanges(stage1, change)
+	}}
+	var wrapped3 output.OutputFileSegmenter = stage3
+	if r.notNullByCheck {
+		wrapped3 = &onlineStage{stage3, func(change *output.Change) error {
+			return r.rewriteSetNotNull(stage1, stage3, change)
+		}}
+	}
+	// foreign keys are written to stage 4 after the data they reference, but added NOT VALID they only check new rows
+	wrapped4 := &onlineStage{stage4, func(change *output.Change) error {
+		if handled, err := r.rewriteConstraint(stage1, stage3, change); handled {
+			return err
+		}
+		return writeChanges(stage4, change)
+	}}
+	return wrapped1, stage2, wrapped3, wrapped4, nil
+}
+
+// rewritten is a copy of the change, made by the given statement instead
+func rewritten(change *output.Change, stmt output.ToSql) *output.Change {
+	copied := *change
+	copied.Statement = stmt
+	return &copied
+}
+
+// rewriteConstraint adds foreign keys and checks on existing tables NOT VALID in stage 1, and validates them in stage 3
+func (r *onlineRewriter) rewriteConstraint(stage1, stage3 output.OutputFileSegmenter, change *output.Change) (bool, error) {
+	inner, rewrap := unwrapAnnotated(change.Statement)
+	switch s := inner.(type) {
+	case *sql.ConstraintCreateForeignKey:
+		if s.NotValid || !r.isExisting(s.Table) {
+			return false, nil
+		}
+		notValid := *s
+		notValid.NotValid = true
+		return true, r.validateLater(stage1, stage3, rewritten(change, rewrap(&notValid)), s.Table, s.Constraint)
+	case *sql.ConstraintCreateRaw:
+		validatable := s.ConstraintType.Equals(ir.ConstraintTypeForeign) || s.ConstraintType.Equals(ir.ConstraintTypeCheck)
+		if !validatable || s.NotValid || !r.isExisting(s.Table) {
+			return false, nil
+		}
+		notValid := *s
+		notValid.NotValid = true
+		return true, r.validateLater(stage1, stage3, rewritten(change, rewrap(&notValid)), s.Table, s.Constraint)
+	}
+	return false, nil
+}
+
+// validateLater writes the NOT VALID constraint to stage 1, and validates it in stage 3
+func (r *onlineRewriter) validateLater(stage1, stage3 output.OutputFileSegmenter, notValid *output.Change, table sql.TableRef, constraint string) error {
+	if err := writeChanges(stage1, notValid); err != nil {
+		return err
+	}
+	validate := rewritten(notValid, &sql.ConstraintValidate{Table: table, Constraint: constraint})
+	validate.Action = output.ChangeAlter
+	return writeChanges(stage3, validate)
+}
+
+// rewriteIndex builds indexes on existing tables concurrently after the stage commits
+func (r *onlineRewriter) rewriteIndex(stage output.OutputFileSegmenter, change *output.Change) (bool, error) {
+	inner, rewrap := unwrapAnnotated(change.Statement)
+	s, ok := inner.(*sql.IndexCreate)
+	// foreign keys may need a new unique index, so leave those where they are
+	if !ok || s.Concurrently || !r.isExisting(s.Table) || (s.Unique && isReferencedByForeignKey(r.newDoc, s.Table)) {
+		return false, nil
+	}
+	changes := []*output.Change{}
+	for _, stmt := range getCreateIndexConcurrentlySql(s) {
+		changes = append(changes, rewritten(change, rewrap(stmt)))
+	}
+	return true, stage.(concurrentWriter).WriteConcurrentChanges(changes...)
+}
+
+// rewriteSetNotNull precedes SET NOT NULL on existing tables with a NOT NULL check added in stage 1 and validated
+// just before it, and drops the check after
+func (r *onlineRewriter) rewriteSetNotNull(stage1, stage3 output.OutputFileSegmenter, change *output.Change) error {
+	inner, _ := unwrapAnnotated(change.Statement)
+	var table sql.TableRef
+	columns := []string{}
+	switch s := inner.(type) {
+	case *sql.TableAlterParts:
+		table = s.Table
+		for _, part := range s.Parts {
+			if an, ok := part.(*sql.TableAlterPartAnnotation); ok {
+				part = an.Wrapped
+			}
+			if setNull, ok := part.(*sql.TableAlterPartColumnSetNull); ok && !setNull.Nullable {
+				columns = append(columns, setNull.Column)
+			}
+		}
+	case *sql.ColumnSetNull:
+		table = sql.TableRef{Schema: s.Column.Schema, Table: s.Column.Table}
+		if !s.Nullable {
+			columns = append(columns, s.Column.Column)
+		}
+	}
+	oldTable := existingTable(r.oldDoc, r.newDoc, table)
+	if len(columns) == 0 || oldTable == nil {
+		return writeChanges(stage3, change)
+	}
+
+	checks := []changed{}
+	for _, column := range columns {
+		if hasNotNullCheck(oldTable, column) {
+			continue
+		}
+		// the check only exists for the duration of the upgrade, so isn't in either definition
+		check := changed{
+			kind:     "constraint",
+			identity: output.ChangeIdentity{Schema: table.Schema, Parent: table.Table, Name: buildIndexName(table.Table, column, "not_null")},
+		}
+		checks = append(checks, check)
+		// existing rows are only checked once stage 3 has filled in any defaults for them
+		err := writeChanges(stage1, check.create(&sql.ConstraintCreateRaw{
+			Table:          table,
+			Constraint:     check.identity.Name,
+			ConstraintType: ir.ConstraintTypeCheck,
+			Definition:     fmt.Sprintf("(%s IS NOT NULL)", r.quoter.QuoteColumn(column)),
+			NotValid:       true,
+		})...)
+		if err != nil {
+			return err
+		}
+		err = writeChanges(stage3, check.alter(&sql.ConstraintValidate{Table: table, Constraint: check.identity.Name})...)
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeChanges(stage3, change); err != nil {
+		return err
+	}
+	// the column itself is NOT NULL now, so the check is redundant
+	for _, check := range checks {
+		if err := writeChanges(stage3, check.drop(&sql.ConstraintDrop{Table: table, Constraint: check.identity.Name})...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *onlineRewriter) isExisting(table sql.TableRef) bool {
+	return existingTable(r.oldDoc, r.newDoc, table) != nil
+}
+
+// unwrapAnnotated returns the statement inside an annotation, and a function which annotates a replacement the same way
+func unwrapAnnotated(stmt output.ToSql) (output.ToSql, func(output.ToSql) output.ToSql) {
+	an, ok := stmt.(*sql.Annotated)
+	if !ok {
+		return stmt, func(s output.ToSql) output.ToSql { return s }
+	}
+	return an.Wrapped, func(s output.ToSql) output.ToSql {
+		return &sql.Annotated{Annotation: an.Annotation, Wrapped: s}
+	}
+}
+
+// isReferencedByForeignKey reports whether any foreign key in doc references the table
+func isReferencedByForeignKey(doc *ir.Definition, ref sql.TableRef) bool {
+	references := func(localSchema, foreignSchema, foreignTable string) bool {
+		if foreignSchema == "" {
+			foreignSchema = localSchema
+		}
+		return foreignTable == ref.Table && foreignSchema == ref.Schema
+	}
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			for _, column := range table.Columns {
+				if column.ForeignTable != "" && references(schema.Name, column.ForeignSchema, column.ForeignTable) {
+					return true
+				}
+			}
+			for _, fk := range table.ForeignKeys {
+				if references(schema.Name, fk.ForeignSchema, fk.ForeignTable) {
+					return true
+				}
+			}
+			for _, constraint := range table.Constraints {
+				if constraint.Type.Equals(ir.ConstraintTypeForeign) && references(schema.Name, constraint.ForeignSchema, constraint.ForeignTable) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
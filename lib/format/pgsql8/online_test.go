@@ -0,0 +1,201 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlineRewriter_ForeignKeys(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer"},
+					},
+				},
+			},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "owners",
+					PrimaryKey: []string{"id"},
+					Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				},
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "owner_id", Type: "integer", ForeignTable: "owners", ForeignColumn: "id", ForeignKeyName: "pets_owner_fkey"},
+					},
+				},
+			},
+		}},
+	}
+
+	conf := DefaultConfig
+	conf.OnlineSafe = true
+	recorders := diffChangesCommon(t, conf, oldDoc, newDoc)
+	// the foreign key is written to stage 4, but added NOT VALID in stage 1 and validated in stage 3
+	assert.Equal(t, []output.ToSql{
+		&sql.ConstraintCreateForeignKey{
+			Table:          sql.TableRef{Schema: "public", Table: "pets"},
+			Constraint:     "pets_owner_fkey",
+			LocalColumns:   []string{"owner_id"},
+			ForeignTable:   sql.TableRef{Schema: "public", Table: "owners"},
+			ForeignColumns: []string{"id"},
+			NotValid:       true,
+		},
+	}, changeStatements(recorders[0].changes))
+	assert.Equal(t, []output.ToSql{
+		&sql.ConstraintValidate{Table: sql.TableRef{Schema: "public", Table: "pets"}, Constraint: "pets_owner_fkey"},
+	}, changeStatements(recorders[2].changes))
+	assert.Empty(t, changeStatements(recorders[3].changes))
+	for _, recorder := range recorders {
+		assert.Empty(t, recorder.concurrent, "stage %d", recorder.stage)
+	}
+}
+
+func TestOnlineRewriter_Indexes(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "pets",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{
+				{
+					Name:       "pets",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "nick", Type: "text"},
+					},
+					Indexes: []*ir.Index{{Name: "pets_nick_idx", Dimensions: []*ir.IndexDim{{Name: "nick_1", Value: "nick"}}}},
+				},
+				{
+					Name:       "visits",
+					PrimaryKey: []string{"id"},
+					Columns: []*ir.Column{
+						{Name: "id", Type: "integer"},
+						{Name: "at", Type: "timestamp"},
+					},
+					Indexes: []*ir.Index{{Name: "visits_at_idx", Dimensions: []*ir.IndexDim{{Name: "at_1", Value: "at"}}}},
+				},
+			},
+		}},
+	}
+
+	conf := DefaultConfig
+	conf.OnlineSafe = true
+	recorders := diffChangesCommon(t, conf, oldDoc, newDoc)
+	// indexes on new tables don't need to be built concurrently
+	assert.Contains(t, changeStatements(recorders[0].changes), &sql.IndexCreate{
+		Table:      sql.TableRef{Schema: "public", Table: "visits"},
+		Index:      "visits_at_idx",
+		Dimensions: []sql.Quotable{&sql.QuoteObject{Ident: "at"}},
+	})
+	assert.Equal(t, []output.ToSql{
+		&sql.IndexDropInvalid{Index: sql.IndexRef{Schema: "public", Index: "pets_nick_idx"}},
+		&sql.IndexCreate{
+			Table:        sql.TableRef{Schema: "public", Table: "pets"},
+			Index:        "pets_nick_idx",
+			Concurrently: true,
+			IfNotExists:  true,
+			Dimensions:   []sql.Quotable{&sql.QuoteObject{Ident: "nick"}},
+		},
+	}, changeStatements(recorders[0].concurrent))
+}
+
+func TestOnlineRewriter_SetNotNull(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "pets",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "pets",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text"},
+				},
+			}},
+		}},
+	}
+	pets := sql.TableRef{Schema: "public", Table: "pets"}
+	setNotNull := &sql.TableAlterParts{
+		Table: pets,
+		Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnSetNull{Column: "nick"}},
+	}
+
+	// postgres 12 uses a validated check instead of scanning the table
+	conf := DefaultConfig
+	conf.OnlineSafe = true
+	conf.TargetVersion = "12"
+	recorders := diffChangesCommon(t, conf, oldDoc, newDoc)
+	assert.Equal(t, []output.ToSql{
+		&sql.ConstraintCreateRaw{
+			Table:          pets,
+			Constraint:     "pets_nick_not_null",
+			ConstraintType: ir.ConstraintTypeCheck,
+			Definition:     "(nick IS NOT NULL)",
+			NotValid:       true,
+		},
+	}, changeStatements(recorders[0].changes))
+	assert.Empty(t, changeStatements(recorders[1].changes))
+	assert.Equal(t, []output.ToSql{
+		&sql.ConstraintValidate{Table: pets, Constraint: "pets_nick_not_null"},
+		setNotNull,
+		&sql.ConstraintDrop{Table: pets, Constraint: "pets_nick_not_null"},
+	}, changeStatements(recorders[2].changes))
+	for _, recorder := range recorders {
+		assert.Empty(t, recorder.concurrent, "stage %d", recorder.stage)
+	}
+	assert.Empty(t, lintChanges(defaultQuoter(conf), recorders, oldDoc, newDoc).Findings)
+
+	// before 12 the table is scanned regardless, so there's nothing to gain
+	conf.TargetVersion = "11"
+	recorders = diffChangesCommon(t, conf, oldDoc, newDoc)
+	assert.Empty(t, changeStatements(recorders[0].changes))
+	assert.Equal(t, []output.ToSql{setNotNull}, changeStatements(recorders[2].changes))
+}
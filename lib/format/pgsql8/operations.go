@@ -3,6 +3,7 @@ package pgsql8
 import (
 	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -68,6 +69,34 @@ func (ops *Operations) CreateStatements(def ir.Definition) ([]output.DDLStatemen
 }
 
 func (ops *Operations) Build(outputPrefix string, dbDoc *ir.Definition) error {
+	target, err := parseTargetVersion(ops.config.TargetVersion, dbDoc)
+	if err != nil {
+		return err
+	}
+	if ops.config.Idempotent && target != 0 && !FEAT_INSERT_ON_CONFLICT(target) {
+		return fmt.Errorf("idempotent builds need a target version of at least 9.5, for IF NOT EXISTS and ON CONFLICT DO NOTHING")
+	}
+	err = checkGeneratedColumns(dbDoc, target)
+	if err != nil {
+		return err
+	}
+	err = checkExtensions(dbDoc, target)
+	if err != nil {
+		return err
+	}
+	err = checkPolicies(dbDoc, target)
+	if err != nil {
+		return err
+	}
+	err = checkDefaultPrivileges(dbDoc, target)
+	if err != nil {
+		return err
+	}
+	err = checkTypes(dbDoc, target)
+	if err != nil {
+		return err
+	}
+
 	buildFileName := outputPrefix + "_build.sql"
 	ops.logger.Info(fmt.Sprintf("Building complete file %s", buildFileName))
 
@@ -77,11 +106,23 @@ func (ops *Operations) Build(outputPrefix string, dbDoc *ir.Definition) error {
 	}
 
 	buildFileOfs := output.NewOutputFileSegmenterToFile(ops.logger, ops.GetQuoter(), buildFileName, 1, buildFile, buildFileName, ops.config.OutputFileStatementLimit)
-	err = ops.build(buildFileOfs, dbDoc)
+	recorder := newBuildChangeRecorder()
+	var buildOfs output.OutputFileSegmenter = recorder
+	if ops.config.Idempotent {
+		buildOfs = &idempotentSegmenter{
+			OutputFileSegmenter: recorder,
+			replaceTriggers:     target != 0 && FEAT_CREATE_OR_REPLACE_TRIGGER(target),
+		}
+	}
+	err = ops.build(buildOfs, dbDoc)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = recorder.render(buildFileOfs)
+	if err != nil {
+		return err
+	}
+	return ops.writePlan(outputPrefix+"_build_plan.json", []*changeRecorder{recorder}, []string{"full database definition"})
 }
 
 func (ops *Operations) build(buildFileOfs output.OutputFileSegmenter, dbDoc *ir.Definition) error {
@@ -92,6 +133,11 @@ func (ops *Operations) build(buildFileOfs output.OutputFileSegmenter, dbDoc *ir.
 	}
 	if !ops.config.GenerateSlonik {
 		buildFileOfs.WriteSql(output.NewRawSQL("BEGIN;\n\n"))
+		timeouts, err := ops.timeoutSettings(dbDoc)
+		if err != nil {
+			return err
+		}
+		buildFileOfs.WriteSql(timeouts...)
 	}
 
 	ops.logger.Info("Calculating table foreign dependency order...")
@@ -110,7 +156,7 @@ func (ops *Operations) build(buildFileOfs output.OutputFileSegmenter, dbDoc *ir.
 			if err != nil {
 				return err
 			}
-			buildFileOfs.WriteSql(s...)
+			writeChanges(buildFileOfs, languageChanged(nil, language).create(s...)...)
 		}
 	}
 
@@ -203,41 +249,54 @@ func (ops *Operations) BuildUpgrade(
 		return fmt.Errorf("calculating dependency order: %w", err)
 	}
 
-	err = ops.differ.DiffDoc(oldCompositeFile, newCompositeFile, oldDoc, newDoc, upgradePrefix)
-	if err != nil {
-		return err
+	// a lint failure still writes the upgrade, so it's only returned once everything else is written too
+	lintErr := ops.differ.DiffDoc(oldCompositeFile, newCompositeFile, oldDoc, newDoc, upgradePrefix)
+	var lintFailure *lib.LintFailure
+	if lintErr != nil && !errors.As(lintErr, &lintFailure) {
+		return lintErr
+	}
+
+	if ops.config.GenerateDowngrade {
+		ops.logger.Info("Calculating downgrade...")
+		err = ops.buildDowngrade(oldCompositeFile, oldDoc, newCompositeFile, newDoc, newOutputPrefix+"_downgrade")
+		if err != nil {
+			return fmt.Errorf("building downgrade: %w", err)
+		}
 	}
 
 	// TODO(go,slony)
 	// if lib.GlobalDBSteward.GenerateSlonik {}
-	return nil
+	return lintErr
 }
 
 func (ops *Operations) Upgrade(l *slog.Logger, oldDoc *ir.Definition, newDoc *ir.Definition) ([]output.DDLStatement, error) {
-	var err error
-	ops.differ.OldTableDependency, err = oldDoc.TableDependencyOrder()
+	recorders, err := ops.diffChanges(oldDoc, newDoc)
 	if err != nil {
-		return nil, fmt.Errorf("old document: %w", err)
+		return nil, err
 	}
-	ops.differ.NewTableDependency, err = newDoc.TableDependencyOrder()
+	err = ops.guardDestructive(recorders, newDoc, "old definition")
 	if err != nil {
-		return nil, fmt.Errorf("new document: %w", err)
+		return nil, err
 	}
-	ops.config.OldDatabase = oldDoc
-	ops.config.NewDatabase = newDoc
-
 	stage1 := output.NewSegmenter(ops.GetQuoter())
 	stage2 := output.NewSegmenter(ops.GetQuoter())
 	stage3 := output.NewSegmenter(ops.GetQuoter())
 	stage4 := output.NewSegmenter(ops.GetQuoter())
-	err = ops.differ.DiffDocWork(stage1, stage2, stage3, stage4)
+	err = renderChanges(recorders, stage1, stage2, stage3, stage4)
 	if err != nil {
 		return nil, err
 	}
-	stmts := stage1.AllStatements()
-	stmts = append(stmts, stage2.AllStatements()...)
-	stmts = append(stmts, stage3.AllStatements()...)
-	stmts = append(stmts, stage4.AllStatements()...)
+	stmts := []output.DDLStatement{}
+	for i, stage := range []*output.Segmenter{stage1, stage2, stage3, stage4} {
+		stmts = append(stmts, stage.AllStatements()...)
+		// concurrent changes follow the COMMIT of their stage
+		concurrent := output.NewSegmenter(ops.GetQuoter())
+		err = output.WriteChanges(concurrent, recorders[i].concurrent)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, concurrent.AllStatements()...)
+	}
 	return stmts, nil
 }
 
@@ -246,9 +305,8 @@ func (ops *Operations) ExtractSchemaConn(ctx context.Context, c *pgx.Conn) (*ir.
 	return ops.extractSchema(ctx, conn)
 }
 
-func (ops *Operations) ExtractSchema(host string, port uint, name, user, pass string) (*ir.Definition, error) {
-	ops.logger.Info(fmt.Sprintf("Connecting to pgsql8 host %s:%d database %s as %s", host, port, name, user))
-	conn, err := newConnection(host, port, name, user, pass)
+func (ops *Operations) ExtractSchema(connString string) (*ir.Definition, error) {
+	conn, err := newConnection(ops.logger, connString)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to database: %w", err)
 	}
@@ -283,6 +341,60 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		storeSchema(doc, roles, schema)
 	}
 
+	for _, roleRow := range pgDoc.Roles {
+		role := &ir.Role{
+			Name:     roleRow.Name,
+			Login:    roleRow.Login,
+			Inherit:  roleRow.Inherit,
+			CreateDB: roleRow.CreateDB,
+		}
+		if roleRow.ConnectionLimit >= 0 {
+			role.ConnectionLimit = util.Ptr(roleRow.ConnectionLimit)
+		}
+		if len(roleRow.MemberOf) > 0 {
+			role.MemberOf = roleRow.MemberOf
+		}
+		doc.AddRole(role)
+	}
+
+	for _, ext := range pgDoc.Extensions {
+		doc.AddExtension(&ir.Extension{
+			Name:    ext.Name,
+			Schema:  ext.Schema,
+			Version: ext.Version,
+		})
+	}
+
+	for _, rangeRow := range pgDoc.RangeTypes {
+		schema := doc.TryGetSchemaNamed(rangeRow.Schema)
+		if schema == nil {
+			return nil, fmt.Errorf("range type '%s' references missing schema '%s'", rangeRow.Name, rangeRow.Schema)
+		}
+		schema.AddType(&ir.TypeDef{
+			Name: rangeRow.Name,
+			Kind: ir.DataTypeKindRange,
+			RangeType: &ir.DataTypeRangeType{
+				Subtype:        rangeRow.Subtype,
+				SubtypeOpClass: rangeRow.SubtypeOpClass,
+				Collation:      rangeRow.Collation,
+				Canonical:      rangeRow.Canonical,
+				SubtypeDiff:    rangeRow.SubtypeDiff,
+			},
+		})
+	}
+
+	castMethods := map[string]ir.CastMethod{"f": ir.CastMethodFunction, "i": ir.CastMethodInout, "b": ir.CastMethodBinary}
+	castContexts := map[string]ir.CastContext{"e": ir.CastContextExplicit, "a": ir.CastContextAssignment, "i": ir.CastContextImplicit}
+	for _, castRow := range pgDoc.Casts {
+		doc.AddCast(&ir.Cast{
+			Source:   castRow.Source,
+			Target:   castRow.Target,
+			Method:   castMethods[castRow.Method],
+			Function: castRow.Function,
+			Context:  castContexts[castRow.Context],
+		})
+	}
+
 	for _, pgTable := range pgDoc.Tables {
 		schemaName := pgTable.Schema
 		tableName := pgTable.Table
@@ -298,9 +410,11 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		util.Assert(table == nil, "table %s.%s already defined in xml object - unexpected", schema.Name, tableName)
 		roles.registerRole(roleContextOwner, pgTable.Owner)
 		table = &ir.Table{
-			Name:        tableName,
-			Owner:       pgTable.Owner,
-			Description: pgTable.TableDescription,
+			Name:                  tableName,
+			Owner:                 pgTable.Owner,
+			Description:           pgTable.TableDescription,
+			RowLevelSecurity:      pgTable.RowSecurity,
+			ForceRowLevelSecurity: pgTable.ForceRowSecurity,
 		}
 		schema.AddTable(table)
 
@@ -313,6 +427,20 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 			table.SetTableOption(ir.SqlFormatPgsql8, "with", "("+util.EncodeKV(pgTable.StorageOptions, ",", "=")+")")
 		}
 
+		if pgTable.PartitionKey != "" {
+			partitioning, err := partitionFromKeyDef(pgTable.PartitionKey)
+			if err != nil {
+				return nil, fmt.Errorf("table %s.%s: %w", schema.Name, table.Name, err)
+			}
+			for _, partition := range pgTable.Partitions {
+				partitioning.Segments = append(partitioning.Segments, &ir.TablePartitionSegment{
+					Name:  partition.Name,
+					Value: partition.Bound,
+				})
+			}
+			table.Partitioning = partitioning
+		}
+
 		// NEW(2): extract table inheritance. need this to complete example diffing validation
 		if len(pgTable.ParentTables) > 1 {
 			// TODO(go,4) remove this restriction
@@ -334,7 +462,9 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 				// TODO(go,nth) legacy logic only ever sets nullable to false (pgsql8.php:1638) but that really doesn't seem correct to me. validate this
 				Nullable: colRow.Nullable,
 				// TODO(go,nth) how does this handle expression defaults?
-				Default: colRow.Default,
+				Default:     colRow.Default,
+				Identity:    colRow.IdentityToIR(),
+				GeneratedAs: colRow.GeneratedAs,
 			}
 			table.AddColumn(column)
 
@@ -427,17 +557,33 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		view := schema.TryGetViewNamed(viewRow.Name)
 		util.Assert(view == nil, "view %s.%s already defined in XML object -- unexpected", schema.Name, viewRow.Name)
 		roles.registerRole(roleContextOwner, viewRow.Owner)
-		schema.AddView(&ir.View{
-			Name:        viewRow.Name,
-			Description: viewRow.Description,
-			Owner:       viewRow.Owner,
+		view = &ir.View{
+			Name:         viewRow.Name,
+			Description:  viewRow.Description,
+			Owner:        viewRow.Owner,
+			Materialized: viewRow.Materialized,
 			Queries: []*ir.ViewQuery{
 				{
 					SqlFormat: ir.SqlFormatPgsql8,
 					Text:      viewRow.Definition,
 				},
 			},
-		})
+		}
+		for _, indexRow := range viewRow.Indexes {
+			index := &ir.Index{
+				Name:   indexRow.Name,
+				Using:  indexRow.UsingToIR(),
+				Unique: indexRow.Unique,
+			}
+			for _, dim := range indexRow.Dimensions {
+				index.AddDimension(dim)
+			}
+			if indexRow.Condition != "" {
+				index.AddCondition(ir.SqlFormatPgsql8, indexRow.Condition)
+			}
+			view.AddIndex(index)
+		}
+		schema.AddView(view)
 	}
 
 	// for all schemas, all tables - get table constraints that are not type 'FOREIGN KEY'
@@ -606,6 +752,30 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		trigger.Function = strings.TrimSpace(util.IReplaceAll(triggerRow.Statement, "EXECUTE PROCEDURE", ""))
 	}
 
+	for _, policyRow := range pgDoc.Policies {
+		table := doc.TryGetSchemaNamed(policyRow.Schema).TryGetTableNamed(policyRow.Table)
+		util.Assert(table != nil, "failed to find table %s.%s for policy %s", policyRow.Schema, policyRow.Table, policyRow.Name)
+
+		for _, role := range policyRow.Roles {
+			roles.registerRole(roleContextGrant, role)
+		}
+		command, err := ir.NewPolicyCommand(policyRow.Command)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s on table %s.%s: %w", policyRow.Name, policyRow.Schema, policyRow.Table, err)
+		}
+		policy := &ir.Policy{
+			Name:        policyRow.Name,
+			Command:     command,
+			Using:       policyRow.Using,
+			WithCheck:   policyRow.WithCheck,
+			Restrictive: policyRow.Restrictive,
+		}
+		if len(policyRow.Roles) > 0 {
+			policy.Roles = policyRow.Roles
+		}
+		table.AddPolicy(policy)
+	}
+
 	// Find table/view grants and save them in the roleIndex
 	// TODO(go,3) can simplify this by array_agg(privilege_type)
 	ops.logger.Info("Analyze table permissions")
@@ -649,6 +819,13 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		}
 	}
 
+	for _, aclRow := range pgDoc.DefaultACLs {
+		roles.registerRole(roleContextOwner, aclRow.Role)
+		if aclRow.Grantee != ir.RolePublic {
+			roles.registerRole(roleContextGrant, aclRow.Grantee)
+		}
+	}
+
 	// The entire list of roles is now available to be analyzed
 	doc.Database.Roles = roles.resolveRoles()
 
@@ -789,9 +966,63 @@ func (ops *Operations) pgToIR(pgDoc structure) (*ir.Definition, error) {
 		grant.SetCanGrant(relationGrant.Grantable)
 	}
 
+	// aggregate default privileges by role and grant option
+	for _, aclRow := range pgDoc.DefaultACLs {
+		objectType, err := ir.NewDefaultPrivilegesObjectType(aclRow.ObjectType)
+		if err != nil {
+			return nil, err
+		}
+		target := &ir.DefaultPrivileges{
+			Role:       roles.get(aclRow.Role),
+			Schema:     aclRow.Schema,
+			ObjectType: objectType,
+		}
+		dp := doc.TryGetDefaultPrivilegesMatching(target)
+		if dp == nil {
+			dp = target
+			doc.AddDefaultPrivileges(dp)
+		}
+		grantee := roles.get(aclRow.Grantee)
+		var grant *ir.Grant
+		for _, g := range dp.Grants {
+			if util.IStrsContains(g.Roles, grantee) && g.CanGrant() == aclRow.Grantable {
+				grant = g
+			}
+		}
+		if grant == nil {
+			grant = &ir.Grant{Roles: []string{grantee}}
+			grant.SetCanGrant(aclRow.Grantable)
+			dp.AddGrant(grant)
+		}
+		grant.AddPermission(aclRow.Type)
+	}
+
+	leaveOutHistory(doc)
+
 	return doc, nil
 }
 
+// partitionFromKeyDef converts the output of pg_get_partkeydef, e.g. "RANGE (created_at)",
+// to a native table partition without segments
+func partitionFromKeyDef(keyDef string) (*ir.TablePartition, error) {
+	typ, key, ok := strings.Cut(keyDef, " ")
+	key = strings.TrimSpace(key)
+	if !ok || !strings.HasPrefix(key, "(") || !strings.HasSuffix(key, ")") {
+		return nil, fmt.Errorf("unexpected partition key definition '%s'", keyDef)
+	}
+	partitionType, err := ir.NewTablePartitionType(typ)
+	if err != nil {
+		return nil, err
+	}
+	return &ir.TablePartition{
+		Type:      partitionType,
+		SqlFormat: ir.SqlFormatPgsql8,
+		Options: []*ir.TablePartitionOption{
+			{Name: "column", Value: key[1 : len(key)-1]},
+		},
+	}, nil
+}
+
 // storeSchema creates a schema record and stores it in the IR
 // Ensures the schema's owner is registered with the roleIndex
 func storeSchema(doc *ir.Definition, roles *roleIndex, schema schemaEntry) {
@@ -804,9 +1035,8 @@ func storeSchema(doc *ir.Definition, roles *roleIndex, schema schemaEntry) {
 	roles.registerRole(roleContextOwner, schema.Owner)
 }
 
-func (ops *Operations) CompareDbData(doc *ir.Definition, host string, port uint, name, user, pass string) (*ir.Definition, error) {
-	ops.logger.Info(fmt.Sprintf("Connecting to pgsql8 host %s:%d database %s as user %s", host, port, name, user))
-	conn, err := newConnection(host, port, name, user, pass)
+func (ops *Operations) CompareDbData(doc *ir.Definition, connString string) (*ir.Definition, error) {
+	conn, err := newConnection(ops.logger, connString)
 	if err != nil {
 		return nil, fmt.Errorf("comparing DB data: %w", err)
 	}
@@ -945,23 +1175,37 @@ func pgdataHomogenize(colType string, value string) string {
 	}
 }
 
-func (ops *Operations) SqlDiff(old, new []string, upgradePrefix string) {
+func (ops *Operations) SqlDiff(old, new []string, upgradePrefix string) error {
 	ops.logger.Info("Calculating sql differences:")
 	ops.logger.Info(fmt.Sprintf("Old set: %v", old))
 	ops.logger.Info(fmt.Sprintf("New set: %v", new))
 	ops.logger.Info(fmt.Sprintf("Upgrade: %s", upgradePrefix))
-	ops.differ.DiffSql(old, new, upgradePrefix)
+	return ops.differ.DiffSql(old, new, upgradePrefix)
 }
 
 func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegmenter, tableDep []*ir.TableRef) error {
 	// TODO(go,3) roll this into diffing nil -> doc
+	// roles, before anything which may be owned by or granted to them
+	for _, role := range doc.Roles {
+		s, err := getCreateRoleSql(ops.config, role)
+		if err != nil {
+			return err
+		}
+		writeChanges(ofs, roleChanged(nil, role).create(s...)...)
+	}
+	for _, role := range doc.Roles {
+		for _, parent := range role.MemberOf {
+			writeChanges(ofs, roleChanged(nil, role).create(&sql.RoleGrantMembership{Role: parent, Member: role.Name})...)
+		}
+	}
+
 	// schema creation
 	for _, schema := range doc.Schemas {
 		s, err := commonSchema.GetCreationSql(ops.config, schema)
 		if err != nil {
 			return err
 		}
-		ofs.WriteSql(s...)
+		writeChanges(ofs, schemaChanged(nil, schema).create(s...)...)
 
 		// schema grants
 		for _, grant := range schema.Grants {
@@ -969,18 +1213,42 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			granted, err := grantChanged(ops.config, "SCHEMA", schema.Name, nil, grant)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, granted.create(s...)...)
 		}
 	}
 
-	// types: enumerated list, etc
+	// default privileges, which may be limited to the schemas above
+	for _, dp := range doc.DefaultPrivileges {
+		for _, grant := range dp.Grants {
+			s, err := getDefaultPrivilegesGrantSql(ops.config, dp, grant)
+			if err != nil {
+				return err
+			}
+			granted, err := defaultPrivilegesChanged(ops.config, nil, dp)
+			if err != nil {
+				return err
+			}
+			writeChanges(ofs, granted.create(s...)...)
+		}
+	}
+
+	// extensions, which may be created in the schemas above and provide types used below
+	for _, ext := range doc.Extensions {
+		writeChanges(ofs, extensionChanged(nil, ext).create(getCreateExtensionSql(ext)...)...)
+	}
+
+	// types: enumerated list, etc, along with the functions they're defined with
 	for _, schema := range doc.Schemas {
-		for _, datatype := range schema.Types {
-			sql, err := getCreateTypeSql(schema, datatype)
+		for _, datatype := range typesInDependencyOrder(schema, schema.Types) {
+			create, err := getCreateTypeWithSupportChanges(ops.config, nil, schema, datatype)
 			if err != nil {
 				return fmt.Errorf("could not get data type creation sql for build: %w", err)
 			}
-			ofs.WriteSql(sql...)
+			writeChanges(ofs, create...)
 		}
 	}
 
@@ -994,10 +1262,10 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 			if err != nil {
 				return err
 			}
-			ofs.WriteSql(s...)
+			writeChanges(ofs, tableChanged(schema.Name, nil, table).create(s...)...)
 
 			// table indexes
-			err = diffIndexesTable(ofs, nil, nil, schema, table)
+			err = diffIndexesTable(ops.config, ofs, nil, nil, schema, table)
 			if err != nil {
 				return err
 			}
@@ -1008,23 +1276,28 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				granted, err := grantChanged(ops.config, "TABLE", schema.Name+"."+table.Name, nil, grant)
+				if err != nil {
+					return err
+				}
+				writeChanges(ofs, granted.create(s...)...)
 			}
 		}
 		includeColumnDefaultNextvalInCreateSql = true
 
 		// sequences contained in the schema
 		for _, sequence := range schema.Sequences {
+			created := sequenceChanged(schema.Name, nil, sequence)
 			if sequence.OwnedByColumn == "" {
 				sql, err := getCreateSequenceSql(ops.config, schema, sequence)
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(sql...)
+				writeChanges(ofs, created.create(sql...)...)
 			} else {
 				// If sequence already created as part of a serial, generate
 				// an ALTER against a default sequence
-				ofs.WriteSql(getAlterSequenceSql(schema.Name, &ir.Sequence{}, sequence))
+				writeChanges(ofs, created.create(getAlterSequenceSql(schema.Name, &ir.Sequence{}, sequence))...)
 			}
 
 			// sequence permission grants
@@ -1033,46 +1306,63 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				granted, err := grantChanged(ops.config, "SEQUENCE", schema.Name+"."+sequence.Name, nil, grant)
+				if err != nil {
+					return err
+				}
+				writeChanges(ofs, granted.create(s...)...)
 			}
 		}
 
 		// add table nextvals that were omitted
 		for _, table := range schema.Tables {
 			if table.HasDefaultNextVal() {
-				ofs.WriteSql(getDefaultNextvalSql(ops.logger, schema, table)...)
+				writeChanges(ofs, tableChanged(schema.Name, nil, table).create(getDefaultNextvalSql(ops.logger, schema, table)...)...)
 			}
 		}
 	}
 
 	// function definitions
 	for _, schema := range doc.Schemas {
+		createdWithTypes := typeSupportFunctionsCreated(nil, schema)
 		for _, function := range schema.Functions {
 			if function.HasDefinition(ir.SqlFormatPgsql8) {
-				s, err := getFunctionCreationSql(ops.config, schema, function)
-				if err != nil {
-					return err
+				// functions a type is defined with were already created along with it
+				if !slices.Contains(createdWithTypes, function) {
+					s, err := getFunctionCreationSql(ops.config, schema, function)
+					if err != nil {
+						return err
+					}
+					writeChanges(ofs, functionChanged(schema.Name, nil, function).create(s...)...)
 				}
-				ofs.WriteSql(s...)
 				// when pg:build_schema() is doing its thing for straight builds, include function permissions
 				// they are not included in pg_function::get_creation_sql()
 
 				for _, grant := range function.Grants {
-					grant, err := getFunctionGrantSql(ops.config, schema, function, grant)
+					s, err := getFunctionGrantSql(ops.config, schema, function, grant)
+					if err != nil {
+						return err
+					}
+					granted, err := grantChanged(ops.config, "FUNCTION", schema.Name+"."+function.ShortSig(), nil, grant)
 					if err != nil {
 						return err
 					}
-					ofs.WriteSql(grant...)
+					writeChanges(ofs, granted.create(s...)...)
 				}
 			}
 		}
 	}
 
+	// casts, once the types and functions they use exist
+	for _, cast := range doc.Casts {
+		writeChanges(ofs, castChanged(nil, cast).create(getCreateCastSql(cast)...)...)
+	}
+
 	// maybe move this but here we're defining column defaults fo realz
 	for _, schema := range doc.Schemas {
 		for _, table := range schema.Tables {
 			// TODO(go,nth) method name consistency - should be GetColumnDefaultsSql?
-			ofs.WriteSql(defineTableColumnDefaults(ops.logger, schema, table)...)
+			writeChanges(ofs, tableChanged(schema.Name, nil, table).create(defineTableColumnDefaults(ops.logger, schema, table)...)...)
 		}
 	}
 
@@ -1104,7 +1394,17 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				writeChanges(ofs, triggerChanged(schema.Name, nil, trigger).create(s...)...)
+			}
+		}
+	}
+
+	// row level security policies, which may refer to any of the above
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			err := createPoliciesTable(ops.config, ofs, nil, schema, table)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -1122,7 +1422,12 @@ func (ops *Operations) buildSchema(doc *ir.Definition, ofs output.OutputFileSegm
 				if err != nil {
 					return err
 				}
-				ofs.WriteSql(s...)
+				// views are granted on as tables are
+				granted, err := grantChanged(ops.config, "TABLE", schema.Name+"."+view.Name, nil, grant)
+				if err != nil {
+					return err
+				}
+				writeChanges(ofs, granted.create(s...)...)
 			}
 		}
 	}
@@ -1150,13 +1455,13 @@ func (ops *Operations) buildData(_ *slog.Logger, doc *ir.Definition, ofs output.
 				continue
 			}
 		}
-		s, err := getCreateDataSql(ops, nil, nil, schema, table)
+		s, err := getCreateDataChanges(ops, nil, nil, schema, table)
 		if err != nil {
 			return err
 		}
-		ofs.WriteSql(s...)
+		writeChanges(ofs, s...)
 
-		// set serial primary keys to the max value after inserts have been performed
+		// set serial and identity primary keys to the max value after inserts have been performed
 		// only if the PRIMARY KEY is not a multi column
 		if table.Rows != nil && len(table.PrimaryKey) == 1 {
 			dataCols := table.Rows.Columns
@@ -1173,7 +1478,7 @@ func (ops *Operations) buildData(_ *slog.Logger, doc *ir.Definition, ofs output.
 						pkCol, schema.Name, table.Name)
 				}
 				// TODO(go,nth) unify DataType.IsLinkedType and Column.IsSerialType
-				if isColumnSerialType(pk) && pk.SerialStart == nil {
+				if (isColumnSerialType(pk) && pk.SerialStart == nil) || pk.Identity != nil {
 					ofs.WriteSql(&sql.SequenceSerialSetValMax{
 						Column: sql.ColumnRef{
 							Schema: schema.Name,
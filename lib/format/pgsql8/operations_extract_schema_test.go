@@ -527,3 +527,148 @@ func TestOperations_ExtractSchema_Sequences(t *testing.T) {
 		},
 	}, actual.Schemas[0].Sequences)
 }
+
+func TestOperations_ExtractSchema_NativePartitions(t *testing.T) {
+	pgDoc := structure{
+		Version: NewVersionNum(13, 0),
+		Schemas: []schemaEntry{{
+			Name: "public",
+		}},
+		Tables: []tableEntry{
+			{
+				Schema:       "public",
+				Table:        "events",
+				PartitionKey: "RANGE (created_at)",
+				Columns: []columnEntry{
+					{Name: "id", AttrType: "integer"},
+					{Name: "created_at", AttrType: "date"},
+				},
+			},
+			{
+				Schema:         "public",
+				Table:          "events_2020",
+				ParentTables:   []string{"public.events"},
+				PartitionBound: "FOR VALUES FROM ('2020-01-01') TO ('2021-01-01')",
+				Columns: []columnEntry{
+					{Name: "id", AttrType: "integer"},
+					{Name: "created_at", AttrType: "date"},
+				},
+			},
+			{
+				Schema:         "public",
+				Table:          "events_default",
+				ParentTables:   []string{"public.events"},
+				PartitionBound: "DEFAULT",
+				Columns: []columnEntry{
+					{Name: "id", AttrType: "integer"},
+					{Name: "created_at", AttrType: "date"},
+				},
+			},
+		},
+		Constraints: []constraintEntry{
+			{Schema: "public", Table: "events", Name: "events_pkey", Type: "p", Columns: []string{"id", "created_at"}},
+			{Schema: "public", Table: "events_2020", Name: "events_2020_pkey", Type: "p", Columns: []string{"id", "created_at"}},
+			{Schema: "public", Table: "events_default", Name: "events_default_pkey", Type: "p", Columns: []string{"id", "created_at"}},
+		},
+	}
+	pgDoc, err := foldPartitions(pgDoc)
+	if err != nil {
+		t.Fatalf("Folding partitions failed: %+v", err)
+	}
+	ops := NewOperations(DefaultConfig).(*Operations)
+	actual, err := ops.pgToIR(pgDoc)
+	if err != nil {
+		t.Fatalf("Conversion failed: %+v", err)
+	}
+	if assert.Len(t, actual.Schemas[0].Tables, 1) {
+		assert.Equal(t, &ir.TablePartition{
+			Type:      ir.TablePartitionTypeRange,
+			SqlFormat: ir.SqlFormatPgsql8,
+			Options: []*ir.TablePartitionOption{
+				{Name: "column", Value: "created_at"},
+			},
+			Segments: []*ir.TablePartitionSegment{
+				{Name: "events_2020", Value: "FROM ('2020-01-01') TO ('2021-01-01')"},
+				{Name: "events_default", Value: "DEFAULT"},
+			},
+		}, actual.Schemas[0].Tables[0].Partitioning)
+	}
+}
+
+func TestOperations_ExtractSchema_UpgradeFromExtracted(t *testing.T) {
+	pgDoc := structure{
+		Version: PG_8_0,
+		Schemas: []schemaEntry{{
+			Name:  "public",
+			Owner: "app",
+		}},
+		Tables: []tableEntry{{
+			Schema: "public",
+			Table:  "test",
+			Owner:  "app",
+			Columns: []columnEntry{
+				{
+					Name:     "id",
+					AttrType: "integer",
+					Position: 1,
+				},
+				{
+					Name:     "col1",
+					AttrType: "text",
+					Nullable: true,
+					Position: 2,
+				},
+			},
+		}},
+		Constraints: []constraintEntry{
+			{
+				Schema:  "public",
+				Table:   "test",
+				Name:    "test_pkey",
+				Type:    "p",
+				Columns: []string{"id"},
+			},
+		},
+	}
+	ops := NewOperations(DefaultConfig).(*Operations)
+	oldDoc, err := ops.pgToIR(pgDoc)
+	if err != nil {
+		t.Fatalf("Conversion failed: %+v", err)
+	}
+	newDoc := &ir.Definition{
+		Database: &ir.Database{
+			SqlFormat: ir.SqlFormatPgsql8,
+			Roles:     oldDoc.Database.Roles,
+		},
+		Schemas: []*ir.Schema{{
+			Name:  "public",
+			Owner: "app",
+			Tables: []*ir.Table{{
+				Name:           "test",
+				Owner:          "app",
+				PrimaryKey:     []string{"id"},
+				PrimaryKeyName: "test_pkey",
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer", Nullable: false},
+					{Name: "col1", Type: "text", Nullable: true},
+					{Name: "col2", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	stmts, err := ops.Upgrade(ops.logger, oldDoc, newDoc)
+	if err != nil {
+		t.Fatalf("Upgrade failed: %+v", err)
+	}
+	ddl := []string{}
+	for _, stmt := range stmts {
+		ddl = append(ddl, stmt.Statement)
+	}
+	all := strings.Join(ddl, "\n")
+
+	// only the new column should be added, the extracted table should be recognized as-is
+	assert.Contains(t, all, "ADD COLUMN col2 text;")
+	assert.NotContains(t, all, "CREATE TABLE")
+	assert.NotContains(t, all, "DROP")
+	assert.NotContains(t, all, "col1")
+}
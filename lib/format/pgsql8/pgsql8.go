@@ -2,6 +2,7 @@ package pgsql8
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/dbsteward/dbsteward/lib"
 	"github.com/dbsteward/dbsteward/lib/ir"
@@ -42,6 +43,21 @@ var DefaultConfig = lib.Config{
 	FileOutputPrefix:               "",
 	IgnoreOldNames:                 false,
 	AlwaysRecreateViews:            true,
+	RefreshMaterializedViews:       false,
+	OutputFormat:                   "text",
+	GenerateDowngrade:              false,
+	SafeMode:                       false,
+	AllowDrop:                      nil,
+	Lint:                           false,
+	LintFailOn:                     lib.LintError,
+	OnlineSafe:                     false,
+	LockTimeout:                    "",
+	StatementTimeout:               "",
+	IdleInTransactionTimeout:       "",
+	LockRetries:                    0,
+	LockRetryDelay:                 5 * time.Second,
+	Idempotent:                     false,
+	TargetVersion:                  "",
 	OldDatabase:                    nil,
 	NewDatabase:                    nil,
 }
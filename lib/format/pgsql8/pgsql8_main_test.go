@@ -1,8 +1,11 @@
 package pgsql8
 
 import (
+	"testing"
+
 	"github.com/dbsteward/dbsteward/lib"
 	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
 )
 
 func setOldNewDocs(conf lib.Config, differ *diff, old, new *ir.Definition) lib.Config {
@@ -23,3 +26,21 @@ func setOldNewDocs(conf lib.Config, differ *diff, old, new *ir.Definition) lib.C
 	}
 	return conf
 }
+
+// diffChangesCommon records the changes upgrading oldDoc to newDoc makes in each stage
+func diffChangesCommon(t *testing.T, conf lib.Config, oldDoc, newDoc *ir.Definition) []*changeRecorder {
+	recorders, err := NewOperations(conf).(*Operations).diffChanges(oldDoc, newDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorders
+}
+
+// changeStatements returns the statements changing objects, without annotations, comments or literal sql
+func changeStatements(changes []*output.Change) []output.ToSql {
+	ofs := output.NewAnnotationStrippingSegmenter(defaultQuoter(DefaultConfig))
+	for _, change := range output.FilterChanges(changes, (*output.Change).IsObject) {
+		ofs.WriteSql(change.Statement)
+	}
+	return ofs.Body
+}
@@ -0,0 +1,136 @@
+package pgsql8
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+var upgradeStageDescriptions = []string{
+	"structure additions and modifications",
+	"data definitions removed",
+	"structure changes, constraints and removals",
+	"data definition changes and additions",
+}
+
+// writePlan writes the json migration plan for the recorded changes, if json output was requested
+func (ops *Operations) writePlan(fileName string, recorders []*changeRecorder, descriptions []string) error {
+	if ops.config.OutputFormat != "json" {
+		return nil
+	}
+	plan := migrationPlan(ops.GetQuoter(), recorders, descriptions, ops.config.SingleStageUpgrade)
+	content, err := plan.JSON()
+	if err != nil {
+		return fmt.Errorf("rendering migration plan: %w", err)
+	}
+	ops.logger.Info(fmt.Sprintf("Saving migration plan as %s", fileName))
+	err = util.WriteFile(string(content)+"\n", fileName)
+	if err != nil {
+		return fmt.Errorf("failed to save migration plan %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// migrationPlan lists the recorded statements stage by stage, or for a single stage upgrade, in the order they are written
+func migrationPlan(q output.Quoter, recorders []*changeRecorder, descriptions []string, singleStage bool) *lib.MigrationPlan {
+	plan := &lib.MigrationPlan{}
+	stages := map[int]*lib.PlanStage{}
+	changes := []*output.Change{}
+	concurrent := map[*output.Change]bool{}
+	for i, recorder := range recorders {
+		plan.Stages = append(plan.Stages, lib.PlanStage{
+			Number:      recorder.stage,
+			Description: descriptions[i],
+		})
+		for _, stmt := range recorder.header {
+			if isTransactionControl(q, stmt, "BEGIN") {
+				plan.Stages[i].Transactional = true
+			}
+		}
+		changes = append(changes, recorder.changes...)
+		changes = append(changes, recorder.concurrent...)
+		for _, change := range recorder.concurrent {
+			concurrent[change] = true
+		}
+	}
+	for i := range plan.Stages {
+		stages[plan.Stages[i].Number] = &plan.Stages[i]
+	}
+	if singleStage {
+		changes = writtenChanges(recorders)
+		for _, recorder := range recorders {
+			changes = append(changes, recorder.concurrent...)
+		}
+	}
+
+	// the differ writes some explanations as comments ahead of the statement they explain
+	comments := []string{}
+	for _, change := range changes {
+		if comment, ok := change.Statement.(output.SQLComment); ok {
+			comments = append(comments, uncomment(comment.Comment()))
+			continue
+		}
+		if isTransactionControl(q, change.Statement, "BEGIN") {
+			stages[change.Stage].Transactional = true
+			continue
+		}
+		if isTransactionControl(q, change.Statement, "COMMIT") {
+			continue
+		}
+		stmt := change.Statement
+		if an, ok := stmt.(*sql.Annotated); ok {
+			comments = append(comments, strings.TrimSpace(an.Annotation))
+			stmt = an.Wrapped
+		}
+		if alter, ok := stmt.(*sql.TableAlterParts); ok {
+			for _, part := range alter.Parts {
+				if an, ok := part.(*sql.TableAlterPartAnnotation); ok {
+					comments = append(comments, strings.TrimSpace(an.Annotation))
+				}
+			}
+		}
+		if alter, ok := stmt.(*sql.RoleAlter); ok && alter.Password != nil {
+			// the hashed password can still be brute forced, so it's only written to the upgrade files
+			redacted := *alter
+			redacted.Password = nil
+			stmt = &redacted
+			comments = append(comments, "sets the password, which is left out of the plan")
+		}
+		rendered := strings.TrimSpace(stmt.ToSql(q))
+		if rendered == "" {
+			// blank lines end a section, so any comments before them don't explain what follows
+			comments = []string{}
+			continue
+		}
+		plan.Statements = append(plan.Statements, lib.PlanStatement{
+			Stage:       change.Stage,
+			Kind:        change.Kind,
+			Object:      change.Identity.String(),
+			Action:      string(change.Action),
+			Annotation:  strings.Join(comments, "\n"),
+			Destructive: change.Destructive,
+			Concurrent:  concurrent[change],
+			SQL:         rendered,
+		})
+		comments = []string{}
+	}
+	return plan
+}
+
+// isTransactionControl reports whether stmt is a bare BEGIN or COMMIT, as written around stages
+func isTransactionControl(q output.Quoter, stmt output.ToSql, keyword string) bool {
+	return strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(stmt.ToSql(q)), ";"), keyword)
+}
+
+// uncomment strips the -- prefix from each line of a sql comment
+func uncomment(comment string) string {
+	lines := strings.Split(strings.TrimSpace(comment), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), output.CommentLinePrefix))
+	}
+	return strings.Join(lines, "\n")
+}
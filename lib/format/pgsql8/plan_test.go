@@ -0,0 +1,116 @@
+package pgsql8
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationPlan_Upgrade(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "contacts",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "legacy", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "contacts",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	plan := migrationPlan(defaultQuoter(DefaultConfig), recorders, upgradeStageDescriptions, false)
+	assert.Equal(t, []lib.PlanStage{
+		{Number: 1, Description: upgradeStageDescriptions[0], Transactional: true},
+		{Number: 2, Description: upgradeStageDescriptions[1], Transactional: true},
+		{Number: 3, Description: upgradeStageDescriptions[2], Transactional: true},
+		{Number: 4, Description: upgradeStageDescriptions[3], Transactional: true},
+	}, plan.Stages)
+	// BEGIN and COMMIT are implied by the stages being transactional
+	assert.Equal(t, []lib.PlanStatement{
+		{
+			Stage:  1,
+			Kind:   "column",
+			Object: "public.contacts.email",
+			Action: "create",
+			SQL:    "ALTER TABLE public.contacts\n  ADD COLUMN email text;",
+		},
+		{
+			Stage:       3,
+			Kind:        "column",
+			Object:      "public.contacts.legacy",
+			Action:      "drop",
+			Destructive: true,
+			SQL:         "ALTER TABLE public.contacts\n  DROP COLUMN legacy;",
+		},
+	}, plan.Statements)
+}
+
+func TestMigrationPlan_JSON(t *testing.T) {
+	ops := NewOperations(DefaultConfig).(*Operations)
+	q := ops.GetQuoter()
+	recorder := newBuildChangeRecorder()
+	assert.NoError(t, recorder.WriteSql(sql.NewComment("explains the next statement")))
+	assert.NoError(t, recorder.WriteChanges(schemaChanged(&ir.Schema{Name: "old"}, nil).drop(&sql.Annotated{
+		Wrapped:    &sql.SchemaDrop{Schema: "old"},
+		Annotation: "schema removed",
+	})...))
+	plan := migrationPlan(q, []*changeRecorder{recorder}, []string{"full database definition"}, false)
+
+	content, err := plan.JSON()
+	assert.NoError(t, err)
+	parsed := lib.MigrationPlan{}
+	assert.NoError(t, json.Unmarshal(content, &parsed))
+	assert.Equal(t, []lib.PlanStatement{{
+		Stage:       1,
+		Kind:        "schema",
+		Object:      "old",
+		Action:      "drop",
+		Annotation:  "explains the next statement\nschema removed",
+		Destructive: true,
+		SQL:         "DROP SCHEMA old;",
+	}}, parsed.Statements)
+}
+
+func TestMigrationPlan_RolePassword(t *testing.T) {
+	t.Setenv("ANALYST_PASSWORD", "it's secret")
+	newDoc := &ir.Definition{Roles: []*ir.Role{{Name: "analyst", Login: true, Inherit: true, PasswordEnv: "ANALYST_PASSWORD"}}}
+
+	recorders := diffChangesCommon(t, DefaultConfig, &ir.Definition{}, newDoc)
+	plan := migrationPlan(defaultQuoter(DefaultConfig), recorders, upgradeStageDescriptions, false)
+	if assert.Len(t, plan.Statements, 2) {
+		assert.Equal(t, lib.PlanStatement{
+			Stage:      1,
+			Kind:       "role",
+			Object:     "analyst",
+			Action:     "create",
+			Annotation: "sets the password, which is left out of the plan",
+			SQL:        "ALTER ROLE analyst WITH LOGIN INHERIT NOCREATEDB CONNECTION LIMIT -1;",
+		}, plan.Statements[1])
+	}
+	// the upgrade itself still sets it
+	stmts := changeStatements(recorders[0].changes)
+	if assert.Len(t, stmts, 2) {
+		assert.NotNil(t, stmts[1].(*sql.RoleAlter).Password)
+	}
+}
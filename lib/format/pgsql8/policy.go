@@ -0,0 +1,122 @@
+package pgsql8
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// checkPolicies makes sure row level security can be used in the target version
+func checkPolicies(doc *ir.Definition, target VersionNum) error {
+	if doc == nil || target == 0 {
+		return nil
+	}
+	for _, schema := range doc.Schemas {
+		for _, table := range schema.Tables {
+			if (table.RowLevelSecurity || table.ForceRowLevelSecurity || len(table.Policies) > 0) && !FEAT_ROW_LEVEL_SECURITY(target) {
+				return fmt.Errorf("table %s.%s uses row level security, which needs a target version of at least 9.5", schema.Name, table.Name)
+			}
+			for _, policy := range table.Policies {
+				if policy.Restrictive && !FEAT_RESTRICTIVE_POLICIES(target) {
+					return fmt.Errorf("policy %s on table %s.%s is restrictive, which needs a target version of at least 10", policy.Name, schema.Name, table.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func getCreatePolicySql(conf lib.Config, schema *ir.Schema, table *ir.Table, policy *ir.Policy) ([]output.ToSql, error) {
+	roles, err := policyRoles(conf, policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s on table %s.%s: %w", policy.Name, schema.Name, table.Name, err)
+	}
+	create := &sql.PolicyCreate{
+		Policy:      policy.Name,
+		Table:       sql.TableRef{Schema: schema.Name, Table: table.Name},
+		Restrictive: policy.Restrictive,
+		Roles:       roles,
+		Using:       policy.Using,
+		WithCheck:   policy.WithCheck,
+	}
+	if policy.Command != "" && !policy.Command.Equals(ir.PolicyCommandAll) {
+		create.Command = string(policy.Command)
+	}
+	return []output.ToSql{create}, nil
+}
+
+// getAlterPolicySql changes the roles and expressions of a policy which differ from the old one.
+// The command and whether it's restrictive can't be altered, nor can an expression be removed,
+// those need the policy to be created again, see policyNeedsRecreate
+func getAlterPolicySql(conf lib.Config, schema *ir.Schema, table *ir.Table, oldPolicy, newPolicy *ir.Policy) ([]output.ToSql, error) {
+	alter := &sql.PolicyAlter{
+		Policy: newPolicy.Name,
+		Table:  sql.TableRef{Schema: schema.Name, Table: table.Name},
+	}
+	if !util.IStrsEq(oldPolicy.Roles, newPolicy.Roles) {
+		roles, err := policyRoles(conf, newPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s on table %s.%s: %w", newPolicy.Name, schema.Name, table.Name, err)
+		}
+		if len(roles) == 0 {
+			roles = []string{ir.RolePublic}
+		}
+		alter.Roles = roles
+	}
+	if oldPolicy.Using != newPolicy.Using {
+		alter.Using = newPolicy.Using
+	}
+	if oldPolicy.WithCheck != newPolicy.WithCheck {
+		alter.WithCheck = newPolicy.WithCheck
+	}
+	return []output.ToSql{alter}, nil
+}
+
+func getDropPolicySql(schema *ir.Schema, table *ir.Table, policy *ir.Policy) []output.ToSql {
+	return []output.ToSql{
+		&sql.PolicyDrop{
+			Policy: policy.Name,
+			Table:  sql.TableRef{Schema: schema.Name, Table: table.Name},
+		},
+	}
+}
+
+// policyNeedsRecreate is true when a changed policy can't be altered in place
+func policyNeedsRecreate(oldPolicy, newPolicy *ir.Policy) bool {
+	return !oldPolicy.Command.Equals(newPolicy.Command) ||
+		oldPolicy.Restrictive != newPolicy.Restrictive ||
+		(oldPolicy.Using != "" && newPolicy.Using == "") ||
+		(oldPolicy.WithCheck != "" && newPolicy.WithCheck == "")
+}
+
+func policyRoles(conf lib.Config, policy *ir.Policy) ([]string, error) {
+	roles := make([]string, len(policy.Roles))
+	for i, role := range policy.Roles {
+		var err error
+		roles[i], err = roleEnum(conf.Logger, conf.NewDatabase, role, conf.IgnoreCustomRoles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// getRowLevelSecuritySql switches row level security on or off to match the new table,
+// oldTable being nil for a table which is being created
+func getRowLevelSecuritySql(schema *ir.Schema, oldTable, newTable *ir.Table) []output.ToSql {
+	parts := []sql.TableAlterPart{}
+	if newTable.RowLevelSecurity != (oldTable != nil && oldTable.RowLevelSecurity) {
+		parts = append(parts, &sql.TableAlterPartRowLevelSecurity{Enabled: newTable.RowLevelSecurity})
+	}
+	if newTable.ForceRowLevelSecurity != (oldTable != nil && oldTable.ForceRowLevelSecurity) {
+		parts = append(parts, &sql.TableAlterPartForceRowLevelSecurity{Forced: newTable.ForceRowLevelSecurity})
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return []output.ToSql{sql.NewTableAlter(sql.TableRef{Schema: schema.Name, Table: newTable.Name}, parts...)}
+}
@@ -135,6 +135,11 @@ func roleEnum(l *slog.Logger, doc *ir.Definition, role string, ignoreCustomRoles
 		return roles.Replication, nil
 	}
 
+	// roles the definition creates are known to exist
+	if doc.TryGetRoleNamed(role) != nil {
+		return role, nil
+	}
+
 	// NEW: if role matches any of the specific role assignments, don't consider it to be an error
 	// this is basically the case where the user has manually resolved the role
 	if strings.EqualFold(roles.Application, role) ||
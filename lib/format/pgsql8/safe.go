@@ -0,0 +1,91 @@
+package pgsql8
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// BlockedChange is a destructive change refused in safe mode
+type BlockedChange struct {
+	Kind   string
+	Object string
+	Action string
+	// OldDefinition names the definition being upgraded from as a whole, not where the object was defined
+	OldDefinition string
+	Statement     string
+}
+
+// DestructiveChangeError lists every destructive change which safe mode refused to generate
+type DestructiveChangeError struct {
+	Blocked []BlockedChange
+}
+
+func (e *DestructiveChangeError) Error() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf(
+		"safe mode blocked %d destructive changes, allow them with --allowdrop or <allowDrop object=\"...\"/> in the new definition:",
+		len(e.Blocked),
+	))
+	for _, blocked := range e.Blocked {
+		b.WriteString(fmt.Sprintf("\n  %s %s %s, upgrading from %s", blocked.Action, blocked.Kind, blocked.Object, blocked.OldDefinition))
+		b.WriteString("\n    " + strings.ReplaceAll(blocked.Statement, "\n", "\n    "))
+	}
+	return b.String()
+}
+
+// guardDestructive fails with a *DestructiveChangeError if safe mode is on and any recorded change
+// is destructive, unless the object it changes is allowed by config or by an <allowDrop> in newDoc.
+// oldDefinition names the old definition being upgraded from, for the error.
+func (ops *Operations) guardDestructive(recorders []*changeRecorder, newDoc *ir.Definition, oldDefinition string) error {
+	if !ops.config.SafeMode {
+		return nil
+	}
+	allowed := append([]string{}, ops.config.AllowDrop...)
+	for _, allow := range newDoc.AllowDrops {
+		allowed = append(allowed, allow.Object)
+	}
+
+	q := ops.GetQuoter()
+	blocked := []BlockedChange{}
+	for _, change := range writtenChanges(recorders) {
+		changes := change.Parts
+		if len(changes) == 0 {
+			changes = []*output.Change{change}
+		}
+		for _, part := range changes {
+			if !part.Destructive || isDropAllowed(allowed, part.Identity.String()) {
+				continue
+			}
+			stmt := part.Statement
+			if an, ok := stmt.(*sql.Annotated); ok {
+				stmt = an.Wrapped
+			}
+			blocked = append(blocked, BlockedChange{
+				Kind:          part.Kind,
+				Object:        part.Identity.String(),
+				Action:        string(part.Action),
+				OldDefinition: oldDefinition,
+				Statement:     strings.TrimSpace(stmt.ToSql(q)),
+			})
+		}
+	}
+	if len(blocked) > 0 {
+		return &DestructiveChangeError{Blocked: blocked}
+	}
+	return nil
+}
+
+func isDropAllowed(allowed []string, object string) bool {
+	object = strings.ToLower(object)
+	for _, pattern := range allowed {
+		if match, _ := path.Match(strings.ToLower(pattern), object); match {
+			return true
+		}
+	}
+	return false
+}
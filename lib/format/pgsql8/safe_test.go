@@ -0,0 +1,91 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperations_GuardDestructive(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "subscribers",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "legacy", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "subscribers",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+
+	conf := DefaultConfig
+	conf.SafeMode = true
+	err := NewOperations(conf).(*Operations).guardDestructive(recorders, newDoc, "old.xml")
+	if assert.IsType(t, &DestructiveChangeError{}, err) {
+		assert.Equal(t, []BlockedChange{{
+			Kind:          "column",
+			Object:        "public.subscribers.legacy",
+			Action:        "drop",
+			OldDefinition: "old.xml",
+			Statement:     "ALTER TABLE public.subscribers\n  DROP COLUMN legacy;",
+		}}, err.(*DestructiveChangeError).Blocked)
+		assert.Contains(t, err.Error(), "drop column public.subscribers.legacy, upgrading from old.xml")
+	}
+
+	conf.AllowDrop = []string{"public.subscribers.legacy"}
+	assert.NoError(t, NewOperations(conf).(*Operations).guardDestructive(recorders, newDoc, "old.xml"))
+	conf.AllowDrop = []string{"PUBLIC.subscribers.*"}
+	assert.NoError(t, NewOperations(conf).(*Operations).guardDestructive(recorders, newDoc, "old.xml"))
+	conf.AllowDrop = []string{"public.other.*"}
+	assert.Error(t, NewOperations(conf).(*Operations).guardDestructive(recorders, newDoc, "old.xml"))
+
+	conf.AllowDrop = nil
+	newDoc.AllowDrops = []*ir.AllowDrop{{Object: "public.subscribers.legacy"}}
+	assert.NoError(t, NewOperations(conf).(*Operations).guardDestructive(recorders, newDoc, "old.xml"))
+}
+
+func TestOperations_GuardDestructive_Off(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "subscribers",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "legacy", Type: "text", Nullable: true},
+				},
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "subscribers",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+			}},
+		}},
+	}
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	assert.NoError(t, NewOperations(DefaultConfig).(*Operations).guardDestructive(recorders, newDoc, "old.xml"))
+}
@@ -0,0 +1,45 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// CastCreate converts with Function when it's given, through text when Inout is set, or otherwise without a function.
+// Context is ASSIGNMENT or IMPLICIT, or empty for a cast which has to be asked for.
+type CastCreate struct {
+	Source      TypeRef
+	Target      TypeRef
+	Function    string
+	Inout       bool
+	Context     string
+	IfNotExists bool
+}
+
+func (self *CastCreate) ToSql(q output.Quoter) string {
+	method := "WITHOUT FUNCTION"
+	if self.Function != "" {
+		method = "WITH FUNCTION " + self.Function
+	} else if self.Inout {
+		method = "WITH INOUT"
+	}
+	ddl := fmt.Sprintf("CREATE CAST (%s AS %s) %s", self.Source.Qualified(q), self.Target.Qualified(q), method)
+	if self.Context != "" {
+		ddl += " AS " + self.Context
+	}
+	ddl += ";"
+	if self.IfNotExists {
+		return unlessExists(castExistsQuery(q, self.Source, self.Target), ddl)
+	}
+	return ddl
+}
+
+type CastDrop struct {
+	Source TypeRef
+	Target TypeRef
+}
+
+func (self *CastDrop) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("DROP CAST IF EXISTS (%s AS %s);", self.Source.Qualified(q), self.Target.Qualified(q))
+}
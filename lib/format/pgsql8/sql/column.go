@@ -2,6 +2,7 @@ package sql
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/dbsteward/dbsteward/lib/output"
 )
@@ -67,16 +68,64 @@ func (self *ColumnRename) ToSql(q output.Quoter) string {
 	}).ToSql(q)
 }
 
+// ColumnIdentity is GENERATED {ALWAYS|BY DEFAULT} AS IDENTITY, with the options of the sequence behind it
+type ColumnIdentity struct {
+	Generation string
+	Start      *int
+	Increment  *int
+	Min        *int
+	Max        *int
+	Cache      *int
+	Cycle      bool
+}
+
+func (self *ColumnIdentity) GetSql() string {
+	opts := []string{}
+	if self.Start != nil {
+		opts = append(opts, fmt.Sprintf("START WITH %d", *self.Start))
+	}
+	if self.Increment != nil {
+		opts = append(opts, fmt.Sprintf("INCREMENT BY %d", *self.Increment))
+	}
+	if self.Min != nil {
+		opts = append(opts, fmt.Sprintf("MINVALUE %d", *self.Min))
+	}
+	if self.Max != nil {
+		opts = append(opts, fmt.Sprintf("MAXVALUE %d", *self.Max))
+	}
+	if self.Cache != nil {
+		opts = append(opts, fmt.Sprintf("CACHE %d", *self.Cache))
+	}
+	if self.Cycle {
+		opts = append(opts, "CYCLE")
+	}
+	sql := fmt.Sprintf("GENERATED %s AS IDENTITY", self.Generation)
+	if len(opts) > 0 {
+		sql += " (" + strings.Join(opts, " ") + ")"
+	}
+	return sql
+}
+
 type ColumnDefinition struct {
 	Name     string
 	Type     TypeRef
 	Default  ToSqlValue
 	Nullable *bool
+	Identity *ColumnIdentity
+	// GeneratedAs is the expression of a GENERATED ALWAYS AS (...) STORED column
+	GeneratedAs string
 }
 
 func (self *ColumnDefinition) GetSql(q output.Quoter) string {
 	sql := q.QuoteColumn(self.Name) + " " + self.Type.Qualified(q)
 
+	if self.GeneratedAs != "" {
+		sql += " GENERATED ALWAYS AS (" + self.GeneratedAs + ") STORED"
+	}
+	if self.Identity != nil {
+		sql += " " + self.Identity.GetSql()
+	}
+
 	if self.Default != nil {
 		sql += " DEFAULT " + self.Default.GetValueSql(q)
 	}
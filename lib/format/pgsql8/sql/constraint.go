@@ -25,6 +25,10 @@ type ConstraintCreateRaw struct {
 	Constraint     string
 	ConstraintType ir.ConstraintType
 	Definition     string
+	// NotValid skips checking existing rows, which is left to a later ConstraintValidate
+	NotValid bool
+	// IfNotExists skips adding the constraint if the table already has one of the same name
+	IfNotExists bool
 }
 
 func (self *ConstraintCreateRaw) ToSql(q output.Quoter) string {
@@ -34,19 +38,25 @@ func (self *ConstraintCreateRaw) ToSql(q output.Quoter) string {
 	util.Assert(string(self.ConstraintType) != "", "Empty constraint type")
 	util.Assert(self.Definition != "", "Empty constraint defintion")
 
-	return fmt.Sprintf(
-		"ALTER TABLE %s\n  ADD CONSTRAINT %s %s %s;",
+	ddl := fmt.Sprintf(
+		"ALTER TABLE %s\n  ADD CONSTRAINT %s %s %s%s;",
 		self.Table.Qualified(q),
 		q.QuoteObject(self.Constraint),
 		string(self.ConstraintType),
 		self.Definition,
+		util.MaybeStr(self.NotValid, " NOT VALID"),
 	)
+	if self.IfNotExists {
+		return unlessExists(constraintExistsQuery(q, self.Table, self.Constraint), ddl)
+	}
+	return ddl
 }
 
 type ConstraintCreatePrimaryKey struct {
-	Table      TableRef
-	Constraint string
-	Columns    []string
+	Table       TableRef
+	Constraint  string
+	Columns     []string
+	IfNotExists bool
 }
 
 func (self *ConstraintCreatePrimaryKey) ToSql(q output.Quoter) string {
@@ -55,10 +65,11 @@ func (self *ConstraintCreatePrimaryKey) ToSql(q output.Quoter) string {
 		cols[i] = q.QuoteColumn(col)
 	}
 	return (&ConstraintCreateRaw{
-		self.Table,
-		self.Constraint,
-		ir.ConstraintType("PRIMARY KEY"), // note that it's invalid for this to exist in the xml so we have to make our own constant
-		fmt.Sprintf("(%s)", strings.Join(cols, ", ")),
+		Table:          self.Table,
+		Constraint:     self.Constraint,
+		ConstraintType: ir.ConstraintType("PRIMARY KEY"), // note that it's invalid for this to exist in the xml so we have to make our own constant
+		Definition:     fmt.Sprintf("(%s)", strings.Join(cols, ", ")),
+		IfNotExists:    self.IfNotExists,
 	}).ToSql(q)
 }
 
@@ -70,6 +81,8 @@ type ConstraintCreateForeignKey struct {
 	ForeignColumns []string
 	OnUpdate       ir.ForeignKeyAction
 	OnDelete       ir.ForeignKeyAction
+	NotValid       bool
+	IfNotExists    bool
 }
 
 func (self *ConstraintCreateForeignKey) ToSql(q output.Quoter) string {
@@ -92,10 +105,10 @@ func (self *ConstraintCreateForeignKey) ToSql(q output.Quoter) string {
 	}
 
 	return (&ConstraintCreateRaw{
-		self.Table,
-		self.Constraint,
-		ir.ConstraintTypeForeign,
-		util.CondJoin(" ",
+		Table:          self.Table,
+		Constraint:     self.Constraint,
+		ConstraintType: ir.ConstraintTypeForeign,
+		Definition: util.CondJoin(" ",
 			fmt.Sprintf(
 				"(%s) REFERENCES %s (%s)",
 				strings.Join(localCols, ", "),
@@ -105,5 +118,16 @@ func (self *ConstraintCreateForeignKey) ToSql(q output.Quoter) string {
 			onUpdate,
 			onDelete,
 		),
+		NotValid:    self.NotValid,
+		IfNotExists: self.IfNotExists,
 	}).ToSql(q)
 }
+
+type ConstraintValidate struct {
+	Table      TableRef
+	Constraint string
+}
+
+func (self *ConstraintValidate) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", self.Table.Qualified(q), q.QuoteObject(self.Constraint))
+}
@@ -12,6 +12,10 @@ type DataInsert struct {
 	Table   TableRef
 	Columns []string
 	Values  []ToSqlValue
+	// OnConflictDoNothing skips rows which violate a unique constraint, such as rows inserted already
+	OnConflictDoNothing bool
+	// OverridingSystemValue lets the row give its own value for a column GENERATED ALWAYS AS IDENTITY
+	OverridingSystemValue bool
 }
 
 func (self *DataInsert) ToSql(q output.Quoter) string {
@@ -26,8 +30,9 @@ func (self *DataInsert) ToSql(q output.Quoter) string {
 		cols[i] = q.QuoteColumn(col)
 		vals[i] = self.Values[i].GetValueSql(q)
 	}
-	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
-		self.Table.Qualified(q), strings.Join(cols, ", "), strings.Join(vals, ", "))
+	return fmt.Sprintf("INSERT INTO %s (%s)%s VALUES (%s)%s;",
+		self.Table.Qualified(q), strings.Join(cols, ", "), util.MaybeStr(self.OverridingSystemValue, " OVERRIDING SYSTEM VALUE"), strings.Join(vals, ", "),
+		util.MaybeStr(self.OnConflictDoNothing, " ON CONFLICT DO NOTHING"))
 }
 
 type DataUpdate struct {
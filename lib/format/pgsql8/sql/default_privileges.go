@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// DefaultPrivilegesGrant grants privileges on objects of ObjectType as Role creates them,
+// in Schema or in every schema if it's empty
+type DefaultPrivilegesGrant struct {
+	Role       string
+	Schema     string
+	ObjectType string
+	Perms      []string
+	Roles      []string
+	CanGrant   bool
+}
+
+func (self *DefaultPrivilegesGrant) ToSql(q output.Quoter) string {
+	option := ""
+	if self.CanGrant {
+		option = " WITH GRANT OPTION"
+	}
+	return fmt.Sprintf(
+		"%s\n  GRANT %s ON %s TO %s%s;",
+		alterDefaultPrivilegesSql(q, self.Role, self.Schema),
+		grantPermsSql(self.Perms),
+		self.ObjectType,
+		granteesSql(q, self.Roles),
+		option,
+	)
+}
+
+type DefaultPrivilegesRevoke struct {
+	Role       string
+	Schema     string
+	ObjectType string
+	Perms      []string
+	Roles      []string
+}
+
+func (self *DefaultPrivilegesRevoke) ToSql(q output.Quoter) string {
+	return fmt.Sprintf(
+		"%s\n  REVOKE %s ON %s FROM %s;",
+		alterDefaultPrivilegesSql(q, self.Role, self.Schema),
+		grantPermsSql(self.Perms),
+		self.ObjectType,
+		granteesSql(q, self.Roles),
+	)
+}
+
+func alterDefaultPrivilegesSql(q output.Quoter, role, schema string) string {
+	sql := fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s", q.QuoteRole(role))
+	if schema != "" {
+		sql += fmt.Sprintf(" IN SCHEMA %s", q.QuoteSchema(schema))
+	}
+	return sql
+}
@@ -0,0 +1,61 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+type ExtensionCreate struct {
+	Extension   string
+	Schema      string
+	Version     string
+	Cascade     bool
+	IfNotExists bool
+}
+
+func (self *ExtensionCreate) ToSql(q output.Quoter) string {
+	sql := fmt.Sprintf("CREATE EXTENSION %s%s", util.MaybeStr(self.IfNotExists, "IF NOT EXISTS "), q.QuoteObject(self.Extension))
+	if self.Schema != "" {
+		sql += " SCHEMA " + q.QuoteSchema(self.Schema)
+	}
+	if self.Version != "" {
+		sql += " VERSION " + q.LiteralString(self.Version)
+	}
+	if self.Cascade {
+		sql += " CASCADE"
+	}
+	return sql + ";"
+}
+
+type ExtensionDrop struct {
+	Extension string
+}
+
+func (self *ExtensionDrop) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("DROP EXTENSION IF EXISTS %s;", q.QuoteObject(self.Extension))
+}
+
+// ExtensionUpdate updates an extension to the given version, or to its default version if none is given
+type ExtensionUpdate struct {
+	Extension string
+	Version   string
+}
+
+func (self *ExtensionUpdate) ToSql(q output.Quoter) string {
+	sql := fmt.Sprintf("ALTER EXTENSION %s UPDATE", q.QuoteObject(self.Extension))
+	if self.Version != "" {
+		sql += " TO " + q.LiteralString(self.Version)
+	}
+	return sql + ";"
+}
+
+type ExtensionSetSchema struct {
+	Extension string
+	Schema    string
+}
+
+func (self *ExtensionSetSchema) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER EXTENSION %s SET SCHEMA %s;", q.QuoteObject(self.Extension), q.QuoteSchema(self.Schema))
+}
@@ -26,32 +26,38 @@ type grant struct {
 }
 
 func (g *grant) ToSql(q output.Quoter) string {
-	roles := make([]string, len(g.Roles))
-	for i, role := range g.Roles {
-		// the PUBLIC role is actually a keyword, not an identifier, so don't quote it
-		if strings.EqualFold(role, "public") {
-			roles[i] = role
-		} else {
-			roles[i] = q.QuoteRole(role)
-		}
-	}
-
-	// NOTE it is the job of callers to validate that the correct permissions are set
-	perms := make([]string, len(g.Perms))
-	for i, perm := range g.Perms {
-		perms[i] = strings.ToUpper(perm)
-	}
-
 	option := ""
 	if g.CanGrant {
 		option = " WITH GRANT OPTION"
 	}
 	return fmt.Sprintf(
 		"GRANT %s ON %s %s TO %s%s;",
-		strings.Join(perms, ", "),
+		grantPermsSql(g.Perms),
 		g.ObjType,
 		g.Object.Qualified(q),
-		strings.Join(roles, ", "),
+		granteesSql(q, g.Roles),
 		option,
 	)
 }
+
+func granteesSql(q output.Quoter, roles []string) string {
+	quoted := make([]string, len(roles))
+	for i, role := range roles {
+		// the PUBLIC role is actually a keyword, not an identifier, so don't quote it
+		if strings.EqualFold(role, "public") {
+			quoted[i] = role
+		} else {
+			quoted[i] = q.QuoteRole(role)
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func grantPermsSql(perms []string) string {
+	// NOTE it is the job of callers to validate that the correct permissions are set
+	upper := make([]string, len(perms))
+	for i, perm := range perms {
+		upper[i] = strings.ToUpper(perm)
+	}
+	return strings.Join(upper, ", ")
+}
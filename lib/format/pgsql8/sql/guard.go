@@ -0,0 +1,63 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// unlessExists wraps ddl in a DO block which only runs it if the catalog query finds nothing,
+// for objects postgres has no IF NOT EXISTS for
+func unlessExists(catalogQuery, ddl string) string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+  IF NOT EXISTS (%s) THEN
+%s
+  END IF;
+END $$;`, catalogQuery, util.PrefixLines(ddl, "    "))
+}
+
+func constraintExistsQuery(q output.Quoter, table TableRef, constraint string) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM pg_constraint INNER JOIN pg_class ON pg_class.oid = pg_constraint.conrelid AND pg_class.relname = %s INNER JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace AND pg_namespace.nspname = %s WHERE pg_constraint.conname = %s",
+		q.LiteralString(table.Table), q.LiteralString(table.Schema), q.LiteralString(constraint),
+	)
+}
+
+func typeExistsQuery(q output.Quoter, t TypeRef) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM pg_type INNER JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace AND pg_namespace.nspname = %s WHERE pg_type.typname = %s",
+		q.LiteralString(t.Schema), q.LiteralString(t.Type),
+	)
+}
+
+// typeDefinedQuery is typeExistsQuery, except that a shell of the type doesn't count
+func typeDefinedQuery(q output.Quoter, t TypeRef) string {
+	return typeExistsQuery(q, t) + " AND pg_type.typisdefined"
+}
+
+func triggerExistsQuery(q output.Quoter, table TableRef, trigger string) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM pg_trigger INNER JOIN pg_class ON pg_class.oid = pg_trigger.tgrelid AND pg_class.relname = %s INNER JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace AND pg_namespace.nspname = %s WHERE pg_trigger.tgname = %s",
+		q.LiteralString(table.Table), q.LiteralString(table.Schema), q.LiteralString(trigger),
+	)
+}
+
+func policyExistsQuery(q output.Quoter, table TableRef, policy string) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM pg_policy INNER JOIN pg_class ON pg_class.oid = pg_policy.polrelid AND pg_class.relname = %s INNER JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace AND pg_namespace.nspname = %s WHERE pg_policy.polname = %s",
+		q.LiteralString(table.Table), q.LiteralString(table.Schema), q.LiteralString(policy),
+	)
+}
+
+func roleExistsQuery(q output.Quoter, role string) string {
+	return fmt.Sprintf("SELECT 1 FROM pg_roles WHERE rolname = %s", q.LiteralString(role))
+}
+
+func castExistsQuery(q output.Quoter, source, target TypeRef) string {
+	return fmt.Sprintf(
+		"SELECT 1 FROM pg_cast WHERE castsource = %s::regtype AND casttarget = %s::regtype",
+		q.LiteralString(source.Qualified(q)), q.LiteralString(target.Qualified(q)),
+	)
+}
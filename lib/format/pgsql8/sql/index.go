@@ -14,6 +14,7 @@ type IndexCreate struct {
 	Index        string
 	Unique       bool
 	Concurrently bool
+	IfNotExists  bool
 	Using        string
 	Dimensions   []Quotable
 	Where        string
@@ -25,6 +26,7 @@ func (ic *IndexCreate) ToSql(q output.Quoter) string {
 		util.MaybeStr(ic.Unique, "UNIQUE"),
 		"INDEX",
 		util.MaybeStr(ic.Concurrently, "CONCURRENTLY"),
+		util.MaybeStr(ic.IfNotExists, "IF NOT EXISTS"),
 		q.QuoteObject(ic.Index),
 		"ON",
 		ic.Table.Qualified(q),
@@ -45,9 +47,33 @@ func (ic *IndexCreate) ToSql(q output.Quoter) string {
 }
 
 type IndexDrop struct {
-	Index IndexRef
+	Index        IndexRef
+	Concurrently bool
+	IfExists     bool
 }
 
 func (self *IndexDrop) ToSql(q output.Quoter) string {
-	return fmt.Sprintf("DROP INDEX %s;", self.Index.Qualified(q))
+	return util.CondJoin(" ",
+		"DROP INDEX",
+		util.MaybeStr(self.Concurrently, "CONCURRENTLY"),
+		util.MaybeStr(self.IfExists, "IF EXISTS"),
+		self.Index.Qualified(q),
+	) + ";"
+}
+
+// IndexDropInvalid drops the index only if it is INVALID, as left behind by a CREATE INDEX CONCURRENTLY which failed
+type IndexDropInvalid struct {
+	Index IndexRef
+}
+
+func (self *IndexDropInvalid) ToSql(q output.Quoter) string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_index
+               INNER JOIN pg_class ON pg_class.oid = pg_index.indexrelid AND pg_class.relname = %s
+               INNER JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace AND pg_namespace.nspname = %s
+             WHERE NOT pg_index.indisvalid) THEN
+    DROP INDEX %s;
+  END IF;
+END $$;`, q.LiteralString(self.Index.Index), q.LiteralString(self.Index.Schema), self.Index.Qualified(q))
 }
@@ -11,12 +11,14 @@ type LanguageCreate struct {
 	Procedural bool
 	Handler    string
 	Validator  string
+	OrReplace  bool
 }
 
 func (self *LanguageCreate) ToSql(q output.Quoter) string {
 	return util.CondJoin(
 		" ",
 		"CREATE",
+		util.MaybeStr(self.OrReplace, "OR REPLACE"),
 		util.MaybeStr(self.Trusted, "TRUSTED"),
 		util.MaybeStr(self.Procedural, "PROCEDURAL"),
 		"LANGUAGE",
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+type PolicyCreate struct {
+	Policy      string
+	Table       TableRef
+	Restrictive bool
+	Command     string
+	Roles       []string
+	Using       string
+	WithCheck   string
+	// IfNotExists skips creating the policy if the table already has one of the same name
+	IfNotExists bool
+}
+
+func (self *PolicyCreate) ToSql(q output.Quoter) string {
+	ddl := fmt.Sprintf("CREATE POLICY %s ON %s", q.QuoteObject(self.Policy), self.Table.Qualified(q))
+	if self.Restrictive {
+		ddl += "\n  AS RESTRICTIVE"
+	}
+	if self.Command != "" {
+		ddl += "\n  FOR " + self.Command
+	}
+	ddl += policyClauses(q, self.Roles, self.Using, self.WithCheck) + ";"
+	if self.IfNotExists {
+		return unlessExists(policyExistsQuery(q, self.Table, self.Policy), ddl)
+	}
+	return ddl
+}
+
+// PolicyAlter changes who a policy applies to and its expressions. Expressions left empty are kept as they are.
+type PolicyAlter struct {
+	Policy    string
+	Table     TableRef
+	Roles     []string
+	Using     string
+	WithCheck string
+}
+
+func (self *PolicyAlter) ToSql(q output.Quoter) string {
+	return fmt.Sprintf(
+		"ALTER POLICY %s ON %s%s;",
+		q.QuoteObject(self.Policy), self.Table.Qualified(q),
+		policyClauses(q, self.Roles, self.Using, self.WithCheck),
+	)
+}
+
+type PolicyDrop struct {
+	Policy string
+	Table  TableRef
+}
+
+func (self *PolicyDrop) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s;", q.QuoteObject(self.Policy), self.Table.Qualified(q))
+}
+
+func policyClauses(q output.Quoter, roles []string, using, withCheck string) string {
+	out := ""
+	if len(roles) > 0 {
+		quoted := make([]string, len(roles))
+		for i, role := range roles {
+			// like in grants, PUBLIC is a keyword rather than a role
+			if strings.EqualFold(role, "public") {
+				quoted[i] = role
+			} else {
+				quoted[i] = q.QuoteRole(role)
+			}
+		}
+		out += "\n  TO " + strings.Join(quoted, ", ")
+	}
+	if using != "" {
+		out += fmt.Sprintf("\n  USING (%s)", using)
+	}
+	if withCheck != "" {
+		out += fmt.Sprintf("\n  WITH CHECK (%s)", withCheck)
+	}
+	return out
+}
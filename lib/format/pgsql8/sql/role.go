@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib/output"
+)
+
+// RoleCreate creates a bare role, to be given its attributes by a RoleAlter. Roles are shared by every database
+// in the cluster, so with IfNotExists a role which was already created for another database, or by hand, is kept
+type RoleCreate struct {
+	Role        string
+	IfNotExists bool
+}
+
+func (self *RoleCreate) ToSql(q output.Quoter) string {
+	ddl := fmt.Sprintf("CREATE ROLE %s;", q.QuoteRole(self.Role))
+	if self.IfNotExists {
+		return unlessExists(roleExistsQuery(q, self.Role), ddl)
+	}
+	return ddl
+}
+
+// RoleAlter sets every attribute of a role, with a nil ConnectionLimit removing the limit.
+// The password is only changed when given
+type RoleAlter struct {
+	Role            string
+	Login           bool
+	Inherit         bool
+	CreateDB        bool
+	ConnectionLimit *int
+	Password        *string
+}
+
+func (self *RoleAlter) ToSql(q output.Quoter) string {
+	limit := self.ConnectionLimit
+	if limit == nil {
+		unlimited := -1
+		limit = &unlimited
+	}
+	return fmt.Sprintf(
+		"ALTER ROLE %s WITH%s;",
+		q.QuoteRole(self.Role),
+		roleOptions(q, self.Login, self.Inherit, self.CreateDB, limit, self.Password),
+	)
+}
+
+type RoleDrop struct {
+	Role string
+}
+
+func (self *RoleDrop) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("DROP ROLE IF EXISTS %s;", q.QuoteRole(self.Role))
+}
+
+// RoleGrantMembership makes Member a member of Role
+type RoleGrantMembership struct {
+	Role   string
+	Member string
+}
+
+func (self *RoleGrantMembership) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("GRANT %s TO %s;", q.QuoteRole(self.Role), q.QuoteRole(self.Member))
+}
+
+type RoleRevokeMembership struct {
+	Role   string
+	Member string
+}
+
+func (self *RoleRevokeMembership) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("REVOKE %s FROM %s;", q.QuoteRole(self.Role), q.QuoteRole(self.Member))
+}
+
+func roleOptions(q output.Quoter, login, inherit, createDB bool, connectionLimit *int, password *string) string {
+	opts := ""
+	if login {
+		opts += " LOGIN"
+	} else {
+		opts += " NOLOGIN"
+	}
+	if inherit {
+		opts += " INHERIT"
+	} else {
+		opts += " NOINHERIT"
+	}
+	if createDB {
+		opts += " CREATEDB"
+	} else {
+		opts += " NOCREATEDB"
+	}
+	if connectionLimit != nil {
+		opts += fmt.Sprintf(" CONNECTION LIMIT %d", *connectionLimit)
+	}
+	if password != nil {
+		opts += " PASSWORD " + q.LiteralString(*password)
+	}
+	return opts
+}
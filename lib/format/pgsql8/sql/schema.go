@@ -4,14 +4,16 @@ import (
 	"fmt"
 
 	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
 )
 
 type SchemaCreate struct {
-	Schema string
+	Schema      string
+	IfNotExists bool
 }
 
 func (self *SchemaCreate) ToSql(q output.Quoter) string {
-	return fmt.Sprintf("CREATE SCHEMA %s;", q.QuoteSchema(self.Schema))
+	return fmt.Sprintf("CREATE SCHEMA %s%s;", util.MaybeStr(self.IfNotExists, "IF NOT EXISTS "), q.QuoteSchema(self.Schema))
 }
 
 type SchemaDrop struct {
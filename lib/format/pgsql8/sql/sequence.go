@@ -8,18 +8,19 @@ import (
 )
 
 type SequenceCreate struct {
-	Sequence  SequenceRef
-	Cache     util.Opt[int]
-	Start     util.Opt[int]
-	Min       util.Opt[int]
-	Max       util.Opt[int]
-	Increment util.Opt[int]
-	Cycle     bool
-	OwnedBy   string
+	Sequence    SequenceRef
+	Cache       util.Opt[int]
+	Start       util.Opt[int]
+	Min         util.Opt[int]
+	Max         util.Opt[int]
+	Increment   util.Opt[int]
+	Cycle       bool
+	OwnedBy     string
+	IfNotExists bool
 }
 
 func (self *SequenceCreate) ToSql(q output.Quoter) string {
-	ddl := "CREATE SEQUENCE " + self.Sequence.Qualified(q)
+	ddl := "CREATE SEQUENCE " + util.MaybeStr(self.IfNotExists, "IF NOT EXISTS ") + self.Sequence.Qualified(q)
 	if val, ok := self.Increment.Maybe(); ok {
 		ddl += fmt.Sprintf("\n  INCREMENT BY %d", val)
 	}
@@ -130,6 +131,26 @@ func (self *SequenceSerialSetVal) ToSql(q output.Quoter) string {
 	}).ToSql(q)
 }
 
+// SequenceOwnedByNone detaches a sequence from the column owning it, so it can outlive the column's default
+type SequenceOwnedByNone struct {
+	Sequence SequenceRef
+}
+
+func (self *SequenceOwnedByNone) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY NONE;", self.Sequence.Qualified(q))
+}
+
+// SequenceSerialSetValFrom carries the position of another sequence over to the sequence of a column,
+// such as when a serial column becomes an identity
+type SequenceSerialSetValFrom struct {
+	Column ColumnRef
+	From   SequenceRef
+}
+
+func (self *SequenceSerialSetValFrom) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("SELECT setval(%s, last_value, is_called) FROM %s;", (&SequenceGetSerialName{self.Column}).GetValueSql(q), self.From.Qualified(q))
+}
+
 // implements `SELECT setval(...);`
 // see https://www.postgresql.org/docs/13/functions-sequence.html
 type SequenceSetVal struct {
@@ -13,3 +13,13 @@ type SetCheckFunctionBodies struct {
 func (self *SetCheckFunctionBodies) ToSql(q output.Quoter) string {
 	return fmt.Sprintf(`SET check_function_bodies = %t;`, self.Value)
 }
+
+// SetLocal sets a configuration parameter for the rest of the current transaction only
+type SetLocal struct {
+	Name  string
+	Value string
+}
+
+func (self *SetLocal) ToSql(q output.Quoter) string {
+	return fmt.Sprintf(`SET LOCAL %s = %s;`, self.Name, q.LiteralString(self.Value))
+}
@@ -5,13 +5,21 @@ import (
 	"strings"
 
 	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
 )
 
 type TableCreate struct {
 	Table        TableRef
 	Columns      []ColumnDefinition
 	Inherits     *TableRef
+	PartitionBy  *TablePartitionBy
 	OtherOptions []TableCreateOption // TODO make individual options first-class
+	IfNotExists  bool
+}
+
+type TablePartitionBy struct {
+	Type string
+	Key  string // raw sql, as it may contain expressions
 }
 
 type TableCreateOption struct {
@@ -30,6 +38,9 @@ func (self *TableCreate) ToSql(q output.Quoter) string {
 	}
 
 	opts := []string{}
+	if self.PartitionBy != nil {
+		opts = append(opts, fmt.Sprintf("PARTITION BY %s (%s)", strings.ToUpper(self.PartitionBy.Type), self.PartitionBy.Key))
+	}
 	for _, opt := range self.OtherOptions {
 		opts = append(opts, fmt.Sprintf("%s %s", strings.ToUpper(opt.Option), opt.Value))
 	}
@@ -42,13 +53,60 @@ func (self *TableCreate) ToSql(q output.Quoter) string {
 	}
 
 	return fmt.Sprintf(
-		"CREATE TABLE %s(%s)%s;",
+		"CREATE TABLE %s%s(%s)%s;",
+		util.MaybeStr(self.IfNotExists, "IF NOT EXISTS "),
 		self.Table.Qualified(q),
 		colsql,
 		optsql,
 	)
 }
 
+type TableCreatePartitionOf struct {
+	Table       TableRef
+	Parent      TableRef
+	Bound       string
+	IfNotExists bool
+}
+
+func (self *TableCreatePartitionOf) ToSql(q output.Quoter) string {
+	return fmt.Sprintf(
+		"CREATE TABLE %s%s PARTITION OF %s %s;",
+		util.MaybeStr(self.IfNotExists, "IF NOT EXISTS "),
+		self.Table.Qualified(q),
+		self.Parent.Qualified(q),
+		partitionBoundSql(self.Bound),
+	)
+}
+
+// partitionBoundSql renders a partition bound spec, which is either DEFAULT
+// or the remainder of a FOR VALUES clause
+func partitionBoundSql(bound string) string {
+	bound = strings.TrimSpace(bound)
+	if strings.EqualFold(bound, "DEFAULT") {
+		return "DEFAULT"
+	}
+	return "FOR VALUES " + bound
+}
+
+type TableAttachPartition struct {
+	Table     TableRef
+	Partition TableRef
+	Bound     string
+}
+
+func (self *TableAttachPartition) ToSql(q output.Quoter) string {
+	return NewTableAlter(self.Table, &TableAlterPartAttachPartition{self.Partition, self.Bound}).ToSql(q)
+}
+
+type TableDetachPartition struct {
+	Table     TableRef
+	Partition TableRef
+}
+
+func (self *TableDetachPartition) ToSql(q output.Quoter) string {
+	return NewTableAlter(self.Table, &TableAlterPartDetachPartition{self.Partition}).ToSql(q)
+}
+
 type TableSetComment struct {
 	Table   TableRef
 	Comment string
@@ -107,6 +107,23 @@ func (t *TableAlterPartRename) GetAlterPartSql(q output.Quoter) string {
 	return fmt.Sprintf("RENAME TO %s", q.QuoteTable(t.Name))
 }
 
+type TableAlterPartAttachPartition struct {
+	Partition TableRef
+	Bound     string
+}
+
+func (t *TableAlterPartAttachPartition) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("ATTACH PARTITION %s %s", t.Partition.Qualified(q), partitionBoundSql(t.Bound))
+}
+
+type TableAlterPartDetachPartition struct {
+	Partition TableRef
+}
+
+func (t *TableAlterPartDetachPartition) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("DETACH PARTITION %s", t.Partition.Qualified(q))
+}
+
 type TableAlterPartSetSchema struct {
 	Name string
 }
@@ -132,6 +149,75 @@ func (t *TableAlterPartColumnDropDefault) GetAlterPartSql(q output.Quoter) strin
 	return fmt.Sprintf("ALTER COLUMN %s DROP DEFAULT", q.QuoteColumn(t.Column))
 }
 
+type TableAlterPartColumnAddIdentity struct {
+	Column   string
+	Identity ColumnIdentity
+}
+
+func (t *TableAlterPartColumnAddIdentity) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER COLUMN %s ADD %s", q.QuoteColumn(t.Column), t.Identity.GetSql())
+}
+
+// TableAlterPartColumnSetIdentity resets every option of an identity, not only the ones which changed,
+// so options which are no longer specified go back to their defaults
+type TableAlterPartColumnSetIdentity struct {
+	Column   string
+	Identity ColumnIdentity
+}
+
+func (t *TableAlterPartColumnSetIdentity) GetAlterPartSql(q output.Quoter) string {
+	id := t.Identity
+	sets := []string{"SET GENERATED " + id.Generation}
+	if id.Start != nil {
+		sets = append(sets, fmt.Sprintf("SET START WITH %d", *id.Start))
+	}
+	sets = append(sets, fmt.Sprintf("SET INCREMENT BY %d", util.SomePtr(id.Increment).GetOr(1)))
+	if id.Min != nil {
+		sets = append(sets, fmt.Sprintf("SET MINVALUE %d", *id.Min))
+	} else {
+		sets = append(sets, "SET NO MINVALUE")
+	}
+	if id.Max != nil {
+		sets = append(sets, fmt.Sprintf("SET MAXVALUE %d", *id.Max))
+	} else {
+		sets = append(sets, "SET NO MAXVALUE")
+	}
+	sets = append(sets, fmt.Sprintf("SET CACHE %d", util.SomePtr(id.Cache).GetOr(1)))
+	if id.Cycle {
+		sets = append(sets, "SET CYCLE")
+	} else {
+		sets = append(sets, "SET NO CYCLE")
+	}
+	return fmt.Sprintf("ALTER COLUMN %s %s", q.QuoteColumn(t.Column), strings.Join(sets, " "))
+}
+
+type TableAlterPartColumnDropIdentity struct {
+	Column string
+}
+
+func (t *TableAlterPartColumnDropIdentity) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER COLUMN %s DROP IDENTITY IF EXISTS", q.QuoteColumn(t.Column))
+}
+
+// TableAlterPartColumnSetExpression changes the expression of a generated column, which needs postgres 17
+type TableAlterPartColumnSetExpression struct {
+	Column     string
+	Expression string
+}
+
+func (t *TableAlterPartColumnSetExpression) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER COLUMN %s SET EXPRESSION AS (%s)", q.QuoteColumn(t.Column), t.Expression)
+}
+
+// TableAlterPartColumnDropExpression turns a generated column into a regular one, keeping its values, which needs postgres 13
+type TableAlterPartColumnDropExpression struct {
+	Column string
+}
+
+func (t *TableAlterPartColumnDropExpression) GetAlterPartSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER COLUMN %s DROP EXPRESSION", q.QuoteColumn(t.Column))
+}
+
 type TableAlterPartColumnDrop struct {
 	Column string
 }
@@ -151,10 +237,12 @@ func (t *TableAlterPartColumnRename) GetAlterPartSql(q output.Quoter) string {
 
 type TableAlterPartColumnCreate struct {
 	ColumnDef ColumnDefinition
+	// IfNotExists skips adding a column the table already has, which needs postgres 9.6
+	IfNotExists bool
 }
 
 func (t *TableAlterPartColumnCreate) GetAlterPartSql(q output.Quoter) string {
-	return fmt.Sprintf("ADD COLUMN %s", t.ColumnDef.GetSql(q))
+	return fmt.Sprintf("ADD COLUMN %s%s", util.MaybeStr(t.IfNotExists, "IF NOT EXISTS "), t.ColumnDef.GetSql(q))
 }
 
 type TableAlterPartColumnSetNull struct {
@@ -220,3 +308,26 @@ type TableAlterPartClusterOn struct {
 func (t *TableAlterPartClusterOn) GetAlterPartSql(q output.Quoter) string {
 	return fmt.Sprintf("CLUSTER ON %s", q.QuoteObject(t.Index))
 }
+
+type TableAlterPartRowLevelSecurity struct {
+	Enabled bool
+}
+
+func (t *TableAlterPartRowLevelSecurity) GetAlterPartSql(output.Quoter) string {
+	if t.Enabled {
+		return "ENABLE ROW LEVEL SECURITY"
+	}
+	return "DISABLE ROW LEVEL SECURITY"
+}
+
+// TableAlterPartForceRowLevelSecurity makes row level security apply to the table owner too
+type TableAlterPartForceRowLevelSecurity struct {
+	Forced bool
+}
+
+func (t *TableAlterPartForceRowLevelSecurity) GetAlterPartSql(output.Quoter) string {
+	if t.Forced {
+		return "FORCE ROW LEVEL SECURITY"
+	}
+	return "NO FORCE ROW LEVEL SECURITY"
+}
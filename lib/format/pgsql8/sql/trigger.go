@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
 )
 
 // TODO(go,3) at what point should we just pass the whole model.Trigger object?
@@ -16,11 +17,16 @@ type TriggerCreate struct {
 	Timing   string
 	ForEach  string
 	Function string
+	// IfNotExists skips creating the trigger if the table already has one of the same name
+	IfNotExists bool
+	// OrReplace replaces a trigger of the same name on the table, which needs postgres 14
+	OrReplace bool
 }
 
 func (self *TriggerCreate) ToSql(q output.Quoter) string {
-	return fmt.Sprintf(
-		"CREATE TRIGGER %s\n  %s %s\n  ON %s\n  FOR EACH %s\n  EXECUTE PROCEDURE %s;",
+	ddl := fmt.Sprintf(
+		"CREATE %sTRIGGER %s\n  %s %s\n  ON %s\n  FOR EACH %s\n  EXECUTE PROCEDURE %s;",
+		util.MaybeStr(self.OrReplace, "OR REPLACE "),
 		self.Trigger.Quoted(q),
 		self.Timing,
 		strings.Join(self.Events, " OR "),
@@ -28,6 +34,10 @@ func (self *TriggerCreate) ToSql(q output.Quoter) string {
 		self.ForEach,
 		self.Function, // TODO(feat) should be a full FunctionRef
 	)
+	if self.IfNotExists && !self.OrReplace {
+		return unlessExists(triggerExistsQuery(q, self.Table, self.Trigger.Trigger), ddl)
+	}
+	return ddl
 }
 
 type TriggerDrop struct {
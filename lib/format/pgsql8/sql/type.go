@@ -8,8 +8,9 @@ import (
 )
 
 type TypeEnumCreate struct {
-	Type   TypeRef
-	Values []string
+	Type        TypeRef
+	Values      []string
+	IfNotExists bool
 }
 
 func (self *TypeEnumCreate) ToSql(q output.Quoter) string {
@@ -17,12 +18,46 @@ func (self *TypeEnumCreate) ToSql(q output.Quoter) string {
 	for i, value := range self.Values {
 		values[i] = q.LiteralString(value)
 	}
-	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", self.Type.Qualified(q), strings.Join(values, ", "))
+	ddl := fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", self.Type.Qualified(q), strings.Join(values, ", "))
+	if self.IfNotExists {
+		return unlessExists(typeExistsQuery(q, self.Type), ddl)
+	}
+	return ddl
 }
 
-type TypeCompositeCreate struct {
+// TypeEnumAddValue adds a value to an enum, placed before or after an existing value,
+// or at the end if neither is given
+type TypeEnumAddValue struct {
 	Type   TypeRef
-	Fields []TypeCompositeCreateField
+	Value  string
+	Before string
+	After  string
+}
+
+func (self *TypeEnumAddValue) ToSql(q output.Quoter) string {
+	ddl := fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", self.Type.Qualified(q), q.LiteralString(self.Value))
+	if self.Before != "" {
+		ddl += " BEFORE " + q.LiteralString(self.Before)
+	} else if self.After != "" {
+		ddl += " AFTER " + q.LiteralString(self.After)
+	}
+	return ddl + ";"
+}
+
+type TypeEnumRenameValue struct {
+	Type     TypeRef
+	OldValue string
+	NewValue string
+}
+
+func (self *TypeEnumRenameValue) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("ALTER TYPE %s RENAME VALUE %s TO %s;", self.Type.Qualified(q), q.LiteralString(self.OldValue), q.LiteralString(self.NewValue))
+}
+
+type TypeCompositeCreate struct {
+	Type        TypeRef
+	Fields      []TypeCompositeCreateField
+	IfNotExists bool
 }
 type TypeCompositeCreateField struct {
 	Name string
@@ -34,14 +69,110 @@ func (self *TypeCompositeCreate) ToSql(q output.Quoter) string {
 	for i, field := range self.Fields {
 		fields[i] = fmt.Sprintf("%s %s", field.Name, field.Type)
 	}
-	return fmt.Sprintf("CREATE TYPE %s AS (\n  %s\n);", self.Type.Qualified(q), strings.Join(fields, ",\n  "))
+	ddl := fmt.Sprintf("CREATE TYPE %s AS (\n  %s\n);", self.Type.Qualified(q), strings.Join(fields, ",\n  "))
+	if self.IfNotExists {
+		return unlessExists(typeExistsQuery(q, self.Type), ddl)
+	}
+	return ddl
+}
+
+// TypeShellCreate creates a placeholder for a type, so the functions it's defined with can be declared first
+type TypeShellCreate struct {
+	Type        TypeRef
+	IfNotExists bool
+}
+
+func (self *TypeShellCreate) ToSql(q output.Quoter) string {
+	ddl := fmt.Sprintf("CREATE TYPE %s;", self.Type.Qualified(q))
+	if self.IfNotExists {
+		return unlessExists(typeExistsQuery(q, self.Type), ddl)
+	}
+	return ddl
+}
+
+type TypeRangeCreate struct {
+	Type           TypeRef
+	Subtype        string
+	SubtypeOpClass string
+	Collation      string
+	Canonical      string
+	SubtypeDiff    string
+	// IfNotExists creates the type unless it's already defined, filling in a shell of it
+	IfNotExists bool
+}
+
+func (self *TypeRangeCreate) ToSql(q output.Quoter) string {
+	opts := []string{"SUBTYPE = " + self.Subtype}
+	if self.SubtypeOpClass != "" {
+		opts = append(opts, "SUBTYPE_OPCLASS = "+self.SubtypeOpClass)
+	}
+	if self.Collation != "" {
+		opts = append(opts, "COLLATION = "+self.Collation)
+	}
+	if self.Canonical != "" {
+		opts = append(opts, "CANONICAL = "+self.Canonical)
+	}
+	if self.SubtypeDiff != "" {
+		opts = append(opts, "SUBTYPE_DIFF = "+self.SubtypeDiff)
+	}
+	ddl := fmt.Sprintf("CREATE TYPE %s AS RANGE (\n  %s\n);", self.Type.Qualified(q), strings.Join(opts, ",\n  "))
+	if self.IfNotExists {
+		return unlessExists(typeDefinedQuery(q, self.Type), ddl)
+	}
+	return ddl
+}
+
+// TypeBaseCreate defines a base type from its functions, filling in the shell the functions were declared with
+type TypeBaseCreate struct {
+	Type           TypeRef
+	Input          string
+	Output         string
+	Receive        string
+	Send           string
+	InternalLength string
+	PassedByValue  bool
+	Alignment      string
+	Storage        string
+	IfNotExists    bool
+}
+
+func (self *TypeBaseCreate) ToSql(q output.Quoter) string {
+	opts := []string{"INPUT = " + self.Input, "OUTPUT = " + self.Output}
+	if self.Receive != "" {
+		opts = append(opts, "RECEIVE = "+self.Receive)
+	}
+	if self.Send != "" {
+		opts = append(opts, "SEND = "+self.Send)
+	}
+	if self.InternalLength != "" {
+		opts = append(opts, "INTERNALLENGTH = "+strings.ToUpper(self.InternalLength))
+	}
+	if self.PassedByValue {
+		opts = append(opts, "PASSEDBYVALUE")
+	}
+	if self.Alignment != "" {
+		opts = append(opts, "ALIGNMENT = "+self.Alignment)
+	}
+	if self.Storage != "" {
+		opts = append(opts, "STORAGE = "+self.Storage)
+	}
+	ddl := fmt.Sprintf("CREATE TYPE %s (\n  %s\n);", self.Type.Qualified(q), strings.Join(opts, ",\n  "))
+	if self.IfNotExists {
+		return unlessExists(typeDefinedQuery(q, self.Type), ddl)
+	}
+	return ddl
 }
 
 type TypeDrop struct {
 	Type TypeRef
+	// Cascade drops what depends on the type along with it, which a base type needs to go with its functions
+	Cascade bool
 }
 
 func (self *TypeDrop) ToSql(q output.Quoter) string {
+	if self.Cascade {
+		return fmt.Sprintf("DROP TYPE %s CASCADE;", self.Type.Qualified(q))
+	}
 	return fmt.Sprintf("DROP TYPE %s;", self.Type.Qualified(q))
 }
 
@@ -51,6 +182,7 @@ type TypeDomainCreate struct {
 	Default     ToSqlValue
 	Nullable    bool
 	Constraints []TypeDomainCreateConstraint
+	IfNotExists bool
 }
 type TypeDomainCreateConstraint struct {
 	Name  string
@@ -69,7 +201,11 @@ func (self *TypeDomainCreate) ToSql(q output.Quoter) string {
 	for _, constraint := range self.Constraints {
 		ddl += fmt.Sprintf("\n  CONSTRAINT %s CHECK(%s)", q.QuoteObject(constraint.Name), constraint.Check)
 	}
-	return ddl + ";"
+	ddl += ";"
+	if self.IfNotExists {
+		return unlessExists(typeExistsQuery(q, self.Type), ddl)
+	}
+	return ddl
 }
 
 type TypeDomainDrop struct {
@@ -4,49 +4,77 @@ import (
 	"fmt"
 
 	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/dbsteward/dbsteward/lib/util"
 )
 
+func viewKind(materialized bool) string {
+	if materialized {
+		return "MATERIALIZED VIEW"
+	}
+	return "VIEW"
+}
+
 type ViewCreate struct {
-	View  ViewRef
-	Query string
+	View         ViewRef
+	Query        string
+	Materialized bool
+	// IfNotExists only applies to materialized views, plain views are always replaced
+	IfNotExists bool
 }
 
 func (self *ViewCreate) ToSql(q output.Quoter) string {
+	if self.Materialized {
+		// there is no CREATE OR REPLACE for materialized views
+		return fmt.Sprintf("CREATE MATERIALIZED VIEW %s%s AS\n%s;", util.MaybeStr(self.IfNotExists, "IF NOT EXISTS "), self.View.Qualified(q), self.Query)
+	}
 	// TODO(feat) OR REPLACE?
 	return fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\n%s;", self.View.Qualified(q), self.Query)
 }
 
 type ViewSetComment struct {
-	View    ViewRef
-	Comment string
+	View         ViewRef
+	Comment      string
+	Materialized bool
 }
 
 func (self *ViewSetComment) ToSql(q output.Quoter) string {
 	return fmt.Sprintf(
-		"COMMENT ON VIEW %s IS %s;",
+		"COMMENT ON %s %s IS %s;",
+		viewKind(self.Materialized),
 		self.View.Qualified(q),
 		q.LiteralString(self.Comment),
 	)
 }
 
 type ViewAlterOwner struct {
-	View ViewRef
-	Role string
+	View         ViewRef
+	Role         string
+	Materialized bool
 }
 
 func (self *ViewAlterOwner) ToSql(q output.Quoter) string {
 	return fmt.Sprintf(
-		"ALTER VIEW %s OWNER TO %s;",
+		"ALTER %s %s OWNER TO %s;",
+		viewKind(self.Materialized),
 		self.View.Qualified(q),
 		q.QuoteRole(self.Role),
 	)
 }
 
 type ViewDrop struct {
-	View ViewRef
+	View         ViewRef
+	Materialized bool
 }
 
 func (self *ViewDrop) ToSql(q output.Quoter) string {
 	// TODO(feat) IF EXISTS?
-	return fmt.Sprintf("DROP VIEW IF EXISTS %s;", self.View.Qualified(q))
+	return fmt.Sprintf("DROP %s IF EXISTS %s;", viewKind(self.Materialized), self.View.Qualified(q))
+}
+
+type MaterializedViewRefresh struct {
+	View ViewRef
+}
+
+func (self *MaterializedViewRefresh) ToSql(q output.Quoter) string {
+	return fmt.Sprintf("REFRESH MATERIALIZED VIEW %s;", self.View.Qualified(q))
 }
@@ -0,0 +1,453 @@
+package pgsql8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlTokenKind classifies the tokens produced by tokenizeSql
+type sqlTokenKind int
+
+const (
+	sqlTokenEOF    sqlTokenKind = iota
+	sqlTokenWord                // unquoted identifier or keyword
+	sqlTokenIdent               // "quoted identifier"
+	sqlTokenString              // 'string', E'string' or $tag$string$tag$
+	sqlTokenNumber
+	sqlTokenPunct // ( ) [ ] , ; . and operators
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	// value is the semantic value of the token: unquoted words are lowercased,
+	// quoted identifiers and strings are unquoted and unescaped
+	value string
+	start int
+	end   int
+}
+
+func (t sqlToken) isWord(word string) bool {
+	return t.kind == sqlTokenWord && t.value == word
+}
+
+func (t sqlToken) isPunct(p string) bool {
+	return t.kind == sqlTokenPunct && t.value == p
+}
+
+func (t sqlToken) isName() bool {
+	return t.kind == sqlTokenWord || t.kind == sqlTokenIdent
+}
+
+const sqlOperatorChars = "+-*/<>=~!@#%^&|`?:"
+
+// tokenizeSql breaks a postgres script into tokens, discarding whitespace,
+// comments and psql meta-commands
+func tokenizeSql(src string) ([]sqlToken, error) {
+	toks := []sqlToken{}
+	i := 0
+	n := len(src)
+	for i < n {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+
+		case c == '\\' && (i == 0 || src[i-1] == '\n'):
+			// psql meta-command, e.g. \connect, runs to the end of the line
+			for i < n && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "--"):
+			for i < n && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			start := i
+			depth := 0
+			for i < n {
+				if strings.HasPrefix(src[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(src[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			if depth != 0 {
+				return nil, fmt.Errorf("unterminated comment starting at offset %d", start)
+			}
+
+		case c == '\'' || ((c == 'E' || c == 'e') && i+1 < n && src[i+1] == '\''):
+			start := i
+			escapes := c != '\''
+			if escapes {
+				i++
+			}
+			value, end, err := scanSqlQuoted(src, i, '\'', escapes)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, sqlToken{kind: sqlTokenString, value: value, start: start, end: end})
+			i = end
+
+		case c == '"':
+			value, end, err := scanSqlQuoted(src, i, '"', false)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, sqlToken{kind: sqlTokenIdent, value: value, start: i, end: end})
+			i = end
+
+		case c == '$' && i+1 < n && !isSqlDigit(src[i+1]):
+			tag, ok := scanSqlDollarTag(src, i)
+			if !ok {
+				toks = append(toks, sqlToken{kind: sqlTokenPunct, value: "$", start: i, end: i + 1})
+				i++
+				continue
+			}
+			bodyStart := i + len(tag)
+			bodyEnd := strings.Index(src[bodyStart:], tag)
+			if bodyEnd < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string starting at offset %d", i)
+			}
+			bodyEnd += bodyStart
+			toks = append(toks, sqlToken{kind: sqlTokenString, value: src[bodyStart:bodyEnd], start: i, end: bodyEnd + len(tag)})
+			i = bodyEnd + len(tag)
+
+		case isSqlIdentStart(c) || c == '$':
+			start := i
+			for i < n && (isSqlIdentStart(src[i]) || isSqlDigit(src[i]) || src[i] == '$') {
+				i++
+			}
+			toks = append(toks, sqlToken{kind: sqlTokenWord, value: strings.ToLower(src[start:i]), start: start, end: i})
+
+		case isSqlDigit(c) || (c == '.' && i+1 < n && isSqlDigit(src[i+1])):
+			start := i
+			for i < n && (isSqlDigit(src[i]) || src[i] == '.' || src[i] == '_') {
+				i++
+			}
+			if i < n && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < n && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < n && isSqlDigit(src[j]) {
+					i = j
+					for i < n && isSqlDigit(src[i]) {
+						i++
+					}
+				}
+			}
+			toks = append(toks, sqlToken{kind: sqlTokenNumber, value: src[start:i], start: start, end: i})
+
+		case strings.IndexByte(sqlOperatorChars, c) >= 0:
+			start := i
+			for i < n && strings.IndexByte(sqlOperatorChars, src[i]) >= 0 {
+				if i > start && (strings.HasPrefix(src[i:], "--") || strings.HasPrefix(src[i:], "/*")) {
+					break
+				}
+				i++
+			}
+			toks = append(toks, sqlToken{kind: sqlTokenPunct, value: src[start:i], start: start, end: i})
+
+		default:
+			toks = append(toks, sqlToken{kind: sqlTokenPunct, value: string(c), start: i, end: i + 1})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// scanSqlQuoted scans a quote-delimited string or identifier starting at src[start],
+// returning the unescaped value and the offset just past the closing quote
+func scanSqlQuoted(src string, start int, quote byte, backslashEscapes bool) (string, int, error) {
+	var b strings.Builder
+	i := start + 1
+	for i < len(src) {
+		c := src[i]
+		if backslashEscapes && c == '\\' && i+1 < len(src) {
+			switch src[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(src[i+1])
+			}
+			i += 2
+			continue
+		}
+		if c == quote {
+			if i+1 < len(src) && src[i+1] == quote {
+				b.WriteByte(quote)
+				i += 2
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return "", 0, fmt.Errorf("unterminated %c-quoted token starting at offset %d", quote, start)
+}
+
+// scanSqlDollarTag returns the $tag$ starting at src[start], if there is one
+func scanSqlDollarTag(src string, start int) (string, bool) {
+	for i := start + 1; i < len(src); i++ {
+		c := src[i]
+		if c == '$' {
+			return src[start : i+1], true
+		}
+		if !isSqlIdentStart(c) && !(i > start+1 && isSqlDigit(c)) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSqlIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isSqlDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// splitSqlStatements groups tokens into statements on top-level semicolons
+func splitSqlStatements(src string, toks []sqlToken) []*sqlStmt {
+	out := []*sqlStmt{}
+	start := 0
+	for i, tok := range toks {
+		if tok.isPunct(";") {
+			if i > start {
+				out = append(out, &sqlStmt{src: src, toks: toks[start:i]})
+			}
+			start = i + 1
+		}
+	}
+	if start < len(toks) {
+		out = append(out, &sqlStmt{src: src, toks: toks[start:]})
+	}
+	return out
+}
+
+// sqlStmt is a cursor over the tokens of a single statement, or a
+// parenthesized/comma-separated part of one
+type sqlStmt struct {
+	src  string
+	toks []sqlToken
+	pos  int
+}
+
+func (s *sqlStmt) String() string {
+	if len(s.toks) == 0 {
+		return ""
+	}
+	return s.src[s.toks[0].start:s.toks[len(s.toks)-1].end]
+}
+
+// summary returns an abbreviated form of the statement suitable for log messages
+func (s *sqlStmt) summary() string {
+	str := strings.Join(strings.Fields(s.String()), " ")
+	if len(str) > 80 {
+		return str[:77] + "..."
+	}
+	return str
+}
+
+func (s *sqlStmt) done() bool {
+	return s.pos >= len(s.toks)
+}
+
+func (s *sqlStmt) peekAt(offset int) sqlToken {
+	if s.pos+offset >= len(s.toks) {
+		return sqlToken{kind: sqlTokenEOF}
+	}
+	return s.toks[s.pos+offset]
+}
+
+func (s *sqlStmt) peek() sqlToken {
+	return s.peekAt(0)
+}
+
+func (s *sqlStmt) next() sqlToken {
+	tok := s.peek()
+	if !s.done() {
+		s.pos++
+	}
+	return tok
+}
+
+// peekWord reports whether the upcoming tokens are exactly the given keywords
+func (s *sqlStmt) peekWord(words ...string) bool {
+	for i, word := range words {
+		if !s.peekAt(i).isWord(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptWord consumes the given keywords if they are next
+func (s *sqlStmt) acceptWord(words ...string) bool {
+	if !s.peekWord(words...) {
+		return false
+	}
+	s.pos += len(words)
+	return true
+}
+
+func (s *sqlStmt) expectWord(words ...string) error {
+	if !s.acceptWord(words...) {
+		return fmt.Errorf("expected %s but found '%s'", strings.ToUpper(strings.Join(words, " ")), s.describeNext())
+	}
+	return nil
+}
+
+func (s *sqlStmt) acceptPunct(p string) bool {
+	if !s.peek().isPunct(p) {
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sqlStmt) expectPunct(p string) error {
+	if !s.acceptPunct(p) {
+		return fmt.Errorf("expected '%s' but found '%s'", p, s.describeNext())
+	}
+	return nil
+}
+
+func (s *sqlStmt) describeNext() string {
+	if s.done() {
+		return "end of statement"
+	}
+	tok := s.peek()
+	return s.src[tok.start:tok.end]
+}
+
+// ident consumes a single, possibly quoted, identifier
+func (s *sqlStmt) ident() (string, error) {
+	tok := s.peek()
+	if !tok.isName() {
+		return "", fmt.Errorf("expected identifier but found '%s'", s.describeNext())
+	}
+	s.pos++
+	return tok.value, nil
+}
+
+// nameParts consumes a dotted name like schema.table.column
+func (s *sqlStmt) nameParts() ([]string, error) {
+	first, err := s.ident()
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{first}
+	for s.acceptPunct(".") {
+		part, err := s.ident()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// parens consumes a parenthesized group, returning a cursor over its contents
+func (s *sqlStmt) parens() (*sqlStmt, error) {
+	if err := s.expectPunct("("); err != nil {
+		return nil, err
+	}
+	start := s.pos
+	depth := 1
+	for !s.done() {
+		tok := s.next()
+		if tok.isPunct("(") {
+			depth++
+		} else if tok.isPunct(")") {
+			depth--
+			if depth == 0 {
+				return &sqlStmt{src: s.src, toks: s.toks[start : s.pos-1]}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unbalanced parentheses")
+}
+
+// split breaks the remaining tokens apart on top-level commas
+func (s *sqlStmt) split() []*sqlStmt {
+	out := []*sqlStmt{}
+	depth := 0
+	start := s.pos
+	for ; !s.done(); s.pos++ {
+		tok := s.peek()
+		switch {
+		case tok.isPunct("(") || tok.isPunct("["):
+			depth++
+		case tok.isPunct(")") || tok.isPunct("]"):
+			depth--
+		case tok.isPunct(",") && depth == 0:
+			out = append(out, &sqlStmt{src: s.src, toks: s.toks[start:s.pos]})
+			start = s.pos + 1
+		}
+	}
+	if start < len(s.toks) {
+		out = append(out, &sqlStmt{src: s.src, toks: s.toks[start:]})
+	}
+	return out
+}
+
+// rawUntil consumes at least one token, stopping before the first top-level
+// token for which stop returns true, and returns the source text consumed
+func (s *sqlStmt) rawUntil(stop func(*sqlStmt) bool) string {
+	start := s.pos
+	depth := 0
+	for !s.done() {
+		if depth == 0 && s.pos > start && stop(s) {
+			break
+		}
+		tok := s.next()
+		if tok.isPunct("(") || tok.isPunct("[") {
+			depth++
+		} else if tok.isPunct(")") || tok.isPunct("]") {
+			depth--
+		}
+	}
+	return s.raw(start, s.pos)
+}
+
+// rest consumes all remaining tokens and returns their source text
+func (s *sqlStmt) rest() string {
+	start := s.pos
+	s.pos = len(s.toks)
+	return s.raw(start, s.pos)
+}
+
+func (s *sqlStmt) raw(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	return s.src[s.toks[from].start:s.toks[to-1].end]
+}
+
+// stopAtWords builds a rawUntil stop function matching any of the given keywords
+func stopAtWords(words ...string) func(*sqlStmt) bool {
+	return func(s *sqlStmt) bool {
+		for _, word := range words {
+			if s.peekWord(word) {
+				return true
+			}
+		}
+		return false
+	}
+}
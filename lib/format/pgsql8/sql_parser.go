@@ -0,0 +1,1663 @@
+package pgsql8
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// sqlParser builds an ir.Definition out of postgres DDL scripts, such as
+// the output of pg_dump --schema-only
+type sqlParser struct {
+	logger     *slog.Logger
+	doc        *ir.Definition
+	roles      *roleIndex
+	searchPath string
+}
+
+func newSqlParser(l *slog.Logger) *sqlParser {
+	return &sqlParser{
+		logger: l,
+		doc: &ir.Definition{
+			Database: &ir.Database{
+				SqlFormat: ir.SqlFormatPgsql8,
+			},
+		},
+		roles:      newRoleIndex(""),
+		searchPath: "public",
+	}
+}
+
+// parseSqlFiles parses the given DDL scripts, in order, into a single definition
+func parseSqlFiles(l *slog.Logger, files []string) (*ir.Definition, error) {
+	p := newSqlParser(l)
+	for _, file := range files {
+		l.Info(fmt.Sprintf("Parsing sql file %s", file))
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read sql file %s: %w", file, err)
+		}
+		err = p.parse(string(contents))
+		if err != nil {
+			return nil, fmt.Errorf("sql file %s: %w", file, err)
+		}
+	}
+	return p.finish(), nil
+}
+
+func (p *sqlParser) parse(src string) error {
+	toks, err := tokenizeSql(src)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitSqlStatements(src, toks) {
+		err := p.parseStatement(stmt)
+		if err != nil {
+			return fmt.Errorf("could not parse statement '%s': %w", stmt.summary(), err)
+		}
+	}
+	return nil
+}
+
+// finish resolves the roles seen during parsing and returns the definition
+func (p *sqlParser) finish() *ir.Definition {
+	p.doc.Database.Roles = p.roles.resolveRoles()
+	for _, schema := range p.doc.Schemas {
+		schema.Owner = p.roles.get(schema.Owner)
+		for _, table := range schema.Tables {
+			if len(table.PrimaryKey) == 0 {
+				p.logger.Warn(fmt.Sprintf("primary key definition not found for %s.%s", schema.Name, table.Name))
+			}
+		}
+	}
+	return p.doc
+}
+
+func (p *sqlParser) parseStatement(s *sqlStmt) error {
+	switch {
+	case s.acceptWord("create"):
+		return p.parseCreate(s)
+	case s.acceptWord("alter"):
+		return p.parseAlter(s)
+	case s.acceptWord("grant"):
+		return p.parseGrant(s)
+	case s.acceptWord("comment", "on"):
+		return p.parseComment(s)
+	case s.acceptWord("set"):
+		return p.parseSet(s)
+	case s.peekWord("begin"), s.peekWord("commit"), s.peekWord("start"), s.peekWord("end"),
+		s.peekWord("revoke"), s.peekWord("select"), s.peekWord("refresh"):
+		p.logger.Debug(fmt.Sprintf("Ignoring statement: %s", s.summary()))
+		return nil
+	}
+	p.ignore(s)
+	return nil
+}
+
+func (p *sqlParser) ignore(s *sqlStmt) {
+	p.logger.Warn(fmt.Sprintf("Ignoring unsupported statement: %s", s.summary()))
+}
+
+// schema returns the named schema, creating it if it has not been seen yet
+func (p *sqlParser) schema(name string) *ir.Schema {
+	schema := p.doc.TryGetSchemaNamed(name)
+	if schema == nil {
+		schema = &ir.Schema{Name: name}
+		p.doc.AddSchema(schema)
+	}
+	return schema
+}
+
+// qualifiedName consumes a possibly schema-qualified object name
+func (p *sqlParser) qualifiedName(s *sqlStmt) (string, string, error) {
+	parts, err := s.nameParts()
+	if err != nil {
+		return "", "", err
+	}
+	switch len(parts) {
+	case 1:
+		return p.searchPath, parts[0], nil
+	case 2:
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("unexpected name %s", strings.Join(parts, "."))
+}
+
+func (p *sqlParser) table(schemaName, tableName string) (*ir.Schema, *ir.Table, error) {
+	schema := p.doc.TryGetSchemaNamed(schemaName)
+	if schema == nil {
+		return nil, nil, fmt.Errorf("schema %s has not been defined", schemaName)
+	}
+	table := schema.TryGetTableNamed(tableName)
+	if table == nil {
+		return nil, nil, fmt.Errorf("table %s.%s has not been defined", schemaName, tableName)
+	}
+	return schema, table, nil
+}
+
+func (p *sqlParser) parseCreate(s *sqlStmt) error {
+	s.acceptWord("or", "replace")
+	if s.acceptWord("temp") || s.acceptWord("temporary") {
+		p.logger.Warn(fmt.Sprintf("Ignoring temporary object: %s", s.summary()))
+		return nil
+	}
+	s.acceptWord("unlogged")
+	s.acceptWord("recursive")
+	switch {
+	case s.acceptWord("schema"):
+		return p.parseCreateSchema(s)
+	case s.acceptWord("table"):
+		return p.parseCreateTable(s)
+	case s.acceptWord("index"):
+		return p.parseCreateIndex(s, false)
+	case s.acceptWord("unique", "index"):
+		return p.parseCreateIndex(s, true)
+	case s.acceptWord("view"):
+		return p.parseCreateView(s, false)
+	case s.acceptWord("materialized", "view"):
+		return p.parseCreateView(s, true)
+	case s.acceptWord("function"):
+		return p.parseCreateFunction(s)
+	case s.acceptWord("sequence"):
+		return p.parseCreateSequence(s)
+	case s.acceptWord("type"):
+		return p.parseCreateType(s)
+	case s.acceptWord("domain"):
+		return p.parseCreateDomain(s)
+	case s.acceptWord("trigger"), s.acceptWord("constraint", "trigger"):
+		return p.parseCreateTrigger(s)
+	}
+	p.ignore(s)
+	return nil
+}
+
+func (p *sqlParser) parseCreateSchema(s *sqlStmt) error {
+	s.acceptWord("if", "not", "exists")
+	var name, owner string
+	var err error
+	if !s.peekWord("authorization") {
+		name, err = s.ident()
+		if err != nil {
+			return err
+		}
+	}
+	if s.acceptWord("authorization") {
+		owner, err = s.ident()
+		if err != nil {
+			return err
+		}
+		name = util.CoalesceStr(name, owner)
+	}
+	if !s.done() {
+		return fmt.Errorf("unsupported CREATE SCHEMA element '%s'", s.describeNext())
+	}
+	schema := p.schema(name)
+	if owner != "" {
+		p.roles.registerRole(roleContextOwner, owner)
+		schema.Owner = owner
+	}
+	return nil
+}
+
+func (p *sqlParser) parseCreateTable(s *sqlStmt) error {
+	s.acceptWord("if", "not", "exists")
+	schemaName, tableName, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	if s.acceptWord("partition", "of") {
+		return p.parseCreatePartition(s, schemaName, tableName)
+	}
+	if s.peekWord("of") {
+		p.ignore(s)
+		return nil
+	}
+	schema := p.schema(schemaName)
+	if schema.TryGetTableNamed(tableName) != nil {
+		return fmt.Errorf("table %s.%s is already defined", schemaName, tableName)
+	}
+	table := &ir.Table{Name: tableName}
+	schema.AddTable(table)
+
+	elems, err := s.parens()
+	if err != nil {
+		return err
+	}
+	for _, elem := range elems.split() {
+		if elem.done() {
+			continue
+		}
+		if elem.peekWord("like") {
+			return fmt.Errorf("CREATE TABLE ... LIKE is not supported")
+		}
+		if isSqlTableConstraint(elem) {
+			err = p.parseTableConstraint(schema, table, elem)
+		} else {
+			err = p.parseColumn(schema, table, elem)
+		}
+		if err != nil {
+			return fmt.Errorf("table %s.%s: %w", schemaName, tableName, err)
+		}
+	}
+
+	for !s.done() {
+		switch {
+		case s.acceptWord("inherits"):
+			parents, err := s.parens()
+			if err != nil {
+				return err
+			}
+			parentSchema, parentTable, err := p.qualifiedName(parents)
+			if err != nil {
+				return err
+			}
+			if !parents.done() {
+				// TODO(go,4) remove this restriction, same as extraction
+				return fmt.Errorf("table %s.%s inherits from more than one table", schemaName, tableName)
+			}
+			table.InheritsSchema = parentSchema
+			table.InheritsTable = parentTable
+		case s.acceptWord("with"):
+			opts, err := s.parens()
+			if err != nil {
+				return err
+			}
+			table.SetTableOption(ir.SqlFormatPgsql8, "with", "("+opts.String()+")")
+		case s.acceptWord("tablespace"):
+			tablespace, err := s.ident()
+			if err != nil {
+				return err
+			}
+			table.SetTableOption(ir.SqlFormatPgsql8, "tablespace", tablespace)
+		case s.acceptWord("partition", "by"):
+			typeTok := s.next()
+			partitionType, err := ir.NewTablePartitionType(strings.ToUpper(typeTok.value))
+			if err != nil || !partitionType.IsNative() {
+				return fmt.Errorf("unsupported partition type '%s'", typeTok.value)
+			}
+			key, err := s.parens()
+			if err != nil {
+				return err
+			}
+			table.Partitioning = &ir.TablePartition{
+				Type:      partitionType,
+				SqlFormat: ir.SqlFormatPgsql8,
+				Options:   []*ir.TablePartitionOption{{Name: "column", Value: key.String()}},
+			}
+		default:
+			return fmt.Errorf("unsupported table option '%s'", s.describeNext())
+		}
+	}
+	return nil
+}
+
+// parseCreatePartition records a CREATE TABLE ... PARTITION OF as a segment of its parent
+func (p *sqlParser) parseCreatePartition(s *sqlStmt, schemaName, tableName string) error {
+	parentSchema, parentTable, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	if parentSchema != schemaName {
+		return fmt.Errorf("partition %s.%s must be in the same schema as its parent %s.%s", schemaName, tableName, parentSchema, parentTable)
+	}
+	_, parent, err := p.table(parentSchema, parentTable)
+	if err != nil {
+		return err
+	}
+	if parent.Partitioning == nil || !parent.Partitioning.Type.IsNative() {
+		return fmt.Errorf("table %s.%s is not partitioned", parentSchema, parentTable)
+	}
+	if s.peek().isPunct("(") {
+		// partition-specific column constraints are not supported, they are inherited from the parent
+		if _, err := s.parens(); err != nil {
+			return err
+		}
+	}
+	bound := "DEFAULT"
+	if !s.acceptWord("default") {
+		if err := s.expectWord("for", "values"); err != nil {
+			return err
+		}
+		bound = s.rawUntil(stopAtWords("partition", "with", "tablespace"))
+	}
+	if !s.done() {
+		return fmt.Errorf("unsupported partition option '%s'", s.describeNext())
+	}
+	parent.Partitioning.Segments = append(parent.Partitioning.Segments, &ir.TablePartitionSegment{
+		Name:  tableName,
+		Value: bound,
+	})
+	return nil
+}
+
+func isSqlTableConstraint(s *sqlStmt) bool {
+	return s.peekWord("constraint") || s.peekWord("primary") || s.peekWord("unique") ||
+		s.peekWord("check") || s.peekWord("foreign") || s.peekWord("exclude")
+}
+
+// isSqlColumnConstraintStart reports whether a column constraint clause starts at the cursor
+func isSqlColumnConstraintStart(s *sqlStmt) bool {
+	return s.peekWord("not", "null") || s.peekWord("null") ||
+		stopAtWords("constraint", "default", "primary", "unique", "check", "references", "collate", "generated", "deferrable", "initially")(s)
+}
+
+func (p *sqlParser) parseColumn(schema *ir.Schema, table *ir.Table, s *sqlStmt) error {
+	name, err := s.ident()
+	if err != nil {
+		return err
+	}
+	if table.TryGetColumnNamed(name) != nil {
+		return fmt.Errorf("column %s is already defined", name)
+	}
+	column := &ir.Column{
+		Name:     name,
+		Nullable: true,
+	}
+	if !isSqlColumnConstraintStart(s) {
+		column.Type = s.rawUntil(isSqlColumnConstraintStart)
+	}
+	table.AddColumn(column)
+	return p.parseColumnConstraints(schema, table, column, s)
+}
+
+func (p *sqlParser) parseColumnConstraints(schema *ir.Schema, table *ir.Table, column *ir.Column, s *sqlStmt) error {
+	for !s.done() {
+		constraintName := ""
+		if s.acceptWord("constraint") {
+			var err error
+			constraintName, err = s.ident()
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case s.acceptWord("not", "null"):
+			column.Nullable = false
+		case s.acceptWord("null"):
+			column.Nullable = true
+		case s.acceptWord("default"):
+			column.Default = s.rawUntil(isSqlColumnConstraintStart)
+		case s.acceptWord("primary", "key"):
+			table.PrimaryKey = []string{column.Name}
+			table.PrimaryKeyName = constraintName
+			column.Nullable = false
+		case s.acceptWord("unique"):
+			if constraintName == "" || constraintName == buildSecondaryKeyName(table.Name, column.Name) {
+				column.Unique = true
+			} else {
+				table.AddConstraint(&ir.Constraint{
+					Name:       constraintName,
+					Type:       ir.ConstraintTypeUnique,
+					Definition: fmt.Sprintf(`("%s")`, column.Name),
+				})
+			}
+		case s.acceptWord("check"):
+			check, err := s.parens()
+			if err != nil {
+				return err
+			}
+			column.Check = check.String()
+		case s.acceptWord("references"):
+			fk, err := p.parseReferences(s)
+			if err != nil {
+				return err
+			}
+			if len(fk.ForeignColumns) > 1 {
+				return fmt.Errorf("column %s references more than one foreign column", column.Name)
+			}
+			column.ForeignSchema = fk.ForeignSchema
+			column.ForeignTable = fk.ForeignTable
+			if len(fk.ForeignColumns) == 1 {
+				column.ForeignColumn = fk.ForeignColumns[0]
+			}
+			column.ForeignKeyName = constraintName
+			column.ForeignOnUpdate = fk.OnUpdate
+			column.ForeignOnDelete = fk.OnDelete
+			// dbsteward fk columns aren't supposed to specify a type, they get it from the referenced column
+			column.Type = ""
+		case s.acceptWord("generated"):
+			err := p.parseGenerated(column, s)
+			if err != nil {
+				return err
+			}
+		case s.acceptWord("collate"):
+			collation, err := s.nameParts()
+			if err != nil {
+				return err
+			}
+			column.Type += " COLLATE " + strings.Join(collation, ".")
+		case s.acceptWord("deferrable"), s.acceptWord("not", "deferrable"),
+			s.acceptWord("initially", "deferred"), s.acceptWord("initially", "immediate"):
+			p.logger.Warn(fmt.Sprintf("Ignoring constraint deferrability on %s.%s.%s", schema.Name, table.Name, column.Name))
+		default:
+			return fmt.Errorf("unsupported column constraint '%s' on column %s", s.describeNext(), column.Name)
+		}
+	}
+	return nil
+}
+
+// parseGenerated consumes the remainder of a GENERATED clause, either an identity or a
+// stored generated column
+func (p *sqlParser) parseGenerated(column *ir.Column, s *sqlStmt) error {
+	identity := columnEntry{AttrType: column.Type}
+	switch {
+	case s.acceptWord("always", "as", "identity"):
+		identity.Identity = "a"
+	case s.acceptWord("by", "default", "as", "identity"):
+		identity.Identity = "d"
+	case s.acceptWord("always", "as"):
+		expr, err := s.parens()
+		if err != nil {
+			return err
+		}
+		if err := s.expectWord("stored"); err != nil {
+			return err
+		}
+		column.GeneratedAs = expr.String()
+		return nil
+	default:
+		return fmt.Errorf("unsupported GENERATED clause '%s' on column %s", s.describeNext(), column.Name)
+	}
+	// NOT NULL is implied, but pg_dump spells it out anyway
+	column.Nullable = false
+	if s.peek().isPunct("(") {
+		options, err := s.parens()
+		if err != nil {
+			return err
+		}
+		if options.acceptWord("sequence", "name") {
+			// the sequence is an implementation detail of the identity
+			if _, err := options.nameParts(); err != nil {
+				return err
+			}
+		}
+		sequence := &ir.Sequence{Name: column.Name}
+		if err := p.parseSequenceOptions(sequence, options); err != nil {
+			return fmt.Errorf("identity column %s: %w", column.Name, err)
+		}
+		identity.IdentityStart = optToNullInt(sequence.Start)
+		identity.IdentityIncrement = optToNullInt(sequence.Increment)
+		identity.IdentityMin = optToNullInt(sequence.Min)
+		identity.IdentityMax = optToNullInt(sequence.Max)
+		identity.IdentityCache = optToNullInt(sequence.Cache)
+		identity.IdentityCycle = sequence.Cycle
+	}
+	column.Identity = identity.IdentityToIR()
+	return nil
+}
+
+func optToNullInt(opt util.Opt[int]) sql.NullInt64 {
+	n, ok := opt.Maybe()
+	return sql.NullInt64{Int64: int64(n), Valid: ok}
+}
+
+// parseReferences consumes the remainder of a REFERENCES clause
+func (p *sqlParser) parseReferences(s *sqlStmt) (*ir.ForeignKey, error) {
+	schemaName, tableName, err := p.qualifiedName(s)
+	if err != nil {
+		return nil, err
+	}
+	fk := &ir.ForeignKey{
+		ForeignSchema: schemaName,
+		ForeignTable:  tableName,
+	}
+	if s.peek().isPunct("(") {
+		fk.ForeignColumns, err = p.identList(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for !s.done() {
+		switch {
+		case s.acceptWord("match", "full"), s.acceptWord("match", "simple"), s.acceptWord("match", "partial"):
+		case s.acceptWord("on", "delete"):
+			fk.OnDelete, err = parseSqlForeignKeyAction(s)
+		case s.acceptWord("on", "update"):
+			fk.OnUpdate, err = parseSqlForeignKeyAction(s)
+		case s.acceptWord("deferrable"), s.acceptWord("not", "deferrable"),
+			s.acceptWord("initially", "deferred"), s.acceptWord("initially", "immediate"):
+			p.logger.Warn(fmt.Sprintf("Ignoring foreign key deferrability referencing %s.%s", schemaName, tableName))
+		case s.acceptWord("not", "valid"):
+		default:
+			return fk, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fk, nil
+}
+
+func parseSqlForeignKeyAction(s *sqlStmt) (ir.ForeignKeyAction, error) {
+	words := []string{}
+	for _, candidate := range [][]string{{"no", "action"}, {"restrict"}, {"cascade"}, {"set", "null"}, {"set", "default"}} {
+		if s.acceptWord(candidate...) {
+			words = candidate
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("unknown foreign key action '%s'", s.describeNext())
+	}
+	return ir.NewForeignKeyAction(strings.Join(words, "_"))
+}
+
+// identList consumes a parenthesized list of identifiers
+func (p *sqlParser) identList(s *sqlStmt) ([]string, error) {
+	list, err := s.parens()
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, item := range list.split() {
+		name, err := item.ident()
+		if err != nil {
+			return nil, err
+		}
+		if !item.done() {
+			return nil, fmt.Errorf("expected a column name but found '%s'", item.String())
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func (p *sqlParser) parseTableConstraint(schema *ir.Schema, table *ir.Table, s *sqlStmt) error {
+	name := ""
+	if s.acceptWord("constraint") {
+		var err error
+		name, err = s.ident()
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case s.acceptWord("primary", "key"):
+		cols, err := p.identList(s)
+		if err != nil {
+			return err
+		}
+		table.PrimaryKey = cols
+		table.PrimaryKeyName = name
+		for _, col := range cols {
+			if column := table.TryGetColumnNamed(col); column != nil {
+				column.Nullable = false
+			}
+		}
+	case s.acceptWord("unique"):
+		cols, err := p.identList(s)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = buildSecondaryKeyName(table.Name, cols[0])
+		}
+		table.AddConstraint(&ir.Constraint{
+			Name:       name,
+			Type:       ir.ConstraintTypeUnique,
+			Definition: fmt.Sprintf(`("%s")`, strings.Join(cols, `", "`)),
+		})
+	case s.acceptWord("check"):
+		check, err := s.parens()
+		if err != nil {
+			return err
+		}
+		table.AddConstraint(&ir.Constraint{
+			Name:       util.CoalesceStr(name, buildIndexName(table.Name, "", "check")),
+			Type:       ir.ConstraintTypeCheck,
+			Definition: check.String(),
+		})
+	case s.acceptWord("foreign", "key"):
+		cols, err := p.identList(s)
+		if err != nil {
+			return err
+		}
+		if err := s.expectWord("references"); err != nil {
+			return err
+		}
+		fk, err := p.parseReferences(s)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 1 {
+			// add inline on the column, same as extraction does
+			column := table.TryGetColumnNamed(cols[0])
+			if column == nil {
+				return fmt.Errorf("foreign key references unknown column %s", cols[0])
+			}
+			column.ForeignSchema = fk.ForeignSchema
+			column.ForeignTable = fk.ForeignTable
+			if len(fk.ForeignColumns) == 1 {
+				column.ForeignColumn = fk.ForeignColumns[0]
+			}
+			column.ForeignKeyName = name
+			column.ForeignOnUpdate = fk.OnUpdate
+			column.ForeignOnDelete = fk.OnDelete
+			column.Type = ""
+		} else {
+			fk.Columns = cols
+			fk.ConstraintName = util.CoalesceStr(name, buildForeignKeyName(table.Name, cols[0]))
+			table.AddForeignKey(fk)
+		}
+	default:
+		return fmt.Errorf("unsupported table constraint '%s'", s.describeNext())
+	}
+	for !s.done() {
+		if !(s.acceptWord("not", "valid") || s.acceptWord("no", "inherit") ||
+			s.acceptWord("deferrable") || s.acceptWord("not", "deferrable") ||
+			s.acceptWord("initially", "deferred") || s.acceptWord("initially", "immediate")) {
+			return fmt.Errorf("unsupported constraint option '%s'", s.describeNext())
+		}
+	}
+	return nil
+}
+
+func (p *sqlParser) parseCreateIndex(s *sqlStmt, unique bool) error {
+	index := &ir.Index{
+		Unique: unique,
+		Using:  ir.IndexTypeBtree,
+	}
+	index.Concurrently = s.acceptWord("concurrently")
+	s.acceptWord("if", "not", "exists")
+	if s.peekWord("on") {
+		return fmt.Errorf("indexes must be named")
+	}
+	var err error
+	index.Name, err = s.ident()
+	if err != nil {
+		return err
+	}
+	if err := s.expectWord("on"); err != nil {
+		return err
+	}
+	s.acceptWord("only")
+	schemaName, tableName, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	addIndex, err := p.indexTarget(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	if s.acceptWord("using") {
+		method, err := s.ident()
+		if err != nil {
+			return err
+		}
+		index.Using, err = parseSqlIndexType(method)
+		if err != nil {
+			return err
+		}
+	}
+	dims, err := s.parens()
+	if err != nil {
+		return err
+	}
+	for _, dim := range dims.split() {
+		if dim.peek().isName() && len(dim.toks) == 1 {
+			index.AddDimension(dim.peek().value)
+		} else {
+			index.AddDimension(dim.String())
+			index.Dimensions[len(index.Dimensions)-1].Sql = true
+		}
+	}
+	for !s.done() {
+		switch {
+		case s.acceptWord("where"):
+			index.AddCondition(ir.SqlFormatPgsql8, s.rest())
+		case s.acceptWord("include"), s.acceptWord("with"):
+			if _, err := s.parens(); err != nil {
+				return err
+			}
+			p.logger.Warn(fmt.Sprintf("Ignoring INCLUDE/WITH options on index %s", index.Name))
+		case s.acceptWord("tablespace"):
+			if _, err := s.ident(); err != nil {
+				return err
+			}
+			p.logger.Warn(fmt.Sprintf("Ignoring tablespace of index %s", index.Name))
+		default:
+			return fmt.Errorf("unsupported index option '%s'", s.describeNext())
+		}
+	}
+	addIndex(index)
+	return nil
+}
+
+// indexTarget finds the table or materialized view an index is being created on
+func (p *sqlParser) indexTarget(schemaName, name string) (func(*ir.Index), error) {
+	if view := p.doc.TryGetSchemaNamed(schemaName).TryGetViewNamed(name); view != nil && view.Materialized {
+		return view.AddIndex, nil
+	}
+	_, table, err := p.table(schemaName, name)
+	if err != nil {
+		return nil, err
+	}
+	return table.AddIndex, nil
+}
+
+func parseSqlIndexType(method string) (ir.IndexType, error) {
+	for _, t := range []ir.IndexType{ir.IndexTypeBtree, ir.IndexTypeHash, ir.IndexTypeGin, ir.IndexTypeGist} {
+		if t.Equals(ir.IndexType(method)) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported index method '%s'", method)
+}
+
+func (p *sqlParser) parseCreateView(s *sqlStmt, materialized bool) error {
+	if materialized {
+		s.acceptWord("if", "not", "exists")
+	}
+	schemaName, viewName, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	if s.peek().isPunct("(") {
+		if _, err := s.parens(); err != nil {
+			return err
+		}
+		p.logger.Warn(fmt.Sprintf("Ignoring column list of view %s.%s", schemaName, viewName))
+	}
+	if materialized && s.acceptWord("using") {
+		if _, err := s.ident(); err != nil {
+			return err
+		}
+		p.logger.Warn(fmt.Sprintf("Ignoring access method of materialized view %s.%s", schemaName, viewName))
+	}
+	if s.acceptWord("with") {
+		if _, err := s.parens(); err != nil {
+			return err
+		}
+		p.logger.Warn(fmt.Sprintf("Ignoring options of view %s.%s", schemaName, viewName))
+	}
+	if materialized && s.acceptWord("tablespace") {
+		if _, err := s.ident(); err != nil {
+			return err
+		}
+		p.logger.Warn(fmt.Sprintf("Ignoring tablespace of materialized view %s.%s", schemaName, viewName))
+	}
+	if err := s.expectWord("as"); err != nil {
+		return err
+	}
+	schema := p.schema(schemaName)
+	if schema.TryGetViewNamed(viewName) != nil {
+		return fmt.Errorf("view %s.%s is already defined", schemaName, viewName)
+	}
+	var query string
+	if materialized {
+		query = s.rawUntil(func(s *sqlStmt) bool {
+			return s.peekWord("with", "data") || s.peekWord("with", "no", "data")
+		})
+		// pg_dump creates materialized views WITH NO DATA and refreshes them later, but we always populate them
+		if !s.acceptWord("with", "data") {
+			s.acceptWord("with", "no", "data")
+		}
+		if !s.done() {
+			return fmt.Errorf("unexpected '%s'", s.describeNext())
+		}
+	} else {
+		query = s.rest()
+	}
+	schema.AddView(&ir.View{
+		Name:         viewName,
+		Materialized: materialized,
+		Queries: []*ir.ViewQuery{
+			{
+				SqlFormat: ir.SqlFormatPgsql8,
+				Text:      strings.TrimSpace(query),
+			},
+		},
+	})
+	return nil
+}
+
+// sqlFunctionOptions are the keywords which may follow RETURNS in CREATE FUNCTION
+var sqlFunctionOptions = []string{
+	"language", "as", "immutable", "stable", "volatile", "security", "external", "strict",
+	"called", "returns", "cost", "rows", "set", "window", "leakproof", "not", "parallel", "support", "transform",
+}
+
+func (p *sqlParser) parseCreateFunction(s *sqlStmt) error {
+	schemaName, name, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	function := &ir.Function{Name: name}
+	params, err := s.parens()
+	if err != nil {
+		return err
+	}
+	hasOutParams := false
+	for _, param := range params.split() {
+		if param.done() {
+			continue
+		}
+		direction := ""
+		for _, dir := range []string{"inout", "in", "out", "variadic"} {
+			if param.acceptWord(dir) {
+				direction = dir
+				break
+			}
+		}
+		if direction == "variadic" {
+			return fmt.Errorf("VARIADIC parameters are not supported")
+		}
+		paramName := ""
+		if second := param.peekAt(1); param.peek().isName() && second.isName() &&
+			!util.IStrsContains([]string{"precision", "varying", "with", "without"}, second.value) {
+			paramName = param.next().value
+		}
+		paramType := param.rawUntil(func(s *sqlStmt) bool {
+			return s.peekWord("default") || s.peek().isPunct("=")
+		})
+		dir, err := ir.NewFuncParamDir(direction)
+		if err != nil {
+			return err
+		}
+		hasOutParams = hasOutParams || dir != ir.FuncParamDirIn
+		function.AddParameter(paramName, paramType, dir)
+	}
+
+	definition := &ir.FunctionDefinition{SqlFormat: ir.SqlFormatPgsql8}
+	if s.acceptWord("returns") {
+		function.Returns = s.rawUntil(stopAtWords(sqlFunctionOptions...))
+	} else if hasOutParams {
+		function.Returns = "record"
+	} else {
+		function.Returns = "void"
+	}
+	for !s.done() {
+		switch {
+		case s.acceptWord("language"):
+			lang, err := s.ident()
+			if err != nil {
+				return err
+			}
+			definition.Language = lang
+		case s.acceptWord("as"):
+			body := s.next()
+			if body.kind != sqlTokenString {
+				return fmt.Errorf("expected function body but found '%s'", s.src[body.start:body.end])
+			}
+			if s.acceptPunct(",") {
+				s.next()
+			}
+			definition.Text = strings.TrimSpace(body.value)
+		case s.acceptWord("immutable"), s.acceptWord("stable"), s.acceptWord("volatile"):
+			function.CachePolicy = strings.ToUpper(s.toks[s.pos-1].value)
+		case s.acceptWord("security", "definer"), s.acceptWord("external", "security", "definer"):
+			function.SecurityDefiner = true
+		case s.acceptWord("security", "invoker"), s.acceptWord("external", "security", "invoker"):
+			function.SecurityDefiner = false
+		case s.acceptWord("strict"), s.acceptWord("called", "on", "null", "input"),
+			s.acceptWord("returns", "null", "on", "null", "input"), s.acceptWord("window"),
+			s.acceptWord("leakproof"), s.acceptWord("not", "leakproof"):
+			p.logger.Warn(fmt.Sprintf("Ignoring option '%s' of function %s.%s", s.toks[s.pos-1].value, schemaName, name))
+		case s.acceptWord("cost"), s.acceptWord("rows"), s.acceptWord("parallel"), s.acceptWord("support"):
+			s.next()
+		case s.acceptWord("set"):
+			s.rawUntil(stopAtWords(sqlFunctionOptions...))
+			p.logger.Warn(fmt.Sprintf("Ignoring SET option of function %s.%s", schemaName, name))
+		default:
+			return fmt.Errorf("unsupported function option '%s'", s.describeNext())
+		}
+	}
+	if strings.EqualFold(definition.Language, "c") || strings.EqualFold(definition.Language, "internal") {
+		p.logger.Warn(fmt.Sprintf("Ignoring native (%s) function %s.%s, this is not currently supported by DBSteward", definition.Language, schemaName, name))
+		return nil
+	}
+	function.Definitions = []*ir.FunctionDefinition{definition}
+	p.schema(schemaName).AddFunction(function)
+	return nil
+}
+
+// functionSignature consumes a function name and parenthesized argument type list,
+// as used by ALTER FUNCTION, GRANT ON FUNCTION and COMMENT ON FUNCTION
+func (p *sqlParser) functionSignature(s *sqlStmt) (*ir.Function, error) {
+	schemaName, name, err := p.qualifiedName(s)
+	if err != nil {
+		return nil, err
+	}
+	types := []string{}
+	if s.peek().isPunct("(") {
+		args, err := s.parens()
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range args.split() {
+			if arg.acceptWord("out") {
+				continue
+			}
+			if !arg.acceptWord("inout") {
+				arg.acceptWord("in")
+			}
+			if second := arg.peekAt(1); arg.peek().isName() && second.isName() &&
+				!util.IStrsContains([]string{"precision", "varying", "with", "without"}, second.value) {
+				arg.next()
+			}
+			if !arg.done() {
+				types = append(types, arg.rest())
+			}
+		}
+	}
+	schema := p.doc.TryGetSchemaNamed(schemaName)
+	if schema != nil {
+	outer:
+		for _, function := range schema.Functions {
+			if !strings.EqualFold(function.Name, name) {
+				continue
+			}
+			inTypes := []string{}
+			for _, param := range function.Parameters {
+				if param.Direction != ir.FuncParamDirOut {
+					inTypes = append(inTypes, param.Type)
+				}
+			}
+			if len(inTypes) != len(types) {
+				continue
+			}
+			for i, t := range types {
+				if !strings.EqualFold(t, inTypes[i]) {
+					continue outer
+				}
+			}
+			return function, nil
+		}
+	}
+	return nil, fmt.Errorf("function %s.%s(%s) has not been defined", schemaName, name, strings.Join(types, ", "))
+}
+
+func (p *sqlParser) parseCreateSequence(s *sqlStmt) error {
+	s.acceptWord("if", "not", "exists")
+	schemaName, name, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	schema := p.schema(schemaName)
+	if schema.TryGetSequenceNamed(name) != nil {
+		return fmt.Errorf("sequence %s.%s is already defined", schemaName, name)
+	}
+	sequence := &ir.Sequence{Name: name}
+	schema.AddSequence(sequence)
+	return p.parseSequenceOptions(sequence, s)
+}
+
+func (p *sqlParser) parseSequenceOptions(sequence *ir.Sequence, s *sqlStmt) error {
+	for !s.done() {
+		var err error
+		switch {
+		case s.acceptWord("as"):
+			_, err = s.ident()
+		case s.acceptWord("increment"):
+			s.acceptWord("by")
+			sequence.Increment, err = parseSqlInt(s)
+		case s.acceptWord("minvalue"):
+			sequence.Min, err = parseSqlInt(s)
+		case s.acceptWord("no", "minvalue"):
+			sequence.Min = util.None[int]()
+		case s.acceptWord("maxvalue"):
+			sequence.Max, err = parseSqlInt(s)
+		case s.acceptWord("no", "maxvalue"):
+			sequence.Max = util.None[int]()
+		case s.acceptWord("start"):
+			s.acceptWord("with")
+			sequence.Start, err = parseSqlInt(s)
+		case s.acceptWord("restart"):
+			if s.acceptWord("with") || s.peek().kind == sqlTokenNumber {
+				_, err = parseSqlInt(s)
+			}
+		case s.acceptWord("cache"):
+			sequence.Cache, err = parseSqlInt(s)
+		case s.acceptWord("cycle"):
+			sequence.Cycle = true
+		case s.acceptWord("no", "cycle"):
+			sequence.Cycle = false
+		case s.acceptWord("owned", "by", "none"):
+			sequence.OwnedBySchema = ""
+			sequence.OwnedByTable = ""
+			sequence.OwnedByColumn = ""
+		case s.acceptWord("owned", "by"):
+			var parts []string
+			parts, err = s.nameParts()
+			if err == nil {
+				switch len(parts) {
+				case 2:
+					sequence.OwnedBySchema, sequence.OwnedByTable, sequence.OwnedByColumn = p.searchPath, parts[0], parts[1]
+				case 3:
+					sequence.OwnedBySchema, sequence.OwnedByTable, sequence.OwnedByColumn = parts[0], parts[1], parts[2]
+				default:
+					err = fmt.Errorf("unexpected OWNED BY %s", strings.Join(parts, "."))
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported sequence option '%s'", s.describeNext())
+		}
+		if err != nil {
+			return fmt.Errorf("sequence %s: %w", sequence.Name, err)
+		}
+	}
+	return nil
+}
+
+func parseSqlInt(s *sqlStmt) (util.Opt[int], error) {
+	sign := ""
+	if s.acceptPunct("-") {
+		sign = "-"
+	}
+	tok := s.next()
+	if tok.kind != sqlTokenNumber {
+		return util.None[int](), fmt.Errorf("expected a number but found '%s'", s.src[tok.start:tok.end])
+	}
+	i, err := strconv.Atoi(sign + tok.value)
+	if err != nil {
+		return util.None[int](), err
+	}
+	return util.Some(i), nil
+}
+
+func (p *sqlParser) parseCreateType(s *sqlStmt) error {
+	schemaName, name, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	datatype := &ir.TypeDef{Name: name}
+	switch {
+	case s.acceptWord("as", "enum"):
+		datatype.Kind = ir.DataTypeKindEnum
+		values, err := s.parens()
+		if err != nil {
+			return err
+		}
+		for _, value := range values.split() {
+			tok := value.next()
+			if tok.kind != sqlTokenString || !value.done() {
+				return fmt.Errorf("expected enum value but found '%s'", value.String())
+			}
+			datatype.EnumValues = append(datatype.EnumValues, ir.DataTypeEnumValue{Name: tok.value})
+		}
+	case s.acceptWord("as"):
+		datatype.Kind = ir.DataTypeKindComposite
+		fields, err := s.parens()
+		if err != nil {
+			return err
+		}
+		for _, field := range fields.split() {
+			fieldName, err := field.ident()
+			if err != nil {
+				return err
+			}
+			datatype.CompositeFields = append(datatype.CompositeFields, ir.DataTypeCompositeField{
+				Name: fieldName,
+				Type: field.rest(),
+			})
+		}
+	default:
+		p.ignore(s)
+		return nil
+	}
+	if !s.done() {
+		return fmt.Errorf("unexpected '%s' after type definition", s.describeNext())
+	}
+	schema := p.schema(schemaName)
+	if schema.TryGetTypeNamed(name) != nil {
+		return fmt.Errorf("type %s.%s is already defined", schemaName, name)
+	}
+	schema.AddType(datatype)
+	return nil
+}
+
+func (p *sqlParser) parseCreateDomain(s *sqlStmt) error {
+	schemaName, name, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	s.acceptWord("as")
+	domainStop := func(s *sqlStmt) bool {
+		return s.peekWord("not", "null") || s.peekWord("null") || stopAtWords("constraint", "default", "check", "collate")(s)
+	}
+	if domainStop(s) {
+		return fmt.Errorf("domain %s.%s has no base type", schemaName, name)
+	}
+	datatype := &ir.TypeDef{
+		Name: name,
+		Kind: ir.DataTypeKindDomain,
+		DomainType: &ir.DataTypeDomainType{
+			BaseType: s.rawUntil(domainStop),
+			Nullable: true,
+		},
+	}
+	for !s.done() {
+		constraintName := ""
+		if s.acceptWord("constraint") {
+			constraintName, err = s.ident()
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case s.acceptWord("not", "null"):
+			datatype.DomainType.Nullable = false
+		case s.acceptWord("null"):
+			datatype.DomainType.Nullable = true
+		case s.acceptWord("default"):
+			start := s.pos
+			def := s.rawUntil(domainStop)
+			if s.pos == start+1 && s.toks[start].kind == sqlTokenString {
+				// domain defaults are stored as values, not expressions
+				def = s.toks[start].value
+			}
+			datatype.DomainType.Default = def
+		case s.acceptWord("check"):
+			check, err := s.parens()
+			if err != nil {
+				return err
+			}
+			datatype.DomainConstraints = append(datatype.DomainConstraints, ir.DataTypeDomainConstraint{
+				Name:  util.CoalesceStr(constraintName, name+"_check"),
+				Check: check.String(),
+			})
+		case s.acceptWord("collate"):
+			if _, err := s.nameParts(); err != nil {
+				return err
+			}
+			p.logger.Warn(fmt.Sprintf("Ignoring collation of domain %s.%s", schemaName, name))
+		default:
+			return fmt.Errorf("unsupported domain option '%s'", s.describeNext())
+		}
+	}
+	schema := p.schema(schemaName)
+	if schema.TryGetTypeNamed(name) != nil {
+		return fmt.Errorf("type %s.%s is already defined", schemaName, name)
+	}
+	schema.AddType(datatype)
+	return nil
+}
+
+func (p *sqlParser) parseCreateTrigger(s *sqlStmt) error {
+	name, err := s.ident()
+	if err != nil {
+		return err
+	}
+	trigger := &ir.Trigger{
+		Name:      name,
+		ForEach:   ir.TriggerForEachStatement,
+		SqlFormat: ir.SqlFormatPgsql8,
+	}
+	switch {
+	case s.acceptWord("before"):
+		trigger.Timing = ir.TriggerTimingBefore
+	case s.acceptWord("after"):
+		trigger.Timing = ir.TriggerTimingAfter
+	case s.acceptWord("instead", "of"):
+		trigger.Timing = ir.TriggerTimingInsteadOf
+	default:
+		return fmt.Errorf("unknown trigger timing '%s'", s.describeNext())
+	}
+	for {
+		event, err := s.ident()
+		if err != nil {
+			return err
+		}
+		trigger.AddEvent(strings.ToUpper(event))
+		if s.acceptWord("of") {
+			s.rawUntil(stopAtWords("or", "on"))
+			p.logger.Warn(fmt.Sprintf("Ignoring UPDATE OF column list of trigger %s", name))
+		}
+		if !s.acceptWord("or") {
+			break
+		}
+	}
+	if err := s.expectWord("on"); err != nil {
+		return err
+	}
+	schemaName, tableName, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	schema, _, err := p.table(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	trigger.Table = tableName
+	for !s.done() {
+		switch {
+		case s.acceptWord("for"):
+			s.acceptWord("each")
+			forEach, err := s.ident()
+			if err != nil {
+				return err
+			}
+			trigger.ForEach, err = ir.NewTriggerForEach(forEach)
+			if err != nil {
+				return err
+			}
+		case s.acceptWord("when"):
+			if _, err := s.parens(); err != nil {
+				return err
+			}
+			// TODO(feat) capture the WHEN clause, see ir.Trigger
+			p.logger.Warn(fmt.Sprintf("Ignoring WHEN clause of trigger %s", name))
+		case s.acceptWord("execute", "procedure"), s.acceptWord("execute", "function"):
+			trigger.Function = strings.TrimSpace(s.rest())
+		case s.acceptWord("from"), s.acceptWord("referencing"):
+			return fmt.Errorf("unsupported trigger clause '%s'", strings.ToUpper(s.toks[s.pos-1].value))
+		case s.acceptWord("deferrable"), s.acceptWord("not", "deferrable"),
+			s.acceptWord("initially", "deferred"), s.acceptWord("initially", "immediate"):
+		default:
+			return fmt.Errorf("unsupported trigger clause '%s'", s.describeNext())
+		}
+	}
+	schema.AddTrigger(trigger)
+	return nil
+}
+
+func (p *sqlParser) parseAlter(s *sqlStmt) error {
+	switch {
+	case s.acceptWord("table"):
+		return p.parseAlterTable(s)
+	case s.acceptWord("sequence"):
+		s.acceptWord("if", "exists")
+		schemaName, name, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		sequence := p.schema(schemaName).TryGetSequenceNamed(name)
+		if sequence == nil {
+			return fmt.Errorf("sequence %s.%s has not been defined", schemaName, name)
+		}
+		if s.acceptWord("owner", "to") {
+			return p.setOwner(s, &sequence.Owner)
+		}
+		return p.parseSequenceOptions(sequence, s)
+	case s.acceptWord("view"), s.acceptWord("materialized", "view"):
+		s.acceptWord("if", "exists")
+		schemaName, name, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		view := p.schema(schemaName).TryGetViewNamed(name)
+		if view == nil {
+			return fmt.Errorf("view %s.%s has not been defined", schemaName, name)
+		}
+		if s.acceptWord("owner", "to") {
+			return p.setOwner(s, &view.Owner)
+		}
+	case s.acceptWord("function"):
+		function, err := p.functionSignature(s)
+		if err != nil {
+			return err
+		}
+		if s.acceptWord("owner", "to") {
+			return p.setOwner(s, &function.Owner)
+		}
+	case s.acceptWord("schema"):
+		name, err := s.ident()
+		if err != nil {
+			return err
+		}
+		if s.acceptWord("owner", "to") {
+			return p.setOwner(s, &p.schema(name).Owner)
+		}
+	}
+	p.ignore(s)
+	return nil
+}
+
+func (p *sqlParser) setOwner(s *sqlStmt, owner *string) error {
+	role, err := s.ident()
+	if err != nil {
+		return err
+	}
+	p.roles.registerRole(roleContextOwner, role)
+	*owner = role
+	return nil
+}
+
+func (p *sqlParser) parseAlterTable(s *sqlStmt) error {
+	s.acceptWord("if", "exists")
+	s.acceptWord("only")
+	schemaName, tableName, err := p.qualifiedName(s)
+	if err != nil {
+		return err
+	}
+	schema := p.schema(schemaName)
+	if s.peekWord("owner", "to") && schema.TryGetTableNamed(tableName) == nil {
+		// pg_dump uses ALTER TABLE to change ownership of views and sequences too
+		s.acceptWord("owner", "to")
+		if view := schema.TryGetViewNamed(tableName); view != nil {
+			return p.setOwner(s, &view.Owner)
+		}
+		if sequence := schema.TryGetSequenceNamed(tableName); sequence != nil {
+			return p.setOwner(s, &sequence.Owner)
+		}
+		return fmt.Errorf("relation %s.%s has not been defined", schemaName, tableName)
+	}
+	_, table, err := p.table(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	for _, action := range s.split() {
+		err := p.parseAlterTableAction(schema, table, action)
+		if err != nil {
+			return fmt.Errorf("table %s.%s: %w", schemaName, tableName, err)
+		}
+	}
+	return nil
+}
+
+func (p *sqlParser) parseAlterTableAction(schema *ir.Schema, table *ir.Table, s *sqlStmt) error {
+	switch {
+	case s.acceptWord("add"):
+		if isSqlTableConstraint(s) {
+			return p.parseTableConstraint(schema, table, s)
+		}
+		s.acceptWord("column")
+		s.acceptWord("if", "not", "exists")
+		return p.parseColumn(schema, table, s)
+	case s.acceptWord("alter"):
+		s.acceptWord("column")
+		name, err := s.ident()
+		if err != nil {
+			return err
+		}
+		column := table.TryGetColumnNamed(name)
+		if column == nil {
+			return fmt.Errorf("column %s has not been defined", name)
+		}
+		switch {
+		case s.acceptWord("set", "default"):
+			column.Default = s.rest()
+		case s.acceptWord("drop", "default"):
+			column.Default = ""
+		case s.acceptWord("set", "not", "null"):
+			column.Nullable = false
+		case s.acceptWord("drop", "not", "null"):
+			column.Nullable = true
+		case s.acceptWord("set", "statistics"):
+			statistics, err := parseSqlInt(s)
+			if err != nil {
+				return err
+			}
+			n := statistics.Get()
+			column.Statistics = &n
+		case s.acceptWord("add", "generated"):
+			// pg_dump adds identities separately from the column, naming the sequence behind it
+			return p.parseGenerated(column, s)
+		default:
+			return fmt.Errorf("unsupported ALTER COLUMN action '%s'", s.String())
+		}
+	case s.acceptWord("owner", "to"):
+		return p.setOwner(s, &table.Owner)
+	case s.acceptWord("cluster", "on"):
+		index, err := s.ident()
+		if err != nil {
+			return err
+		}
+		table.ClusterIndex = index
+	case s.acceptWord("inherit"):
+		parentSchema, parentTable, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		table.InheritsSchema = parentSchema
+		table.InheritsTable = parentTable
+	default:
+		p.ignore(s)
+		return nil
+	}
+	if !s.done() {
+		return fmt.Errorf("unexpected '%s'", s.describeNext())
+	}
+	return nil
+}
+
+// sqlUnsupportedGrantObjects are GRANT ... ON object types which have no representation in the IR
+var sqlUnsupportedGrantObjects = []string{
+	"all", "database", "domain", "foreign", "language", "large", "parameter", "tablespace", "type",
+}
+
+func (p *sqlParser) parseGrant(s *sqlStmt) error {
+	// GRANT role TO role has no ON clause
+	start := s.pos
+	s.rawUntil(stopAtWords("on"))
+	if s.done() {
+		p.logger.Debug(fmt.Sprintf("Ignoring role membership grant: %s", s.String()))
+		return nil
+	}
+	privileges := &sqlStmt{src: s.src, toks: s.toks[start:s.pos]}
+	s.next()
+
+	grant := &ir.Grant{}
+	for _, priv := range privileges.split() {
+		words := []string{}
+		for !priv.done() {
+			tok := priv.next()
+			if tok.kind != sqlTokenWord {
+				p.logger.Warn(fmt.Sprintf("Ignoring unsupported column-level grant: %s", s.String()))
+				return nil
+			}
+			if tok.value != "privileges" {
+				words = append(words, strings.ToUpper(tok.value))
+			}
+		}
+		grant.AddPermission(strings.Join(words, " "))
+	}
+
+	type grantTarget interface {
+		AddGrant(*ir.Grant)
+	}
+	targets := []grantTarget{}
+	switch {
+	case s.acceptWord("schema"):
+		for {
+			name, err := s.ident()
+			if err != nil {
+				return err
+			}
+			targets = append(targets, p.schema(name))
+			if !s.acceptPunct(",") {
+				break
+			}
+		}
+	case s.acceptWord("function"), s.acceptWord("procedure"), s.acceptWord("routine"):
+		for {
+			function, err := p.functionSignature(s)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, function)
+			if !s.acceptPunct(",") {
+				break
+			}
+		}
+	case s.acceptWord("sequence"):
+		for {
+			schemaName, name, err := p.qualifiedName(s)
+			if err != nil {
+				return err
+			}
+			sequence := p.schema(schemaName).TryGetSequenceNamed(name)
+			if sequence == nil {
+				return fmt.Errorf("sequence %s.%s has not been defined", schemaName, name)
+			}
+			targets = append(targets, sequence)
+			if !s.acceptPunct(",") {
+				break
+			}
+		}
+	case s.acceptWord("table") || !stopAtWords(sqlUnsupportedGrantObjects...)(s):
+		for {
+			schemaName, name, err := p.qualifiedName(s)
+			if err != nil {
+				return err
+			}
+			relation := p.schema(schemaName).TryGetRelationNamed(name)
+			if relation == nil {
+				// postgres allows GRANT ... ON TABLE for sequences too
+				sequence := p.schema(schemaName).TryGetSequenceNamed(name)
+				if sequence == nil {
+					return fmt.Errorf("relation %s.%s has not been defined", schemaName, name)
+				}
+				targets = append(targets, sequence)
+			} else {
+				targets = append(targets, relation)
+			}
+			if !s.acceptPunct(",") {
+				break
+			}
+		}
+	default:
+		p.ignore(s)
+		return nil
+	}
+
+	if err := s.expectWord("to"); err != nil {
+		return err
+	}
+	for {
+		s.acceptWord("group")
+		role, err := s.ident()
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(role, ir.RolePublic) {
+			role = ir.RolePublic
+		} else {
+			p.roles.registerRole(roleContextGrant, role)
+		}
+		grant.Roles = append(grant.Roles, role)
+		if !s.acceptPunct(",") {
+			break
+		}
+	}
+	if s.acceptWord("with", "grant", "option") {
+		grant.SetCanGrant(true)
+	}
+	if s.acceptWord("granted", "by") {
+		if _, err := s.ident(); err != nil {
+			return err
+		}
+	}
+	if !s.done() {
+		return fmt.Errorf("unexpected '%s'", s.describeNext())
+	}
+
+	for _, target := range targets {
+		target.AddGrant(&ir.Grant{
+			Roles:       grant.Roles,
+			Permissions: grant.Permissions,
+			With:        grant.With,
+		})
+	}
+	return nil
+}
+
+func (p *sqlParser) parseComment(s *sqlStmt) error {
+	var description *string
+	setDescription := func() error {
+		if err := s.expectWord("is"); err != nil {
+			return err
+		}
+		tok := s.next()
+		switch {
+		case tok.isWord("null"):
+			*description = ""
+		case tok.kind == sqlTokenString:
+			*description = tok.value
+		default:
+			return fmt.Errorf("expected comment text but found '%s'", s.src[tok.start:tok.end])
+		}
+		return nil
+	}
+	switch {
+	case s.acceptWord("schema"):
+		name, err := s.ident()
+		if err != nil {
+			return err
+		}
+		description = &p.schema(name).Description
+	case s.acceptWord("table"):
+		schemaName, name, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		_, table, err := p.table(schemaName, name)
+		if err != nil {
+			return err
+		}
+		description = &table.Description
+	case s.acceptWord("view"), s.acceptWord("materialized", "view"):
+		schemaName, name, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		view := p.schema(schemaName).TryGetViewNamed(name)
+		if view == nil {
+			return fmt.Errorf("view %s.%s has not been defined", schemaName, name)
+		}
+		description = &view.Description
+	case s.acceptWord("sequence"):
+		schemaName, name, err := p.qualifiedName(s)
+		if err != nil {
+			return err
+		}
+		sequence := p.schema(schemaName).TryGetSequenceNamed(name)
+		if sequence == nil {
+			return fmt.Errorf("sequence %s.%s has not been defined", schemaName, name)
+		}
+		description = &sequence.Description
+	case s.acceptWord("function"):
+		function, err := p.functionSignature(s)
+		if err != nil {
+			return err
+		}
+		description = &function.Description
+	case s.acceptWord("column"):
+		parts, err := s.nameParts()
+		if err != nil {
+			return err
+		}
+		if len(parts) == 2 {
+			parts = append([]string{p.searchPath}, parts...)
+		}
+		if len(parts) != 3 {
+			return fmt.Errorf("unexpected column name %s", strings.Join(parts, "."))
+		}
+		_, table, err := p.table(parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+		column := table.TryGetColumnNamed(parts[2])
+		if column == nil {
+			return fmt.Errorf("column %s has not been defined", strings.Join(parts, "."))
+		}
+		description = &column.Description
+	default:
+		p.ignore(s)
+		return nil
+	}
+	return setDescription()
+}
+
+func (p *sqlParser) parseSet(s *sqlStmt) error {
+	s.acceptWord("session")
+	s.acceptWord("local")
+	if !s.acceptWord("search_path") {
+		p.logger.Debug(fmt.Sprintf("Ignoring statement: SET %s", s.rest()))
+		return nil
+	}
+	if !s.acceptWord("to") && !s.acceptPunct("=") {
+		return fmt.Errorf("expected TO or = but found '%s'", s.describeNext())
+	}
+	first := s.next()
+	if first.isName() || (first.kind == sqlTokenString && first.value != "") {
+		p.searchPath = first.value
+	}
+	return nil
+}
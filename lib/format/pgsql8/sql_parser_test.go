@@ -0,0 +1,414 @@
+package pgsql8
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a trimmed down example of pg_dump --schema-only output
+const sqlParserDump = `
+--
+-- PostgreSQL database dump
+--
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+\connect app
+
+CREATE SCHEMA app;
+ALTER SCHEMA app OWNER TO app_owner;
+COMMENT ON SCHEMA app IS 'application schema';
+
+CREATE TYPE app.status AS ENUM ('new', 'it''s done');
+CREATE DOMAIN app.pct AS numeric(5,2) DEFAULT '0' NOT NULL CONSTRAINT pct_range CHECK (VALUE >= 0 AND VALUE <= 100);
+
+CREATE FUNCTION app.touch(p_id integer, OUT touched timestamp with time zone) RETURNS timestamp with time zone
+    LANGUAGE plpgsql STABLE SECURITY DEFINER
+    AS $_$
+BEGIN
+  RETURN now(); -- not a statement terminator
+END;
+$_$;
+ALTER FUNCTION app.touch(p_id integer, OUT touched timestamp with time zone) OWNER TO app_owner;
+
+CREATE TABLE app.users (
+    user_id integer NOT NULL,
+    "Name" character varying(100) DEFAULT 'anon'::character varying,
+    status app.status DEFAULT 'new'::app.status NOT NULL,
+    score numeric(5,2) CHECK (score > (0)::numeric),
+    email text UNIQUE
+);
+ALTER TABLE app.users OWNER TO app_owner;
+COMMENT ON COLUMN app.users.user_id IS 'the user';
+
+CREATE SEQUENCE app.users_user_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+ALTER TABLE app.users_user_id_seq OWNER TO app_owner;
+ALTER SEQUENCE app.users_user_id_seq OWNED BY app.users.user_id;
+
+CREATE TABLE app.logins (
+    user_id integer NOT NULL,
+    seq integer NOT NULL,
+    at timestamp without time zone,
+    CONSTRAINT logins_seq_positive CHECK ((seq > 0))
+);
+
+CREATE VIEW app.active_users AS
+ SELECT users.user_id
+   FROM app.users
+  WHERE (users.status = 'new'::app.status);
+
+ALTER TABLE ONLY app.users ALTER COLUMN user_id SET DEFAULT nextval('app.users_user_id_seq'::regclass);
+ALTER TABLE ONLY app.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (user_id);
+ALTER TABLE ONLY app.logins
+    ADD CONSTRAINT logins_pkey PRIMARY KEY (user_id, seq);
+CREATE INDEX logins_at_idx ON app.logins USING btree (at) WHERE (at IS NOT NULL);
+CREATE UNIQUE INDEX users_lower_name_idx ON app.users USING btree (lower(("Name")::text));
+CREATE TRIGGER users_touch BEFORE INSERT OR UPDATE ON app.users FOR EACH ROW EXECUTE FUNCTION app.touch();
+ALTER TABLE ONLY app.logins
+    ADD CONSTRAINT logins_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users(user_id) ON DELETE CASCADE;
+
+REVOKE ALL ON SCHEMA app FROM PUBLIC;
+GRANT USAGE ON SCHEMA app TO app_user;
+GRANT SELECT,INSERT ON TABLE app.users TO app_user WITH GRANT OPTION;
+GRANT SELECT ON TABLE app.active_users TO app_ro;
+GRANT USAGE ON SEQUENCE app.users_user_id_seq TO app_user;
+GRANT EXECUTE ON FUNCTION app.touch(p_id integer) TO app_user;
+`
+
+func TestSqlParser_PgDump(t *testing.T) {
+	p := newSqlParser(slog.Default())
+	require.NoError(t, p.parse(sqlParserDump))
+	doc := p.finish()
+
+	require.Len(t, doc.Schemas, 1)
+	schema := doc.Schemas[0]
+	assert.Equal(t, "app", schema.Name)
+	assert.Equal(t, "app_owner", schema.Owner)
+	assert.Equal(t, "application schema", schema.Description)
+	assert.Equal(t, []*ir.Grant{{Roles: []string{"app_user"}, Permissions: []string{"USAGE"}}}, schema.Grants)
+
+	assert.Equal(t, &ir.RoleAssignment{
+		Owner:       "app_owner",
+		Application: "app_user",
+		ReadOnly:    "app_ro",
+	}, doc.Database.Roles)
+
+	assert.Equal(t, []*ir.TypeDef{
+		{
+			Name:       "status",
+			Kind:       ir.DataTypeKindEnum,
+			EnumValues: []ir.DataTypeEnumValue{{Name: "new"}, {Name: "it's done"}},
+		},
+		{
+			Name: "pct",
+			Kind: ir.DataTypeKindDomain,
+			DomainType: &ir.DataTypeDomainType{
+				BaseType: "numeric(5,2)",
+				Default:  "0",
+				Nullable: false,
+			},
+			DomainConstraints: []ir.DataTypeDomainConstraint{
+				{Name: "pct_range", Check: "VALUE >= 0 AND VALUE <= 100"},
+			},
+		},
+	}, schema.Types)
+
+	require.Len(t, schema.Functions, 1)
+	fn := schema.Functions[0]
+	assert.Equal(t, "touch", fn.Name)
+	assert.Equal(t, "app_owner", fn.Owner)
+	assert.Equal(t, "timestamp with time zone", fn.Returns)
+	assert.Equal(t, "STABLE", fn.CachePolicy)
+	assert.True(t, fn.SecurityDefiner)
+	assert.Equal(t, []*ir.FunctionParameter{
+		{Name: "p_id", Type: "integer", Direction: ir.FuncParamDirIn},
+		{Name: "touched", Type: "timestamp with time zone", Direction: ir.FuncParamDirOut},
+	}, fn.Parameters)
+	require.Len(t, fn.Definitions, 1)
+	assert.Equal(t, "plpgsql", fn.Definitions[0].Language)
+	assert.Equal(t, "BEGIN\n  RETURN now(); -- not a statement terminator\nEND;", fn.Definitions[0].Text)
+	assert.Equal(t, []*ir.Grant{{Roles: []string{"app_user"}, Permissions: []string{"EXECUTE"}}}, fn.Grants)
+
+	users := schema.TryGetTableNamed("users")
+	require.NotNil(t, users)
+	assert.Equal(t, "app_owner", users.Owner)
+	assert.Equal(t, []string{"user_id"}, users.PrimaryKey)
+	assert.Equal(t, "users_pkey", users.PrimaryKeyName)
+	assert.Equal(t, []*ir.Column{
+		{Name: "user_id", Type: "integer", Description: "the user", Default: "nextval('app.users_user_id_seq'::regclass)"},
+		{Name: "Name", Type: "character varying(100)", Nullable: true, Default: "'anon'::character varying"},
+		{Name: "status", Type: "app.status", Default: "'new'::app.status"},
+		{Name: "score", Type: "numeric(5,2)", Nullable: true, Check: "score > (0)::numeric"},
+		{Name: "email", Type: "text", Nullable: true, Unique: true},
+	}, users.Columns)
+	assert.Equal(t, []*ir.Index{
+		{
+			Name:       "users_lower_name_idx",
+			Using:      ir.IndexTypeBtree,
+			Unique:     true,
+			Dimensions: []*ir.IndexDim{{Name: "users_lower_name_idx_1", Sql: true, Value: `lower(("Name")::text)`}},
+		},
+	}, users.Indexes)
+	assert.Equal(t, []*ir.Grant{{Roles: []string{"app_user"}, Permissions: []string{"SELECT", "INSERT"}, With: ir.PermOptionGrant}}, users.Grants)
+
+	logins := schema.TryGetTableNamed("logins")
+	require.NotNil(t, logins)
+	assert.Equal(t, []string{"user_id", "seq"}, logins.PrimaryKey)
+	userID := logins.TryGetColumnNamed("user_id")
+	assert.Equal(t, "", userID.Type)
+	assert.Equal(t, "app", userID.ForeignSchema)
+	assert.Equal(t, "users", userID.ForeignTable)
+	assert.Equal(t, "user_id", userID.ForeignColumn)
+	assert.Equal(t, "logins_user_id_fkey", userID.ForeignKeyName)
+	assert.Equal(t, ir.ForeignKeyActionCascade, userID.ForeignOnDelete)
+	assert.Equal(t, []*ir.Constraint{
+		{Name: "logins_seq_positive", Type: ir.ConstraintTypeCheck, Definition: "(seq > 0)"},
+	}, logins.Constraints)
+	assert.Equal(t, []*ir.Index{
+		{
+			Name:       "logins_at_idx",
+			Using:      ir.IndexTypeBtree,
+			Dimensions: []*ir.IndexDim{{Name: "logins_at_idx_1", Value: "at"}},
+			Conditions: []*ir.IndexCond{{SqlFormat: ir.SqlFormatPgsql8, Condition: "(at IS NOT NULL)"}},
+		},
+	}, logins.Indexes)
+
+	assert.Equal(t, []*ir.Sequence{
+		{
+			Name:          "users_user_id_seq",
+			Owner:         "app_owner",
+			Start:         util.Some(1),
+			Increment:     util.Some(1),
+			Cache:         util.Some(1),
+			OwnedBySchema: "app",
+			OwnedByTable:  "users",
+			OwnedByColumn: "user_id",
+			Grants:        []*ir.Grant{{Roles: []string{"app_user"}, Permissions: []string{"USAGE"}}},
+		},
+	}, schema.Sequences)
+
+	require.Len(t, schema.Views, 1)
+	assert.Equal(t, "active_users", schema.Views[0].Name)
+	assert.Equal(t, "SELECT users.user_id\n   FROM app.users\n  WHERE (users.status = 'new'::app.status)", schema.Views[0].Queries[0].Text)
+	assert.Equal(t, []*ir.Grant{{Roles: []string{"app_ro"}, Permissions: []string{"SELECT"}}}, schema.Views[0].Grants)
+
+	assert.Equal(t, []*ir.Trigger{
+		{
+			Name:      "users_touch",
+			Table:     "users",
+			Events:    []string{"INSERT", "UPDATE"},
+			Timing:    ir.TriggerTimingBefore,
+			ForEach:   ir.TriggerForEachRow,
+			Function:  "app.touch()",
+			SqlFormat: ir.SqlFormatPgsql8,
+		},
+	}, schema.Triggers)
+}
+
+func TestSqlParser_SearchPath(t *testing.T) {
+	p := newSqlParser(slog.Default())
+	require.NoError(t, p.parse(`
+		CREATE TABLE t1 (id int PRIMARY KEY);
+		CREATE SCHEMA other;
+		SET search_path = other, public;
+		CREATE TABLE t2 (id int CONSTRAINT t2_pk PRIMARY KEY, t1_id int REFERENCES public.t1 (id));
+	`))
+	doc := p.finish()
+	assert.NotNil(t, doc.TryGetSchemaNamed("public").TryGetTableNamed("t1"))
+	t2 := doc.TryGetSchemaNamed("other").TryGetTableNamed("t2")
+	require.NotNil(t, t2)
+	assert.Equal(t, "t2_pk", t2.PrimaryKeyName)
+	assert.Equal(t, "public", t2.Columns[1].ForeignSchema)
+}
+
+func TestSqlParser_NativePartitions(t *testing.T) {
+	p := newSqlParser(slog.Default())
+	require.NoError(t, p.parse(`
+		CREATE TABLE events (id int, created_at date, PRIMARY KEY (id, created_at)) PARTITION BY RANGE (created_at);
+		CREATE TABLE events_2020 PARTITION OF events FOR VALUES FROM ('2020-01-01') TO ('2021-01-01');
+		CREATE TABLE events_other PARTITION OF events DEFAULT;
+	`))
+	schema := p.finish().TryGetSchemaNamed("public")
+	require.Len(t, schema.Tables, 1)
+	assert.Equal(t, &ir.TablePartition{
+		Type:      ir.TablePartitionTypeRange,
+		SqlFormat: ir.SqlFormatPgsql8,
+		Options:   []*ir.TablePartitionOption{{Name: "column", Value: "created_at"}},
+		Segments: []*ir.TablePartitionSegment{
+			{Name: "events_2020", Value: "FROM ('2020-01-01') TO ('2021-01-01')"},
+			{Name: "events_other", Value: "DEFAULT"},
+		},
+	}, schema.Tables[0].Partitioning)
+}
+
+func TestSqlParser_MaterializedViews(t *testing.T) {
+	p := newSqlParser(slog.Default())
+	require.NoError(t, p.parse(`
+		CREATE TABLE t (id int PRIMARY KEY, name text);
+		CREATE MATERIALIZED VIEW public.mv AS
+			SELECT id, name FROM t
+		WITH NO DATA;
+		ALTER MATERIALIZED VIEW public.mv OWNER TO bob;
+		COMMENT ON MATERIALIZED VIEW public.mv IS 'cached';
+		CREATE UNIQUE INDEX mv_id ON public.mv USING btree (id);
+		REFRESH MATERIALIZED VIEW public.mv;
+	`))
+	view := p.finish().TryGetSchemaNamed("public").TryGetViewNamed("mv")
+	require.NotNil(t, view)
+	assert.True(t, view.Materialized)
+	assert.Equal(t, "bob", view.Owner)
+	assert.Equal(t, "cached", view.Description)
+	assert.Equal(t, "SELECT id, name FROM t", view.Queries[0].Text)
+	require.Len(t, view.Indexes, 1)
+	assert.Equal(t, "mv_id", view.Indexes[0].Name)
+	assert.True(t, view.Indexes[0].Unique)
+}
+
+func TestSqlParser_GeneratedColumns(t *testing.T) {
+	p := newSqlParser(slog.Default())
+	require.NoError(t, p.parse(`
+		CREATE TABLE public.orders (
+			id integer NOT NULL,
+			number bigint GENERATED BY DEFAULT AS IDENTITY (START WITH 1000 INCREMENT BY 1 NO CYCLE),
+			price numeric NOT NULL,
+			qty integer NOT NULL,
+			total numeric GENERATED ALWAYS AS ((price * (qty)::numeric)) STORED
+		);
+		ALTER TABLE public.orders ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
+			SEQUENCE NAME public.orders_id_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1
+		);
+	`))
+	doc := p.finish()
+	orders := doc.TryGetSchemaNamed("public").TryGetTableNamed("orders")
+	require.NotNil(t, orders)
+
+	// the sequence options are all the defaults, so aren't kept
+	assert.Equal(t, &ir.ColumnIdentity{Generation: ir.IdentityGenerationAlways}, orders.TryGetColumnNamed("id").Identity)
+	start := 1000
+	number := orders.TryGetColumnNamed("number")
+	assert.Equal(t, &ir.ColumnIdentity{Generation: ir.IdentityGenerationByDefault, Start: &start}, number.Identity)
+	assert.False(t, number.Nullable)
+	total := orders.TryGetColumnNamed("total")
+	assert.Nil(t, total.Identity)
+	assert.Equal(t, "(price * (qty)::numeric)", total.GeneratedAs)
+	assert.Equal(t, "numeric", total.Type)
+
+	// the sequence behind the identity isn't one of the schema's own
+	assert.Empty(t, doc.TryGetSchemaNamed("public").Sequences)
+}
+
+func TestSqlParser_Errors(t *testing.T) {
+	tests := []struct {
+		name, sql, err string
+	}{
+		{"unterminated string", "CREATE TABLE t (a text DEFAULT 'oops);", "unterminated"},
+		{"unterminated dollar", "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1;", "unterminated dollar-quoted"},
+		{"unknown table", "ALTER TABLE missing OWNER TO bob;", "missing has not been defined"},
+		{"unknown index method", "CREATE TABLE t (a int); CREATE INDEX i ON t USING brin (a);", "unsupported index method 'brin'"},
+		{"virtual generated column", "CREATE TABLE t (a int, b int GENERATED ALWAYS AS (a * 2) VIRTUAL);", "expected"},
+		{"unpartitioned parent", "CREATE TABLE t (a int); CREATE TABLE t1 PARTITION OF t DEFAULT;", "is not partitioned"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newSqlParser(slog.Default()).parse(test.sql)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestDiffSql(t *testing.T) {
+	oldSql := `
+		CREATE TABLE public.widget (id integer NOT NULL, name text);
+		ALTER TABLE ONLY public.widget ADD CONSTRAINT widget_pkey PRIMARY KEY (id);
+	`
+	newSql := `
+		CREATE TABLE public.widget (id integer NOT NULL, name text NOT NULL, color text DEFAULT 'red');
+		ALTER TABLE ONLY public.widget ADD CONSTRAINT widget_pkey PRIMARY KEY (id);
+		CREATE INDEX widget_color_idx ON public.widget USING btree (color);
+	`
+	oldParser := newSqlParser(slog.Default())
+	require.NoError(t, oldParser.parse(oldSql))
+	newParser := newSqlParser(slog.Default())
+	require.NoError(t, newParser.parse(newSql))
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	stmts, err := ops.Upgrade(slog.Default(), oldParser.finish(), newParser.finish())
+	require.NoError(t, err)
+	all := []string{}
+	for _, stmt := range stmts {
+		all = append(all, stmt.Statement)
+	}
+	ddl := strings.Join(all, "\n")
+	assert.Contains(t, ddl, "ADD COLUMN color text")
+	assert.Contains(t, ddl, "CREATE INDEX widget_color_idx ON public.widget")
+	assert.Contains(t, ddl, "ALTER COLUMN name SET NOT NULL")
+	assert.NotContains(t, ddl, "CREATE TABLE")
+}
+
+func TestSqlDiff_Sequences(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	// the sequences as pg_dump writes them, with every option spelled out
+	oldFile := write("old.sql", `
+		CREATE SEQUENCE public.invoice_number_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE SEQUENCE public.ticket_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+	`)
+	newFile := write("new.sql", `
+		CREATE SEQUENCE public.invoice_number_seq
+			START WITH 1
+			INCREMENT BY 1
+			NO MINVALUE
+			NO MAXVALUE
+			CACHE 1;
+		CREATE SEQUENCE public.ticket_seq
+			START WITH 1
+			INCREMENT BY 10
+			NO MINVALUE
+			MAXVALUE 100000
+			CACHE 20;
+	`)
+
+	ops := NewOperations(DefaultConfig).(*Operations)
+	require.NoError(t, ops.SqlDiff([]string{oldFile}, []string{newFile}, filepath.Join(dir, "upgrade")))
+	stage1, err := os.ReadFile(filepath.Join(dir, "upgrade_stage1_schema1.sql"))
+	require.NoError(t, err)
+	assert.Contains(t, string(stage1), "ALTER SEQUENCE public.ticket_seq")
+	assert.Contains(t, string(stage1), "INCREMENT BY 10")
+	assert.Contains(t, string(stage1), "MAXVALUE 100000")
+	assert.Contains(t, string(stage1), "CACHE 20")
+	assert.NotContains(t, string(stage1), "invoice_number_seq")
+}
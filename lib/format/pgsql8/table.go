@@ -48,14 +48,32 @@ func getCreateTableSql(conf lib.Config, schema *ir.Schema, table *ir.Table) ([]o
 		}
 	}
 
+	var partitionBy *sql.TablePartitionBy
+	if table.Partitioning != nil && table.Partitioning.Type.IsNative() {
+		key, err := nativePartitionKey(schema, table)
+		if err != nil {
+			return nil, err
+		}
+		partitionBy = &sql.TablePartitionBy{
+			Type: string(table.Partitioning.Type),
+			Key:  key,
+		}
+	}
+
 	ddl := []output.ToSql{
 		&sql.TableCreate{
 			Table:        sql.TableRef{Schema: schema.Name, Table: table.Name},
 			Columns:      cols,
 			Inherits:     inherits,
+			PartitionBy:  partitionBy,
 			OtherOptions: opts,
 		},
 	}
+	if partitionBy != nil {
+		for _, segment := range table.Partitioning.Segments {
+			ddl = append(ddl, getCreatePartitionSql(schema, table, segment))
+		}
+	}
 
 	if table.Description != "" {
 		ddl = append(ddl, &sql.TableSetComment{
@@ -77,6 +95,14 @@ func getCreateTableSql(conf lib.Config, schema *ir.Schema, table *ir.Table) ([]o
 		})
 
 		// update the owner of all linked tables as well
+		if partitionBy != nil {
+			for _, segment := range table.Partitioning.Segments {
+				ddl = append(ddl, &sql.TableAlterOwner{
+					Table: sql.TableRef{Schema: schema.Name, Table: segment.Name},
+					Role:  role,
+				})
+			}
+		}
 		for _, col := range table.Columns {
 			// TODO(feat) more than just serials?
 			if isColumnSerialType(col) {
@@ -92,6 +118,14 @@ func getCreateTableSql(conf lib.Config, schema *ir.Schema, table *ir.Table) ([]o
 	return ddl, nil
 }
 
+func getCreatePartitionSql(schema *ir.Schema, table *ir.Table, segment *ir.TablePartitionSegment) output.ToSql {
+	return &sql.TableCreatePartitionOf{
+		Table:  sql.TableRef{Schema: schema.Name, Table: segment.Name},
+		Parent: sql.TableRef{Schema: schema.Name, Table: table.Name},
+		Bound:  segment.Value,
+	}
+}
+
 func getDropTableSql(schema *ir.Schema, table *ir.Table) []output.ToSql {
 	return []output.ToSql{
 		&sql.TableDrop{
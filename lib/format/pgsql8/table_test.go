@@ -64,3 +64,64 @@ func TestTable_GetCreationSql_TableOptions(t *testing.T) {
 		},
 	}, ddl)
 }
+
+func TestTable_GetCreationSql_NativePartition(t *testing.T) {
+	schema := &ir.Schema{
+		Name: "public",
+		Tables: []*ir.Table{
+			{
+				Name:       "test",
+				PrimaryKey: []string{"id", "region"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "int"},
+					{Name: "region", Type: "text"},
+				},
+				Partitioning: &ir.TablePartition{
+					Type: ir.TablePartitionTypeList,
+					Options: []*ir.TablePartitionOption{
+						{Name: "column", Value: "region"},
+					},
+					Segments: []*ir.TablePartitionSegment{
+						{Name: "test_us", Value: "IN ('us')"},
+						{Name: "test_other", Value: "DEFAULT"},
+					},
+				},
+			},
+		},
+	}
+
+	ddl, err := getCreateTableSql(DefaultConfig, schema, schema.Tables[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []output.ToSql{
+		&sql.TableCreate{
+			Table: sql.TableRef{Schema: "public", Table: "test"},
+			Columns: []sql.ColumnDefinition{
+				{Name: "id", Type: sql.TypeRef{Type: "int"}},
+				{Name: "region", Type: sql.TypeRef{Type: "text"}},
+			},
+			PartitionBy:  &sql.TablePartitionBy{Type: "LIST", Key: "region"},
+			OtherOptions: []sql.TableCreateOption{},
+		},
+		&sql.TableCreatePartitionOf{
+			Table:  sql.TableRef{Schema: "public", Table: "test_us"},
+			Parent: sql.TableRef{Schema: "public", Table: "test"},
+			Bound:  "IN ('us')",
+		},
+		&sql.TableCreatePartitionOf{
+			Table:  sql.TableRef{Schema: "public", Table: "test_other"},
+			Parent: sql.TableRef{Schema: "public", Table: "test"},
+			Bound:  "DEFAULT",
+		},
+	}, ddl)
+
+	q := defaultQuoter(DefaultConfig)
+	assert.Equal(t, "CREATE TABLE public.test(\n\tid int,\n\tregion text\n)\nPARTITION BY LIST (region);", ddl[0].ToSql(q))
+	assert.Equal(t, "CREATE TABLE public.test_us PARTITION OF public.test FOR VALUES IN ('us');", ddl[1].ToSql(q))
+	assert.Equal(t, "CREATE TABLE public.test_other PARTITION OF public.test DEFAULT;", ddl[2].ToSql(q))
+
+	schema.Tables[0].Partitioning.Options = nil
+	_, err = getCreateTableSql(DefaultConfig, schema, schema.Tables[0])
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package pgsql8
+
+import (
+	"fmt"
+
+	"github.com/dbsteward/dbsteward/lib"
+	"github.com/dbsteward/dbsteward/lib/ir"
+)
+
+// parseTargetVersion returns the postgres version configured on the command line, falling back to
+// the targetVersion of the <database> element of doc, or 0 if neither names one
+func parseTargetVersion(configured string, doc *ir.Definition) (VersionNum, error) {
+	if configured == "" && doc != nil && doc.Database != nil {
+		configured = doc.Database.TargetVersion
+	}
+	if configured == "" {
+		return 0, nil
+	}
+	v, err := ParseVersionNum(configured)
+	if err != nil {
+		return 0, fmt.Errorf("target version: %w", err)
+	}
+	return v, nil
+}
+
+// targetVersion returns the version the sql being generated for conf.NewDatabase is for, which
+// has already been validated by the time anything is generated
+func targetVersion(conf lib.Config) VersionNum {
+	v, _ := parseTargetVersion(conf.TargetVersion, conf.NewDatabase)
+	return v
+}
+
+// targetAtLeast reports whether newer syntax may be used. Without a target version, it may not.
+func targetAtLeast(conf lib.Config, feature func(VersionNum) bool) bool {
+	v := targetVersion(conf)
+	return v != 0 && feature(v)
+}
+
+// targetOlderThan reports whether syntax which is generated by default has to be avoided.
+// Without a target version, it doesn't, so output is the same as it always was.
+func targetOlderThan(conf lib.Config, feature func(VersionNum) bool) bool {
+	v := targetVersion(conf)
+	return v != 0 && !feature(v)
+}
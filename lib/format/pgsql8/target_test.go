@@ -0,0 +1,147 @@
+package pgsql8
+
+import (
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersionNum(t *testing.T) {
+	for spec, expected := range map[string]VersionNum{
+		"15":    NewVersionNum(15, 0),
+		"15.2":  NewVersionNum(15, 2),
+		"9.6":   NewVersionNum(9, 6),
+		"9.6.3": NewVersionNum(9, 6, 3),
+		" 12 ":  NewVersionNum(12, 0),
+	} {
+		v, err := ParseVersionNum(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, v, spec)
+	}
+	for _, spec := range []string{"", "fifteen", "9.x", "1.2.3.4", "-9", "7.4"} {
+		_, err := ParseVersionNum(spec)
+		assert.Error(t, err, spec)
+	}
+
+	doc := &ir.Definition{Database: &ir.Database{SqlFormat: ir.SqlFormatPgsql8, TargetVersion: "12"}}
+	v, err := parseTargetVersion("", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, NewVersionNum(12, 0), v)
+	v, err = parseTargetVersion("9.6", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, NewVersionNum(9, 6), v, "the command line wins over the definition")
+	v, err = parseTargetVersion("", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, VersionNum(0), v)
+	_, err = parseTargetVersion("latest", doc)
+	assert.ErrorContains(t, err, `target version: invalid postgres version "latest"`)
+}
+
+func TestDiff_TargetVersion(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "events",
+				PrimaryKey: []string{"id"},
+				Columns:    []*ir.Column{{Name: "id", Type: "integer"}},
+				Indexes:    []*ir.Index{{Name: "events_id_idx", Concurrently: true, Dimensions: []*ir.IndexDim{{Name: "id_1", Value: "id"}}}},
+			}},
+			Triggers: []*ir.Trigger{{
+				Name:      "events_audit",
+				Table:     "events",
+				Events:    []string{"INSERT"},
+				Timing:    ir.TriggerTimingAfter,
+				ForEach:   ir.TriggerForEachRow,
+				Function:  "public.audit()",
+				SqlFormat: ir.SqlFormatPgsql8,
+			}},
+		}},
+	}
+	newDoc := &ir.Definition{
+		Schemas: []*ir.Schema{{
+			Name: "public",
+			Tables: []*ir.Table{{
+				Name:       "events",
+				PrimaryKey: []string{"id"},
+				Columns: []*ir.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "nick", Type: "text", Nullable: true},
+				},
+			}},
+			Triggers: []*ir.Trigger{{
+				Name:      "events_audit",
+				Table:     "events",
+				Events:    []string{"INSERT", "UPDATE"},
+				Timing:    ir.TriggerTimingAfter,
+				ForEach:   ir.TriggerForEachRow,
+				Function:  "public.audit()",
+				SqlFormat: ir.SqlFormatPgsql8,
+			}},
+		}},
+	}
+	events := sql.TableRef{Schema: "public", Table: "events"}
+	addNick := func(ifNotExists bool) output.ToSql {
+		return &sql.TableAlterParts{
+			Table: events,
+			Parts: []sql.TableAlterPart{&sql.TableAlterPartColumnCreate{
+				ColumnDef:   sql.ColumnDefinition{Name: "nick", Type: sql.TypeRef{Type: "text"}},
+				IfNotExists: ifNotExists,
+			}},
+		}
+	}
+	triggerDrop := &sql.TriggerDrop{Trigger: sql.TriggerRef{Schema: "public", Trigger: "events_audit"}, Table: events}
+	triggerCreate := func(orReplace bool) output.ToSql {
+		return &sql.TriggerCreate{
+			Trigger:   sql.TriggerRef{Schema: "public", Trigger: "events_audit"},
+			Table:     events,
+			Events:    []string{"INSERT", "UPDATE"},
+			Timing:    "AFTER",
+			ForEach:   "ROW",
+			Function:  "public.audit()",
+			OrReplace: orReplace,
+		}
+	}
+	indexDropConcurrently := &sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "events_id_idx"}, Concurrently: true, IfExists: true}
+
+	tests := []struct {
+		name       string
+		target     string
+		changes    []output.ToSql
+		concurrent []output.ToSql
+	}{
+		{
+			name:       "without a target version, output is what it always was",
+			changes:    []output.ToSql{addNick(false), triggerDrop, triggerCreate(false)},
+			concurrent: []output.ToSql{indexDropConcurrently},
+		},
+		{
+			name:       "14 adds columns if not exists and replaces triggers in place",
+			target:     "14",
+			changes:    []output.ToSql{addNick(true), triggerCreate(true)},
+			concurrent: []output.ToSql{indexDropConcurrently},
+		},
+		{
+			name:   "indexes can't be dropped concurrently before 9.2, so that happens in the transaction",
+			target: "9.1",
+			changes: []output.ToSql{
+				addNick(false),
+				&sql.IndexDrop{Index: sql.IndexRef{Schema: "public", Index: "events_id_idx"}},
+				triggerDrop,
+				triggerCreate(false),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := DefaultConfig
+			conf.TargetVersion = tt.target
+			recorders := diffChangesCommon(t, conf, oldDoc, newDoc)
+			assert.Equal(t, tt.changes, changeStatements(recorders[0].changes))
+			assert.Equal(t, tt.concurrent, changeStatements(recorders[0].concurrent))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package pgsql8
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/jackc/pgconn"
+)
+
+// timeoutRegex matches the values postgres accepts for time parameters: milliseconds, or a number with a unit
+var timeoutRegex = regexp.MustCompile(`^\d+\s*(us|ms|s|min|h|d)?$`)
+
+// timeoutSettings returns the SET LOCAL statements for the timeouts configured on the command line,
+// falling back to those of the <database> element of doc. A blocked statement then fails once its
+// timeout runs out, rather than holding up everything queued behind its locks until someone kills it.
+func (ops *Operations) timeoutSettings(doc *ir.Definition) ([]output.ToSql, error) {
+	var database *ir.Database
+	if doc != nil && doc.Database != nil {
+		database = doc.Database
+	} else {
+		database = &ir.Database{}
+	}
+	timeouts := []struct {
+		param, configured, defined string
+	}{
+		{"lock_timeout", ops.config.LockTimeout, database.LockTimeout},
+		{"statement_timeout", ops.config.StatementTimeout, database.StatementTimeout},
+		{"idle_in_transaction_session_timeout", ops.config.IdleInTransactionTimeout, database.IdleInTransactionTimeout},
+	}
+	out := []output.ToSql{}
+	for _, timeout := range timeouts {
+		value := timeout.configured
+		if value == "" {
+			value = timeout.defined
+		}
+		if value == "" {
+			continue
+		}
+		if !timeoutRegex.MatchString(value) {
+			return nil, fmt.Errorf("invalid %s %q, expected milliseconds or a number with a unit such as 5s or 2min", timeout.param, value)
+		}
+		out = append(out, &sql.SetLocal{Name: timeout.param, Value: value})
+	}
+	return out, nil
+}
+
+// isLockTimeout reports whether err is postgres giving up on acquiring a lock within lock_timeout
+func isLockTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "55P03"
+}
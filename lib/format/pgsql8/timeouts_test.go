@@ -0,0 +1,60 @@
+package pgsql8
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/format/pgsql8/sql"
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/output"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutSettings(t *testing.T) {
+	doc := &ir.Definition{
+		Database: &ir.Database{
+			LockTimeout:              "2s",
+			IdleInTransactionTimeout: "1min",
+		},
+	}
+
+	conf := DefaultConfig
+	conf.LockTimeout = "500ms"
+	conf.StatementTimeout = "30000"
+	ops := NewOperations(conf).(*Operations)
+	timeouts, err := ops.timeoutSettings(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []output.ToSql{
+		&sql.SetLocal{Name: "lock_timeout", Value: "500ms"},
+		&sql.SetLocal{Name: "statement_timeout", Value: "30000"},
+		&sql.SetLocal{Name: "idle_in_transaction_session_timeout", Value: "1min"},
+	}, timeouts)
+
+	ops.config.StatementTimeout = "30 seconds; DROP TABLE x"
+	_, err = ops.timeoutSettings(doc)
+	assert.ErrorContains(t, err, "invalid statement_timeout")
+
+	timeouts, err = NewOperations(DefaultConfig).(*Operations).timeoutSettings(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, timeouts)
+}
+
+func TestDiffDocWork_Timeouts(t *testing.T) {
+	oldDoc := &ir.Definition{
+		Database: &ir.Database{},
+		Schemas:  []*ir.Schema{{Name: "public"}},
+	}
+	newDoc := &ir.Definition{
+		Database: &ir.Database{LockTimeout: "5s"},
+		Schemas:  []*ir.Schema{{Name: "public"}},
+	}
+
+	recorders := diffChangesCommon(t, DefaultConfig, oldDoc, newDoc)
+	for _, recorder := range recorders {
+		assert.Equal(t, []output.ToSql{
+			output.NewRawSQL("\nBEGIN;\n\n"),
+			&sql.SetLocal{Name: "lock_timeout", Value: "5s"},
+			output.NewRawSQL("\n"),
+			output.NewRawSQL("\n"),
+		}, recorder.header, "stage %d", recorder.stage)
+	}
+}
@@ -2,6 +2,7 @@ package pgsql8
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/dbsteward/dbsteward/lib"
@@ -11,6 +12,21 @@ import (
 	"github.com/dbsteward/dbsteward/lib/util"
 )
 
+// checkTypes makes sure the types can be created in the target version
+func checkTypes(doc *ir.Definition, target VersionNum) error {
+	if doc == nil || target == 0 {
+		return nil
+	}
+	for _, schema := range doc.Schemas {
+		for _, datatype := range schema.Types {
+			if datatype.Kind == ir.DataTypeKindRange && !FEAT_RANGE_TYPES(target) {
+				return fmt.Errorf("range type %s.%s needs a target version of at least 9.2", schema.Name, datatype.Name)
+			}
+		}
+	}
+	return nil
+}
+
 func getCreateTypeSql(schema *ir.Schema, datatype *ir.TypeDef) ([]output.ToSql, error) {
 	switch datatype.Kind {
 	case ir.DataTypeKindEnum:
@@ -20,7 +36,7 @@ func getCreateTypeSql(schema *ir.Schema, datatype *ir.TypeDef) ([]output.ToSql,
 		}
 		vals := make([]string, len(datatype.EnumValues))
 		for i, val := range datatype.EnumValues {
-			vals[i] = string(val)
+			vals[i] = val.Name
 		}
 		return []output.ToSql{
 			&sql.TypeEnumCreate{
@@ -86,11 +102,183 @@ func getCreateTypeSql(schema *ir.Schema, datatype *ir.TypeDef) ([]output.ToSql,
 				Constraints: constraints,
 			},
 		}, nil
+	case ir.DataTypeKindRange:
+		if datatype.RangeType == nil || datatype.RangeType.Subtype == "" {
+			return nil, fmt.Errorf("range type %s.%s contains no rangeType child with a subtype", schema.Name, datatype.Name)
+		}
+		return []output.ToSql{
+			&sql.TypeRangeCreate{
+				Type:           sql.TypeRef{Schema: schema.Name, Type: datatype.Name},
+				Subtype:        datatype.RangeType.Subtype,
+				SubtypeOpClass: datatype.RangeType.SubtypeOpClass,
+				Collation:      datatype.RangeType.Collation,
+				Canonical:      datatype.RangeType.Canonical,
+				SubtypeDiff:    datatype.RangeType.SubtypeDiff,
+			},
+		}, nil
+	case ir.DataTypeKindBase:
+		ref := sql.TypeRef{Schema: schema.Name, Type: datatype.Name}
+		if datatype.BaseType == nil {
+			return []output.ToSql{&sql.TypeShellCreate{Type: ref}}, nil
+		}
+		return []output.ToSql{
+			&sql.TypeBaseCreate{
+				Type:           ref,
+				Input:          datatype.BaseType.Input,
+				Output:         datatype.BaseType.Output,
+				Receive:        datatype.BaseType.Receive,
+				Send:           datatype.BaseType.Send,
+				InternalLength: datatype.BaseType.InternalLength,
+				PassedByValue:  datatype.BaseType.PassedByValue,
+				Alignment:      datatype.BaseType.Alignment,
+				Storage:        datatype.BaseType.Storage,
+			},
+		}, nil
 	}
 	return nil, fmt.Errorf("unknown type %s type %s", datatype.Name, datatype.Kind.String())
 }
 
+// getCreateTypeWithSupportChanges creates a type along with the functions of its schema it's defined with.
+// Those take or return the type itself, so they're declared against a shell of the type before it's defined.
+// The functions are replaced if they're in oldSchema already.
+func getCreateTypeWithSupportChanges(conf lib.Config, oldSchema *ir.Schema, schema *ir.Schema, datatype *ir.TypeDef) ([]*output.Change, error) {
+	support, err := getTypeSupportChanges(conf, oldSchema, schema, datatype)
+	if err != nil {
+		return nil, err
+	}
+	create, err := getCreateTypeSql(schema, datatype)
+	if err != nil {
+		return nil, err
+	}
+	created := typeChanged(schema.Name, nil, datatype)
+	if len(support) == 0 {
+		return created.create(create...), nil
+	}
+	out := created.create(&sql.TypeShellCreate{Type: sql.TypeRef{Schema: schema.Name, Type: datatype.Name}})
+	out = append(out, support...)
+	return append(out, created.create(create...)...), nil
+}
+
+func getTypeSupportChanges(conf lib.Config, oldSchema *ir.Schema, schema *ir.Schema, datatype *ir.TypeDef) ([]*output.Change, error) {
+	out := []*output.Change{}
+	for _, function := range typeSupportFunctions(schema, datatype) {
+		s, err := getFunctionCreationSql(conf, schema, function)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, functionChanged(schema.Name, oldSchema.TryGetFunctionMatching(function), function).createOrAlter(s...)...)
+	}
+	return out, nil
+}
+
+// typeSupportFunctions finds the functions a type is defined with among those of its own schema.
+// Functions from elsewhere, such as those of extensions, are expected to exist already.
+func typeSupportFunctions(schema *ir.Schema, datatype *ir.TypeDef) []*ir.Function {
+	out := []*ir.Function{}
+	for _, name := range datatype.SupportFunctions() {
+		if parts := strings.SplitN(name, ".", 2); len(parts) == 2 {
+			if !strings.EqualFold(parts[0], schema.Name) {
+				continue
+			}
+			name = parts[1]
+		}
+		for _, function := range schema.Functions {
+			if strings.EqualFold(function.Name, name) && function.HasDefinition(ir.SqlFormatPgsql8) && !slices.Contains(out, function) {
+				out = append(out, function)
+			}
+		}
+	}
+	return out
+}
+
+// typeSupportFunctionsCreated lists the functions of the new schema which are created along with its types,
+// those of types which are new or were only a shell, so they aren't created again with the other functions
+func typeSupportFunctionsCreated(oldSchema *ir.Schema, newSchema *ir.Schema) []*ir.Function {
+	out := []*ir.Function{}
+	for _, newType := range newSchema.Types {
+		oldType := oldSchema.TryGetTypeNamed(newType.Name)
+		if oldType == nil || isTypeShell(oldType) {
+			out = append(out, typeSupportFunctions(newSchema, newType)...)
+		}
+	}
+	return out
+}
+
+// typeDependsOnOwnFunctions is whether the type is defined with functions which take the type itself, the functions
+// of a base type or the canonical function of a range. Those depend on each other, so can only be dropped together.
+func typeDependsOnOwnFunctions(datatype *ir.TypeDef) bool {
+	switch datatype.Kind {
+	case ir.DataTypeKindBase:
+		return datatype.BaseType != nil
+	case ir.DataTypeKindRange:
+		return datatype.RangeType != nil && datatype.RangeType.Canonical != ""
+	}
+	return false
+}
+
+func isTypeShell(datatype *ir.TypeDef) bool {
+	return datatype.Kind == ir.DataTypeKindBase && datatype.BaseType == nil
+}
+
+// typesInDependencyOrder orders types of the schema so that those used by others come first, such as the
+// subtype of a range or the base type of a domain, keeping the given order otherwise
+func typesInDependencyOrder(schema *ir.Schema, types []*ir.TypeDef) []*ir.TypeDef {
+	out := make([]*ir.TypeDef, 0, len(types))
+	visited := map[*ir.TypeDef]bool{}
+	var visit func(datatype *ir.TypeDef)
+	visit = func(datatype *ir.TypeDef) {
+		if visited[datatype] {
+			return
+		}
+		visited[datatype] = true
+		for _, other := range types {
+			if other != datatype && typeUsesType(datatype, schema, other) {
+				visit(other)
+			}
+		}
+		out = append(out, datatype)
+	}
+	for _, datatype := range types {
+		visit(datatype)
+	}
+	return out
+}
+
+// typeUsesType is whether the definition of datatype refers to the other type of the schema
+func typeUsesType(datatype *ir.TypeDef, schema *ir.Schema, other *ir.TypeDef) bool {
+	used := []string{}
+	switch datatype.Kind {
+	case ir.DataTypeKindRange:
+		if datatype.RangeType != nil {
+			used = append(used, datatype.RangeType.Subtype)
+		}
+	case ir.DataTypeKindDomain:
+		if datatype.DomainType != nil {
+			used = append(used, datatype.DomainType.BaseType)
+		}
+	case ir.DataTypeKindComposite:
+		for _, field := range datatype.CompositeFields {
+			used = append(used, field.Type)
+		}
+	}
+	for _, spec := range used {
+		spec = strings.TrimRight(spec, "[] ") // allow for arrays
+		if strings.EqualFold(spec, other.Name) || strings.EqualFold(spec, schema.Name+"."+other.Name) {
+			return true
+		}
+	}
+	return false
+}
+
 func getDropTypeSql(schema *ir.Schema, datatype *ir.TypeDef) []output.ToSql {
+	if typeDependsOnOwnFunctions(datatype) {
+		return []output.ToSql{
+			&sql.TypeDrop{
+				Type:    sql.TypeRef{Schema: schema.Name, Type: datatype.Name},
+				Cascade: true,
+			},
+		}
+	}
 	if datatype.Kind == ir.DataTypeKindDomain {
 		return []output.ToSql{
 			&sql.TypeDomainDrop{
@@ -119,8 +307,8 @@ func isIntType(spec string) bool {
 }
 
 // Change all table columns that are the given datatype to a placeholder type
-func alterColumnTypePlaceholder(conf lib.Config, differ *diff, datatype *ir.TypeDef) ([]*ir.ColumnRef, []output.ToSql, error) {
-	ddl := []output.ToSql{}
+func alterColumnTypePlaceholder(conf lib.Config, differ *diff, datatype *ir.TypeDef) ([]*ir.ColumnRef, []*output.Change, error) {
+	changes := []*output.Change{}
 	cols := []*ir.ColumnRef{}
 	for _, newTableRef := range differ.NewTableDependency {
 		for _, newColumn := range newTableRef.Table.Columns {
@@ -130,19 +318,30 @@ func alterColumnTypePlaceholder(conf lib.Config, differ *diff, datatype *ir.Type
 			}
 			if strings.EqualFold(columnType, datatype.Name) || strings.EqualFold(columnType, newTableRef.Schema.Name+"."+datatype.Name) {
 				sqlRef := sql.TableRef{Schema: newTableRef.Schema.Name, Table: newTableRef.Table.Name}
-				ddl = append(ddl, sql.NewTableAlter(sqlRef, &sql.TableAlterPartColumnChangeType{
+				column := newTableRef.ToColumnRef(newColumn)
+				changes = append(changes, retypedColumn(conf, column).alter(sql.NewTableAlter(sqlRef, &sql.TableAlterPartColumnChangeType{
 					Column: newColumn.Name,
 					Type:   alterColumnTypePlaceholderType(datatype),
-				}))
-				cols = append(cols, newTableRef.ToColumnRef(newColumn))
+				}))...)
+				cols = append(cols, column)
 			}
 		}
 	}
-	return cols, ddl, nil
+	return cols, changes, nil
+}
+
+// retypedColumn describes a column converted to a placeholder type and back while its type is recreated
+func retypedColumn(conf lib.Config, column *ir.ColumnRef) changed {
+	var oldColumn *ir.Column
+	if oldTable := conf.OldDatabase.TryGetSchemaNamed(column.Schema.Name).TryGetTableNamed(column.Table.Name); oldTable != nil {
+		oldColumn = oldTable.TryGetColumnNamed(column.Column.Name)
+	}
+	return columnChanged(column.Schema.Name, column.Table.Name, oldColumn, column.Column)
 }
 
 func alterColumnTypePlaceholderType(datatype *ir.TypeDef) sql.TypeRef {
-	if datatype.Kind == ir.DataTypeKindEnum {
+	if datatype.Kind == ir.DataTypeKindEnum || datatype.Kind == ir.DataTypeKindRange || datatype.Kind == ir.DataTypeKindBase {
+		// values of these convert to and from text
 		return sql.BuiltinTypeRef("text")
 	}
 	if datatype.Kind == ir.DataTypeKindDomain {
@@ -153,15 +352,15 @@ func alterColumnTypePlaceholderType(datatype *ir.TypeDef) sql.TypeRef {
 }
 
 // restores types changed by AlterColumnTypePlaceholder
-func alterColumnTypeRestore(columns []*ir.ColumnRef, schema *ir.Schema, datatype *ir.TypeDef) []output.ToSql {
-	ddl := []output.ToSql{}
+func alterColumnTypeRestore(conf lib.Config, columns []*ir.ColumnRef, schema *ir.Schema, datatype *ir.TypeDef) []*output.Change {
+	changes := []*output.Change{}
 	// do the columns backwards to maintain dependency ordering
 	for i := len(columns) - 1; i >= 0; i-- {
 		sqlRef := sql.TableRef{Schema: columns[i].Schema.Name, Table: columns[i].Table.Name}
-		ddl = append(ddl, sql.NewTableAlter(sqlRef, &sql.TableAlterPartColumnChangeTypeUsingCast{
+		changes = append(changes, retypedColumn(conf, columns[i]).alter(sql.NewTableAlter(sqlRef, &sql.TableAlterPartColumnChangeTypeUsingCast{
 			Column: columns[i].Column.Name,
 			Type:   sql.TypeRef{Schema: schema.Name, Type: datatype.Name},
-		}))
+		}))...)
 	}
-	return ddl
+	return changes
 }
@@ -3,6 +3,7 @@ package pgsql8
 import (
 	"database/sql"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/dbsteward/dbsteward/lib/ir"
@@ -13,6 +14,8 @@ type structure struct {
 	Version     VersionNum
 	Database    Database
 	Schemas     []schemaEntry
+	Extensions  []extensionEntry
+	Roles       []roleEntry
 	Tables      []tableEntry
 	Sequences   []sequenceRelEntry
 	Views       []viewEntry
@@ -20,8 +23,12 @@ type structure struct {
 	ForeignKeys []foreignKeyEntry
 	Functions   []functionEntry
 	Triggers    []triggerEntry
+	Policies    []policyEntry
 	TablePerms  []tablePermEntry
 	SchemaPerms []schemaPermEntry
+	DefaultACLs []defaultPrivilegeEntry
+	RangeTypes  []rangeTypeEntry
+	Casts       []castEntry
 }
 
 type schemaEntry struct {
@@ -30,6 +37,21 @@ type schemaEntry struct {
 	Description string
 }
 
+type extensionEntry struct {
+	Name    string
+	Schema  string
+	Version string
+}
+
+type roleEntry struct {
+	Name            string
+	Login           bool
+	Inherit         bool
+	CreateDB        bool
+	ConnectionLimit int // -1 for no limit
+	MemberOf        []string
+}
+
 type tableEntry struct {
 	Schema            string
 	Table             string
@@ -41,6 +63,16 @@ type tableEntry struct {
 	TableDescription  string
 	ParentTables      []string
 	StorageOptions    map[string]string
+	PartitionKey      string // pg_get_partkeydef, only set on partitioned tables
+	PartitionBound    string // pg_get_expr(relpartbound), only set on partitions
+	Partitions        []partitionEntry
+	RowSecurity       bool
+	ForceRowSecurity  bool
+}
+
+type partitionEntry struct {
+	Name  string
+	Bound string
 }
 
 type columnEntry struct {
@@ -50,6 +82,58 @@ type columnEntry struct {
 	Description string
 	Position    int
 	AttrType    string
+	GeneratedAs string // expression of a GENERATED ALWAYS AS (...) STORED column
+	Identity    string // pg_attribute.attidentity, 'a' for ALWAYS and 'd' for BY DEFAULT
+
+	// the sequence behind an identity column
+	IdentitySequence  string
+	IdentityStart     sql.NullInt64
+	IdentityIncrement sql.NullInt64
+	IdentityMin       sql.NullInt64
+	IdentityMax       sql.NullInt64
+	IdentityCache     sql.NullInt64
+	IdentityCycle     bool
+}
+
+// IdentityToIR returns the identity of the column, if it is one. Sequence options which are the
+// defaults for the column's type are left out, as they would be from the definition.
+func (c columnEntry) IdentityToIR() *ir.ColumnIdentity {
+	id := &ir.ColumnIdentity{Cycle: c.IdentityCycle}
+	switch c.Identity {
+	case "a":
+		id.Generation = ir.IdentityGenerationAlways
+	case "d":
+		id.Generation = ir.IdentityGenerationByDefault
+	default:
+		return nil
+	}
+	typeMax := math.MaxInt64
+	switch strings.ToLower(c.AttrType) {
+	case "smallint":
+		typeMax = math.MaxInt16
+	case "integer":
+		typeMax = math.MaxInt32
+	}
+	// descending sequences count down from -1
+	defaultMin, defaultMax := 1, typeMax
+	defaultStart := defaultMin
+	if c.IdentityIncrement.Valid && c.IdentityIncrement.Int64 < 0 {
+		defaultMin, defaultMax = -typeMax-1, -1
+		defaultStart = defaultMax
+	}
+	option := func(v sql.NullInt64, def int) *int {
+		if !v.Valid || int(v.Int64) == def {
+			return nil
+		}
+		n := int(v.Int64)
+		return &n
+	}
+	id.Start = option(c.IdentityStart, defaultStart)
+	id.Increment = option(c.IdentityIncrement, 1)
+	id.Min = option(c.IdentityMin, defaultMin)
+	id.Max = option(c.IdentityMax, defaultMax)
+	id.Cache = option(c.IdentityCache, 1)
+	return id
 }
 
 type indexEntry struct {
@@ -102,11 +186,13 @@ type sequenceEntry struct {
 }
 
 type viewEntry struct {
-	Schema      string
-	Name        string
-	Description string
-	Owner       string
-	Definition  string
+	Schema       string
+	Name         string
+	Description  string
+	Owner        string
+	Definition   string
+	Materialized bool
+	Indexes      []indexEntry
 }
 
 type constraintEntry struct {
@@ -160,6 +246,44 @@ type triggerEntry struct {
 	Statement   string
 }
 
+type policyEntry struct {
+	Schema      string
+	Table       string
+	Name        string
+	Command     string
+	Restrictive bool
+	Roles       []string // empty for PUBLIC
+	Using       string
+	WithCheck   string
+}
+
+type defaultPrivilegeEntry struct {
+	Role       string
+	Schema     string // empty for all schemas
+	ObjectType string
+	Grantee    string
+	Type       string
+	Grantable  bool
+}
+
+type rangeTypeEntry struct {
+	Schema         string
+	Name           string
+	Subtype        string
+	SubtypeOpClass string // empty for the default operator class of the subtype
+	Collation      string // empty for the collation of the subtype
+	Canonical      string
+	SubtypeDiff    string
+}
+
+type castEntry struct {
+	Source   string
+	Target   string
+	Method   string // f, i or b as in pg_cast.castmethod
+	Function string
+	Context  string // e, a or i as in pg_cast.castcontext
+}
+
 type schemaPermEntry struct {
 	Schema    string
 	Grantee   string
@@ -1,6 +1,10 @@
 package pgsql8
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // https://www.postgresql.org/support/versioning/
 // This is obtained from `SHOW server_version_num;`
@@ -26,6 +30,26 @@ func NewVersionNum(major, minor int, patch ...int) VersionNum {
 	return VersionNum(major*10000 + minor*100 + patch[0])
 }
 
+// ParseVersionNum parses a version as written by people, such as "15", "9.6" or "9.6.3"
+func ParseVersionNum(s string) (VersionNum, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid postgres version %q", s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid postgres version %q", s)
+		}
+		nums[i] = n
+	}
+	if nums[0] < 8 {
+		return 0, fmt.Errorf("invalid postgres version %q, the oldest supported is 8.0", s)
+	}
+	return NewVersionNum(nums[0], nums[1], nums[2]), nil
+}
+
 func (self VersionNum) IsOlderThan(major, minor int, patch ...int) bool {
 	return self < NewVersionNum(major, minor, patch...)
 }
@@ -18,15 +18,17 @@ func getCreateViewSql(conf lib.Config, schema *ir.Schema, view *ir.View) ([]outp
 
 	out := []output.ToSql{
 		&sql.ViewCreate{
-			View:  ref,
-			Query: query.GetNormalizedText(),
+			View:         ref,
+			Query:        query.GetNormalizedText(),
+			Materialized: view.Materialized,
 		},
 	}
 
 	if view.Description != "" {
 		out = append(out, &sql.ViewSetComment{
-			View:    ref,
-			Comment: view.Description,
+			View:         ref,
+			Comment:      view.Description,
+			Materialized: view.Materialized,
 		})
 	}
 	if view.Owner != "" {
@@ -35,17 +37,35 @@ func getCreateViewSql(conf lib.Config, schema *ir.Schema, view *ir.View) ([]outp
 			return nil, err
 		}
 		out = append(out, &sql.ViewAlterOwner{
-			View: ref,
-			Role: role,
+			View:         ref,
+			Role:         role,
+			Materialized: view.Materialized,
 		})
 	}
 
+	for _, index := range view.Indexes {
+		out = append(out, getCreateViewIndexSql(schema, view, index)...)
+	}
+
 	return out, nil
 }
 
+func getCreateViewIndexSql(schema *ir.Schema, view *ir.View, index *ir.Index) []output.ToSql {
+	return getCreateRelationIndexSql(sql.TableRef{Schema: schema.Name, Table: view.Name}, index)
+}
+
 func getDropViewSql(schema *ir.Schema, view *ir.View) []output.ToSql {
 	return []output.ToSql{
 		&sql.ViewDrop{
+			View:         sql.ViewRef{Schema: schema.Name, View: view.Name},
+			Materialized: view.Materialized,
+		},
+	}
+}
+
+func getRefreshViewSql(schema *ir.Schema, view *ir.View) []output.ToSql {
+	return []output.ToSql{
+		&sql.MaterializedViewRefresh{
 			View: sql.ViewRef{Schema: schema.Name, View: view.Name},
 		},
 	}
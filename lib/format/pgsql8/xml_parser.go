@@ -57,6 +57,10 @@ func (parser *XmlParser) Process(l *slog.Logger, doc *ir.Definition) error {
 	for _, schema := range doc.Schemas {
 		for _, table := range schema.Tables {
 			if table.Partitioning != nil {
+				if table.Partitioning.Type.IsNative() {
+					// native partitions are created alongside their parent, nothing to expand
+					continue
+				}
 				l.Warn(fmt.Sprintf("Table %s.%s definies partition which is only partially supported at this time", schema.Name, table.Name))
 				return parser.expandPartitionedTable(doc, schema, table)
 			}
@@ -68,7 +72,6 @@ func (parser *XmlParser) Process(l *slog.Logger, doc *ir.Definition) error {
 func (parser *XmlParser) expandPartitionedTable(doc *ir.Definition, schema *ir.Schema, table *ir.Table) error {
 	util.Assert(table.Partitioning != nil, "Table.Partitioning must not be nil")
 	// TODO(feat) hash partitions
-	// TODO(feat) native partitioning in recent postgres
 
 	if table.Partitioning.Type.Equals(ir.TablePartitionTypeModulo) {
 		return parser.expandModuloParitionedTable(doc, schema, table)
@@ -92,6 +95,9 @@ func (parser *XmlParser) CheckPartitionChange(oldSchema *ir.Schema, oldTable *ir
 	if newTable.Partitioning.Type.Equals(ir.TablePartitionTypeModulo) {
 		return parser.checkModuloPartitionChange(oldSchema, oldTable, newSchema, newTable)
 	}
+	if newTable.Partitioning.Type.IsNative() {
+		return parser.checkNativePartitionChange(oldSchema, oldTable, newSchema, newTable)
+	}
 
 	return errors.Errorf("Invalid partition type: %s", newTable.Partitioning.Type)
 }
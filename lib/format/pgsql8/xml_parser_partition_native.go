@@ -0,0 +1,41 @@
+package pgsql8
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/ir"
+	"github.com/dbsteward/dbsteward/lib/util"
+	"github.com/pkg/errors"
+)
+
+func nativePartitionKey(schema *ir.Schema, table *ir.Table) (string, error) {
+	key := table.Partitioning.TryGetOptionValueNamed("column")
+	if key == "" {
+		return "", fmt.Errorf("tablePartitionOption 'column' must be specificed for table %s.%s", schema.Name, table.Name)
+	}
+	return key, nil
+}
+
+func (p *XmlParser) checkNativePartitionChange(oldSchema *ir.Schema, oldTable *ir.Table, newSchema *ir.Schema, newTable *ir.Table) error {
+	util.Assert(newTable.Partitioning.Type.IsNative(), "must be a native partition type")
+
+	oldKey, err := nativePartitionKey(oldSchema, oldTable)
+	if err != nil {
+		return err
+	}
+	newKey, err := nativePartitionKey(newSchema, newTable)
+	if err != nil {
+		return err
+	}
+	// keys are sql expressions, so only whitespace and case of the whole thing are insignificant
+	if !strings.EqualFold(strings.Join(strings.Fields(oldKey), " "), strings.Join(strings.Fields(newKey), " ")) {
+		return errors.Errorf("Changing the partition key of a table is not supported: %s.%s", newSchema.Name, newTable.Name)
+	}
+	for _, segment := range newTable.Partitioning.Segments {
+		if segment.Name == "" || strings.TrimSpace(segment.Value) == "" {
+			return errors.Errorf("tablePartitionSegment on table %s.%s must have a name and a bound value", newSchema.Name, newTable.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package lib
+
+// MigrationHistory identifies an upgrade in the dbsteward.migration_history table of the database it is applied to
+type MigrationHistory struct {
+	// OldHash and NewHash are the xml.DefinitionHash of the composited old and new definitions
+	OldHash     string
+	NewHash     string
+	ToolVersion string
+	// VerifyBaseline refuses to apply the upgrade unless the last upgrade recorded in the database left it at OldHash
+	VerifyBaseline bool
+}
@@ -0,0 +1,126 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CastMethod is how a cast converts its values
+type CastMethod string
+
+const (
+	// CastMethodFunction calls the cast's function
+	CastMethodFunction CastMethod = "FUNCTION"
+	// CastMethodInout goes through the text output and input functions of the types
+	CastMethodInout CastMethod = "INOUT"
+	// CastMethodBinary reinterprets the value as is, for types which are stored alike
+	CastMethodBinary CastMethod = "BINARY"
+)
+
+func NewCastMethod(s string) (CastMethod, error) {
+	if s == "" {
+		return CastMethodFunction, nil
+	}
+	v := CastMethod(s)
+	for _, method := range []CastMethod{CastMethodFunction, CastMethodInout, CastMethodBinary} {
+		if v.Equals(method) {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("invalid cast method '%s'", s)
+}
+
+func (cm CastMethod) Equals(other CastMethod) bool {
+	return strings.EqualFold(string(cm), string(other))
+}
+
+// CastContext is where a cast is applied without being asked for
+type CastContext string
+
+const (
+	CastContextExplicit   CastContext = "EXPLICIT"
+	CastContextAssignment CastContext = "ASSIGNMENT"
+	CastContextImplicit   CastContext = "IMPLICIT"
+)
+
+func NewCastContext(s string) (CastContext, error) {
+	if s == "" {
+		return CastContextExplicit, nil
+	}
+	v := CastContext(s)
+	for _, context := range []CastContext{CastContextExplicit, CastContextAssignment, CastContextImplicit} {
+		if v.Equals(context) {
+			return context, nil
+		}
+	}
+	return "", fmt.Errorf("invalid cast context '%s'", s)
+}
+
+func (cc CastContext) Equals(other CastContext) bool {
+	return strings.EqualFold(string(cc), string(other))
+}
+
+// Cast converts values of the Source type to the Target type. Casts belong to the database rather than a schema,
+// so types outside of the search path are named along with their schema, as is the function, which is given
+// with its parameter types, such as public.to_cents(public.dollars)
+type Cast struct {
+	Source   string
+	Target   string
+	Method   CastMethod
+	Function string
+	Context  CastContext
+}
+
+func (self *Cast) IdentityMatches(other *Cast) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Source, other.Source) && strings.EqualFold(self.Target, other.Target)
+}
+
+func (self *Cast) String() string {
+	return fmt.Sprintf("cast from %s to %s", self.Source, self.Target)
+}
+
+// DependsOnType is whether the cast is from or to the given type, named with or without its schema
+func (self *Cast) DependsOnType(schema *Schema, datatype *TypeDef) bool {
+	for _, name := range []string{self.Source, self.Target} {
+		if strings.EqualFold(name, datatype.Name) || strings.EqualFold(name, schema.Name+"."+datatype.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Cast) Merge(overlay *Cast) {
+	if overlay == nil {
+		return
+	}
+	self.Method = overlay.Method
+	self.Function = overlay.Function
+	self.Context = overlay.Context
+}
+
+func (self *Cast) Equals(other *Cast) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return self.IdentityMatches(other) &&
+		self.Method.Equals(other.Method) &&
+		strings.EqualFold(strings.ReplaceAll(self.Function, " ", ""), strings.ReplaceAll(other.Function, " ", "")) &&
+		self.Context.Equals(other.Context)
+}
+
+func (self *Cast) Validate(*Definition) []error {
+	out := []error{}
+	if self.Source == "" || self.Target == "" {
+		out = append(out, fmt.Errorf("%s must name both types", self))
+	}
+	if self.Method.Equals(CastMethodFunction) && self.Function == "" {
+		out = append(out, fmt.Errorf("%s must name its function", self))
+	}
+	if !self.Method.Equals(CastMethodFunction) && self.Function != "" {
+		out = append(out, fmt.Errorf("%s is made %s, so it can't have a function", self, strings.ToLower(string(self.Method))))
+	}
+	return out
+}
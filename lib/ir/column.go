@@ -7,6 +7,49 @@ import (
 	"github.com/dbsteward/dbsteward/lib/util"
 )
 
+// IdentityGeneration is whether a column GENERATED AS IDENTITY accepts values other than its own
+type IdentityGeneration string
+
+const (
+	IdentityGenerationAlways    IdentityGeneration = "ALWAYS"
+	IdentityGenerationByDefault IdentityGeneration = "BY DEFAULT"
+)
+
+func NewIdentityGeneration(s string) (IdentityGeneration, error) {
+	switch strings.ToLower(strings.NewReplacer(" ", "", "_", "").Replace(s)) {
+	case "always":
+		return IdentityGenerationAlways, nil
+	case "bydefault":
+		return IdentityGenerationByDefault, nil
+	}
+	return "", fmt.Errorf("invalid identity generation: '%s'", s)
+}
+
+// ColumnIdentity is a column GENERATED AS IDENTITY, along with the options of the sequence
+// postgres creates for it. Options left nil are the sequence defaults.
+type ColumnIdentity struct {
+	Generation IdentityGeneration
+	Start      *int
+	Increment  *int
+	Min        *int
+	Max        *int
+	Cache      *int
+	Cycle      bool
+}
+
+func (id *ColumnIdentity) Equals(other *ColumnIdentity) bool {
+	if id == nil || other == nil {
+		return id == other
+	}
+	return id.Generation == other.Generation &&
+		util.PtrEq(id.Start, other.Start) &&
+		util.PtrEq(id.Increment, other.Increment) &&
+		util.PtrEq(id.Min, other.Min) &&
+		util.PtrEq(id.Max, other.Max) &&
+		util.PtrEq(id.Cache, other.Cache) &&
+		id.Cycle == other.Cycle
+}
+
 type Column struct {
 	Name             string
 	Type             string
@@ -16,6 +59,8 @@ type Column struct {
 	Unique           bool
 	Check            string
 	SerialStart      *int
+	Identity         *ColumnIdentity
+	GeneratedAs      string
 	OldColumnName    string
 	ConvertUsing     string
 	ForeignSchema    string
@@ -88,6 +133,8 @@ func (col *Column) Merge(overlay *Column) {
 	col.Default = overlay.Default
 	col.Description = overlay.Description
 	col.SerialStart = overlay.SerialStart
+	col.Identity = overlay.Identity
+	col.GeneratedAs = overlay.GeneratedAs
 	col.ForeignSchema = overlay.ForeignSchema
 	col.ForeignTable = overlay.ForeignTable
 	col.ForeignKeyName = overlay.ForeignKeyName
@@ -101,6 +148,15 @@ func (col *Column) Validate(_ *Definition, s *Schema, t *Table) []error {
 	if col.Name == "" {
 		errs = append(errs, fmt.Errorf("column in %s.%s has empty name", s.Name, t.Name))
 	}
+	if col.Identity != nil && col.GeneratedAs != "" {
+		errs = append(errs, fmt.Errorf("column %s.%s.%s can't be both an identity and generated", s.Name, t.Name, col.Name))
+	}
+	if (col.Identity != nil || col.GeneratedAs != "") && col.Default != "" {
+		errs = append(errs, fmt.Errorf("column %s.%s.%s is generated and can't have a default", s.Name, t.Name, col.Name))
+	}
+	if col.Identity != nil && col.SerialStart != nil {
+		errs = append(errs, fmt.Errorf("column %s.%s.%s is an identity, its start belongs to the identity rather than serialStart", s.Name, t.Name, col.Name))
+	}
 	// TODO(go,3) validate values
 	// TODO(go,3) validate foreign references, remove other codepaths
 	// TODO(go,3) validate oldname references, remove other codepaths
@@ -128,6 +184,8 @@ func (col *Column) EqualsInherited(other *Column) bool {
 		col.Nullable == other.Nullable &&
 		col.Default == other.Default &&
 		col.SerialStart == other.SerialStart &&
+		col.Identity.Equals(other.Identity) &&
+		col.GeneratedAs == other.GeneratedAs &&
 		strings.EqualFold(col.ForeignSchema, other.ForeignSchema) &&
 		strings.EqualFold(col.ForeignTable, other.ForeignTable) &&
 		col.ForeignOnUpdate.Equals(other.ForeignOnUpdate) &&
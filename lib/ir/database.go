@@ -11,6 +11,14 @@ type Database struct {
 	Roles        *RoleAssignment
 	ConfigParams []*ConfigParam
 
+	// timeouts set at the top of every upgrade stage transaction and the build, e.g. "5s"
+	LockTimeout              string
+	StatementTimeout         string
+	IdleInTransactionTimeout string
+
+	// TargetVersion is the postgres version generated sql may rely on, e.g. "15" or "9.6"
+	TargetVersion string
+
 	// slony
 }
 
@@ -56,6 +64,18 @@ func (self *Database) Merge(overlay *Database) {
 	}
 
 	self.SqlFormat = overlay.SqlFormat
+	if overlay.LockTimeout != "" {
+		self.LockTimeout = overlay.LockTimeout
+	}
+	if overlay.StatementTimeout != "" {
+		self.StatementTimeout = overlay.StatementTimeout
+	}
+	if overlay.IdleInTransactionTimeout != "" {
+		self.IdleInTransactionTimeout = overlay.IdleInTransactionTimeout
+	}
+	if overlay.TargetVersion != "" {
+		self.TargetVersion = overlay.TargetVersion
+	}
 
 	if self.Roles == nil {
 		self.Roles = &RoleAssignment{}
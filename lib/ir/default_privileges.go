@@ -0,0 +1,126 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+type DefaultPrivilegesObjectType string
+
+const (
+	DefaultPrivilegesTables    DefaultPrivilegesObjectType = "TABLES"
+	DefaultPrivilegesSequences DefaultPrivilegesObjectType = "SEQUENCES"
+	DefaultPrivilegesFunctions DefaultPrivilegesObjectType = "FUNCTIONS"
+	DefaultPrivilegesTypes     DefaultPrivilegesObjectType = "TYPES"
+	DefaultPrivilegesSchemas   DefaultPrivilegesObjectType = "SCHEMAS"
+)
+
+func NewDefaultPrivilegesObjectType(s string) (DefaultPrivilegesObjectType, error) {
+	v := DefaultPrivilegesObjectType(s)
+	for _, objType := range []DefaultPrivilegesObjectType{
+		DefaultPrivilegesTables, DefaultPrivilegesSequences, DefaultPrivilegesFunctions, DefaultPrivilegesTypes, DefaultPrivilegesSchemas,
+	} {
+		if v.Equals(objType) {
+			return objType, nil
+		}
+	}
+	return "", fmt.Errorf("invalid default privileges object type '%s'", s)
+}
+
+func (ot DefaultPrivilegesObjectType) Equals(other DefaultPrivilegesObjectType) bool {
+	return strings.EqualFold(string(ot), string(other))
+}
+
+// ValidPermissions lists the permissions which can be granted on the object type
+func (ot DefaultPrivilegesObjectType) ValidPermissions() []string {
+	switch {
+	case ot.Equals(DefaultPrivilegesTables):
+		return PermissionListValidTable
+	case ot.Equals(DefaultPrivilegesSequences):
+		return PermissionListValidSequence
+	case ot.Equals(DefaultPrivilegesFunctions):
+		return PermissionListValidFunction
+	case ot.Equals(DefaultPrivilegesTypes):
+		return []string{PermissionAll, PermissionUsage}
+	case ot.Equals(DefaultPrivilegesSchemas):
+		return PermissionListValidSchema
+	}
+	return nil
+}
+
+// DefaultPrivileges are the grants given on objects of a type as they're created by Role,
+// whether by dbsteward or not. They apply to objects created in Schema, or in any schema if it's empty.
+type DefaultPrivileges struct {
+	Role       string
+	Schema     string
+	ObjectType DefaultPrivilegesObjectType
+	Grants     []*Grant
+}
+
+func (self *DefaultPrivileges) IdentityMatches(other *DefaultPrivileges) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Role, other.Role) &&
+		strings.EqualFold(self.Schema, other.Schema) &&
+		self.ObjectType.Equals(other.ObjectType)
+}
+
+func (self *DefaultPrivileges) String() string {
+	if self.Schema == "" {
+		return fmt.Sprintf("default privileges on %s for role %s", strings.ToLower(string(self.ObjectType)), self.Role)
+	}
+	return fmt.Sprintf("default privileges on %s for role %s in schema %s", strings.ToLower(string(self.ObjectType)), self.Role, self.Schema)
+}
+
+func (self *DefaultPrivileges) GetGrants() []*Grant {
+	return self.Grants
+}
+
+func (self *DefaultPrivileges) AddGrant(grant *Grant) {
+	self.Grants = append(self.Grants, grant)
+}
+
+// GetPermissionsForRole returns every permission granted to the role, and whether it's granted with the grant option
+func (self *DefaultPrivileges) GetPermissionsForRole(role string) ([]string, bool) {
+	if self == nil {
+		return nil, false
+	}
+	perms := []string{}
+	canGrant := false
+	for _, grant := range self.Grants {
+		if util.IStrsContains(grant.Roles, role) {
+			perms = append(perms, grant.Permissions...)
+			canGrant = canGrant || grant.CanGrant()
+		}
+	}
+	return perms, canGrant
+}
+
+func (self *DefaultPrivileges) Merge(overlay *DefaultPrivileges) {
+	if overlay == nil {
+		return
+	}
+	for _, overlayGrant := range overlay.Grants {
+		self.AddGrant(overlayGrant)
+	}
+}
+
+func (self *DefaultPrivileges) Validate(*Definition) []error {
+	out := []error{}
+	if self.Role == "" {
+		out = append(out, fmt.Errorf("default privileges on %s need the role creating the objects", strings.ToLower(string(self.ObjectType))))
+	}
+	if self.Schema != "" && self.ObjectType.Equals(DefaultPrivilegesSchemas) {
+		out = append(out, fmt.Errorf("%s can't be limited to a schema", self))
+	}
+	for _, grant := range self.Grants {
+		invalid := util.IDifferenceStrs(grant.Permissions, self.ObjectType.ValidPermissions())
+		if len(invalid) > 0 {
+			out = append(out, fmt.Errorf("invalid permissions on %s: %v", self, invalid))
+		}
+	}
+	return out
+}
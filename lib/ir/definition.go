@@ -18,13 +18,25 @@ type Definition struct {
 	Database       *Database
 	Schemas        []*Schema
 	Languages      []*Language
-	Sql            []*Sql
+	Extensions     []*Extension
+	Roles          []*Role
+	Casts          []*Cast
+	// DefaultPrivileges are identified by their role, schema and object type
+	DefaultPrivileges []*DefaultPrivileges
+	Sql               []*Sql
+	AllowDrops        []*AllowDrop
 }
 
 type IncludeFile struct {
 	Name string
 }
 
+// AllowDrop permits a destructive change to the named object when upgrading in safe mode.
+// Object is a qualified name such as schema.table.column, and may contain * wildcards
+type AllowDrop struct {
+	Object string
+}
+
 type InlineAssembly struct {
 	Name string
 }
@@ -99,10 +111,95 @@ func (def *Definition) AddLanguage(lang *Language) {
 	def.Languages = append(def.Languages, lang)
 }
 
+func (def *Definition) TryGetExtensionNamed(name string) *Extension {
+	if def == nil {
+		return nil
+	}
+	for _, ext := range def.Extensions {
+		if ext.IdentityMatches(&Extension{Name: name}) {
+			return ext
+		}
+	}
+	return nil
+}
+
+func (def *Definition) AddExtension(ext *Extension) {
+	def.Extensions = append(def.Extensions, ext)
+}
+
+func (def *Definition) TryGetRoleNamed(name string) *Role {
+	if def == nil {
+		return nil
+	}
+	for _, role := range def.Roles {
+		if role.IdentityMatches(&Role{Name: name}) {
+			return role
+		}
+	}
+	return nil
+}
+
+func (def *Definition) AddRole(role *Role) {
+	def.Roles = append(def.Roles, role)
+}
+
+// KeepDeclaredRoles leaves only the roles other declares too. A definition extracted from a database holds
+// every role in the cluster, most of which no definition manages, so only those named on the other side are diffed.
+// Passwords can't be extracted, so the kept roles take theirs from the same place as the declared ones,
+// and are never set again by the diff
+func (def *Definition) KeepDeclaredRoles(other *Definition) {
+	if def == nil {
+		return
+	}
+	roles := []*Role{}
+	for _, role := range def.Roles {
+		if declared := other.TryGetRoleNamed(role.Name); declared != nil {
+			role.PasswordEnv = declared.PasswordEnv
+			roles = append(roles, role)
+		}
+	}
+	def.Roles = roles
+}
+
+func (def *Definition) TryGetDefaultPrivilegesMatching(target *DefaultPrivileges) *DefaultPrivileges {
+	if def == nil {
+		return nil
+	}
+	for _, dp := range def.DefaultPrivileges {
+		if dp.IdentityMatches(target) {
+			return dp
+		}
+	}
+	return nil
+}
+
+func (def *Definition) AddDefaultPrivileges(dp *DefaultPrivileges) {
+	def.DefaultPrivileges = append(def.DefaultPrivileges, dp)
+}
+
+func (def *Definition) TryGetCastMatching(target *Cast) *Cast {
+	if def == nil {
+		return nil
+	}
+	for _, cast := range def.Casts {
+		if cast.IdentityMatches(target) {
+			return cast
+		}
+	}
+	return nil
+}
+
+func (def *Definition) AddCast(cast *Cast) {
+	def.Casts = append(def.Casts, cast)
+}
+
 func (def *Definition) IsRoleDefined(role string) bool {
 	if util.IStrsContains(MACRO_ROLES, role) {
 		return true
 	}
+	if def.TryGetRoleNamed(role) != nil {
+		return true
+	}
 	if def.Database == nil {
 		return false
 	}
@@ -142,6 +239,7 @@ func (def *Definition) Merge(overlay *Definition) {
 	}
 	def.IncludeFiles = append(def.IncludeFiles, overlay.IncludeFiles...)
 	def.InlineAssembly = append(def.InlineAssembly, overlay.InlineAssembly...)
+	def.AllowDrops = append(def.AllowDrops, overlay.AllowDrops...)
 
 	if def.Database == nil {
 		def.Database = &Database{}
@@ -165,6 +263,38 @@ func (def *Definition) Merge(overlay *Definition) {
 		}
 	}
 
+	for _, overlayExt := range overlay.Extensions {
+		if baseExt := def.TryGetExtensionNamed(overlayExt.Name); baseExt != nil {
+			baseExt.Merge(overlayExt)
+		} else {
+			def.AddExtension(overlayExt)
+		}
+	}
+
+	for _, overlayRole := range overlay.Roles {
+		if baseRole := def.TryGetRoleNamed(overlayRole.Name); baseRole != nil {
+			baseRole.Merge(overlayRole)
+		} else {
+			def.AddRole(overlayRole)
+		}
+	}
+
+	for _, overlayDP := range overlay.DefaultPrivileges {
+		if baseDP := def.TryGetDefaultPrivilegesMatching(overlayDP); baseDP != nil {
+			baseDP.Merge(overlayDP)
+		} else {
+			def.AddDefaultPrivileges(overlayDP)
+		}
+	}
+
+	for _, overlayCast := range overlay.Casts {
+		if baseCast := def.TryGetCastMatching(overlayCast); baseCast != nil {
+			baseCast.Merge(overlayCast)
+		} else {
+			def.AddCast(overlayCast)
+		}
+	}
+
 	for _, overlaySql := range overlay.Sql {
 		if baseSql := def.TryGetSqlMatching(overlaySql); baseSql != nil {
 			baseSql.Merge(overlaySql)
@@ -195,6 +325,43 @@ func (def *Definition) Validate() []error {
 		}
 	}
 
+	for i, ext := range def.Extensions {
+		for _, other := range def.Extensions[i+1:] {
+			if ext.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two extensions with name %q", ext.Name))
+			}
+		}
+	}
+
+	for i, role := range def.Roles {
+		for _, other := range def.Roles[i+1:] {
+			if role.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two roles with name %q", role.Name))
+			}
+		}
+		if util.IStrsContains(role.MemberOf, role.Name) {
+			out = append(out, fmt.Errorf("role %s can't be a member of itself", role.Name))
+		}
+	}
+
+	for i, dp := range def.DefaultPrivileges {
+		out = append(out, dp.Validate(def)...)
+		for _, other := range def.DefaultPrivileges[i+1:] {
+			if dp.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two sets of %s", dp))
+			}
+		}
+	}
+
+	for i, cast := range def.Casts {
+		out = append(out, cast.Validate(def)...)
+		for _, other := range def.Casts[i+1:] {
+			if cast.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two of the %s", cast))
+			}
+		}
+	}
+
 	for i, sql := range def.Sql {
 		out = append(out, sql.Validate(def)...)
 		for _, other := range def.Sql[i+1:] {
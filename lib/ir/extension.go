@@ -0,0 +1,39 @@
+package ir
+
+import "strings"
+
+// Extension is a postgres extension installed in the database. Version and Schema
+// may be left empty, in which case the extension's default version is installed
+// into the first schema of the search path, and neither is changed on upgrade.
+type Extension struct {
+	Name    string
+	Schema  string
+	Version string
+	// Cascade installs any extensions this one requires which aren't installed yet
+	Cascade bool
+}
+
+func (self *Extension) IdentityMatches(other *Extension) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Name, other.Name)
+}
+
+func (self *Extension) Merge(overlay *Extension) {
+	if overlay == nil {
+		return
+	}
+	self.Schema = overlay.Schema
+	self.Version = overlay.Version
+	self.Cascade = overlay.Cascade
+}
+
+func (self *Extension) Equals(other *Extension) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Name, other.Name) &&
+		self.Schema == other.Schema &&
+		self.Version == other.Version
+}
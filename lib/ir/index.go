@@ -90,9 +90,7 @@ func (idx *Index) Equals(other *Index, sqlFormat SqlFormat) bool {
 	if idx.Unique != other.Unique {
 		return false
 	}
-	if idx.Concurrently != other.Concurrently {
-		return false
-	}
+	// Concurrently is how the index gets built, not part of the index itself
 	if !idx.Using.Equals(other.Using) {
 		return false
 	}
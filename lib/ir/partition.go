@@ -9,11 +9,19 @@ type TablePartitionType string
 
 const (
 	TablePartitionTypeModulo TablePartitionType = "MODULO"
+
+	// Native declarative partitioning types. The partition key is given by
+	// the "column" option, and each segment names a partition table whose
+	// value is its bound, e.g. "FROM (1) TO (10)", "IN ('a')",
+	// "WITH (MODULUS 4, REMAINDER 0)" or "DEFAULT"
+	TablePartitionTypeRange TablePartitionType = "RANGE"
+	TablePartitionTypeList  TablePartitionType = "LIST"
+	TablePartitionTypeHash  TablePartitionType = "HASH"
 )
 
 func NewTablePartitionType(s string) (TablePartitionType, error) {
 	rv := TablePartitionType(s)
-	if rv.Equals(TablePartitionTypeModulo) {
+	if rv.Equals(TablePartitionTypeModulo) || rv.IsNative() {
 		return rv, nil
 	}
 	return "", fmt.Errorf("invalid TablePartitionType '%s'", s)
@@ -23,6 +31,14 @@ func (tpt TablePartitionType) Equals(other TablePartitionType) bool {
 	return strings.EqualFold(string(tpt), string(other))
 }
 
+// IsNative returns true if this partition type is implemented with
+// PARTITION BY rather than inheritance and triggers
+func (tpt TablePartitionType) IsNative() bool {
+	return tpt.Equals(TablePartitionTypeRange) ||
+		tpt.Equals(TablePartitionTypeList) ||
+		tpt.Equals(TablePartitionTypeHash)
+}
+
 type TablePartition struct {
 	Type      TablePartitionType
 	SqlFormat SqlFormat
@@ -48,3 +64,12 @@ func (self *TablePartition) TryGetOptionValueNamed(name string) string {
 	}
 	return ""
 }
+
+func (self *TablePartition) TryGetSegmentNamed(name string) *TablePartitionSegment {
+	for _, segment := range self.Segments {
+		if strings.EqualFold(segment.Name, name) {
+			return segment
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+type PolicyCommand string
+
+const (
+	PolicyCommandAll    PolicyCommand = "ALL"
+	PolicyCommandSelect PolicyCommand = "SELECT"
+	PolicyCommandInsert PolicyCommand = "INSERT"
+	PolicyCommandUpdate PolicyCommand = "UPDATE"
+	PolicyCommandDelete PolicyCommand = "DELETE"
+)
+
+func NewPolicyCommand(s string) (PolicyCommand, error) {
+	if s == "" {
+		return PolicyCommandAll, nil
+	}
+	v := PolicyCommand(s)
+	for _, cmd := range []PolicyCommand{PolicyCommandAll, PolicyCommandSelect, PolicyCommandInsert, PolicyCommandUpdate, PolicyCommandDelete} {
+		if v.Equals(cmd) {
+			return cmd, nil
+		}
+	}
+	return "", fmt.Errorf("invalid policy command '%s'", s)
+}
+
+func (pc PolicyCommand) Equals(other PolicyCommand) bool {
+	return strings.EqualFold(string(pc), string(other))
+}
+
+// Policy is a row level security policy on a table. Policies only take effect once
+// row level security is enabled on their table.
+type Policy struct {
+	Name    string
+	Command PolicyCommand
+	// Roles the policy applies to, or every role if empty
+	Roles []string
+	// Using filters the rows which already exist, WithCheck the rows being written
+	Using     string
+	WithCheck string
+	// Restrictive policies must all pass, as well as at least one of the permissive ones
+	Restrictive bool
+}
+
+func (self *Policy) IdentityMatches(other *Policy) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Name, other.Name)
+}
+
+func (self *Policy) Equals(other *Policy) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return self.IdentityMatches(other) &&
+		self.Command.Equals(other.Command) &&
+		util.IStrsEq(self.Roles, other.Roles) &&
+		self.Using == other.Using &&
+		self.WithCheck == other.WithCheck &&
+		self.Restrictive == other.Restrictive
+}
+
+func (self *Policy) Merge(overlay *Policy) {
+	if overlay == nil {
+		return
+	}
+	self.Command = overlay.Command
+	self.Roles = overlay.Roles
+	self.Using = overlay.Using
+	self.WithCheck = overlay.WithCheck
+	self.Restrictive = overlay.Restrictive
+}
+
+func (self *Policy) Validate(doc *Definition, schema *Schema, table *Table) []error {
+	out := []error{}
+	if self.Command.Equals(PolicyCommandInsert) && self.Using != "" {
+		out = append(out, fmt.Errorf("policy %s on table %s.%s is for INSERT, which can only have a WITH CHECK expression", self.Name, schema.Name, table.Name))
+	}
+	if (self.Command.Equals(PolicyCommandSelect) || self.Command.Equals(PolicyCommandDelete)) && self.WithCheck != "" {
+		out = append(out, fmt.Errorf("policy %s on table %s.%s is for %s, which can only have a USING expression", self.Name, schema.Name, table.Name, self.Command))
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package ir
+
+import (
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// Role is a role the definition creates and manages, as opposed to the roles in RoleAssignment,
+// which are only referred to and have to exist already
+type Role struct {
+	Name     string
+	Login    bool
+	Inherit  bool
+	CreateDB bool
+	// ConnectionLimit is unlimited when nil
+	ConnectionLimit *int
+	// MemberOf are the roles this one is granted
+	MemberOf []string
+	// PasswordEnv names the environment variable holding the role's password when generating sql.
+	// The password itself is never part of the definition, and is hashed before it is written out to upgrade files,
+	// though not to migration plans. A value which is already hashed is written as it is
+	PasswordEnv string
+}
+
+func (self *Role) IdentityMatches(other *Role) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(self.Name, other.Name)
+}
+
+// AttributesEqual compares everything ALTER ROLE sets but membership and the password,
+// which can't be compared, only where it comes from
+func (self *Role) AttributesEqual(other *Role) bool {
+	if self == nil || other == nil {
+		return false
+	}
+	return self.Login == other.Login &&
+		self.Inherit == other.Inherit &&
+		self.CreateDB == other.CreateDB &&
+		util.PtrEq(self.ConnectionLimit, other.ConnectionLimit)
+}
+
+func (self *Role) Merge(overlay *Role) {
+	if overlay == nil {
+		return
+	}
+	self.Login = overlay.Login
+	self.Inherit = overlay.Inherit
+	self.CreateDB = overlay.CreateDB
+	self.ConnectionLimit = overlay.ConnectionLimit
+	self.MemberOf = overlay.MemberOf
+	self.PasswordEnv = overlay.PasswordEnv
+}
@@ -53,6 +53,20 @@ func (self *Schema) TryGetTableNamed(name string) *Table {
 	return nil
 }
 
+// TryGetNativePartitionParent returns the natively partitioned table which
+// has a partition segment with the given name, if any
+func (self *Schema) TryGetNativePartitionParent(name string) *Table {
+	if self == nil {
+		return nil
+	}
+	for _, table := range self.Tables {
+		if table.Partitioning != nil && table.Partitioning.Type.IsNative() && table.Partitioning.TryGetSegmentNamed(name) != nil {
+			return table
+		}
+	}
+	return nil
+}
+
 func (self *Schema) AddTable(table *Table) {
 	// TODO(feat) sanity check
 	self.Tables = append(self.Tables, table)
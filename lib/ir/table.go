@@ -28,6 +28,11 @@ type Table struct {
 	Constraints    []*Constraint
 	Grants         []*Grant
 	Rows           *DataRows
+	// RowLevelSecurity makes the policies apply to everyone but the table owner,
+	// and ForceRowLevelSecurity to the table owner as well
+	RowLevelSecurity      bool
+	ForceRowLevelSecurity bool
+	Policies              []*Policy
 }
 
 type TableOption struct {
@@ -210,6 +215,22 @@ func (self *Table) AddConstraint(constraint *Constraint) {
 	self.Constraints = append(self.Constraints, constraint)
 }
 
+func (self *Table) TryGetPolicyNamed(name string) *Policy {
+	if self == nil {
+		return nil
+	}
+	for _, policy := range self.Policies {
+		if policy.IdentityMatches(&Policy{Name: name}) {
+			return policy
+		}
+	}
+	return nil
+}
+
+func (self *Table) AddPolicy(policy *Policy) {
+	self.Policies = append(self.Policies, policy)
+}
+
 // TODO(go,nth) replace other table name matches with IdentityMatches where possible
 // TODO(go,nth) replace schema.TryGetTableNamed with TryGetTableMatching where possible
 func (self *Table) IdentityMatches(other *Table) bool {
@@ -272,6 +293,16 @@ func (self *Table) Merge(overlay *Table) {
 		self.AddGrant(overlayGrant)
 	}
 
+	self.RowLevelSecurity = overlay.RowLevelSecurity
+	self.ForceRowLevelSecurity = overlay.ForceRowLevelSecurity
+	for _, overlayPolicy := range overlay.Policies {
+		if basePolicy := self.TryGetPolicyNamed(overlayPolicy.Name); basePolicy != nil {
+			basePolicy.Merge(overlayPolicy)
+		} else {
+			self.AddPolicy(overlayPolicy)
+		}
+	}
+
 	self.MergeDataRows(overlay.Rows)
 }
 
@@ -331,6 +362,14 @@ func (self *Table) Validate(doc *Definition, schema *Schema) []error {
 			}
 		}
 	}
+	for i, policy := range self.Policies {
+		out = append(out, policy.Validate(doc, schema, self)...)
+		for _, other := range self.Policies[i+1:] {
+			if policy.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two policies in table %s.%s with name %q", schema.Name, self.Name, policy.Name))
+			}
+		}
+	}
 
 	return out
 }
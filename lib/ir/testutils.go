@@ -60,6 +60,32 @@ func FullFeatureSchema(role string) Definition {
 					Increment:     util.Some(1),
 				}},
 			},
+			{
+				Name:        "identity_schema",
+				Description: "test identity and generated columns handled appropriately",
+				Owner:       role,
+				Tables: []*Table{
+					{
+						Name:           "t1",
+						Owner:          role,
+						PrimaryKeyName: "t1_pkey",
+						PrimaryKey:     []string{"id"},
+						Columns: []*Column{
+							{Name: "id", Type: "bigint", Identity: &ColumnIdentity{
+								Generation: IdentityGenerationByDefault,
+								Start:      util.Ptr(100),
+								Cache:      util.Ptr(10),
+							}},
+							{Name: "cents", Type: "bigint"},
+							{Name: "doubled", Type: "bigint", GeneratedAs: "(cents * 2)"},
+						},
+						RowLevelSecurity: true,
+						Policies: []*Policy{
+							{Name: "positive_cents", Command: PolicyCommandAll, Using: "(cents > 0)"},
+						},
+					},
+				},
+			},
 			{
 				Name:        "sequence_schema",
 				Description: "test schema with a single sequence",
@@ -280,10 +306,35 @@ func FullFeatureSchema(role string) Definition {
 						Permissions: []string{"USAGE"},
 					},
 				},
-				Types:     nil,
+				Types: []*TypeDef{
+					{
+						Name:      "score_range",
+						Kind:      DataTypeKindRange,
+						RangeType: &DataTypeRangeType{Subtype: "integer"},
+					},
+				},
 				Sequences: nil,
 				Triggers:  nil,
 			},
 		},
+		Extensions: []*Extension{
+			{Name: "pgcrypto", Schema: "public", Version: "1.3"},
+		},
+		Roles: []*Role{
+			{Name: AdditionalRole, Inherit: true},
+		},
+		Casts: []*Cast{
+			{Source: "score_range", Target: "text", Method: CastMethodInout, Context: CastContextAssignment},
+		},
+		DefaultPrivileges: []*DefaultPrivileges{
+			{
+				Role:       role,
+				Schema:     "public",
+				ObjectType: DefaultPrivilegesTables,
+				Grants: []*Grant{
+					{Roles: []string{AdditionalRole}, Permissions: []string{PermissionSelect}},
+				},
+			},
+		},
 	}
 }
@@ -13,8 +13,21 @@ const (
 	DataTypeKindEnum TypeDefKind = iota
 	DataTypeKindComposite
 	DataTypeKindDomain
+	DataTypeKindRange
+	// DataTypeKindBase is a type implemented by its own input and output functions,
+	// or only a shell of one when those aren't given
+	DataTypeKindBase
 )
 
+func NewTypeDefKind(s string) (TypeDefKind, error) {
+	for _, kind := range []TypeDefKind{DataTypeKindEnum, DataTypeKindComposite, DataTypeKindDomain, DataTypeKindRange, DataTypeKindBase} {
+		if strings.EqualFold(s, kind.String()) {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid type kind '%s'", s)
+}
+
 // String returns a value suitable for showing the user.
 // !! Do not use this as part of SQL. It can change and
 // is not intended to be valid SQL!!
@@ -26,6 +39,10 @@ func (tdk TypeDefKind) String() string {
 		return "composite"
 	case DataTypeKindDomain:
 		return "domain"
+	case DataTypeKindRange:
+		return "range"
+	case DataTypeKindBase:
+		return "base"
 	default:
 		return "unknown"
 	}
@@ -39,9 +56,16 @@ type TypeDef struct {
 	CompositeFields   []DataTypeCompositeField
 	DomainType        *DataTypeDomainType
 	DomainConstraints []DataTypeDomainConstraint
+	RangeType         *DataTypeRangeType
+	BaseType          *DataTypeBaseType
 }
 
-type DataTypeEnumValue string
+// DataTypeEnumValue is one of the values of an enum. OldName is set when the value
+// used to be called something else, so it's renamed rather than added
+type DataTypeEnumValue struct {
+	Name    string
+	OldName string
+}
 
 type DataTypeCompositeField struct {
 	Name string
@@ -59,6 +83,47 @@ type DataTypeDomainConstraint struct {
 	Check string
 }
 
+// DataTypeRangeType defines a range over Subtype. Canonical and SubtypeDiff name functions, the rest is optional.
+type DataTypeRangeType struct {
+	Subtype        string
+	SubtypeOpClass string
+	Collation      string
+	Canonical      string
+	SubtypeDiff    string
+}
+
+// DataTypeBaseType names the functions implementing a base type, along with how its values are stored.
+// Input and Output are required, everything else is optional.
+type DataTypeBaseType struct {
+	Input   string
+	Output  string
+	Receive string
+	Send    string
+	// InternalLength is a number of bytes, or "variable"
+	InternalLength string
+	PassedByValue  bool
+	Alignment      string
+	Storage        string
+}
+
+// SupportFunctions are the names of the functions the type is defined with, in the order they're given
+func (td *TypeDef) SupportFunctions() []string {
+	names := []string{}
+	switch {
+	case td.Kind == DataTypeKindRange && td.RangeType != nil:
+		names = []string{td.RangeType.Canonical, td.RangeType.SubtypeDiff}
+	case td.Kind == DataTypeKindBase && td.BaseType != nil:
+		names = []string{td.BaseType.Input, td.BaseType.Output, td.BaseType.Receive, td.BaseType.Send}
+	}
+	out := []string{}
+	for _, name := range names {
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
 func (td *TypeDef) TryGetDomainConstraintNamed(name string) *DataTypeDomainConstraint {
 	util.Assert(td.Kind == DataTypeKindDomain, "can only be called for Domain kind")
 	for _, constraint := range td.DomainConstraints {
@@ -69,6 +134,17 @@ func (td *TypeDef) TryGetDomainConstraintNamed(name string) *DataTypeDomainConst
 	return nil
 }
 
+// TryGetEnumValueNamed returns the index of the enum value, or -1 if the type has no such value.
+// Enum values are case sensitive, unlike most names
+func (td *TypeDef) TryGetEnumValueNamed(name string) int {
+	for i, val := range td.EnumValues {
+		if val.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (td *TypeDef) IdentityMatches(other *TypeDef) bool {
 	if td == nil || other == nil {
 		return false
@@ -86,6 +162,8 @@ func (td *TypeDef) Merge(overlay *TypeDef) {
 	td.CompositeFields = overlay.CompositeFields
 	td.DomainType = overlay.DomainType
 	td.DomainConstraints = overlay.DomainConstraints
+	td.RangeType = overlay.RangeType
+	td.BaseType = overlay.BaseType
 }
 
 func (td *TypeDef) Validate(doc *Definition, schema *Schema) []error {
@@ -115,6 +193,14 @@ func (td *TypeDef) Validate(doc *Definition, schema *Schema) []error {
 		if len(td.CompositeFields) > 0 {
 			out = append(out, fmt.Errorf("enum data type %s.%s must not define composite fields", schema.Name, td.Name))
 		}
+		for i, val := range td.EnumValues {
+			if td.TryGetEnumValueNamed(val.Name) != i {
+				out = append(out, fmt.Errorf("enum data type %s.%s has value %q more than once", schema.Name, td.Name, val.Name))
+			}
+			if val.OldName != "" && td.TryGetEnumValueNamed(val.OldName) >= 0 {
+				out = append(out, fmt.Errorf("enum data type %s.%s value %q can't be renamed from %q, which is still a value", schema.Name, td.Name, val.Name, val.OldName))
+			}
+		}
 	case DataTypeKindDomain:
 		if len(td.EnumValues) > 0 {
 			out = append(out, fmt.Errorf("domain data type %s.%s must not define enum values", schema.Name, td.Name))
@@ -138,6 +224,20 @@ func (td *TypeDef) Validate(doc *Definition, schema *Schema) []error {
 		if len(td.CompositeFields) == 0 {
 			out = append(out, fmt.Errorf("composite data type %s.%s must define at least one composite field", schema.Name, td.Name))
 		}
+	case DataTypeKindRange:
+		if len(td.EnumValues) > 0 || len(td.CompositeFields) > 0 || td.DomainType != nil || len(td.DomainConstraints) > 0 || td.BaseType != nil {
+			out = append(out, fmt.Errorf("range data type %s.%s must only define a range type", schema.Name, td.Name))
+		}
+		if td.RangeType == nil || td.RangeType.Subtype == "" {
+			out = append(out, fmt.Errorf("range data type %s.%s must define a range type with a subtype", schema.Name, td.Name))
+		}
+	case DataTypeKindBase:
+		if len(td.EnumValues) > 0 || len(td.CompositeFields) > 0 || td.DomainType != nil || len(td.DomainConstraints) > 0 || td.RangeType != nil {
+			out = append(out, fmt.Errorf("base data type %s.%s must only define a base type", schema.Name, td.Name))
+		}
+		if td.BaseType != nil && (td.BaseType.Input == "" || td.BaseType.Output == "") {
+			out = append(out, fmt.Errorf("base data type %s.%s must name both its input and output functions", schema.Name, td.Name))
+		}
 	}
 
 	return out
@@ -196,6 +296,14 @@ func (td *TypeDef) Equals(other *TypeDef) bool {
 			}
 		}
 		return true
+	} else if td.Kind == DataTypeKindRange {
+		return td.RangeType.Equals(other.RangeType)
+	} else if td.Kind == DataTypeKindBase {
+		if td.BaseType == nil || other.BaseType == nil {
+			// both are only shells
+			return td.BaseType == other.BaseType
+		}
+		return *td.BaseType == *other.BaseType
 	} else {
 		// TODO(go,nth) should we assert here or otherwise have some kind of warning?
 		return false
@@ -204,7 +312,7 @@ func (td *TypeDef) Equals(other *TypeDef) bool {
 
 func (enumVal DataTypeEnumValue) Equals(other DataTypeEnumValue) bool {
 	// TODO(go,core) are non-postgres engines case insensitive?
-	return enumVal == other
+	return enumVal.Name == other.Name
 }
 
 func (dtcf DataTypeCompositeField) Equals(other DataTypeCompositeField) bool {
@@ -221,6 +329,17 @@ func (domain *DataTypeDomainType) Equals(other *DataTypeDomainType) bool {
 		domain.Nullable == other.Nullable
 }
 
+func (rt *DataTypeRangeType) Equals(other *DataTypeRangeType) bool {
+	if rt == nil || other == nil {
+		return false
+	}
+	return strings.EqualFold(rt.Subtype, other.Subtype) &&
+		strings.EqualFold(rt.SubtypeOpClass, other.SubtypeOpClass) &&
+		strings.EqualFold(rt.Collation, other.Collation) &&
+		strings.EqualFold(rt.Canonical, other.Canonical) &&
+		strings.EqualFold(rt.SubtypeDiff, other.SubtypeDiff)
+}
+
 func (dConst *DataTypeDomainConstraint) GetNormalizedCheck() string {
 	// @TODO: This is Postgres-specific and doesnt't belong in the IR.
 	// However, it's assumed by .Equals() so will require some careful
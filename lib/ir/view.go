@@ -12,8 +12,10 @@ type View struct {
 	Description    string
 	Owner          string
 	DependsOnViews []string
+	Materialized   bool
 	Grants         []*Grant
 	Queries        []*ViewQuery
+	Indexes        []*Index // only valid for materialized views
 }
 
 type ViewQuery struct {
@@ -44,14 +46,37 @@ func (self *View) AddGrant(grant *Grant) {
 	self.Grants = append(self.Grants, grant)
 }
 
+func (self *View) TryGetIndexMatching(target *Index) *Index {
+	for _, index := range self.Indexes {
+		if index.IdentityMatches(target) {
+			return index
+		}
+	}
+	return nil
+}
+
+func (self *View) AddIndex(index *Index) {
+	// TODO(feat) sanity check
+	self.Indexes = append(self.Indexes, index)
+}
+
 func (self *View) Merge(overlay *View) {
 	self.Description = overlay.Description
 	self.Owner = overlay.Owner
+	self.Materialized = overlay.Materialized
 
 	for _, grant := range overlay.Grants {
 		self.AddGrant(grant)
 	}
 
+	for _, overlayIndex := range overlay.Indexes {
+		if baseIndex := self.TryGetIndexMatching(overlayIndex); baseIndex != nil {
+			baseIndex.Merge(overlayIndex)
+		} else {
+			self.AddIndex(overlayIndex)
+		}
+	}
+
 	for _, overlayQuery := range overlay.Queries {
 		for _, baseQuery := range self.Queries {
 			if baseQuery.SqlFormat.Equals(overlayQuery.SqlFormat) {
@@ -73,7 +98,10 @@ func (self *View) Equals(other *View, sqlFormat SqlFormat) bool {
 	if self == nil || other == nil {
 		return false
 	}
-	if strings.EqualFold(self.Owner, other.Owner) {
+	if !strings.EqualFold(self.Owner, other.Owner) {
+		return false
+	}
+	if self.Materialized != other.Materialized {
 		return false
 	}
 	if !self.TryGetViewQuery(sqlFormat).Equals(other.TryGetViewQuery(sqlFormat)) {
@@ -82,10 +110,21 @@ func (self *View) Equals(other *View, sqlFormat SqlFormat) bool {
 	return true
 }
 
-func (self *View) Validate(*Definition, *Schema) []error {
+func (self *View) Validate(_ *Definition, schema *Schema) []error {
 	// TODO(go,3) validate owner, remove from other codepaths
 	// TODO(go,3) validate ViewQueries
-	return nil
+	out := []error{}
+	if len(self.Indexes) > 0 && !self.Materialized {
+		out = append(out, fmt.Errorf("view %s.%s has indexes but is not materialized", schema.Name, self.Name))
+	}
+	for i, index := range self.Indexes {
+		for _, other := range self.Indexes[i+1:] {
+			if index.IdentityMatches(other) {
+				out = append(out, fmt.Errorf("found two indexes in view %s.%s with name %q", schema.Name, self.Name, index.Name))
+			}
+		}
+	}
+	return out
 }
 
 func (self *View) TryGetViewQuery(sqlFormat SqlFormat) *ViewQuery {
@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+type LintSeverity string
+
+const (
+	// LintInfo findings are worth knowing about but rarely a problem
+	LintInfo LintSeverity = "info"
+	// LintWarning findings block reads or writes while the table is scanned
+	LintWarning LintSeverity = "warning"
+	// LintError findings rewrite the whole table under an exclusive lock
+	LintError LintSeverity = "error"
+)
+
+var lintSeverityRanks = map[LintSeverity]int{
+	LintInfo:    1,
+	LintWarning: 2,
+	LintError:   3,
+}
+
+// AtLeast reports whether s is as severe as other
+func (s LintSeverity) AtLeast(other LintSeverity) bool {
+	return lintSeverityRanks[s] >= lintSeverityRanks[other]
+}
+
+// ParseLintSeverity accepts a severity name, or "none" which no finding is severe enough to reach
+func ParseLintSeverity(s string) (LintSeverity, error) {
+	severity := LintSeverity(strings.ToLower(s))
+	if severity == "none" {
+		return severity, nil
+	}
+	if _, ok := lintSeverityRanks[severity]; !ok {
+		return "", fmt.Errorf("unknown lint severity %q, expected info, warning, error or none", s)
+	}
+	return severity, nil
+}
+
+// LintFinding is a single statement of an upgrade which is likely to hold locks for a long time on a live database
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	Rule     string       `json:"rule"`
+	Stage    int          `json:"stage"`
+	Object   string       `json:"object"`
+	Message  string       `json:"message"`
+	SQL      string       `json:"sql"`
+}
+
+// LintReport is the result of linting an upgrade
+type LintReport struct {
+	Findings []LintFinding `json:"findings"`
+}
+
+// Fails reports whether any finding is at least as severe as threshold
+func (r *LintReport) Fails(threshold LintSeverity) bool {
+	if r == nil || lintSeverityRanks[threshold] == 0 {
+		return false
+	}
+	for _, finding := range r.Findings {
+		if finding.Severity.AtLeast(threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LintReport) JSON() ([]byte, error) {
+	r.Findings = util.NonNil(r.Findings)
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (r *LintReport) Text() string {
+	if r == nil || len(r.Findings) == 0 {
+		return "No lint findings\n"
+	}
+	b := strings.Builder{}
+	for _, finding := range r.Findings {
+		b.WriteString(fmt.Sprintf("%-7s stage %d %s: %s [%s]\n", finding.Severity, finding.Stage, finding.Object, finding.Message, finding.Rule))
+		b.WriteString("    " + strings.ReplaceAll(finding.SQL, "\n", "\n    ") + "\n")
+	}
+	return b.String()
+}
+
+// LintFailure is returned when an upgrade has findings at or above the configured severity
+type LintFailure struct {
+	Report    *LintReport
+	Threshold LintSeverity
+}
+
+func (e *LintFailure) Error() string {
+	count := 0
+	for _, finding := range e.Report.Findings {
+		if finding.Severity.AtLeast(e.Threshold) {
+			count++
+		}
+	}
+	return fmt.Sprintf("migration lint found %d problems of severity %s or worse:\n%s", count, e.Threshold, e.Report.Text())
+}
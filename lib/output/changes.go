@@ -0,0 +1,85 @@
+package output
+
+import (
+	"strings"
+)
+
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeAlter  ChangeAction = "alter"
+	ChangeDrop   ChangeAction = "drop"
+	ChangeRename ChangeAction = "rename"
+)
+
+// ChangeIdentity names the object a change applies to. Parent is the owning
+// table for columns, constraints, triggers and policies, the source type of a
+// cast, the role of default privileges, and empty otherwise.
+type ChangeIdentity struct {
+	Schema string
+	Parent string
+	Name   string
+}
+
+func (id ChangeIdentity) String() string {
+	parts := []string{}
+	for _, part := range []string{id.Schema, id.Parent, id.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Change is a single step of an upgrade, as calculated by the differ.
+//
+// Old and New are the IR nodes on either side of the change, where they could
+// be found; Old is nil for creations and New is nil for drops. Statements which
+// don't map to a schema object (comments, literal sql, and so on) have an empty
+// Kind, and are kept so that rendering the changes in order reproduces the upgrade.
+type Change struct {
+	Kind        string
+	Identity    ChangeIdentity
+	Action      ChangeAction
+	NewName     string
+	Old         interface{}
+	New         interface{}
+	Stage       int
+	Destructive bool
+	Statement   ToSql
+	// Parts are the individual changes combined into Statement, if there is more than one
+	Parts []*Change
+}
+
+func (c *Change) ToSql(q Quoter) string {
+	return c.Statement.ToSql(q)
+}
+
+// IsObject reports whether the change applies to a schema object, rather than
+// being a comment or literal sql carried along with the upgrade
+func (c *Change) IsObject() bool {
+	return c.Kind != ""
+}
+
+// FilterChanges returns the changes for which keep returns true, in order
+func FilterChanges(changes []*Change, keep func(*Change) bool) []*Change {
+	out := []*Change{}
+	for _, change := range changes {
+		if keep(change) {
+			out = append(out, change)
+		}
+	}
+	return out
+}
+
+// WriteChanges renders changes into ofs, in order
+func WriteChanges(ofs OutputFileSegmenter, changes []*Change) error {
+	for _, change := range changes {
+		// write the underlying statement so that segmenters can still strip annotations
+		if err := ofs.WriteSql(change.Statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"encoding/json"
+
+	"github.com/dbsteward/dbsteward/lib/util"
+)
+
+// MigrationPlan lists every statement of a build or upgrade, in the order they are written
+type MigrationPlan struct {
+	Stages     []PlanStage     `json:"stages"`
+	Statements []PlanStatement `json:"statements"`
+}
+
+// PlanStage describes one of the stages statements are grouped into
+type PlanStage struct {
+	Number      int    `json:"number"`
+	Description string `json:"description"`
+	// Transactional stages are wrapped in BEGIN/COMMIT, which are not listed as statements
+	Transactional bool `json:"transactional"`
+}
+
+// PlanStatement is a single statement of a migration plan. Kind, Object and Action are empty
+// for statements which don't apply to a particular object, such as literal sql.
+type PlanStatement struct {
+	Stage       int    `json:"stage"`
+	Kind        string `json:"kind,omitempty"`
+	Object      string `json:"object,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Annotation  string `json:"annotation,omitempty"`
+	Destructive bool   `json:"destructive"`
+	// Concurrent statements run outside of a transaction, once their stage has committed
+	Concurrent bool   `json:"concurrent,omitempty"`
+	SQL        string `json:"sql"`
+}
+
+func (p *MigrationPlan) JSON() ([]byte, error) {
+	p.Stages = util.NonNil(p.Stages)
+	p.Statements = util.NonNil(p.Statements)
+	return json.MarshalIndent(p, "", "  ")
+}
@@ -140,3 +140,11 @@ func MapErr[S ~[]T, T, U any](slice S, f func(T) (U, error)) ([]U, error) {
 	}
 	return out, nil
 }
+
+// NonNil returns an empty slice in place of a nil one, so that it marshals to [] rather than null
+func NonNil[S ~[]T, T any](slice S) S {
+	if slice == nil {
+		return S{}
+	}
+	return slice
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"database/sql"
 	"fmt"
+	"reflect"
 )
 
 type Opt[T any] struct {
@@ -73,5 +74,9 @@ func (self Opt[T]) Equals(other Opt[T]) bool {
 		// we need to do a runtime check to see if T implements Equals(T) because we can't specialize T in go 1.18
 		return t.Equals(other.value)
 	}
+	if typ := reflect.TypeOf(self.value); typ == nil || typ.Comparable() {
+		// plain values such as ints and strings compare as they are
+		return (any)(self.value) == (any)(other.value)
+	}
 	panic(fmt.Sprintf("Type %T does not implement Equals(%T)", self.value, self.value))
 }
@@ -3,12 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/dbsteward/dbsteward/lib"
@@ -49,6 +51,8 @@ const (
 	ModeSlonikConvert Mode = 256
 	ModeSlonyCompare  Mode = 512
 	ModeSlonyDiff     Mode = 1024
+	ModeApply         Mode = 2048
+	ModeDrift         Mode = 4096
 )
 
 type DBSteward struct {
@@ -87,6 +91,21 @@ func NewDBSteward() *DBSteward {
 			FileOutputPrefix:               "",
 			IgnoreOldNames:                 false,
 			AlwaysRecreateViews:            true,
+			RefreshMaterializedViews:       false,
+			OutputFormat:                   "text",
+			GenerateDowngrade:              false,
+			SafeMode:                       false,
+			AllowDrop:                      nil,
+			Lint:                           false,
+			LintFailOn:                     lib.LintError,
+			OnlineSafe:                     false,
+			LockTimeout:                    "",
+			StatementTimeout:               "",
+			IdleInTransactionTimeout:       "",
+			LockRetries:                    0,
+			LockRetryDelay:                 5 * time.Second,
+			Idempotent:                     false,
+			TargetVersion:                  "",
 			OldDatabase:                    nil,
 			NewDatabase:                    nil,
 		},
@@ -115,7 +134,13 @@ func (dbsteward *DBSteward) ArgParse() {
 	if len(args.OldXmlFiles) > 0 && len(args.NewXmlFiles) == 0 {
 		dbsteward.fatal("Parameter error: oldxml needs newxml specified for differencing to occur")
 	}
-	if len(args.NewXmlFiles) > 0 && len(args.OldXmlFiles) == 0 {
+	if args.OldDb && len(args.NewXmlFiles) == 0 {
+		dbsteward.fatal("Parameter error: olddb needs newxml specified for differencing to occur")
+	}
+	if args.OldDb && len(args.OldXmlFiles) > 0 {
+		dbsteward.fatal("Parameter error: oldxml and olddb options are not to be mixed")
+	}
+	if len(args.NewXmlFiles) > 0 && len(args.OldXmlFiles) == 0 && !args.OldDb {
 		dbsteward.fatal("Parameter error: oldxml needs newxml specified for differencing to occur")
 	}
 	dbsteward.config.Logger = slog.New(newLogHandler(dbsteward))
@@ -144,6 +169,25 @@ func (dbsteward *DBSteward) ArgParse() {
 	dbsteward.config.IgnoreOldNames = args.IgnoreOldNames
 	dbsteward.config.IgnoreCustomRoles = args.IgnoreCustomRoles
 	dbsteward.config.IgnorePrimaryKeyErrors = args.IgnorePrimaryKeyErrors
+	dbsteward.config.RefreshMaterializedViews = args.RefreshMatViews
+	dbsteward.config.OutputFormat = args.OutputFormat
+	dbsteward.config.GenerateDowngrade = args.Downgrade
+	dbsteward.config.SafeMode = args.Safe
+	dbsteward.config.AllowDrop = args.AllowDrop
+	dbsteward.config.Lint = args.Lint
+	lintFailOn, err := lib.ParseLintSeverity(args.LintFailOn)
+	if err != nil {
+		dbsteward.fatal("lintfailon: %s", err)
+	}
+	dbsteward.config.LintFailOn = lintFailOn
+	dbsteward.config.OnlineSafe = args.Online
+	dbsteward.config.LockTimeout = args.LockTimeout
+	dbsteward.config.StatementTimeout = args.StatementTimeout
+	dbsteward.config.IdleInTransactionTimeout = args.IdleTimeout
+	dbsteward.config.LockRetries = args.LockRetries
+	dbsteward.config.LockRetryDelay = args.LockRetryDelay
+	dbsteward.config.Idempotent = args.Idempotent
+	dbsteward.config.TargetVersion = args.TargetVersion
 	dbsteward.config.RequireSlonyId = args.RequireSlonyId
 	dbsteward.config.RequireSlonySetId = args.RequireSlonySetId
 	dbsteward.config.GenerateSlonik = args.GenerateSlonik
@@ -159,8 +203,12 @@ func (dbsteward *DBSteward) ArgParse() {
 		mode = ModeXmlSort
 	case len(args.XmlConvert) > 0:
 		mode = ModeXmlConvert
+	case len(args.XmlFiles) > 0 && args.Drift:
+		mode = ModeDrift
 	case len(args.XmlFiles) > 0:
 		mode = ModeBuild
+	case len(args.NewXmlFiles) > 0 && args.Apply:
+		mode = ModeApply
 	case len(args.NewXmlFiles) > 0:
 		mode = ModeDiff
 	case args.DbSchemaDump:
@@ -187,22 +235,78 @@ func (dbsteward *DBSteward) ArgParse() {
 			dbsteward.fatal("xmldatainsert only supports one xml file")
 		}
 	}
-	if mode == ModeExtract || mode == ModeDbDataDiff {
-		if len(args.DbHost) == 0 {
-			dbsteward.fatal("dbhost not specified")
-		}
-		if len(args.DbName) == 0 {
-			dbsteward.fatal("dbname not specified")
-		}
-		if len(args.DbUser) == 0 {
-			dbsteward.fatal("dbuser not specified")
+	if args.Apply && mode != ModeApply {
+		dbsteward.fatal("apply needs oldxml or olddb, and newxml specified")
+	}
+	if args.Downgrade && mode != ModeDiff {
+		dbsteward.fatal("downgrade needs oldxml or olddb, and newxml specified")
+	}
+	if args.Safe && mode != ModeDiff && mode != ModeApply && mode != ModeSqlDiff {
+		dbsteward.fatal("safe is only supported when diffing or applying an upgrade")
+	}
+	if len(args.AllowDrop) > 0 && !args.Safe {
+		dbsteward.fatal("allowdrop is only meaningful together with safe")
+	}
+	if args.Lint && mode != ModeDiff && mode != ModeApply {
+		dbsteward.fatal("lint is only supported when diffing or applying an upgrade")
+	}
+	if lintFailOn != lib.LintError && !args.Lint {
+		dbsteward.fatal("lintfailon is only meaningful together with lint")
+	}
+	if args.Online && mode != ModeDiff && mode != ModeApply {
+		dbsteward.fatal("online is only supported when diffing or applying an upgrade")
+	}
+	if args.Online && args.SingleStageUpgrade {
+		dbsteward.fatal("online needs a staged upgrade, a single stage upgrade runs in one transaction")
+	}
+	timeouts := args.LockTimeout != "" || args.StatementTimeout != "" || args.IdleTimeout != ""
+	if timeouts && mode != ModeBuild && mode != ModeDiff && mode != ModeApply {
+		dbsteward.fatal("locktimeout, statementtimeout and idletimeout are only supported when building, diffing or applying")
+	}
+	if args.Idempotent && mode != ModeBuild {
+		dbsteward.fatal("idempotent is only supported when building")
+	}
+	if args.TargetVersion != "" && mode != ModeBuild && mode != ModeDiff && mode != ModeApply {
+		dbsteward.fatal("target-version is only supported when building, diffing or applying")
+	}
+	if args.LockRetries > 0 && !args.Apply {
+		dbsteward.fatal("lockretries is only supported together with apply")
+	}
+	if args.DryRun && !args.Apply {
+		dbsteward.fatal("dry-run is only supported together with apply")
+	}
+	if args.Drift && mode != ModeDrift {
+		dbsteward.fatal("drift needs xml specified")
+	}
+	if args.OutputFormat != "text" && args.OutputFormat != "json" {
+		dbsteward.fatal("output-format must be text or json")
+	}
+	if args.OutputFormat == "json" && mode != ModeBuild && mode != ModeDiff && mode != ModeDrift {
+		dbsteward.fatal("output-format json is only supported for build, diff and drift")
+	}
+	if mode == ModeApply && args.GenerateSlonik {
+		dbsteward.fatal("generateslonik output cannot be applied directly to a database")
+	}
+	connects := mode == ModeExtract || mode == ModeDbDataDiff || mode == ModeApply || mode == ModeDrift || args.OldDb
+	if connects {
+		if len(args.Db) > 0 && (len(args.DbHost) > 0 || args.DbPort > 0 || len(args.DbName) > 0 || len(args.DbUser) > 0 || args.DbPassword != nil) {
+			dbsteward.fatal("Parameter error: db and dbhost, dbport, dbname, dbuser or dbpassword options are not to be mixed")
 		}
-		if args.DbPassword == nil {
-			p, err := util.PromptPassword("[DBSteward] Enter password for postgres://%s@%s:%d/%s: ", args.DbUser, args.DbHost, args.DbPort, args.DbName)
+		if needsPasswordPrompt(args) {
+			// there's no password in the connection string yet, or we wouldn't be asking for one
+			p, err := util.PromptPassword("[DBSteward] Enter password for %s: ", connectionString(args))
 			dbsteward.fatalIfError(err, "Could not read password input")
 			args.DbPassword = &p
 		}
 	}
+	if mode == ModeSqlDiff {
+		if len(args.OldSql) == 0 {
+			dbsteward.fatal("oldsql not specified")
+		}
+		if len(args.NewSql) == 0 {
+			dbsteward.fatal("newsql not specified")
+		}
+	}
 	if mode == ModeExtract || mode == ModeSqlDiff {
 		if len(args.OutputFile) == 0 {
 			dbsteward.fatal("output file not specified")
@@ -251,6 +355,11 @@ func (dbsteward *DBSteward) ArgParse() {
 	dbsteward.config.QuoteIllegalIdentifiers = args.QuoteIllegalNames
 	dbsteward.config.QuoteReservedIdentifiers = args.QuoteReservedNames
 
+	connString := ""
+	if connects {
+		connString = connectionString(args)
+	}
+
 	// TODO(go,3) move all of these to separate subcommands
 	switch mode {
 	case ModeXmlDataInsert:
@@ -264,11 +373,19 @@ func (dbsteward *DBSteward) ArgParse() {
 	case ModeBuild:
 		dbsteward.doBuild(args.XmlFiles, args.PgDataXml, args.XmlCollectDataAddendums)
 	case ModeDiff:
-		dbsteward.doDiff(args.OldXmlFiles, args.NewXmlFiles, args.PgDataXml)
+		if args.OldDb {
+			dbsteward.doDbDiff(args.NewXmlFiles, args.PgDataXml, connString)
+		} else {
+			dbsteward.doDiff(args.OldXmlFiles, args.NewXmlFiles, args.PgDataXml)
+		}
+	case ModeApply:
+		dbsteward.doApply(args.OldXmlFiles, args.OldDb, args.NewXmlFiles, args.PgDataXml, connString, args.DryRun)
 	case ModeExtract:
-		dbsteward.doExtract(args.DbHost, args.DbPort, args.DbName, args.DbUser, *args.DbPassword, args.OutputFile)
+		dbsteward.doExtract(connString, args.OutputFile)
 	case ModeDbDataDiff:
-		dbsteward.doDbDataDiff(args.XmlFiles, args.PgDataXml, args.XmlCollectDataAddendums, args.DbHost, args.DbPort, args.DbName, args.DbUser, *args.DbPassword)
+		dbsteward.doDbDataDiff(args.XmlFiles, args.PgDataXml, args.XmlCollectDataAddendums, connString)
+	case ModeDrift:
+		dbsteward.doDrift(args.XmlFiles, args.PgDataXml, connString, args.OutputFormat, args.OutputFile)
 	case ModeSqlDiff:
 		dbsteward.doSqlDiff(args.OldSql, args.NewSql, args.OutputFile)
 	case ModeSlonikConvert:
@@ -282,6 +399,57 @@ func (dbsteward *DBSteward) ArgParse() {
 	}
 }
 
+// connectionString builds a libpq style connection string from the --db or --dbhost etc parameters.
+// Anything left unspecified is filled in by the driver from PG* environment variables,
+// pg_service.conf and ~/.pgpass, as libpq would.
+func connectionString(args *config.Args) string {
+	if len(args.Db) > 0 {
+		return args.Db
+	}
+	parts := []string{}
+	add := func(key, value string) {
+		if len(value) > 0 {
+			parts = append(parts, key+"="+dsnQuote(value))
+		}
+	}
+	add("host", args.DbHost)
+	if args.DbPort > 0 {
+		add("port", fmt.Sprintf("%d", args.DbPort))
+	}
+	add("dbname", args.DbName)
+	add("user", args.DbUser)
+	if args.DbPassword != nil {
+		parts = append(parts, "password="+dsnQuote(*args.DbPassword))
+	}
+	return strings.Join(parts, " ")
+}
+
+func dsnQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// needsPasswordPrompt decides whether to interactively ask for a password, which we only do
+// when there's no other way for the driver to find one
+func needsPasswordPrompt(args *config.Args) bool {
+	if args.DbPassword != nil || len(args.Db) > 0 {
+		return false
+	}
+	if os.Getenv("PGPASSWORD") != "" || os.Getenv("PGSERVICE") != "" {
+		return false
+	}
+	passfile := os.Getenv("PGPASSFILE")
+	if passfile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return true
+		}
+		passfile = path.Join(home, ".pgpass")
+	}
+	return !util.IsFile(passfile)
+}
+
 // Logger returns an *slog.Logger pointed at the console
 func (dbsteward *DBSteward) Logger() *slog.Logger {
 	if dbsteward == nil {
@@ -302,6 +470,15 @@ func (dbsteward *DBSteward) fatalIfError(err error, s string, args ...interface{
 	}
 }
 
+// exitIfLintFailed exits with status 3 if err is a lint failure, so CI can tell it apart from other errors
+func (dbsteward *DBSteward) exitIfLintFailed(err error) {
+	var failure *lib.LintFailure
+	if errors.As(err, &failure) {
+		dbsteward.logger.Error().Msg(failure.Error())
+		os.Exit(3)
+	}
+}
+
 func (dbsteward *DBSteward) warning(s string, args ...interface{}) {
 	dbsteward.logger.Warn().Msgf(s, args...)
 }
@@ -377,9 +554,6 @@ func (dbsteward *DBSteward) defineSqlFormatDefaultValues(SqlFormat ir.SqlFormat,
 		dbsteward.config.QuoteSchemaNames = false
 		dbsteward.config.QuoteTableNames = false
 		dbsteward.config.QuoteColumnNames = false
-		if args.DbPort == 0 {
-			args.DbPort = 5432
-		}
 	}
 
 	if SqlFormat != ir.SqlFormatPgsql8 {
@@ -542,12 +716,14 @@ func (dbsteward *DBSteward) doBuild(files []string, dataFiles []string, addendum
 	err = ops(dbsteward.config).Build(outputPrefix, dbDoc)
 	dbsteward.fatalIfError(err, "building")
 }
-func (dbsteward *DBSteward) doDiff(oldFiles []string, newFiles []string, dataFiles []string) {
+func (dbsteward *DBSteward) compositeOldDefinition(oldFiles []string) *ir.Definition {
 	dbsteward.Info("Compositing old XML files...")
 	oldDbDoc, err := xml.XmlComposite(dbsteward.Logger(), oldFiles)
 	dbsteward.fatalIfError(err, "compositing")
 	dbsteward.Info("Old XML files %s composited", strings.Join(oldFiles, " "))
-
+	return oldDbDoc
+}
+func (dbsteward *DBSteward) compositeNewDefinition(newFiles []string, dataFiles []string) *ir.Definition {
 	dbsteward.Info("Compositing new XML files...")
 	newDbDoc, err := xml.XmlComposite(dbsteward.Logger(), newFiles)
 	dbsteward.fatalIfError(err, "compositing")
@@ -557,11 +733,28 @@ func (dbsteward *DBSteward) doDiff(oldFiles []string, newFiles []string, dataFil
 		dbsteward.Info("postgres data XML files [%s] composited", strings.Join(dataFiles, " "))
 	}
 	dbsteward.Info("New XML files %s composited", strings.Join(newFiles, " "))
+	return newDbDoc
+}
+
+// extractOldDefinition reads the live database structure to serve as the old side of a diff to newDbDoc
+func (dbsteward *DBSteward) extractOldDefinition(connString string, newDbDoc *ir.Definition) *ir.Definition {
+	dbsteward.Info("Extracting old definition from database...")
+	ops, err := lib.Format(lib.DefaultSqlFormat)
+	dbsteward.fatalIfError(err, "loading default format")
+	oldDbDoc, err := ops(dbsteward.config).ExtractSchema(connString)
+	dbsteward.fatalIfError(err, "extracting")
+	// roles the new definition doesn't name aren't managed by it, so mustn't be dropped
+	oldDbDoc.KeepDeclaredRoles(newDbDoc)
+	return oldDbDoc
+}
+func (dbsteward *DBSteward) doDiff(oldFiles []string, newFiles []string, dataFiles []string) {
+	oldDbDoc := dbsteward.compositeOldDefinition(oldFiles)
+	newDbDoc := dbsteward.compositeNewDefinition(newFiles, dataFiles)
 
 	oldOutputPrefix := dbsteward.calculateFileOutputPrefix(oldFiles)
 	oldCompositeFile := oldOutputPrefix + "_composite.xml"
 	dbsteward.Info("Saving composite as %s", oldCompositeFile)
-	err = xml.SaveDefinition(dbsteward.Logger(), oldCompositeFile, oldDbDoc)
+	err := xml.SaveDefinition(dbsteward.Logger(), oldCompositeFile, oldDbDoc)
 	dbsteward.fatalIfError(err, "saving file")
 
 	newOutputPrefix := dbsteward.calculateFileOutputPrefix(newFiles)
@@ -576,18 +769,70 @@ func (dbsteward *DBSteward) doDiff(oldFiles []string, newFiles []string, dataFil
 		oldOutputPrefix, oldCompositeFile, oldDbDoc, oldFiles,
 		newOutputPrefix, newCompositeFile, newDbDoc, newFiles,
 	)
+	dbsteward.exitIfLintFailed(err)
 	dbsteward.fatalIfError(err, "building upgrade")
 }
-func (dbsteward *DBSteward) doExtract(dbHost string, dbPort uint, dbName, dbUser, dbPass string, outputFile string) {
+func (dbsteward *DBSteward) doDbDiff(newFiles []string, dataFiles []string, connString string) {
+	newDbDoc := dbsteward.compositeNewDefinition(newFiles, dataFiles)
+	oldDbDoc := dbsteward.extractOldDefinition(connString, newDbDoc)
+
+	newOutputPrefix := dbsteward.calculateFileOutputPrefix(newFiles)
+	newCompositeFile := newOutputPrefix + "_composite.xml"
+	dbsteward.Info("Saving composite as %s", newCompositeFile)
+	err := xml.SaveDefinition(dbsteward.Logger(), newCompositeFile, newDbDoc)
+	dbsteward.fatalIfError(err, "saving file")
+
+	ops, err := lib.Format(lib.DefaultSqlFormat)
+	dbsteward.fatalIfError(err, "loading default format")
+	err = ops(dbsteward.config).BuildUpgrade(
+		"", "live database", oldDbDoc, nil,
+		newOutputPrefix, newCompositeFile, newDbDoc, newFiles,
+	)
+	dbsteward.exitIfLintFailed(err)
+	dbsteward.fatalIfError(err, "building upgrade")
+}
+func (dbsteward *DBSteward) doApply(oldFiles []string, oldDb bool, newFiles []string, dataFiles []string, connString string, dryRun bool) {
+	newDbDoc := dbsteward.compositeNewDefinition(newFiles, dataFiles)
+	var oldDbDoc *ir.Definition
+	if oldDb {
+		oldDbDoc = dbsteward.extractOldDefinition(connString, newDbDoc)
+	} else {
+		oldDbDoc = dbsteward.compositeOldDefinition(oldFiles)
+	}
+
+	oldHash, err := xml.DefinitionHash(dbsteward.Logger(), oldDbDoc)
+	dbsteward.fatalIfError(err, "hashing old definition")
+	newHash, err := xml.DefinitionHash(dbsteward.Logger(), newDbDoc)
+	dbsteward.fatalIfError(err, "hashing new definition")
+	history := &lib.MigrationHistory{
+		OldHash:     oldHash,
+		NewHash:     newHash,
+		ToolVersion: Version,
+		// an old definition extracted from the database is the baseline by definition
+		VerifyBaseline: !oldDb,
+	}
+
+	ops, err := lib.Format(lib.DefaultSqlFormat)
+	dbsteward.fatalIfError(err, "loading default format")
+	err = ops(dbsteward.config).ApplyUpgrade(oldDbDoc, newDbDoc, connString, dryRun, history)
+	dbsteward.exitIfLintFailed(err)
+	dbsteward.fatalIfError(err, "applying upgrade")
+	if dryRun {
+		dbsteward.Info("Upgrade applied successfully and rolled back")
+	} else {
+		dbsteward.Info("Upgrade applied successfully")
+	}
+}
+func (dbsteward *DBSteward) doExtract(connString string, outputFile string) {
 	ops, err := lib.Format(lib.DefaultSqlFormat)
 	dbsteward.fatalIfError(err, "loading default format")
-	output, err := ops(dbsteward.config).ExtractSchema(dbHost, dbPort, dbName, dbUser, dbPass)
+	output, err := ops(dbsteward.config).ExtractSchema(connString)
 	dbsteward.fatalIfError(err, "extracting")
 	dbsteward.Info("Saving extracted database schema to %s", outputFile)
 	err = xml.SaveDefinition(dbsteward.Logger(), outputFile, output)
 	dbsteward.fatalIfError(err, "saving file")
 }
-func (dbsteward *DBSteward) doDbDataDiff(files []string, dataFiles []string, addendums uint, dbHost string, dbPort uint, dbName, dbUser, dbPass string) {
+func (dbsteward *DBSteward) doDbDataDiff(files []string, dataFiles []string, addendums uint, connString string) {
 	dbsteward.Info("Compositing XML files...")
 	if addendums > 0 {
 		dbsteward.Info("Collecting %d data addendums", addendums)
@@ -612,15 +857,54 @@ func (dbsteward *DBSteward) doDbDataDiff(files []string, dataFiles []string, add
 
 	ops, err := lib.Format(lib.DefaultSqlFormat)
 	dbsteward.fatalIfError(err, "loading default format")
-	output, err := ops(dbsteward.config).CompareDbData(dbDoc, dbHost, dbPort, dbName, dbUser, dbPass)
+	output, err := ops(dbsteward.config).CompareDbData(dbDoc, connString)
 	dbsteward.fatalIfError(err, "comparing data")
 	err = xml.SaveDefinition(dbsteward.Logger(), compositeFile, output)
 	dbsteward.fatalIfError(err, "saving file")
 }
+func (dbsteward *DBSteward) doDrift(files []string, dataFiles []string, connString string, format string, outputFile string) {
+	dbsteward.Info("Compositing XML files...")
+	dbDoc, err := xml.XmlComposite(dbsteward.Logger(), files)
+	dbsteward.fatalIfError(err, "compositing")
+	if len(dataFiles) > 0 {
+		dbsteward.Info("Compositing pgdata XML files on top of XML composite...")
+		xml.XmlCompositePgData(dbDoc, dataFiles)
+		dbsteward.Info("postgres data XML files [%s] composited", strings.Join(dataFiles, " "))
+	}
+	dbsteward.Info("XML files %s composited", strings.Join(files, " "))
+
+	ops, err := lib.Format(lib.DefaultSqlFormat)
+	dbsteward.fatalIfError(err, "loading default format")
+	report, err := ops(dbsteward.config).Drift(dbDoc, connString)
+	dbsteward.fatalIfError(err, "detecting drift")
+
+	var output []byte
+	if format == "json" {
+		output, err = report.JSON()
+		dbsteward.fatalIfError(err, "rendering drift report")
+		output = append(output, '\n')
+	} else {
+		output = []byte(report.Text())
+	}
+	if len(outputFile) > 0 {
+		err = util.WriteFile(string(output), outputFile)
+		dbsteward.fatalIfError(err, "Failed to save drift report to %s", outputFile)
+	} else {
+		_, err = os.Stdout.Write(output)
+		dbsteward.fatalIfError(err, "writing drift report")
+	}
+
+	if report.HasDrift() {
+		dbsteward.warning("Database has drifted from the definition: %d differences", len(report.Items))
+		os.Exit(2)
+	}
+	dbsteward.Info("No drift detected")
+}
 func (dbsteward *DBSteward) doSqlDiff(oldSql, newSql []string, outputFile string) {
 	ops, err := lib.Format(lib.DefaultSqlFormat)
 	dbsteward.fatalIfError(err, "loading default format")
-	ops(dbsteward.config).SqlDiff(oldSql, newSql, outputFile)
+	err = ops(dbsteward.config).SqlDiff(oldSql, newSql, outputFile)
+	dbsteward.fatalIfError(err, "diffing sql")
 }
 func (dbsteward *DBSteward) doSlonikConvert(file string, outputFile string) {
 	// TODO(go,nth) is there a nicer way to handle this output idiom?
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dbsteward/dbsteward/lib/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionString(t *testing.T) {
+	pass := `it's a \secret`
+	assert.Equal(t, "postgres://app@db.example.com/app?sslmode=require", connectionString(&config.Args{
+		Db: "postgres://app@db.example.com/app?sslmode=require",
+	}))
+	assert.Equal(t, `host='db' port='5433' dbname='app' user='app' password='it\'s a \\secret'`, connectionString(&config.Args{
+		DbHost:     "db",
+		DbPort:     5433,
+		DbName:     "app",
+		DbUser:     "app",
+		DbPassword: &pass,
+	}))
+	// unspecified parameters are left for the driver to find in the environment
+	assert.Equal(t, `dbname='app'`, connectionString(&config.Args{DbName: "app"}))
+}
+
+func TestNeedsPasswordPrompt(t *testing.T) {
+	t.Setenv("PGPASSWORD", "")
+	t.Setenv("PGSERVICE", "")
+	t.Setenv("PGPASSFILE", "/nonexistent/pgpass")
+	pass := "secret"
+	assert.True(t, needsPasswordPrompt(&config.Args{DbName: "app"}))
+	assert.False(t, needsPasswordPrompt(&config.Args{DbName: "app", DbPassword: &pass}))
+	assert.False(t, needsPasswordPrompt(&config.Args{Db: "postgres://app@db/app"}))
+
+	t.Setenv("PGPASSWORD", "secret")
+	assert.False(t, needsPasswordPrompt(&config.Args{DbName: "app"}))
+}